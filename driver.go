@@ -24,6 +24,7 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"sync"
 
@@ -88,23 +89,76 @@ func (c *Conn) Close() error {
 	return nil
 }
 
+/*
+OpenValue streams the VARCHAR, TEXT or BLOB value of column in the row with the primary key key,
+large values are read from their overflow pages as the reader is read. The reader must be closed,
+until then the pages of the value are kept even if the row is written. Reached through sql.Conn.Raw:
+
+	conn.Raw(func(dc any) error {
+		r, err := dc.(*databasego.Conn).OpenValue("images", "data", 1)
+		...
+		defer r.Close()
+		...
+	})
+*/
+func (c *Conn) OpenValue(table, column string, key ...any) (io.ReadCloser, error) {
+	return c.db.OpenValue(table, column, key...)
+}
+
+/*
+CheckNamedValue accepts uint64 arguments, database/sql rejects those above math.MaxInt64 by default.
+Arguments of any other type are converted by database/sql as usual
+*/
+func (c *Conn) CheckNamedValue(nv *driver.NamedValue) error {
+	switch v := nv.Value.(type) {
+	case uint64:
+		return nil
+	case uint:
+		nv.Value = uint64(v)
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+// Exec runs a statement for database/sql's Exec, rows of a RETURNING clause are dropped.
+// The result counts the rows an INSERT, UPDATE or DELETE wrote
+func (c *Conn) Exec(query string, args []driver.Value) (driver.Result, error) {
+	rows, err := c.Query(query, args)
+	if err != nil {
+		return nil, err
+	}
+	rows.Close()
+	if result, ok := rows.(driver.Result); ok {
+		return result, nil
+	}
+	return driver.RowsAffected(0), nil
+}
+
+// rows of a statement returning none
+func noRows(err error) (driver.Rows, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &internal.Rows{}, nil
+}
+
+// Query runs a statement, args are bound in order to the ? placeholders of query
 func (c *Conn) Query(query string, args []driver.Value) (driver.Rows, error) {
-	if len(args) > 0 {
-		// TODO: support parameterization
-		panic("Parameterization not supported")
+	values := make([]any, len(args))
+	for i, arg := range args {
+		values[i] = arg
 	}
 
-	ast, err := internal.Parse(query) //check if query for tablename is too long must be less than 16bits
+	ast, err := internal.ParseArgs(query, values) //check if query for tablename is too long must be less than 16bits
 	if err != nil {
-		return nil, fmt.Errorf("error while parsing: %s", err)
+		return nil, fmt.Errorf("error while parsing: %w", err)
 	}
 
 	// NOTE: ignorning all but the first statement
 	stmt := ast.Type
 	switch stmt {
 	case internal.Create:
-		err := c.db.CreateTable(ast)
-		return nil, err
+		return noRows(c.db.CreateTable(ast))
 	case internal.Select:
 		rows, err := c.db.Select(ast)
 		if err != nil {
@@ -112,8 +166,21 @@ func (c *Conn) Query(query string, args []driver.Value) (driver.Rows, error) {
 		}
 		return rows, nil
 	case internal.Insert:
-		err := c.db.Insert(ast)
-		return nil, err
+		return c.db.Insert(ast)
+	case internal.Update:
+		return c.db.Update(ast)
+	case internal.Delete:
+		return c.db.Delete(ast)
+	case internal.CreateIndex:
+		return noRows(c.db.CreateIndex(ast))
+	case internal.DropIndex:
+		return noRows(c.db.DropIndex(ast))
+	case internal.AlterTable:
+		return noRows(c.db.AlterTable(ast))
+	case internal.Explain:
+		return c.db.Explain(ast)
+	case internal.Analyze:
+		return noRows(c.db.Analyze(ast))
 	default:
 		return nil, errors.ErrUnsupported
 	}
@@ -0,0 +1,130 @@
+package databasego_test
+
+import (
+	"database/sql"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	_ "github.com/treeform-system/rootdb"
+)
+
+// the driver keeps the database it opened first, every test of the package runs against the one in testDir
+var testDir string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "rootdb")
+	if err != nil {
+		panic(err)
+	}
+	testDir = filepath.Join(dir, "db")
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func openTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("rootdb", testDir)
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	return db
+}
+
+func TestQueryArguments(t *testing.T) {
+	db := openTestDB(t)
+	_, err := db.Exec("CREATE TABLE events (id int primary key, name varchar(20), at timestamp, n float);")
+	require.NoError(t, err)
+
+	at := time.Date(2024, 3, 1, 12, 30, 15, 250000000, time.FixedZone("", 3600))
+	_, err = db.Exec("INSERT INTO events (id, name, at, n) VALUES (?, ?, ?, ?), (?, ?, ?, ?);",
+		1, "it's ?", at, 2.0, 2, nil, at.Add(time.Hour), 0.5)
+	require.NoError(t, err)
+
+	var name string
+	var got time.Time
+	require.NoError(t, db.QueryRow("SELECT name, at FROM events WHERE at = ?;", at).Scan(&name, &got))
+	require.Equal(t, "it's ?", name, "quotes and placeholders in a bound string are part of the value")
+	require.True(t, at.Equal(got), "%v is not %v", got, at)
+
+	var id int64
+	require.NoError(t, db.QueryRow("SELECT id FROM events WHERE at > ? AND n < ?;", at, 1.0).Scan(&id))
+	require.Equal(t, int64(2), id)
+
+	_, err = db.Exec("INSERT INTO events (id, name) VALUES (?, ?);", 3)
+	require.EqualError(t, err, "error while parsing: no argument given for placeholder 2 (line 1, column 42)")
+	_, err = db.Exec("INSERT INTO events (id) VALUES (?);", 3, 4)
+	require.EqualError(t, err, "error while parsing: 2 arguments given for 1 placeholders (line 1, column 35)")
+}
+
+func TestUnsignedArguments(t *testing.T) {
+	db := openTestDB(t)
+	_, err := db.Exec("CREATE TABLE counters (id int primary key, big bigint unsigned);")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO counters (id, big) VALUES (?, ?), (?, ?);", 1, uint64(math.MaxUint64), 2, uint(7))
+	require.NoError(t, err)
+
+	var big uint64
+	require.NoError(t, db.QueryRow("SELECT big FROM counters WHERE big = ?;", uint64(math.MaxUint64)).Scan(&big))
+	require.Equal(t, uint64(math.MaxUint64), big)
+	require.NoError(t, db.QueryRow("SELECT big FROM counters WHERE id = ?;", 2).Scan(&big))
+	require.Equal(t, uint64(7), big)
+}
+
+func TestExecResult(t *testing.T) {
+	db := openTestDB(t)
+	_, err := db.Exec("CREATE TABLE items (id int primary key, name varchar(20) unique, n int);")
+	require.NoError(t, err)
+
+	res, err := db.Exec("INSERT INTO items (name, n) VALUES ('a', 1), ('b', 2), ('c', 3);")
+	require.NoError(t, err)
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	require.Equal(t, int64(3), affected)
+	id, err := res.LastInsertId()
+	require.NoError(t, err)
+	require.Equal(t, int64(3), id, "key handed out to the last inserted row")
+
+	res, err = db.Exec("INSERT INTO items (id, name, n) VALUES (2, 'b', 20), (9, 'd', 4) ON CONFLICT (id) DO UPDATE SET n = excluded.n;")
+	require.NoError(t, err)
+	affected, err = res.RowsAffected()
+	require.NoError(t, err)
+	require.Equal(t, int64(2), affected, "rows updated by DO UPDATE count as written")
+	_, err = res.LastInsertId()
+	require.Error(t, err, "every key was given")
+
+	res, err = db.Exec("UPDATE items SET n = n + 1 WHERE n > 2;")
+	require.NoError(t, err)
+	affected, err = res.RowsAffected()
+	require.NoError(t, err)
+	require.Equal(t, int64(3), affected)
+	res, err = db.Exec("DELETE FROM items WHERE id = 9;")
+	require.NoError(t, err)
+	affected, err = res.RowsAffected()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), affected)
+}
+
+func TestQueryWithoutRows(t *testing.T) {
+	db := openTestDB(t)
+	for _, sql := range []string{
+		"CREATE TABLE things (id int primary key, n int);",
+		"INSERT INTO things (n) VALUES (1);",
+		"UPDATE things SET n = 2 WHERE id = 1;",
+		"CREATE INDEX things_n ON things (n);",
+		"ANALYZE things;",
+		"ALTER TABLE things ADD COLUMN m int;",
+		"DROP INDEX things_n;",
+		"DELETE FROM things WHERE id = 1;",
+	} {
+		rows, err := db.Query(sql)
+		require.NoError(t, err, sql)
+		columns, err := rows.Columns()
+		require.NoError(t, err)
+		require.Empty(t, columns, sql)
+		require.False(t, rows.Next(), sql)
+		require.NoError(t, rows.Close())
+	}
+}
@@ -0,0 +1,215 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+	"strings"
+)
+
+/*
+Aggregate functions fold every row of a SELECT into a single row, a SELECT either selects aggregates only or none.
+COUNT(*) counts the rows and COUNT(column) the values that are not NULL. SUM and AVG skip NULL values and are NULL
+without any: integers add up exactly into a BIGINT, floats into a FLOAT and decimals exactly into a DECIMAL keeping
+the scale of the column. AVG of integers and floats is a FLOAT, AVG of a DECIMAL keeps avgScale more digits after
+the point than the column, as far as the precision of a DECIMAL allows, and is rounded half away from zero.
+*/
+
+const avgScale = 4 //digits after the point an average of decimals keeps beyond those of the column
+
+var aggregateFunctions = []string{"COUNT", "SUM", "AVG"}
+
+func isAggregate(expr Expr) bool {
+	f, ok := expr.(*FuncCall)
+	return ok && slices.Contains(aggregateFunctions, f.Name)
+}
+
+// aggregateCall is an aggregate function of a column of the table, or COUNT(*)
+type aggregateCall struct {
+	call   *FuncCall
+	pos    int    //aggregated column, -1 for COUNT(*)
+	result Column //column the result is stored as
+}
+
+// resolves an aggregate function over a column of table
+func aggregateOf(table *Table, f *FuncCall) (aggregateCall, error) {
+	agg := aggregateCall{call: f, pos: -1, result: Column{columnName: strings.ToLower(f.Name), columnIsNullable: true}}
+	if f.Star {
+		if f.Name != "COUNT" {
+			return agg, fmt.Errorf("%s(*) is not an aggregate function", f.Name)
+		}
+		agg.result.columnType, agg.result.columnSize = INT, 8
+		return agg, nil
+	}
+	if len(f.Args) != 1 {
+		return agg, fmt.Errorf("%s takes a single column", f.Name)
+	}
+	ident, ok := f.Args[0].(*Identifier)
+	if !ok {
+		return agg, fmt.Errorf("%s takes a column, not %s", f.Name, FormatExpr(f.Args[0]))
+	}
+	agg.pos = slices.IndexFunc(table.Columns, func(col Column) bool { return col.columnName == ident.Name })
+	if agg.pos == -1 {
+		return agg, fmt.Errorf("Columns not in table: %s", ident.Name)
+	}
+	col := &table.Columns[agg.pos]
+	if f.Name == "COUNT" {
+		agg.result.columnType, agg.result.columnSize = INT, 8
+		return agg, nil
+	}
+	switch col.columnType {
+	case INT, UINT:
+		agg.result.columnType, agg.result.columnSize = col.columnType, 8
+		if f.Name == "AVG" {
+			agg.result.columnType = FLOAT
+		}
+	case FLOAT:
+		agg.result.columnType, agg.result.columnSize = FLOAT, 8
+	case DECIMAL:
+		agg.result.columnType, agg.result.columnPrecision = DECIMAL, maxDecimalPrecision
+		agg.result.columnScale = col.columnScale
+		if f.Name == "AVG" { //digits before the point of an average are at most those of the column
+			agg.result.columnScale = min(col.columnScale+avgScale, maxDecimalPrecision-(col.columnPrecision-col.columnScale))
+		}
+		agg.result.columnSize = decimalSize(maxDecimalPrecision)
+	default:
+		return agg, fmt.Errorf("%s cannot add up column %s", f.Name, col.columnName)
+	}
+	return agg, nil
+}
+
+// aggregateState is the running count and sum of an aggregate, integers are summed exactly as decimals
+type aggregateState struct {
+	count int64
+	sum   decimal
+	fsum  float64
+}
+
+func (s *aggregateState) add(v any) {
+	s.count++
+	switch n := v.(type) {
+	case float64:
+		s.fsum += n
+	case decimal:
+		s.sum, _ = s.sum.arithmetic(Plus, n)
+	default:
+		i, _ := bigInteger(v)
+		s.sum, _ = s.sum.arithmetic(Plus, decimal{unscaled: i})
+	}
+}
+
+// value of the aggregate once every row was added
+func (a *aggregateCall) value(s *aggregateState) (any, error) {
+	switch {
+	case a.call.Name == "COUNT":
+		return s.count, nil
+	case s.count == 0:
+		return nil, nil
+	case a.call.Name == "SUM" && a.result.columnType == DECIMAL:
+		return s.sum, nil
+	case a.call.Name == "SUM" && a.result.columnType == FLOAT:
+		return s.fsum, nil
+	case a.call.Name == "SUM":
+		return integerValue(s.sum.unscaled)
+	case a.result.columnType == DECIMAL:
+		// the sum is scaled one digit beyond the result so the quotient is rounded once
+		scaled := s.sum.rescale(int32(a.result.columnScale) + 1)
+		scaled.unscaled.Quo(scaled.unscaled, big.NewInt(s.count))
+		return scaled.rescale(int32(a.result.columnScale)), nil
+	}
+	return (s.sum.float() + s.fsum) / float64(s.count), nil //AVG of integers or floats, one of the sums is 0
+}
+
+// aggregate folds the rows of its child into a single row holding the aggregates after the columns of the table
+type aggregate struct {
+	operatorStats
+	child planNode
+	table *Table
+	calls []aggregateCall
+	done  bool
+}
+
+func (a *aggregate) next() ([]Cell, error) {
+	if a.done {
+		return nil, io.EOF
+	}
+	a.done = true
+	states := make([]aggregateState, len(a.calls))
+	for i := range states {
+		states[i].sum = decimalFromInt(0)
+	}
+	for {
+		row, err := pull(a.child)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		for i, call := range a.calls {
+			switch {
+			case call.pos == -1:
+				states[i].count++
+			case row[call.pos] == nil:
+			case call.call.Name == "COUNT":
+				states[i].count++
+			default:
+				states[i].add(cellValue(&a.table.Columns[call.pos], row[call.pos]))
+			}
+		}
+	}
+	row := make([]Cell, len(a.table.Columns), len(a.table.Columns)+len(a.calls))
+	for i := range a.calls {
+		v, err := a.calls[i].value(&states[i])
+		if err != nil {
+			return nil, err
+		}
+		cell, err := valueCell(&a.calls[i].result, v)
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, cell)
+	}
+	return row, nil
+}
+
+func (a *aggregate) explain() (string, string) {
+	calls := make([]string, len(a.calls))
+	for i := range a.calls {
+		calls[i] = FormatExpr(a.calls[i].call)
+	}
+	return "Aggregate", strings.Join(calls, ", ")
+}
+
+func (a *aggregate) children() []planNode {
+	return []planNode{a.child}
+}
+
+// plans a SELECT of aggregates over the rows produced by scan
+func planAggregate(table *Table, scan planNode, items []SelectItem) (*project, error) {
+	agg := &aggregate{child: scan, table: table, calls: make([]aggregateCall, 0, len(items))} //results are pointed to by the columns
+	columns := make([]ResultColumn, 0, len(items))
+	for _, item := range items {
+		if item.Star || !isAggregate(item.Expr) {
+			selected := "*"
+			if !item.Star {
+				selected = FormatExpr(item.Expr)
+			}
+			return nil, fmt.Errorf("%s must be used in an aggregate function when aggregates are selected", selected)
+		}
+		call, err := aggregateOf(table, item.Expr.(*FuncCall))
+		if err != nil {
+			return nil, err
+		}
+		if item.Alias != "" {
+			call.result.columnName = item.Alias
+		}
+		agg.calls = append(agg.calls, call)
+		result := &agg.calls[len(agg.calls)-1].result
+		columns = append(columns, ResultColumn{Name: result.columnName, ColumnType: result.columnType, columnPos: len(table.Columns) + len(columns), scale: result.columnScale, computed: result})
+	}
+	agg.estRows, agg.cost = 1, scan.stats().cost+scan.stats().estRows*cpuRowCost
+	root := &project{child: agg, table: table, columns: columns}
+	root.estRows, root.cost = agg.estRows, agg.cost
+	return root, nil
+}
@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregates(t *testing.T) {
+	b := newTestBackend(t,
+		"CREATE TABLE t (id int primary key, d decimal(10,2), n int, f float, s char(5));",
+		"CREATE TABLE e (id int primary key, d decimal(10,2));",
+		"INSERT INTO t (id, d, n, f, s) VALUES (1, 0.10, 3, 0.5, 'a'), (2, 0.20, -1, 1.5, 'b'), (3, NULL, NULL, NULL, NULL), (4, 0.05, 9223372036854775807, 1.0, 'c');",
+	)
+	require.Equal(t, [][]any{{"0.35", "0.116667", int64(4), int64(3)}},
+		queryRows(t, b, "SELECT SUM(d), AVG(d), COUNT(*), COUNT(d) FROM t;"), "decimals add up and divide exactly")
+	require.Equal(t, [][]any{{"0.30", int64(2)}},
+		queryRows(t, b, "SELECT SUM(d), COUNT(s) FROM t WHERE id < 3;"))
+	require.Equal(t, [][]any{{int64(2), 2.0, 1.0}},
+		queryRows(t, b, "SELECT SUM(n), SUM(f), AVG(f) FROM t WHERE id < 4;"))
+	require.Equal(t, [][]any{{nil, nil, int64(0)}}, queryRows(t, b, "SELECT SUM(d), AVG(d), COUNT(*) FROM e;"), "aggregates of no rows")
+
+	_, err := execSQL(b, "INSERT INTO e (id, d) VALUES (1, 0.05), (2, 0.06), (3, 0.06), (4, -12345678.91);")
+	require.NoError(t, err)
+	require.Equal(t, [][]any{{"0.056667"}}, queryRows(t, b, "SELECT AVG(d) FROM e WHERE id < 4;"), "averages keep four more digits, rounded")
+	require.Equal(t, [][]any{{"-3086419.685000"}}, queryRows(t, b, "SELECT AVG(d) FROM e;"))
+
+	rows, err := execSQL(b, "SELECT SUM(d) AS total, count(*) FROM t;")
+	require.NoError(t, err)
+	require.Equal(t, []string{"total", "count"}, rows.Columns())
+
+	errs := []struct {
+		SQL      string
+		Expected string
+	}{
+		{"SELECT id, SUM(d) FROM t;", `"id" must be used in an aggregate function when aggregates are selected`},
+		{"SELECT SUM(*) FROM t;", "SUM(*) is not an aggregate function"},
+		{"SELECT SUM(s) FROM t;", "SUM cannot add up column s"},
+		{"SELECT SUM(n) FROM t;", "value 9223372036854775809 is out of range for column sum"},
+	}
+	for _, tc := range errs {
+		t.Run(tc.SQL, func(t *testing.T) {
+			_, err := execSQL(b, tc.SQL)
+			require.EqualError(t, err, tc.Expected)
+		})
+	}
+}
@@ -0,0 +1,438 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+/*
+ALTER TABLE changes the catalog entry of a table.
+Renames only touch the catalog and the names of the files of the table.
+Rows are stored in slotted pages as the number of their columns and a null bitset followed by the columns that aren't null,
+fixed width columns at their size and VARCHAR, TEXT and BLOB values behind a uint16 length, the largest of them on overflow pages.
+Like in Postgres a column added with no default or a fixed one only changes the catalog, rows written before it
+have fewer columns than the table and read the default or NULL for it.
+As the bitset and the values are encoded by the position of the columns, dropping a column or adding one that has to be
+computed or checked for every row rewrites the table: the converted rows and their indexes are copied a page at a time
+to files of the next version of the table, see Table.files.
+The new catalog replacing main.db moves the table to them, until then the files of the table stay as they were.
+*/
+
+// prefix of the name of the shadow table holding the pool of the new files while the table is still open
+const shadowPrefix = "temp-"
+
+// AlterTable adds, drops or renames a column of a table or renames the table
+func (b *Backend) AlterTable(q Query) error {
+	stmt, ok := q.Statement.(*AlterTableStatement)
+	if !ok {
+		return errors.New("not an ALTER TABLE statement")
+	}
+	table, ok := b.checkTableExist(q)
+	if !ok {
+		return errors.New("Table does not exist")
+	}
+
+	locked := []*Table{table}
+	for _, ref := range q.TableConstruction.foreignKeys {
+		if parent, ok := b.checkTableExist(Query{TableName: ref.Table}); ok {
+			locked = append(locked, parent)
+		}
+	}
+	defer b.lockTables(locked...)()
+
+	switch stmt.Action {
+	case AddColumn:
+		return b.addColumn(table, q)
+	case DropColumn:
+		return b.dropColumn(table, stmt.Name)
+	case RenameColumn:
+		return b.renameColumn(table, stmt.Name, stmt.NewName)
+	case RenameTable:
+		return b.renameTable(table, stmt.NewName)
+	}
+	return errors.ErrUnsupported
+}
+
+func (b *Backend) addColumn(table *Table, q Query) error {
+	tc := &q.TableConstruction
+	col, err := buildColumn(tc.fieldsWTypes[0], tc)
+	if err != nil {
+		return err
+	}
+	if slices.ContainsFunc(table.Columns, func(c Column) bool { return c.columnName == col.columnName }) {
+		return fmt.Errorf("column %s already exists in table %s", col.columnName, table.Name)
+	}
+
+	altered := *table
+	altered.Columns = append(slices.Clone(table.Columns), col)
+	altered.GenerateFields()
+	altered.rowEmptyBytes = 0
+	if err := altered.checkRowSize(); err != nil {
+		return err
+	}
+	pos := len(table.Columns)
+	altered.checks = slices.Clone(table.checks)
+	for _, check := range tc.checks {
+		altered.checks = addCheck(altered.checks, check.name, check.expr)
+	}
+	if err := altered.checkColumnValues(); err != nil {
+		return err
+	}
+	altered.indexes = slices.Clone(table.indexes)
+	if col.columnIsUnique {
+		name := b.uniqueIndexName(&altered, col.columnName)
+		altered.indexes = append(altered.indexes, indexDef{name: name, unique: true, constraint: true, columns: []int{pos}})
+	}
+	altered.foreignKeys = slices.Clone(table.foreignKeys)
+	for _, ref := range tc.foreignKeys {
+		fk, err := b.foreignKey(&altered, ref)
+		if err != nil {
+			return err
+		}
+		altered.foreignKeys = append(altered.foreignKeys, fk)
+	}
+
+	missing := missingCells([]Column{col})[0]
+	catalogOnly := col.columnGenerated == nil && !col.columnIsPrimary && col.fixedDefault() && (missing != nil || col.columnIsNullable)
+	if catalogOnly && len(tc.checks) == 0 && len(altered.indexes) == len(table.indexes) && len(altered.foreignKeys) == len(table.foreignKeys) {
+		return b.extendTable(table, &altered, missing)
+	}
+
+	existing := make([]int, pos)
+	for i := range existing {
+		existing[i] = i
+	}
+	return b.rewriteTable(table, &altered, func(row []Cell) ([]Cell, error) {
+		newRow := append(row, nil)
+		return newRow, altered.applyDefaults(newRow, existing)
+	})
+}
+
+// adds the last column of altered to the catalog entry of table, the rows are left as they are and read missing for it
+func (b *Backend) extendTable(table, altered *Table, missing Cell) error {
+	table.Columns = altered.Columns
+	table.rowEmptyBytes = 0
+	if table.stats != nil { //every row holds the same value
+		stats := columnStats{min: missing, max: missing, distinct: 1}
+		if missing == nil {
+			stats = columnStats{nulls: table.stats.rows}
+		}
+		table.stats.columns = append(table.stats.columns, stats)
+	}
+	b.writeTablesToDisk()
+	b.bufferPool.allpools[table.Name].setColumns(table.Columns)
+	return b.writeStatsToDisk()
+}
+
+func (b *Backend) dropColumn(table *Table, name string) error {
+	pos := slices.IndexFunc(table.Columns, func(col Column) bool { return col.columnName == name })
+	if pos == -1 {
+		return fmt.Errorf("Columns not in table: %s", name)
+	}
+	if slices.Contains(table.primaryKey, pos) {
+		return fmt.Errorf("cannot drop column %s, it is part of the primary key of table %s", name, table.Name)
+	}
+	for _, fk := range table.foreignKeys {
+		if slices.Contains(fk.columns, pos) {
+			return fmt.Errorf("cannot drop column %s, foreign key %s uses it", name, fk.name)
+		}
+	}
+	uses := func(expr Expr) bool {
+		found := false
+		walkExpr(expr, func(e Expr) bool {
+			if ident, ok := e.(*Identifier); ok && ident.Name == name {
+				found = true
+			}
+			return !found
+		})
+		return found
+	}
+	for i := range table.Columns {
+		if i != pos && uses(table.Columns[i].columnGenerated) {
+			return fmt.Errorf("cannot drop column %s, generated column %s uses it", name, table.Columns[i].columnName)
+		}
+	}
+	for _, check := range table.checks {
+		if uses(check.expr) {
+			return fmt.Errorf("cannot drop column %s, check constraint %s uses it", name, check.name)
+		}
+	}
+
+	//positions after the dropped column move down by one
+	shift := func(positions []int) []int {
+		shifted := make([]int, len(positions))
+		for i, p := range positions {
+			shifted[i] = p
+			if p > pos {
+				shifted[i]--
+			}
+		}
+		return shifted
+	}
+	altered := *table
+	altered.Columns = slices.Delete(slices.Clone(table.Columns), pos, pos+1)
+	altered.GenerateFields()
+	altered.rowEmptyBytes = 0
+	altered.primaryKey = shift(table.primaryKey)
+	altered.indexes = nil
+	for _, def := range table.indexes {
+		if slices.Contains(def.columns, pos) || slices.ContainsFunc(def.exprs, uses) {
+			continue //indexes of the column are dropped with it
+		}
+		def.columns = shift(def.columns)
+		altered.indexes = append(altered.indexes, def)
+	}
+	altered.foreignKeys = slices.Clone(table.foreignKeys)
+	for i := range altered.foreignKeys {
+		altered.foreignKeys[i].columns = shift(altered.foreignKeys[i].columns)
+	}
+	return b.rewriteTable(table, &altered, func(row []Cell) ([]Cell, error) {
+		return slices.Delete(row, pos, pos+1), nil
+	})
+}
+
+// renames a column in the catalog and in the expressions of the table using it, rows are left as they are
+func (b *Backend) renameColumn(table *Table, name, newName string) error {
+	pos := slices.IndexFunc(table.Columns, func(col Column) bool { return col.columnName == name })
+	if pos == -1 {
+		return fmt.Errorf("Columns not in table: %s", name)
+	}
+	if slices.ContainsFunc(table.Columns, func(col Column) bool { return col.columnName == newName }) {
+		return fmt.Errorf("column %s already exists in table %s", newName, table.Name)
+	}
+	if len(newName) >= 255 {
+		return errors.New("column name too large in size")
+	}
+
+	oldPrimary := table.primaryIndexName()
+	table.Columns[pos].columnName = newName
+	rename := func(e Expr) bool {
+		if ident, ok := e.(*Identifier); ok && ident.Name == name {
+			ident.Name = newName
+		}
+		return true
+	}
+	for i := range table.Columns {
+		walkExpr(table.Columns[i].columnGenerated, rename)
+	}
+	for i := range table.checks {
+		walkExpr(table.checks[i].expr, rename)
+	}
+	for i := range table.indexes {
+		for _, expr := range table.indexes[i].exprs {
+			walkExpr(expr, rename)
+		}
+	}
+	if primary := table.primaryIndexName(); primary != oldPrimary { //the primary index file is named after the key columns
+		table.indices.primaryTree.closeIndex()
+		if err := os.Rename(primaryIndexFile(b.dir, table.files(), oldPrimary), primaryIndexFile(b.dir, table.files(), primary)); err != nil {
+			return err
+		}
+		if err := table.indices.addIndex(b.dir, table.files(), primary); err != nil {
+			return err
+		}
+	}
+	b.writeTablesToDisk()
+	return nil
+}
+
+// renames the table, its files and the references of foreign keys to it
+func (b *Backend) renameTable(table *Table, newName string) error {
+	if _, exists := b.checkTableExist(Query{TableName: newName}); exists {
+		return errors.New("Table already exist")
+	}
+	if err := checkTableName(newName); err != nil {
+		return err
+	}
+
+	oldName, oldFiles, newFiles := table.Name, table.files(), tableFiles(newName, table.version)
+	b.bufferPool.closePool(oldName)
+	table.indices.close()
+	renames := [][2]string{
+		{tableFile(b.dir, oldFiles), tableFile(b.dir, newFiles)},
+		{overflowFile(b.dir, oldFiles), overflowFile(b.dir, newFiles)},
+		{primaryIndexFile(b.dir, oldFiles, table.primaryIndexName()), primaryIndexFile(b.dir, newFiles, table.primaryIndexName())},
+	}
+	// generated constraint names start with the table name, they follow the table so a new table of the old name can generate them again
+	renamed := func(name string, taken func(string) bool) string {
+		if !strings.HasPrefix(name, oldName+"_") {
+			return name
+		}
+		return uniqueName(newName+strings.TrimPrefix(name, oldName), taken)
+	}
+	for i, def := range table.indexes {
+		name := def.name
+		if def.constraint {
+			name = renamed(name, func(name string) bool {
+				_, _, exists := b.findIndex(name)
+				return exists
+			})
+		}
+		renames = append(renames, [2]string{indexFile(b.dir, oldFiles, def.name), indexFile(b.dir, newFiles, name)})
+		table.indexes[i].name = name
+	}
+	for _, r := range renames {
+		if err := os.Rename(r[0], r[1]); err != nil {
+			return err
+		}
+	}
+	for i := range table.checks {
+		table.checks[i].name = renamed(table.checks[i].name, func(name string) bool {
+			return slices.ContainsFunc(table.checks, func(c checkDef) bool { return c.name == name })
+		})
+	}
+	for i := range table.foreignKeys {
+		table.foreignKeys[i].name = renamed(table.foreignKeys[i].name, func(name string) bool {
+			return slices.ContainsFunc(table.foreignKeys, func(f foreignKey) bool { return f.name == name })
+		})
+	}
+
+	table.Name = newName
+	for i := range b.tables {
+		for j := range b.tables[i].foreignKeys {
+			if b.tables[i].foreignKeys[j].parent == oldName {
+				b.tables[i].foreignKeys[j].parent = newName
+			}
+		}
+	}
+	if err := b.openTableFiles(table, newFiles); err != nil {
+		return err
+	}
+	b.writeTablesToDisk()
+	return b.writeStatsToDisk()
+}
+
+// opens the data file and the index files with the base name files for table
+func (b *Backend) openTableFiles(table *Table, files string) error {
+	b.bufferPool.newPool(table.Name, files, b.dir, table.Columns)
+	table.indices = newIndexManager()
+	if err := table.indices.addIndex(b.dir, files, table.primaryIndexName()); err != nil {
+		return err
+	}
+	for _, def := range table.indexes {
+		if _, err := table.indices.addSecondary(b.dir, files, def.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+rewriteTable replaces table by altered, storing convert(row) for every row of the table.
+The rows are copied a page at a time, they must satisfy every constraint of altered, otherwise the table is left untouched
+*/
+func (b *Backend) rewriteTable(table, altered *Table, convert func(row []Cell) ([]Cell, error)) error {
+	build := func(row []Cell) ([]Cell, error) {
+		row, err := convert(row)
+		if err != nil {
+			return nil, err
+		}
+		if err := altered.computeGenerated(row); err != nil {
+			return nil, err
+		}
+		if err := altered.checkNotNull(row); err != nil {
+			return nil, err
+		}
+		return row, altered.checkRow(row)
+	}
+
+	shadow := *altered
+	shadow.Name = shadowPrefix + table.Name
+	shadow.version = table.version + 1
+	if _, exists := b.checkTableExist(Query{TableName: shadow.Name}); exists {
+		return fmt.Errorf("cannot rewrite table %s while a table named %s exists", table.Name, shadow.Name)
+	}
+	files := tableFiles(table.Name, shadow.version)
+	written := []string{tableFile(b.dir, files), overflowFile(b.dir, files), primaryIndexFile(b.dir, files, shadow.primaryIndexName())}
+	for _, def := range shadow.indexes {
+		written = append(written, indexFile(b.dir, files, def.name))
+	}
+	for _, f := range written {
+		os.Remove(f) //left behind if a previous rewrite was interrupted
+	}
+	if err := createTableFile(written[0]); err != nil {
+		return err
+	}
+	if err := b.openTableFiles(&shadow, files); err != nil {
+		return err
+	}
+	if err := b.fillShadow(table, &shadow, build); err != nil {
+		var cerr *ConstraintError
+		if errors.As(err, &cerr) {
+			cerr.Table = table.Name
+		}
+		b.bufferPool.closePool(shadow.Name)
+		shadow.indices.close()
+		for _, f := range written {
+			os.Remove(f)
+		}
+		return err
+	}
+	b.bufferPool.closePool(shadow.Name)
+	shadow.indices.close()
+
+	oldFiles := table.files()
+	replaced := []string{tableFile(b.dir, oldFiles), overflowFile(b.dir, oldFiles), primaryIndexFile(b.dir, oldFiles, table.primaryIndexName())}
+	for _, def := range table.indexes {
+		replaced = append(replaced, indexFile(b.dir, oldFiles, def.name))
+	}
+	b.bufferPool.closePool(table.Name)
+	table.indices.close()
+
+	lastRowId, tableLock := table.lastRowId, table.tableLock
+	*table = *altered
+	table.version = shadow.version
+	table.lastPage = shadow.lastPage
+	table.lastRowId, table.tableLock = lastRowId, tableLock
+	table.stats = nil //stats are kept by column position
+	// the table moves to the new files once the catalog naming them replaces main.db
+	b.writeTablesToDisk()
+	for _, f := range replaced {
+		os.Remove(f)
+	}
+	if err := b.openTableFiles(table, files); err != nil {
+		return err
+	}
+	return b.writeStatsToDisk()
+}
+
+// writes the rows of table built by build to the empty shadow table and its indexes, one page of the table at a time
+func (b *Backend) fillShadow(table, shadow *Table, build func(row []Cell) ([]Cell, error)) error {
+	for page := PageID(0); uint64(page) <= table.lastPage; page++ {
+		rows := b.bufferPool.fetchPage(table.Name, page, nil)
+		if len(rows) == 0 {
+			continue
+		}
+		for i := range rows {
+			var err error
+			if rows[i], err = build(rows[i]); err != nil {
+				return err
+			}
+		}
+		//keys of the pages copied before are in the indexes of the shadow table
+		if err := shadow.checkUnique(nil, rows); err != nil {
+			return err
+		}
+		var s changeSet
+		for _, row := range rows {
+			s.of(shadow).add(nil, row)
+		}
+		if err := b.checkReferences(s, s.of(shadow)); err != nil {
+			return err
+		}
+		lastPage, locations, err := b.bufferPool.InsertData(shadow.Name, rows)
+		if err != nil {
+			return err
+		}
+		shadow.lastPage = uint64(lastPage)
+		for i := range rows {
+			if err := shadow.insertIndexEntries(rows[i], locations[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlterTable(t *testing.T) {
+	dir := t.TempDir()
+	b := CreateNewDatabase(dir)
+	values := make([]string, 0, 600)
+	for i := 1; i <= 600; i++ {
+		values = append(values, fmt.Sprintf("(%d, 'n%d', %d)", i, i, i%7))
+	}
+	for _, sql := range []string{
+		"CREATE TABLE t (id int primary key, name char(10) unique, grp int, total int generated always as (grp * 2) stored);",
+		"CREATE INDEX t_grp ON t (grp);",
+		"INSERT INTO t (id, name, grp) VALUES " + strings.Join(values, ", ") + ";",
+		"ALTER TABLE t ADD COLUMN score float default 1.5 check (score >= 0);",
+		"ALTER TABLE t DROP COLUMN name;",
+		"ALTER TABLE t RENAME COLUMN grp TO g;",
+		"ALTER TABLE t RENAME id TO ident;",
+		"ALTER TABLE t RENAME TO u;",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	expected := [][]any{{int64(599), int64(4), int64(8), 1.5}, {int64(600), int64(5), int64(10), 1.5}}
+	require.Equal(t, expected, queryRows(t, b, "SELECT ident, g, total, score FROM u WHERE ident > 598;"))
+	require.Len(t, queryRows(t, b, "SELECT ident FROM u WHERE g = 3;"), 86, "secondary index survives the rewrite")
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	require.Equal(t, []string{"main.db", "main.stats", "u.2.db", "u.2.ident.pkey", "u.2.ovf", "u.2.t_grp.idx"}, names,
+		"each rewrite moves the table to files of the next version and removes the earlier ones, with the index of the dropped column")
+
+	errs := []struct {
+		SQL      string
+		Expected string
+	}{
+		{"ALTER TABLE u ADD COLUMN g int;", "column g already exists in table u"},
+		{"ALTER TABLE u ADD COLUMN n int not null;", "null value in column n of table u violates not null constraint"},
+		{"ALTER TABLE u ADD COLUMN s float default -1.0 check (s >= 0);", `row of table u violates check constraint "u_s_check"`},
+		{"ALTER TABLE u DROP COLUMN ident;", "cannot drop column ident, it is part of the primary key of table u"},
+		{"ALTER TABLE u DROP COLUMN g;", "cannot drop column g, generated column total uses it"},
+		{"ALTER TABLE u RENAME COLUMN g TO score;", "column score already exists in table u"},
+		{"ALTER TABLE u RENAME TO u;", "Table already exist"},
+		{"ALTER TABLE missing DROP COLUMN g;", "Table does not exist"},
+	}
+	for _, tc := range errs {
+		t.Run(tc.SQL, func(t *testing.T) {
+			_, err := execSQL(b, tc.SQL)
+			require.EqualError(t, err, tc.Expected)
+		})
+	}
+	_, err = execSQL(b, "UPDATE u SET g = 1 WHERE ident = 600;")
+	require.NoError(t, err)
+	_, err = execSQL(b, "INSERT INTO u (g) VALUES (2);")
+	require.NoError(t, err)
+	b.Close()
+
+	b, err = OpenExistingDatabase(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	expected = [][]any{{int64(600), int64(1), int64(2), 1.5}, {int64(601), int64(2), int64(4), 1.5}}
+	require.Equal(t, expected, queryRows(t, b, "SELECT ident, g, total, score FROM u WHERE ident > 599;"))
+	_, err = execSQL(b, "UPDATE u SET score = -1.0 WHERE ident = 1;")
+	require.EqualError(t, err, `row of table u violates check constraint "u_score_check"`)
+}
+
+func TestAlterTableForeignKeys(t *testing.T) {
+	b := newTestBackend(t,
+		"CREATE TABLE p (id int primary key);",
+		"CREATE TABLE c (id int primary key, n int);",
+		"INSERT INTO p (id) VALUES (1), (2);",
+		"INSERT INTO c (id, n) VALUES (1, 1), (2, 3);",
+	)
+	_, err := execSQL(b, "ALTER TABLE c ADD COLUMN pid int default 3 references p on delete cascade;")
+	require.EqualError(t, err, `column pid of table c violates foreign key "c_pid_fkey" referencing table p`)
+	for _, sql := range []string{
+		"ALTER TABLE c ADD COLUMN pid int default 2 references p on delete cascade;",
+		"ALTER TABLE p RENAME TO parent;",
+		"DELETE FROM parent WHERE id = 2;",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	require.Empty(t, queryRows(t, b, "SELECT id FROM c WHERE id > 0;"))
+	_, err = execSQL(b, "INSERT INTO c (id, pid) VALUES (3, 1);")
+	require.NoError(t, err)
+	_, err = execSQL(b, "ALTER TABLE c DROP COLUMN pid;")
+	require.EqualError(t, err, "cannot drop column pid, foreign key c_pid_fkey uses it")
+}
+
+func TestRenameTableConstraintNames(t *testing.T) {
+	dir := t.TempDir()
+	b := CreateNewDatabase(dir)
+	for _, sql := range []string{
+		"CREATE TABLE p (id int primary key);",
+		"CREATE TABLE t (id int primary key, e int unique check (e > 0), pid int references p);",
+		"CREATE INDEX t_own ON t (pid);",
+		"INSERT INTO p (id) VALUES (1);",
+		"INSERT INTO t (id, e, pid) VALUES (1, 1, 1);",
+		"ALTER TABLE t RENAME TO u;",
+		"CREATE TABLE t (id int primary key, e int unique check (e > 0), pid int references p);",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	u := b.tables[1]
+	require.Equal(t, []string{"u_e_key", "t_own"}, []string{u.indexes[0].name, u.indexes[1].name}, "names chosen by the user are kept")
+	require.Equal(t, "u_e_check", u.checks[0].name)
+	require.Equal(t, "u_pid_fkey", u.foreignKeys[0].name)
+	require.Equal(t, "t_e_key", b.tables[2].indexes[0].name)
+	require.FileExists(t, indexFile(dir, "u", "u_e_key"))
+	require.FileExists(t, indexFile(dir, "u", "t_own"))
+	b.Close()
+
+	b, err := OpenExistingDatabase(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	_, err = execSQL(b, "INSERT INTO u (id, e, pid) VALUES (2, 1, 1);")
+	require.EqualError(t, err, `insert: duplicate key value in column e of table u violates unique index "u_e_key"`)
+	require.Equal(t, [][]any{{int64(1)}}, queryRows(t, b, "SELECT id FROM u WHERE e = 1;"))
+}
+
+func TestRewriteTableFiles(t *testing.T) {
+	dir := t.TempDir()
+	b := CreateNewDatabase(dir)
+	for _, sql := range []string{
+		"CREATE TABLE t (id int primary key, n int);",
+		"INSERT INTO t (id, n) VALUES (1, 1), (2, 2);",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	_, err := execSQL(b, "ALTER TABLE t ADD COLUMN m int default 1 unique;")
+	require.EqualError(t, err, `duplicate key value in column m of table t violates unique index "t_m_key"`)
+	require.NoFileExists(t, tableFile(dir, tableFiles("t", 1)), "files of a failed rewrite are removed")
+	b.Close()
+
+	// a rewrite interrupted before the catalog named its files leaves them behind, the table keeps its files
+	for _, f := range []string{tableFile(dir, tableFiles("t", 1)), primaryIndexFile(dir, tableFiles("t", 1), "id"), indexFile(dir, tableFiles("t", 1), "t_m_key")} {
+		require.NoError(t, os.WriteFile(f, []byte("partial"), 0644))
+	}
+	b, err = OpenExistingDatabase(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	require.Equal(t, [][]any{{int64(1), int64(1)}, {int64(2), int64(2)}}, queryRows(t, b, "SELECT id, n FROM t WHERE id > 0;"))
+	_, err = execSQL(b, "ALTER TABLE t ADD COLUMN m int unique;")
+	require.NoError(t, err)
+	require.Equal(t, [][]any{{int64(1), int64(1), nil}, {int64(2), int64(2), nil}}, queryRows(t, b, "SELECT id, n, m FROM t WHERE id > 0;"))
+	require.Equal(t, uint32(1), b.tables[0].version)
+	require.NoFileExists(t, tableFile(dir, "t"))
+	require.NoFileExists(t, primaryIndexFile(dir, "t", "id"))
+}
+
+func TestAddColumnWithoutRewrite(t *testing.T) {
+	dir := t.TempDir()
+	b := CreateNewDatabase(dir)
+	values := make([]string, 0, 300)
+	for i := 1; i <= 300; i++ {
+		values = append(values, fmt.Sprintf("(%d, %d, 2, 3, 4, 5, 6, 7)", i, i%5))
+	}
+	for _, sql := range []string{
+		"CREATE TABLE t (id int primary key, a int, b int, c int, d int, e int, f int, g int);",
+		"INSERT INTO t (id, a, b, c, d, e, f, g) VALUES " + strings.Join(values, ", ") + ";",
+		"ANALYZE t;",
+		"ALTER TABLE t ADD COLUMN note text;",
+		"ALTER TABLE t ADD COLUMN n int not null default 7;",
+		"ALTER TABLE t ADD COLUMN amt decimal(6, 2) default 1.25;",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	info, err := os.Stat(tableFile(dir, "t"))
+	require.NoError(t, err)
+	require.Equal(t, uint32(0), b.tables[0].version, "the rows are left in the files of the table")
+	require.Equal(t, [][]any{{int64(1), int64(1), nil, int64(7), "1.25"}}, queryRows(t, b, "SELECT id, a, note, n, amt FROM t WHERE id = 1;"),
+		"rows written before a column was added read its default or NULL")
+	require.Len(t, queryRows(t, b, "SELECT id FROM t WHERE n = 7 AND note IS NULL;"), 300)
+	stats := b.tables[0].stats.columns
+	require.Len(t, stats, 11, "the stats of the added columns follow from their value")
+	require.Equal(t, columnStats{nulls: 300}, stats[8])
+	require.Equal(t, int64(1), stats[9].distinct)
+
+	for _, sql := range []string{
+		"INSERT INTO t (id, note, n, amt) VALUES (301, NULL, 1, NULL);",
+		"UPDATE t SET note = 'set' WHERE id = 2;",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	b.Close()
+
+	b, err = OpenExistingDatabase(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	expected := [][]any{
+		{int64(1), int64(1), nil, int64(7), "1.25"},
+		{int64(2), int64(2), "set", int64(7), "1.25"},
+		{int64(301), nil, nil, int64(1), nil},
+	}
+	selected := func() [][]any {
+		return append(queryRows(t, b, "SELECT id, a, note, n, amt FROM t WHERE id <= 2;"), queryRows(t, b, "SELECT id, a, note, n, amt FROM t WHERE id = 301;")...)
+	}
+	require.Equal(t, expected, selected(), "written rows hold every column")
+	after, err := os.Stat(tableFile(dir, "t"))
+	require.NoError(t, err)
+	require.Equal(t, info.Size(), after.Size())
+
+	_, err = execSQL(b, "ALTER TABLE t ADD COLUMN at timestamp default now();")
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), b.tables[0].version, "a default of a function is written to every row")
+	require.Equal(t, expected, selected(), "the rewrite keeps the values read for the added columns")
+	require.Len(t, queryRows(t, b, "SELECT id FROM t WHERE at IS NOT NULL;"), 301)
+}
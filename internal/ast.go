@@ -0,0 +1,318 @@
+package internal
+
+/*
+Abstract syntax tree produced by the parser.
+Statements are the roots, expressions and table references hang off of them.
+Nodes carry no position information so two trees parsed from differently formatted
+sql strings compare equal if they mean the same thing.
+*/
+
+// Statement is the root node of a parsed sql string
+type Statement interface {
+	statementNode()
+}
+
+// Expr is any node that evaluates to a value
+type Expr interface {
+	exprNode()
+}
+
+// TableRef is anything that can appear after FROM
+type TableRef interface {
+	tableRefNode()
+}
+
+// SelectStatement -> SELECT items FROM from [WHERE where]
+type SelectStatement struct {
+	Items []SelectItem
+	From  TableRef
+	Where Expr
+}
+
+// SelectItem is a single entry of the select list, either * or an expression with an optional alias
+type SelectItem struct {
+	Star  bool
+	Expr  Expr
+	Alias string
+}
+
+// InsertStatement -> INSERT INTO table (columns) VALUES (row), (row)... | SELECT ... [ON CONFLICT ...] [RETURNING item, ...]
+type InsertStatement struct {
+	Table      *TableName
+	Columns    []string
+	Rows       [][]Expr
+	Query      *SelectStatement // rows of INSERT ... SELECT, Rows is empty when set
+	OnConflict *OnConflict
+	Returning  []SelectItem
+}
+
+// OnConflict -> ON CONFLICT [(column, ...)] DO NOTHING | DO UPDATE SET column = value, ... [WHERE where]
+type OnConflict struct {
+	Columns  []string // primary key or unique index conflicts are detected on, empty for all of them
+	DoUpdate bool
+	Set      []Assignment // excluded.column is the value proposed for insertion
+	Where    Expr
+}
+
+// UpdateStatement -> UPDATE table SET column = value, ... [WHERE where] [RETURNING item, ...]
+type UpdateStatement struct {
+	Table     *TableName
+	Set       []Assignment
+	Where     Expr
+	Returning []SelectItem
+}
+
+// Assignment is a single column = value pair of an UPDATE
+type Assignment struct {
+	Column string
+	Value  Expr
+}
+
+// DeleteStatement -> DELETE FROM table [WHERE where] [RETURNING item, ...]
+type DeleteStatement struct {
+	Table     *TableName
+	Where     Expr
+	Returning []SelectItem
+}
+
+// CreateTableStatement -> CREATE TABLE [IF NOT EXISTS] name (column definitions [, PRIMARY KEY (column, ...)]) | AS SELECT ...
+type CreateTableStatement struct {
+	Name        string
+	IfNotExists bool
+	Query       *SelectStatement // rows and column types of CREATE TABLE ... AS SELECT, the fields below are empty when set
+	Columns     []ColumnDef
+	PrimaryKey  []string     // columns of a table level primary key in key order, empty if not given
+	Checks      []Expr       // conditions of table level CHECK constraints
+	ForeignKeys []ForeignKey // table level FOREIGN KEY constraints
+}
+
+// ColumnDef is a single column definition inside CREATE TABLE
+type ColumnDef struct {
+	Name        string
+	Type        DataType
+	Constraints []ColumnConstraint
+}
+
+// DataType is the upper cased type keyword followed by its optional size arguments ie. CHAR(10)
+type DataType struct {
+	Name string
+	Args []string
+}
+
+type ConstraintKind int
+
+const (
+	// UnknownConstraint is the zero value for a ConstraintKind
+	UnknownConstraint ConstraintKind = iota
+	PrimaryKeyConstraint
+	UniqueConstraint
+	NotNullConstraint
+	NullConstraint
+	DefaultConstraint   // DEFAULT expr
+	GeneratedConstraint // GENERATED ALWAYS AS (expr) STORED
+	CheckConstraint     // CHECK (expr)
+	ForeignKeyConstraint
+)
+
+// ColumnConstraint is a constraint written after the data type of a column
+type ColumnConstraint struct {
+	Kind       ConstraintKind
+	Expr       Expr        // value of a DEFAULT or GENERATED constraint, condition of a CHECK
+	ForeignKey *ForeignKey // target of a REFERENCES constraint
+}
+
+// ForeignKey -> [FOREIGN KEY (columns)] REFERENCES table [(columns)] [ON DELETE action] [ON UPDATE action]
+type ForeignKey struct {
+	Columns    []string // empty for a column constraint, which references with its own column
+	Table      string
+	RefColumns []string // empty when referencing the primary key without naming its columns
+	OnDelete   ReferentialAction
+	OnUpdate   ReferentialAction
+}
+
+// ReferentialAction is what happens to referencing rows when the row they reference is deleted or its key updated
+type ReferentialAction int
+
+const (
+	NoAction ReferentialAction = iota // NO ACTION, the write fails like RESTRICT
+	Restrict
+	Cascade
+	SetNull
+)
+
+func (a ReferentialAction) String() string {
+	switch a {
+	case Restrict:
+		return "RESTRICT"
+	case Cascade:
+		return "CASCADE"
+	case SetNull:
+		return "SET NULL"
+	}
+	return "NO ACTION"
+}
+
+// DropTableStatement -> DROP TABLE name
+type DropTableStatement struct {
+	Name string
+}
+
+// ExplainStatement -> EXPLAIN [ANALYZE] statement
+type ExplainStatement struct {
+	Analyze   bool
+	Statement Statement
+}
+
+// AnalyzeStatement -> ANALYZE [table], an empty Table analyzes every table
+type AnalyzeStatement struct {
+	Table string
+}
+
+// CreateIndexStatement -> CREATE [UNIQUE] INDEX name ON table (column | expression, ...)
+type CreateIndexStatement struct {
+	Name    string
+	Table   string
+	Columns []string
+	Exprs   []Expr //expression of each key part that is not a column, whose column is empty. nil without expressions
+	Unique  bool
+}
+
+// DropIndexStatement -> DROP INDEX name
+type DropIndexStatement struct {
+	Name string
+}
+
+// AlterTableStatement -> ALTER TABLE table ADD [COLUMN] column | DROP [COLUMN] name | RENAME [COLUMN] name TO new | RENAME TO new
+type AlterTableStatement struct {
+	Table   string
+	Action  AlterAction
+	Column  ColumnDef // added column
+	Name    string    // dropped or renamed column
+	NewName string    // new name of the column or of the table
+}
+
+// AlterAction is the change an ALTER TABLE makes
+type AlterAction int
+
+const (
+	// UnknownAlter is the zero value for an AlterAction
+	UnknownAlter AlterAction = iota
+	AddColumn
+	DropColumn
+	RenameColumn
+	RenameTable
+)
+
+func (*SelectStatement) statementNode()      {}
+func (*InsertStatement) statementNode()      {}
+func (*UpdateStatement) statementNode()      {}
+func (*DeleteStatement) statementNode()      {}
+func (*CreateTableStatement) statementNode() {}
+func (*DropTableStatement) statementNode()   {}
+func (*ExplainStatement) statementNode()     {}
+func (*AnalyzeStatement) statementNode()     {}
+func (*CreateIndexStatement) statementNode() {}
+func (*DropIndexStatement) statementNode()   {}
+func (*AlterTableStatement) statementNode()  {}
+
+// TableName references a stored table by name
+type TableName struct {
+	Name  string
+	Alias string
+}
+
+func (*TableName) tableRefNode() {}
+
+// Identifier references a column, optionally qualified with a table name or alias
+type Identifier struct {
+	Table string
+	Name  string
+}
+
+type LiteralKind int
+
+const (
+	// UnknownLiteral is the zero value for a LiteralKind
+	UnknownLiteral LiteralKind = iota
+	StringLiteral
+	NumberLiteral
+	BoolLiteral // Value is always upper case "TRUE" or "FALSE"
+	NullLiteral
+	DateLiteral // DATE '2024-01-31', Value holds the quoted text
+	TimeLiteral
+	TimestampLiteral
+	IntervalLiteral
+	HexLiteral // X'0aff', Value holds the hex digits of the bytes
+)
+
+// Literal is a constant written in the sql string, Value holds the text without quotes
+type Literal struct {
+	Kind  LiteralKind
+	Value string
+}
+
+// UnaryExpr is a prefix operator applied to an expression (NOT, -)
+type UnaryExpr struct {
+	Operator Operator
+	Expr     Expr
+}
+
+// BinaryExpr is an infix operator between two expressions
+type BinaryExpr struct {
+	Operator Operator
+	Left     Expr
+	Right    Expr
+}
+
+// InExpr -> expr [NOT] IN (list)
+type InExpr struct {
+	Expr Expr
+	List []Expr
+	Not  bool
+}
+
+// IsNullExpr -> expr IS [NOT] NULL
+type IsNullExpr struct {
+	Expr Expr
+	Not  bool
+}
+
+// FuncCall is a function applied to arguments, Name is always upper case. Star is set for COUNT(*)
+type FuncCall struct {
+	Name string
+	Args []Expr
+	Star bool
+}
+
+func (*Identifier) exprNode() {}
+func (*Literal) exprNode()    {}
+func (*UnaryExpr) exprNode()  {}
+func (*BinaryExpr) exprNode() {}
+func (*InExpr) exprNode()     {}
+func (*IsNullExpr) exprNode() {}
+func (*FuncCall) exprNode()   {}
+
+// walkExpr calls fn for expr and every expression below it, children are skipped when fn returns false
+func walkExpr(expr Expr, fn func(Expr) bool) {
+	if expr == nil || !fn(expr) {
+		return
+	}
+	switch e := expr.(type) {
+	case *UnaryExpr:
+		walkExpr(e.Expr, fn)
+	case *BinaryExpr:
+		walkExpr(e.Left, fn)
+		walkExpr(e.Right, fn)
+	case *InExpr:
+		walkExpr(e.Expr, fn)
+		for _, item := range e.List {
+			walkExpr(item, fn)
+		}
+	case *IsNullExpr:
+		walkExpr(e.Expr, fn)
+	case *FuncCall:
+		for _, arg := range e.Args {
+			walkExpr(arg, fn)
+		}
+	}
+}
@@ -1,18 +1,29 @@
 package internal
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
+	"slices"
 )
 
 const (
-	ORDER  = 128 //order*2=256 which is how many fit in a PAGESIZE block 4096/16(16 = 2 int64)
+	ORDER  = 128 //branches hold at most ORDER*2 keys
 	MAXAMT = ORDER*2 + 1
+
+	MAXKEYSIZE   = 1024         //at least three entries always fit on a leaf page
+	leafCapacity = PAGESIZE - 2 //bytes left for entries after the entry count of a leaf page
+	leafOverhead = 2 + 8        //key length and value stored with every key
 )
 
+/*
+B+tree mapping byte string keys to int64 values, keys are compared with bytes.Compare
+so callers encode values with an order preserving encoding (see key.go).
+Only leaves are written to disk, branches are rebuilt in memory when the index file is opened.
+Leaves are never merged on delete, an emptied leaf stays in place until the file is opened again.
+*/
 type tree struct {
 	root    node
 	leafBuf *leafBuffer
@@ -22,29 +33,35 @@ type tree struct {
 type node interface {
 	isLeaf() bool
 	size() int //represent how many values in node
-	addValue(int)
 	setParent(node)
 }
 
 /*
 Creates file if not exist, otherwise opens to read
-File combination of the directory and concatenation of "id"+"<tablename>"+"<columname>"
+File is the primary index file of the table, see primaryIndexFile
 returns valid tree with at least one node
 Tree contains close function must be called at end to sync
 error if any part of tree doesn't work
 */
-func initializeTree(dir, tablename, columname string) (*tree, error) {
-	filename := filepath.Join(dir, "id"+tablename+columname+".db")
-	if f, err := os.OpenFile(filename, os.O_WRONLY, 0666); err != nil {
-		f, err = os.Create(filename)
+func initializeTree(dir, files, columname string) (*tree, error) {
+	return openTree(primaryIndexFile(dir, files, columname))
+}
+
+// openTree opens the index stored in filename, creating an empty one if the file doesn't exist
+func openTree(filename string) (*tree, error) {
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		f, err := os.Create(filename)
 		if err != nil {
 			return nil, err
 		}
-		var newpage [PAGESIZE * 2]byte
-		f.Write(newpage[:])
-		f.Close()
-	} else {
+		var newpage [PAGESIZE * 2]byte //meta page followed by the first leaf
+		_, err = f.Write(newpage[:])
 		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
 	}
 
 	lb := new(leafBuffer)
@@ -60,142 +77,160 @@ func (t *tree) closeIndex() {
 	t.leafBuf.closeLeafBuffer()
 }
 
-func (t *tree) findKeyValue(key int64) (int64, bool) {
-	n := t.findLeaf(t.root, key)
-	return t.findValue(n, key)
+// closes the index and deletes its file
+func (t *tree) removeIndex() error {
+	t.leafBuf.indexFile.Close()
+	return os.Remove(t.leafBuf.indexFile.Name())
 }
 
-func (t *tree) findLeaf(thisNode node, key int64) *leafint64Node {
-	if thisNode == nil {
-		return nil
-	}
-	if thisNode.isLeaf() {
-		return thisNode.(*leafint64Node)
+func (t *tree) findKeyValue(key []byte) (int64, bool) {
+	leaf := t.findLeaf(t.root, key)
+	i, found := leaf.search(key)
+	if !found {
+		return 0, false
 	}
-	//otherwise its a branch
-	branchnode := thisNode.(*branchNode)
-	index := 0
-	for i := range branchnode.keys {
-		if key < branchnode.keys[i] || branchnode.keys[i] == 0 { //i >= (ORDER*2+1-thisNode.nums) (this is wrong meaning at some point i messed up setting the nums field in a node)
-			return t.findLeaf(branchnode.pointers[index], key)
+	return leaf.values[i], true
+}
+
+// returns the leaf key belongs in, a branch key equal to key leads to the right
+func (t *tree) findLeaf(thisNode node, key []byte) *leafNode {
+	for !thisNode.isLeaf() {
+		branch := thisNode.(*branchNode)
+		i, found := slices.BinarySearchFunc(branch.keys, key, bytes.Compare)
+		if found {
+			i++
 		}
-		index++
+		thisNode = branch.pointers[i]
 	}
-	return t.findLeaf(branchnode.pointers[index], key)
+	return thisNode.(*leafNode)
 }
 
-func (t *tree) findValue(leafnode node, key int64) (int64, bool) {
-	if leafnode == nil || !leafnode.isLeaf() {
-		return 0, false
+func (t *tree) findFirstLeaf() *leafNode {
+	currentNode := t.root
+	for !currentNode.isLeaf() {
+		currentNode = currentNode.(*branchNode).pointers[0]
 	}
-	thisLeaf := leafnode.(*leafint64Node)
-	for i := range thisLeaf.keys {
-		if key == thisLeaf.keys[i] {
-			return thisLeaf.values[i], true
+	return currentNode.(*leafNode)
+}
+
+/*
+ascend calls fn for every entry in key order starting at the first key not less than from,
+from nil starts at the smallest key. Stops as soon as fn returns false
+*/
+func (t *tree) ascend(from []byte, fn func(key []byte, value int64) bool) {
+	var leaf *leafNode
+	i := 0
+	if from == nil {
+		leaf = t.findFirstLeaf()
+	} else {
+		leaf = t.findLeaf(t.root, from)
+		i, _ = leaf.search(from)
+	}
+	for ; leaf != nil; leaf, i = leaf.next, 0 {
+		for ; i < len(leaf.keys); i++ {
+			if !fn(leaf.keys[i], leaf.values[i]) {
+				return
+			}
 		}
 	}
-	return 0, false
 }
 
-func (t *tree) insertNode(key int64, value int64) error {
-	leaf := t.findLeaf(t.root, key)
-
-	if leaf == nil {
-		return errors.New("unable to insert node")
+func (t *tree) insertNode(key []byte, value int64) error {
+	if len(key) > MAXKEYSIZE {
+		return fmt.Errorf("index key of %d bytes is larger than the maximum of %d", len(key), MAXKEYSIZE)
 	}
-	if _, ok := t.findValue(leaf, key); ok {
+	leaf := t.findLeaf(t.root, key)
+	i, found := leaf.search(key)
+	if found {
 		return errors.New("key already exists")
 	}
-	if leaf.size() == MAXAMT-1 {
-		//split node into two
-		anotherLeaf := new(leafint64Node) //new one
-		thisLeaf := leaf
-
-		InsertKVLeafNode(leaf, key, value)
-
-		count := 0
-		for i := ORDER; i < ORDER*2+1; i++ { //modify to change distribution on split
-			anotherLeaf.keys[i-ORDER] = thisLeaf.keys[i]
-			anotherLeaf.values[i-ORDER] = thisLeaf.values[i]
-			thisLeaf.keys[i] = 0
-			thisLeaf.values[i] = 0
-			count++
-		}
-
-		anotherLeaf.next = thisLeaf.next
-		thisLeaf.next = anotherLeaf
-		thisLeaf.addValue(-count)
-		anotherLeaf.addValue(count)
-		anotherLeaf.parent = thisLeaf.parent
-		t.leafBuf.lastPageId++
-		anotherLeaf.meta_pageNum = t.leafBuf.lastPageId
-		t.leafBuf.writeNewPage(thisLeaf, anotherLeaf)
-		t.propogateBranchKeyUp(thisLeaf.parent, anotherLeaf.keys[0], leaf, anotherLeaf) //propogate key upwards
-	} else {
-		InsertKVLeafNode(leaf, key, value)
+	leaf.keys = slices.Insert(leaf.keys, i, slices.Clone(key))
+	leaf.values = slices.Insert(leaf.values, i, value)
+	leaf.bytes += leafOverhead + len(key)
+	if leaf.bytes <= leafCapacity {
 		t.leafBuf.writeLeafToBuffer(leaf)
+		return nil
 	}
 
+	//split leaf into two halves of about the same size in bytes
+	mid, half := 0, 0
+	for half < leaf.bytes/2 && mid < len(leaf.keys)-1 {
+		half += leafOverhead + len(leaf.keys[mid])
+		mid++
+	}
+	anotherLeaf := &leafNode{
+		keys:   slices.Clone(leaf.keys[mid:]),
+		values: slices.Clone(leaf.values[mid:]),
+		bytes:  leaf.bytes - half,
+		next:   leaf.next,
+		parent: leaf.parent,
+	}
+	leaf.keys = slices.Clip(leaf.keys[:mid])
+	leaf.values = slices.Clip(leaf.values[:mid])
+	leaf.bytes = half
+	leaf.next = anotherLeaf
+	t.leafBuf.lastPageId++
+	anotherLeaf.meta_pageNum = t.leafBuf.lastPageId
+	if err := t.leafBuf.writeNewPage(leaf, anotherLeaf); err != nil {
+		return err
+	}
+	t.propogateBranchKeyUp(leaf.parent, anotherLeaf.keys[0], leaf, anotherLeaf) //propogate key upwards
 	return nil
 }
 
-func (t *tree) propogateBranchKeyUp(thisParent node, key int64, left, right node) {
+// removes key from the tree, returns false if it wasn't there
+func (t *tree) deleteKey(key []byte) bool {
+	leaf := t.findLeaf(t.root, key)
+	i, found := leaf.search(key)
+	if !found {
+		return false
+	}
+	leaf.bytes -= leafOverhead + len(key)
+	leaf.keys = slices.Delete(leaf.keys, i, i+1)
+	leaf.values = slices.Delete(leaf.values, i, i+1)
+	t.leafBuf.writeLeafToBuffer(leaf)
+	return true
+}
+
+// inserts key between left and right into their parent, splitting it when full
+func (t *tree) propogateBranchKeyUp(thisParent node, key []byte, left, right node) {
 	if thisParent == nil {
-		topBranch := new(branchNode)
-		topBranch.keys[0] = key
-		topBranch.pointers[0] = left
-		topBranch.pointers[1] = right
-		topBranch.nums = 1
+		topBranch := &branchNode{keys: [][]byte{key}, pointers: []node{left, right}}
 		t.root = topBranch
-		left.setParent(t.root)
-		right.setParent(t.root)
+		left.setParent(topBranch)
+		right.setParent(topBranch)
 		return
 	}
 
-	if thisParent.size() == MAXAMT-1 {
-		anotherBranch := new(branchNode)
-
-		InsertBranchKCNode(thisParent, key, left, right)
-
-		parentBranch := thisParent.(*branchNode)
-		middleKey := parentBranch.keys[ORDER]
-
-		for i := ORDER + 1; i < ORDER*2+1; i++ {
-			anotherBranch.keys[i-ORDER-1] = parentBranch.keys[i]
-			parentBranch.keys[i] = 0
-		}
-		for i := ORDER + 1; i < ORDER*2+2; i++ {
-			anotherBranch.pointers[i-ORDER-1] = parentBranch.pointers[i]
-			parentBranch.pointers[i].setParent(anotherBranch)
-			parentBranch.pointers[i] = nil
-		}
-		anotherBranch.parent = parentBranch.parent
-		anotherBranch.nums = ORDER
-		parentBranch.nums = ORDER
-
-		t.propogateBranchKeyUp(parentBranch.parent, middleKey, parentBranch, anotherBranch)
-	} else {
-		InsertBranchKCNode(thisParent, key, left, right)
+	parentBranch := thisParent.(*branchNode)
+	i := slices.Index(parentBranch.pointers, left)
+	parentBranch.keys = slices.Insert(parentBranch.keys, i, key)
+	parentBranch.pointers = slices.Insert(parentBranch.pointers, i+1, right)
+	right.setParent(parentBranch)
+	if len(parentBranch.keys) < MAXAMT {
+		return
 	}
-}
 
-func (t *tree) findFirstLeaf() *leafint64Node {
-	currentLeaf := t.root
-	for currentLeaf != nil && !currentLeaf.isLeaf() {
-		currentBranch := currentLeaf.(*branchNode)
-		currentLeaf = currentBranch.pointers[0]
+	middleKey := parentBranch.keys[ORDER]
+	anotherBranch := &branchNode{
+		keys:     slices.Clone(parentBranch.keys[ORDER+1:]),
+		pointers: slices.Clone(parentBranch.pointers[ORDER+1:]),
+		parent:   parentBranch.parent,
 	}
-	if currentLeaf == nil {
-		return nil
+	for _, child := range anotherBranch.pointers {
+		child.setParent(anotherBranch)
 	}
-	return currentLeaf.(*leafint64Node)
+	parentBranch.keys = slices.Clip(parentBranch.keys[:ORDER])
+	parentBranch.pointers = slices.Clip(parentBranch.pointers[:ORDER+1])
+
+	t.propogateBranchKeyUp(parentBranch.parent, middleKey, parentBranch, anotherBranch)
 }
 
+// pointers[i] holds keys less than keys[i], pointers[i+1] the keys greater or equal
 type branchNode struct {
 	parent   node
-	keys     [ORDER*2 + 1]int64
-	pointers [ORDER*2 + 2]node
-	nums     int //less than max keys
+	keys     [][]byte
+	pointers []node
 }
 
 func (b *branchNode) isLeaf() bool {
@@ -203,11 +238,7 @@ func (b *branchNode) isLeaf() bool {
 }
 
 func (b *branchNode) size() int {
-	return b.nums
-}
-
-func (b *branchNode) addValue(delta int) {
-	b.nums += delta
+	return len(b.keys)
 }
 
 func (b *branchNode) setParent(parent node) {
@@ -215,135 +246,49 @@ func (b *branchNode) setParent(parent node) {
 }
 
 func (b *branchNode) String() string {
-	return fmt.Sprintf("(%d)", b.nums)
+	return fmt.Sprintf("(%d)", len(b.keys))
 }
 
-type leafint64Node struct {
-	nums         int                //less than max keys
-	keys         [ORDER*2 + 1]int64 //zero represents unset key
-	values       [ORDER*2 + 1]int64
-	next         *leafint64Node
+type leafNode struct {
+	keys         [][]byte //sorted
+	values       []int64
+	bytes        int //size of the entries once written to a page
+	next         *leafNode
 	parent       node
 	meta_pageNum uint32
-	meta_min     int64 //same type as values
 }
 
-func (l *leafint64Node) isLeaf() bool {
+func (l *leafNode) isLeaf() bool {
 	return true
 }
 
-func (l *leafint64Node) size() int {
-	return l.nums
-}
-
-func (l *leafint64Node) addValue(delta int) {
-	l.nums += delta
+func (l *leafNode) size() int {
+	return len(l.keys)
 }
 
-func (l *leafint64Node) setParent(parent node) {
+func (l *leafNode) setParent(parent node) {
 	l.parent = parent
 }
 
-func (l *leafint64Node) String() string {
-	return fmt.Sprintf("(%d)", l.nums)
+func (l *leafNode) String() string {
+	return fmt.Sprintf("(%d)", len(l.keys))
 }
 
-//
-// # General Functions
-//
-
-func InsertKVLeafNode(node node, key int64, value int64) {
-	leafNode, ok := node.(*leafint64Node)
-	if !ok {
-		panic("node interface set to leafnode untrue (InsertKVLeafNode)")
-	}
-	index := 0
-	for i := range leafNode.keys {
-		if key < leafNode.keys[i] || leafNode.keys[i] == 0 {
-			break
-		}
-		index++
-	}
-	for i := ORDER * 2; i > index; i-- {
-		leafNode.keys[i] = leafNode.keys[i-1]
-		leafNode.values[i] = leafNode.values[i-1]
-	}
-	leafNode.keys[index] = key
-	leafNode.values[index] = value
-	leafNode.nums++
-}
-
-func InsertBranchKCNode(node node, key int64, left, right node) {
-	branchNode := node.(*branchNode)
-	index := 0
-	for i := range branchNode.keys {
-		if key < branchNode.keys[i] || branchNode.keys[i] == 0 {
-			break
-		}
-		index++
-	}
-	for i := ORDER * 2; i > index; i-- {
-		branchNode.keys[i] = branchNode.keys[i-1]
-		branchNode.pointers[i+1] = branchNode.pointers[i]
-	}
-
-	branchNode.pointers[index] = left
-	branchNode.pointers[index+1] = right
-	branchNode.keys[index] = key
-
-	branchNode.nums++
-}
-
-func bulkPropogateBranchKey(thisParent node, key int64, left, right *branchNode) {
-	if thisParent == nil {
-		newRoot := new(branchNode)
-		newRoot.keys[0] = key
-		newRoot.pointers[0] = left
-		newRoot.pointers[1] = right
-		newRoot.nums = 1
-		left.parent = newRoot
-		right.parent = newRoot
-		return
-	}
-
-	if thisParent.size() == MAXAMT-1 {
-		anotherBranch := new(branchNode)
-
-		InsertBranchKCNode(thisParent, key, left, right)
-
-		thisParent := thisParent.(*branchNode)
-		middleKey := thisParent.keys[ORDER]
-
-		for i := ORDER + 1; i < ORDER*2+1; i++ {
-			anotherBranch.keys[i-ORDER-1] = thisParent.keys[i]
-			thisParent.keys[i] = 0
-		}
-		for i := ORDER + 1; i < ORDER*2+2; i++ {
-			anotherBranch.pointers[i-ORDER-1] = thisParent.pointers[i]
-			thisParent.pointers[i].setParent(anotherBranch)
-			thisParent.pointers[i] = nil
-		}
-		anotherBranch.parent = thisParent.parent
-		anotherBranch.nums = ORDER
-		thisParent.nums = ORDER
-
-		bulkPropogateBranchKey(thisParent.parent, middleKey, thisParent, anotherBranch)
-	} else {
-		InsertBranchKCNode(thisParent, key, left, right)
-	}
+// position of key in the leaf or where it would be inserted
+func (l *leafNode) search(key []byte) (int, bool) {
+	return slices.BinarySearchFunc(l.keys, key, bytes.Compare)
 }
 
 /*
-uses pointer to static array since that avoids copying the array can be called either by
-setting parameter to same type with pointer to it or casting parameter to slice and calling function with staticArray[:]
-since also creates temp slice data type that uses pointer.
-first leaf page after meta page always going to be min page
+Leaf pages are laid out as the number of entries (2 bytes) followed by the entries,
+each entry is the key length (2 bytes), the key and the value (8 bytes).
+first leaf page after meta page always exists, leaves are linked in key order when the file is opened
 */
 type leafBuffer struct {
 	indexFile     *os.File
 	metapage      [PAGESIZE]byte
 	lastPageId    uint32
-	bufferedPages []*leafint64Node
+	bufferedPages []*leafNode
 }
 
 func (l *leafBuffer) InitializeLeafBuffer(filepath string) (node, error) {
@@ -352,254 +297,158 @@ func (l *leafBuffer) InitializeLeafBuffer(filepath string) (node, error) {
 	if err != nil {
 		return nil, err
 	}
-	l.indexFile.Read(l.metapage[:])
-	l.lastPageId = 1
-	l.bufferedPages = make([]*leafint64Node, 0, 5)
+	if _, err := l.indexFile.Read(l.metapage[:]); err != nil {
+		return nil, err
+	}
+	l.bufferedPages = make([]*leafNode, 0, 5)
 
-	listHead := l.linkLeafNodes()
+	leaves, err := l.linkLeafNodes()
+	if err != nil {
+		return nil, err
+	}
 
 	//read all pages and rerender tree from scratch
-
-	return l.constructTree(listHead), nil
+	return l.constructTree(leaves), nil
 }
 
-func (l *leafBuffer) linkLeafNodes() node {
-	head := new(leafint64Node)
-	tail := new(leafint64Node)
+// reads every leaf page and returns the leaves linked in key order
+func (l *leafBuffer) linkLeafNodes() ([]*leafNode, error) {
+	leaves := make([]*leafNode, 0)
+	var first *leafNode
 	var pageBuf [PAGESIZE]byte
-	pageNum := uint32(1)
-
-	l.indexFile.Read(pageBuf[:]) //first read always valid
-	head.meta_pageNum = pageNum
-	head.meta_min = MAXINT64
-	var byteOffset int = 0
-	for i := 0; i < ORDER*2; i++ {
-		var tmpKey int64 = int64(binary.LittleEndian.Uint64(pageBuf[byteOffset:]))
-		if tmpKey == 0 {
+	for pageNum := uint32(1); ; pageNum++ {
+		_, err := l.indexFile.ReadAt(pageBuf[:], int64(pageNum)*PAGESIZE)
+		if err != nil {
 			break
 		}
-		if tmpKey < head.meta_min {
-			head.meta_min = tmpKey
+		leaf, err := readLeafPage(pageBuf[:])
+		if err != nil {
+			return nil, fmt.Errorf("index page %d of %s: %w", pageNum, l.indexFile.Name(), err)
 		}
-		head.keys[i] = tmpKey
-		byteOffset += 8
-		head.values[i] = int64(binary.LittleEndian.Uint64(pageBuf[byteOffset:]))
-		byteOffset += 8
-		head.nums++
+		leaf.meta_pageNum = pageNum
+		l.lastPageId = pageNum
+		if first == nil {
+			first = leaf
+		}
+		if len(leaf.keys) > 0 { //empty leaves are dropped, their pages are left unused
+			leaves = append(leaves, leaf)
+		}
+	}
+	if first == nil {
+		return nil, fmt.Errorf("index file %s has no leaf page", l.indexFile.Name())
+	}
+	if len(leaves) == 0 {
+		return []*leafNode{first}, nil
 	}
-	tail = head
 
-	var err error
-	for err == nil {
-		_, err = l.indexFile.Read(pageBuf[:])
-		if err != nil {
-			break
-		}
-		newnode := new(leafint64Node)
-		pageNum++
-		newnode.meta_pageNum = pageNum
-		newnode.meta_min = MAXINT64
-		var byteOffset int = 0
-		for i := 0; i < ORDER*2; i++ {
-			var tmpKey int64 = int64(binary.LittleEndian.Uint64(pageBuf[byteOffset:]))
-			if tmpKey == 0 {
-				break
-			}
-			if tmpKey < newnode.meta_min {
-				newnode.meta_min = tmpKey
-			}
-			newnode.keys[i] = tmpKey
-			byteOffset += 8
-			newnode.values[i] = int64(binary.LittleEndian.Uint64(pageBuf[byteOffset:]))
-			byteOffset += 8
-			newnode.nums++
+	slices.SortFunc(leaves, func(a, b *leafNode) int {
+		return bytes.Compare(a.keys[0], b.keys[0])
+	})
+	for i := 0; i < len(leaves)-1; i++ {
+		leaves[i].next = leaves[i+1]
+	}
+	return leaves, nil
+}
+
+func readLeafPage(buf []byte) (*leafNode, error) {
+	leaf := &leafNode{}
+	nums := int(binary.LittleEndian.Uint16(buf[0:2]))
+	leaf.keys = make([][]byte, nums)
+	leaf.values = make([]int64, nums)
+	offset := 2
+	for i := 0; i < nums; i++ {
+		if offset+leafOverhead > len(buf) {
+			return nil, errors.New("entries run past the end of the page")
 		}
-		if newnode.meta_min > tail.meta_min {
-			tail.next = newnode
-			tail = newnode
-			continue
+		keySize := int(binary.LittleEndian.Uint16(buf[offset:]))
+		offset += 2
+		if offset+keySize+8 > len(buf) {
+			return nil, errors.New("entries run past the end of the page")
 		}
-		curr := head
-		for curr != nil {
-			if newnode.meta_min > curr.meta_min {
-				newnode.next = curr.next
-				curr.next = newnode
-			}
-			curr = curr.next
+		leaf.keys[i] = slices.Clone(buf[offset : offset+keySize])
+		offset += keySize
+		leaf.values[i] = int64(binary.LittleEndian.Uint64(buf[offset:]))
+		offset += 8
+	}
+	leaf.bytes = offset - 2
+	return leaf, nil
+}
+
+// bulk loads branches on top of the sorted leaves, every branch but the last of a level is full
+func (l *leafBuffer) constructTree(leaves []*leafNode) node {
+	level := make([]node, len(leaves))
+	mins := make([][]byte, len(leaves)) //smallest key below each node of the level
+	for i := range leaves {
+		level[i] = leaves[i]
+		if len(leaves[i].keys) > 0 {
+			mins[i] = leaves[i].keys[0]
 		}
 	}
-
-	l.lastPageId = pageNum
-	return head
-}
-
-func (l *leafBuffer) constructTree(head node) *branchNode {
-	thisBranch := new(branchNode)
-	thisBranch.nums = 0
-
-	var curr *leafint64Node
-	thisBranch.pointers[0] = head
-	head.setParent(thisBranch)
-	curr = head.(*leafint64Node).next
-	var index int = 0
-	for curr != nil {
-		if thisBranch.nums == MAXAMT-1 {
-			//repeated so there is check when nums is 1 and last branch node not accidentally set to 0
-			//and full and break later on for new insertion
-			//since we always want to check for branch node being full since bulk loading may end with a branch being full
-			//and no checks later on for fullness
-			thisBranch.keys[index] = curr.meta_min
-			thisBranch.pointers[index+1] = curr
-			curr.parent = thisBranch
-			curr = curr.next
-			thisBranch.nums++
-
-			middleKey := thisBranch.keys[ORDER]
-			rightBranch := new(branchNode)
-
-			for i := ORDER + 1; i < ORDER*2+1; i++ {
-				rightBranch.keys[i-ORDER-1] = thisBranch.keys[i]
-				thisBranch.keys[i] = 0
+	for len(level) > 1 {
+		nextLevel := make([]node, 0, len(level)/MAXAMT+1)
+		nextMins := make([][]byte, 0, cap(nextLevel))
+		for start := 0; start < len(level); start += MAXAMT {
+			end := min(start+MAXAMT, len(level))
+			thisBranch := &branchNode{
+				keys:     slices.Clone(mins[start+1 : end]),
+				pointers: slices.Clone(level[start:end]),
 			}
-			for i := ORDER + 1; i < ORDER*2+2; i++ {
-				rightBranch.pointers[i-ORDER-1] = thisBranch.pointers[i]
-				thisBranch.pointers[i].setParent(rightBranch)
-				thisBranch.pointers[i] = nil
+			for _, child := range thisBranch.pointers {
+				child.setParent(thisBranch)
 			}
-			rightBranch.parent = thisBranch.parent
-			rightBranch.nums = ORDER
-			thisBranch.nums = ORDER
-
-			bulkPropogateBranchKey(thisBranch.parent, middleKey, thisBranch, rightBranch)
-
-			thisBranch = rightBranch
-			index = 0
-			continue
+			nextLevel = append(nextLevel, thisBranch)
+			nextMins = append(nextMins, mins[start])
 		}
-		thisBranch.keys[index] = curr.meta_min
-		thisBranch.pointers[index+1] = curr
-		curr.setParent(thisBranch)
-		curr = curr.next
-		index++
-		thisBranch.nums++
-	}
-
-	for thisBranch.parent != nil {
-		thisBranch = thisBranch.parent.(*branchNode)
+		level, mins = nextLevel, nextMins
 	}
-	return thisBranch
+	level[0].setParent(nil)
+	return level[0]
 }
 
-func (l *leafBuffer) writeLeafToBuffer(node *leafint64Node) {
-	for i := range l.bufferedPages {
-		if l.bufferedPages[i].meta_min == node.meta_min {
-			return
-		}
+func (l *leafBuffer) writeLeafToBuffer(leaf *leafNode) {
+	if slices.Contains(l.bufferedPages, leaf) {
+		return
 	}
-	l.bufferedPages = append(l.bufferedPages, node)
+	l.bufferedPages = append(l.bufferedPages, leaf)
 }
 
-func (l *leafBuffer) removeLeafFromBuffer(leaf *leafint64Node) {
-	for i := range l.bufferedPages {
-		if l.bufferedPages[i].meta_pageNum == leaf.meta_pageNum {
-			l.bufferedPages[i] = l.bufferedPages[len(l.bufferedPages)-1]
-			l.bufferedPages = l.bufferedPages[:len(l.bufferedPages)-1]
-			return
-		}
+func (l *leafBuffer) removeLeafFromBuffer(leaf *leafNode) {
+	if i := slices.Index(l.bufferedPages, leaf); i != -1 {
+		l.bufferedPages = slices.Delete(l.bufferedPages, i, i+1)
 	}
 }
 
-func (l *leafBuffer) writeNewPage(oldleaf, newleaf *leafint64Node) {
-	l.writeLeafPage(oldleaf)
-	l.writeLeafPage(newleaf)
-	l.removeLeafFromBuffer(oldleaf)
+func (l *leafBuffer) writeNewPage(oldleaf, newleaf *leafNode) error {
+	if err := l.writeLeafPage(oldleaf); err != nil {
+		return err
+	}
+	return l.writeLeafPage(newleaf)
 }
 
-func (l *leafBuffer) writeLeafPage(leaf *leafint64Node) {
-	var offset int64 = int64(leaf.meta_pageNum) * PAGESIZE
+func (l *leafBuffer) writeLeafPage(leaf *leafNode) error {
 	var leafbytes [PAGESIZE]byte
-	var byteOffset int = 0
-	for i := 0; i < ORDER*2; i++ {
-		binary.LittleEndian.PutUint64(leafbytes[byteOffset:], uint64(leaf.keys[i]))
-		byteOffset += 8
+	binary.LittleEndian.PutUint16(leafbytes[0:2], uint16(len(leaf.keys)))
+	byteOffset := 2
+	for i := range leaf.keys {
+		binary.LittleEndian.PutUint16(leafbytes[byteOffset:], uint16(len(leaf.keys[i])))
+		byteOffset += 2
+		byteOffset += copy(leafbytes[byteOffset:], leaf.keys[i])
 		binary.LittleEndian.PutUint64(leafbytes[byteOffset:], uint64(leaf.values[i]))
 		byteOffset += 8
 	}
 
-	l.indexFile.Seek(offset, 0)
-	l.indexFile.Write(leafbytes[:])
+	_, err := l.indexFile.WriteAt(leafbytes[:], int64(leaf.meta_pageNum)*PAGESIZE)
 	l.removeLeafFromBuffer(leaf)
+	return err
 }
 
 func (l *leafBuffer) syncNodesFromBuffer() {
-	for i := range l.bufferedPages {
-		l.writeLeafPage(l.bufferedPages[i])
+	for len(l.bufferedPages) > 0 {
+		l.writeLeafPage(l.bufferedPages[0]) //removes the page from the buffer
 	}
-	l.bufferedPages = l.bufferedPages[:0] //sets len to 0 for reuse since underlying memory always overwritten
 }
 
 func (l *leafBuffer) closeLeafBuffer() {
 	l.syncNodesFromBuffer()
 	l.indexFile.Close()
 }
-
-// func main() {
-// 	mytree, err := initializeTree("./", "mytable", "mycolumn")
-// 	if err != nil {
-// 		log.Fatal(err)
-// 	}
-// 	defer mytree.closeIndex()
-
-// 	r1 := rand.New(rand.NewPCG(42, 1024))
-// 	for i := int64(1); i < 200; i++ {
-// 		err := mytree.insertNode(i, r1.Int64N(100))
-// 		if err != nil {
-// 			log.Fatal(i, err)
-// 		}
-// 	}
-// 	err = mytree.insertNode(202, 256)
-// 	if err != nil {
-// 		log.Fatal(err)
-// 	}
-
-// 	val, ok := mytree.findKeyValue(202)
-// 	fmt.Printf("%t -ok; %d - val: %d\n", ok, 202, val)
-
-// 	val, ok = mytree.findKeyValue(201)
-// 	fmt.Printf("%t -ok; %d - val: %d\n", ok, 201, val) //false
-
-// 	err = mytree.insertNode(17, 256)
-// 	if err == nil {
-// 		log.Fatal("number passed through nonunique")
-// 	} else {
-// 		fmt.Println("correctly blocks duplicate")
-// 	}
-
-// 	for i := int64(203); i < 8000; i++ {
-// 		err := mytree.insertNode(i, r1.Int64N(100))
-// 		if err != nil {
-// 			log.Fatal(i, err)
-// 		}
-// 	}
-
-// 	val, ok = mytree.findKeyValue(7811)
-// 	fmt.Printf("%t -ok; %d - val: %d\n", ok, 7811, val) //23
-
-// 	val, ok = mytree.findKeyValue(7992)
-// 	fmt.Printf("%t -ok; %d - val: %d\n", ok, 7992, val) //2
-
-// 	val, ok = mytree.findKeyValue(7999)
-// 	fmt.Printf("%t -ok; %d - val: %d\n", ok, 7999, val) //44
-
-// 	val, ok = mytree.findKeyValue(7451)
-// 	fmt.Printf("%t -ok; %d - val: %d\n", ok, 7451, val) //31
-
-// 	val, ok = mytree.findKeyValue(7555)
-// 	fmt.Printf("%t -ok; %d - val: %d\n", ok, 7555, val) //8
-
-// 	val, ok = mytree.findKeyValue(9000)
-// 	fmt.Printf("%t -ok; %d - val: %d\n", ok, 9000, val) //false
-
-// 	val, ok = mytree.findKeyValue(1000)
-// 	fmt.Printf("%t -ok; %d - val: %d\n", ok, 1000, val) //75
-// }
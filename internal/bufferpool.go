@@ -2,12 +2,13 @@ package internal
 
 import (
 	"bytes"
+	"cmp"
 	"crypto/md5"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
+	"slices"
 	"sync"
 	"sync/atomic"
 )
@@ -32,14 +33,18 @@ func NewBufferPoolManager(dir string) *bufferPoolManager {
 	return &newManager
 }
 
-func (bm *bufferPoolManager) newPool(tablename string, dir string, cols []Column) (int64, uint64) {
+func (bm *bufferPoolManager) newPool(tablename, files string, dir string, cols []Column) (int64, uint64) {
 	newPool := &bufferPool{
-		slots:   [MAXPOOLSIZE]*internalSlots{},
-		mxread:  &sync.Mutex{},
-		mxwrite: &sync.Mutex{},
-		pagemx:  &sync.RWMutex{},
-		lru:     InitialLRU(),
-		columns: cols,
+		slots:    [MAXPOOLSIZE]*internalSlots{},
+		mxread:   &sync.Mutex{},
+		mxwrite:  &sync.Mutex{},
+		pagemx:   &sync.RWMutex{},
+		lru:      InitialLRU(),
+		columns:  cols,
+		missing:  missingCells(cols),
+		mxpin:    &sync.Mutex{},
+		pinned:   make(map[PageID]int),
+		released: make(map[PageID]bool),
 	}
 	for i := range newPool.lru.buffers {
 		newPool.lru.buffers[i].pos = i
@@ -48,7 +53,7 @@ func (bm *bufferPoolManager) newPool(tablename string, dir string, cols []Column
 		newPool.slots[i] = new(internalSlots)
 	}
 
-	filePathStr := filepath.Join(dir, fmt.Sprintf("%s.db", tablename))
+	filePathStr := tableFile(dir, files)
 	_, err := os.Stat(filePathStr)
 	if err != nil {
 		f, err := os.Create(filePathStr)
@@ -59,6 +64,9 @@ func (bm *bufferPoolManager) newPool(tablename string, dir string, cols []Column
 	}
 	newPool.tablefileRead, _ = os.OpenFile(filePathStr, os.O_RDONLY, 0644)
 	newPool.tablefileWrite, _ = os.OpenFile(filePathStr, os.O_WRONLY, 0644)
+	if err := newPool.openOverflow(overflowFile(dir, files)); err != nil {
+		panic(err)
+	}
 	bm.allpools[tablename] = newPool
 
 	fi, err := newPool.tablefileRead.Stat()
@@ -68,116 +76,252 @@ func (bm *bufferPoolManager) newPool(tablename string, dir string, cols []Column
 	if fi.Size() == 0 {
 		return 1, 0
 	}
-	lastPage := fi.Size()/PAGESIZE - 1
-	allrows := newPool.FetchPage(PageID(lastPage))
-	max := int64(1)
-	primaryIndex := 0
-	for i := range newPool.columns {
-		if newPool.columns[i].columnIsPrimary {
+	newPool.lastPage = PageID(fi.Size()/PAGESIZE - 1)
+	maxId, err := newPool.scanPages()
+	if err != nil {
+		panic(err)
+	}
+	return maxId, uint64(newPool.lastPage)
+}
+
+/*
+scanPages reads every page of the table, returns the highest key of an integer primary key
+and notes the pages before the last one with room for another row like the ones they hold
+*/
+func (b *bufferPool) scanPages() (int64, error) {
+	primaryIndex := -1
+	for i := range b.columns {
+		if b.columns[i].columnIsPrimary && primaryIndex == -1 {
 			primaryIndex = i
+		} else if b.columns[i].columnIsPrimary {
+			primaryIndex = -1 //composite keys don't hand out row ids
 			break
 		}
 	}
-	for i := range allrows {
-		if allrows[i][primaryIndex].AsInt() > int64(max) {
-			max = allrows[i][primaryIndex].AsInt()
+	if primaryIndex != -1 && b.columns[primaryIndex].columnType != INT {
+		primaryIndex = -1
+	}
+
+	max := int64(1)
+	var page heapPage
+	for pageid := PageID(0); pageid <= b.lastPage; pageid++ {
+		if _, err := b.tablefileRead.ReadAt(page[:], int64(pageid)*PAGESIZE); err != nil {
+			return 0, err
+		}
+		smallest := 0
+		for slot := range page.slotCount() {
+			raw := page.row(slot)
+			if raw == nil {
+				continue
+			}
+			if smallest == 0 || len(raw) < smallest {
+				smallest = len(raw)
+			}
+			if primaryIndex == -1 {
+				continue
+			}
+			if row, _ := b.splitRow(raw); row[primaryIndex] != nil && row[primaryIndex].AsInt() > max {
+				max = row[primaryIndex].AsInt()
+			}
+		}
+		if free := page.freeSpace(); pageid < b.lastPage && free >= smallest+slotSize {
+			b.roomy = append(b.roomy, roomyPage{page: pageid, free: free})
 		}
 	}
-	return max, uint64(lastPage)
+	return max, nil
 }
 
-// return last page modified
-func (bm *bufferPoolManager) InsertData(tablename string, pageid PageID, data [][]Cell, indexData *[][2]int64) (PageID, error) {
+/*
+InsertData writes the rows into pages with room left by deletes first, the rows left are appended to the end of the table.
+Returns the last page of the table and the location (page*PAGESIZE+slot) each row was written to
+*/
+func (bm *bufferPoolManager) InsertData(tablename string, data [][]Cell) (PageID, []int64, error) {
 	pool, ok := bm.allpools[tablename]
 	if !ok {
-		return 0, fmt.Errorf("table name: \"%s\" does not exist", tablename)
+		return 0, nil, fmt.Errorf("table name: \"%s\" does not exist", tablename)
 	}
-
-	pageToModify, err := pool.rawFetchPage(pageid)
-	if err != nil {
-		return 0, errors.Join(errors.New("internal error fetching page: "), err)
-	}
-
+	pool.mxwrite.Lock()
+	defer pool.mxwrite.Unlock()
+	w := pool.newPageWriter()
 	rows := make([][]byte, len(data))
-	rowsize := 0
-	for i := range pool.columns {
-		rowsize += int(pool.columns[i].columnSize)
-	}
 	for i := range data {
-		nullColumns := InitializeBitSet(uint64(len(data[i]) + 1))
-		nullColumns.setBit(len(pool.columns) + 1)
-		newrow := make([]byte, nullColumns.Size()+uint64(rowsize))
-		offset := int(nullColumns.Size())
-		for j := range data[i] {
-			colsize := int(pool.columns[j].columnSize)
-			if data[i][j] == nil {
-				offset += colsize
-				continue
-			}
-			nullColumns.setBit(j)
-			copy(newrow[offset:offset+colsize], data[i][j])
-			offset += colsize
+		var err error
+		if rows[i], err = w.encodeRow(data[i]); err != nil {
+			return 0, nil, err
 		}
-		copy(newrow[0:nullColumns.Size()], nullColumns.bytes)
-		rows[i] = newrow
 	}
+	locations := make([]int64, len(rows))
+	for i := range rows {
+		var err error
+		if locations[i], err = w.place(rows[i]); err != nil {
+			return 0, nil, err
+		}
+	}
+	if err := w.flush(); err != nil {
+		return 0, nil, err
+	}
+	return pool.lastPage, locations, nil
+}
 
+/*
+WriteRows overwrites the rows stored at locations, a nil row deletes the row at its location.
+A row growing too large for its page moves to another one, returns the last page of the table
+and the location every row ended up at. The overflow pages of the old rows are freed after the
+new pages are synced, a crash in between leaves them unused rather than shared
+*/
+func (bm *bufferPoolManager) WriteRows(tablename string, locations []int64, data [][]Cell) (PageID, []int64, error) {
+	pool, ok := bm.allpools[tablename]
+	if !ok {
+		return 0, nil, fmt.Errorf("table name: \"%s\" does not exist", tablename)
+	}
 	pool.mxwrite.Lock()
 	defer pool.mxwrite.Unlock()
-	f := pool.tablefileWrite
+	w := pool.newPageWriter()
+	rows := make([][]byte, len(data))
+	for i := range data {
+		if data[i] == nil {
+			continue
+		}
+		var err error
+		if rows[i], err = w.encodeRow(data[i]); err != nil {
+			return 0, nil, err
+		}
+	}
+	moved := make([]int, 0)
+	released := make([]PageID, 0)
+	for i, loc := range locations {
+		pageid, slot := PageID(loc/PAGESIZE), int(loc%PAGESIZE)
+		page, err := w.page(pageid)
+		if err != nil {
+			return 0, nil, err
+		}
+		_, refs := pool.splitRow(page.row(slot))
+		for _, ref := range refs {
+			released = append(released, ref.page)
+		}
+		if rows[i] == nil {
+			page.delete(slot)
+			w.freed[pageid] = true
+		} else if !page.update(slot, rows[i]) {
+			w.freed[pageid] = true
+			moved = append(moved, i)
+		}
+	}
+	newLocations := slices.Clone(locations)
+	for _, i := range moved {
+		var err error
+		if newLocations[i], err = w.place(rows[i]); err != nil {
+			return 0, nil, err
+		}
+	}
+	if err := w.flush(); err != nil {
+		return 0, nil, err
+	}
+	//values of the old rows give back their overflow pages once no page on disk points at them
+	for _, first := range released {
+		if err := pool.releaseOverflow(first); err != nil {
+			return 0, nil, err
+		}
+	}
+	return pool.lastPage, newLocations, nil
+}
 
-	pages := make([][PAGESIZE]byte, 1, 10)
-	pages[0] = pageToModify //copies by value
-	pgIndex := 0
+// pageWriter collects the pages changed by one write to a table, they are written to the file together
+type pageWriter struct {
+	pool  *bufferPool
+	pages map[PageID]*heapPage
+	freed map[PageID]bool //pages rows were deleted or moved from
+}
 
-	//numberOfPage := binary.LittleEndian.Uint64(buf[0:8])
-	rowNums := binary.LittleEndian.Uint16(pages[pgIndex][8:10])
-	//checksum := buf[10:26]
+// roomyPage is a page before the last one of a table with free space
+type roomyPage struct {
+	page PageID
+	free int
+}
 
-	offset := 26 + int(rowNums)*len(data[0])
+// pool.mxwrite must be held until the writer is flushed
+func (b *bufferPool) newPageWriter() *pageWriter {
+	return &pageWriter{pool: b, pages: make(map[PageID]*heapPage), freed: make(map[PageID]bool)}
+}
 
-	pgNum := pageid
-	for i := range rows {
-		if offset+len(rows[i]) > PAGESIZE {
-			//create new page and write old page
-			//binary.LittleEndian.PutUint64(pages[pgIndex][0:8], uint64(pgNum))
-			binary.LittleEndian.PutUint16(pages[pgIndex][8:10], rowNums)
-			checksum := md5.Sum(pages[pgIndex][26:])
-			copy(pages[pgIndex][10:26], checksum[:])
-
-			rowNums = 0
-			pgNum += 1
-			buf := [PAGESIZE]byte{}
-			binary.LittleEndian.PutUint64(buf[:], uint64(pgNum))
-			offset = 26
-			pages = append(pages, buf)
-			pgIndex += 1
-		}
-		copy(pages[pgIndex][offset:], rows[i])
-		(*indexData)[i][1] = int64(offset) + int64(pgNum*PAGESIZE)
-		offset += len(rows[i])
-		rowNums += 1
-	}
-
-	checksum := md5.Sum(pages[pgIndex][26:])
-	copy(pages[pgIndex][10:26], checksum[:])
-	binary.LittleEndian.PutUint16(pages[pgIndex][8:10], rowNums)
-
-	f.Seek(int64(pageid)*PAGESIZE, 0)
-	for i := range pages {
-		_, err := f.Write(pages[i][:])
+// page pageid as changed by the write so far
+func (w *pageWriter) page(pageid PageID) (*heapPage, error) {
+	if page, ok := w.pages[pageid]; ok {
+		return page, nil
+	}
+	buf, err := w.pool.rawFetchPage(pageid)
+	if err != nil {
+		return nil, errors.Join(errors.New("internal error fetching page: "), err)
+	}
+	w.pages[pageid] = (*heapPage)(&buf)
+	return w.pages[pageid], nil
+}
+
+// stores row on the first page with room for it, a new page is added once the last page is full
+func (w *pageWriter) place(row []byte) (int64, error) {
+	pool := w.pool
+	need := len(row) + slotSize
+	for i := 0; i < len(pool.roomy); i++ {
+		if pool.roomy[i].free < need {
+			continue
+		}
+		pageid := pool.roomy[i].page
+		page, err := w.page(pageid)
 		if err != nil {
 			return 0, err
 		}
+		slot, ok := page.insert(row)
+		pool.roomy[i].free = page.freeSpace()
+		if pool.roomy[i].free < need { //a page without room for a row like this one is left alone
+			pool.roomy = slices.Delete(pool.roomy, i, i+1)
+			i--
+		}
+		if ok {
+			return int64(pageid)*PAGESIZE + int64(slot), nil
+		}
 	}
-	f.Sync()
-
-	//deletes page from bufferpool and all data should be written to disk by this point
-	pool.pagemx.Lock()
-	pool.deletePage(pageid)
-	pool.pagemx.Unlock()
+	for {
+		page, err := w.page(pool.lastPage)
+		if err != nil {
+			return 0, err
+		}
+		if slot, ok := page.insert(row); ok {
+			return int64(pool.lastPage)*PAGESIZE + int64(slot), nil
+		}
+		pool.lastPage++
+		w.pages[pool.lastPage] = newHeapPage(pool.lastPage)
+	}
+}
 
-	return pgNum, nil
+// writes the changed pages, drops them from the pool and notes the room left by deletes
+func (w *pageWriter) flush() error {
+	pool := w.pool
+	pageids := make([]PageID, 0, len(w.pages))
+	for pageid := range w.pages {
+		pageids = append(pageids, pageid)
+	}
+	slices.Sort(pageids)
+	for _, pageid := range pageids {
+		page := w.pages[pageid]
+		page.seal()
+		if _, err := pool.tablefileWrite.WriteAt(page[:], int64(pageid)*PAGESIZE); err != nil {
+			return err
+		}
+		pool.pagemx.Lock()
+		pool.deletePage(pageid)
+		pool.pagemx.Unlock()
+
+		i, found := slices.BinarySearchFunc(pool.roomy, pageid, func(r roomyPage, pageid PageID) int { return cmp.Compare(r.page, pageid) })
+		if found {
+			pool.roomy[i].free = page.freeSpace()
+		} else if w.freed[pageid] && pageid < pool.lastPage {
+			pool.roomy = slices.Insert(pool.roomy, i, roomyPage{page: pageid, free: page.freeSpace()})
+		}
+	}
+	if err := pool.overflowFile.Sync(); err != nil {
+		return err
+	}
+	return pool.tablefileWrite.Sync()
 }
 
 func (bm *bufferPoolManager) SelectDataRange(tablename string, start, end PageID) [][]Cell {
@@ -203,10 +347,28 @@ func (bm *bufferPoolManager) SelectDataPages(tablename string, nums []PageID) []
 	return allpages
 }
 
+// fetches a single page of the table, hits and misses are added to stats if not nil
+func (bm *bufferPoolManager) fetchPage(tablename string, pageid PageID, stats *bufferStats) [][]Cell {
+	return bm.allpools[tablename].fetchPage(pageid, stats)
+}
+
+// closes the files of a table and forgets its pages
+func (bm *bufferPoolManager) closePool(tablename string) {
+	pool, ok := bm.allpools[tablename]
+	if !ok {
+		return
+	}
+	pool.tablefileRead.Close()
+	pool.tablefileWrite.Close()
+	pool.overflowFile.Close()
+	delete(bm.allpools, tablename)
+}
+
 func (bm *bufferPoolManager) close() {
 	for _, val := range bm.allpools {
 		val.tablefileRead.Close()
 		val.tablefileWrite.Close()
+		val.overflowFile.Close()
 	}
 }
 
@@ -220,17 +382,169 @@ type bufferPool struct {
 	tablefileWrite *os.File
 	lru            LRU
 	columns        []Column
+	lastPage       PageID      //guarded by mxwrite
+	roomy          []roomyPage //pages with room left by deletes sorted by page, guarded by mxwrite
+	overflowFile   *os.File
+	overflowPages  PageID   //pages in the overflow file, guarded by mxwrite
+	freeOverflow   []PageID //overflow pages not in a chain, guarded by mxwrite
+	missing        []Cell   //value of every column in rows written before it was added, see Backend.addColumn
+	mxpin          *sync.Mutex
+	pinned         map[PageID]int  //open readers of the chain starting at a page, guarded by mxpin
+	released       map[PageID]bool //pinned chains released by a write, freed when their last reader closes, guarded by mxpin
+}
+
+// bufferStats counts page requests served from the pool (hits) and read from disk (misses)
+type bufferStats struct {
+	hits   int64
+	misses int64
+}
+
+/*
+encodes a row the way it is stored on a page, the number of its columns as a uvarint, the null bitset and the columns that aren't null.
+Fixed width columns take their size and VARCHAR, TEXT and BLOB values are prefixed with their length.
+The largest values move to overflow pages until the row fits on a page
+*/
+func (w *pageWriter) encodeRow(row []Cell) ([]byte, error) {
+	columns := w.pool.columns
+	nullColumns := InitializeBitSet(uint64(len(columns)))
+	overflow := make([]bool, len(row))
+	size := rowHeaderSize(len(columns))
+	for j := range row {
+		switch {
+		case row[j] == nil:
+		case !columns[j].isVariable():
+			size += int(columns[j].columnSize)
+		case len(row[j]) >= overflowMarker:
+			overflow[j] = true
+			size += overflowPointerSize
+		default:
+			size += 2 + len(row[j])
+		}
+	}
+	for size > maxRowSize {
+		largest := -1
+		for j := range row {
+			if columns[j].isVariable() && !overflow[j] && 2+len(row[j]) > overflowPointerSize && (largest == -1 || len(row[j]) > len(row[largest])) {
+				largest = j
+			}
+		}
+		if largest == -1 {
+			return nil, fmt.Errorf("row of %d bytes is larger than the maximum row size of %d", size, maxRowSize)
+		}
+		overflow[largest] = true
+		size += overflowPointerSize - 2 - len(row[largest])
+	}
+
+	newrow := binary.AppendUvarint(make([]byte, 0, size), uint64(len(columns)))
+	bitsetStart := len(newrow)
+	newrow = append(newrow, nullColumns.bytes...)
+	for j := range row {
+		if row[j] == nil {
+			continue
+		}
+		nullColumns.setBit(j)
+		if overflow[j] {
+			first, err := w.pool.writeOverflow(row[j])
+			if err != nil {
+				return nil, err
+			}
+			newrow = binary.LittleEndian.AppendUint16(newrow, overflowMarker)
+			newrow = binary.LittleEndian.AppendUint64(newrow, uint64(len(row[j])))
+			newrow = binary.LittleEndian.AppendUint64(newrow, uint64(first))
+			continue
+		}
+		if columns[j].isVariable() {
+			newrow = binary.LittleEndian.AppendUint16(newrow, uint16(len(row[j])))
+			newrow = append(newrow, row[j]...)
+			continue
+		}
+		offset := len(newrow)
+		newrow = append(newrow, make([]byte, columns[j].columnSize)...)
+		copy(newrow[offset:], row[j])
+	}
+	copy(newrow[bitsetStart:], nullColumns.bytes)
+	return newrow, nil
+}
+
+// bytes a row of columns starts with, the number of its columns and the null bitset
+func rowHeaderSize(columns int) int {
+	nullColumns := InitializeBitSet(uint64(columns))
+	return len(binary.AppendUvarint(nil, uint64(columns))) + int(nullColumns.Size())
+}
+
+// decodes a row stored on a page reading in the values kept on overflow pages, the other cells point into buf
+func (b *bufferPool) decodeRow(buf []byte) ([]Cell, error) {
+	row, refs := b.splitRow(buf)
+	for _, ref := range refs {
+		var err error
+		if row[ref.column], err = b.readOverflow(ref); err != nil {
+			return nil, err
+		}
+	}
+	return row, nil
+}
+
+/*
+decodes the values stored in the row itself, values kept on overflow pages are left nil and returned as references.
+Columns added to the table after the row was written take their missing value
+*/
+func (b *bufferPool) splitRow(buf []byte) ([]Cell, []overflowRef) {
+	if buf == nil {
+		return nil, nil
+	}
+	count, offset := binary.Uvarint(buf)
+	nullColumns := InitializeBitSet(count)
+	nullColumns.fromBytes(buf[offset : offset+int(nullColumns.Size())])
+	offset += int(nullColumns.Size())
+	row := make([]Cell, len(b.columns))
+	for k := int(count); k < len(b.columns); k++ {
+		row[k] = b.missing[k]
+	}
+	var refs []overflowRef
+	for k := range int(count) {
+		if !nullColumns.hasBit(k) {
+			continue
+		}
+		size := int(b.columns[k].columnSize)
+		if b.columns[k].isVariable() {
+			size = int(binary.LittleEndian.Uint16(buf[offset:]))
+			offset += 2
+			if size == overflowMarker {
+				refs = append(refs, overflowRef{
+					column: k,
+					length: binary.LittleEndian.Uint64(buf[offset:]),
+					page:   PageID(binary.LittleEndian.Uint64(buf[offset+8:])),
+				})
+				offset += overflowPointerSize - 2
+				continue
+			}
+		}
+		row[k] = Cell(buf[offset : offset+size : offset+size])
+		offset += size
+	}
+	return row, refs
 }
 
 // returns copy of cell rows
 func (b *bufferPool) FetchPage(pageid PageID) [][]Cell {
+	return b.fetchPage(pageid, nil)
+}
+
+// same as FetchPage and records whether the page was already in the pool when stats is not nil
+func (b *bufferPool) fetchPage(pageid PageID, stats *bufferStats) [][]Cell {
 	b.pagemx.RLock()
 	pagepos, ok := b.lru.findNum(pageid)
 	if ok {
 		tmprows := b.slots[pagepos].returnClone()
 		b.pagemx.RUnlock()
+		if stats != nil {
+			stats.hits++
+		}
 		return tmprows
 	}
+	if stats != nil {
+		stats.misses++
+	}
 	//getslotindex and allocate page from buffer if none free free LRU slot
 	b.pagemx.RUnlock()
 	b.pagemx.Lock()
@@ -238,7 +552,7 @@ func (b *bufferPool) FetchPage(pageid PageID) [][]Cell {
 	pos, ok := b.lru.addNum(pageid)
 	if !ok {
 		pos = b.lru.freeNum(pageid)
-		b.slots[pos] = nil //frees' the slice in that slot
+		b.slots[pos] = new(internalSlots) //frees the rows of the page held in that slot
 	}
 	err := b.AllocatePage(pageid, pos)
 	if err != nil {
@@ -261,7 +575,7 @@ func (b *bufferPool) rawFetchPage(pageid PageID) ([PAGESIZE]byte, error) {
 	pos, ok := b.lru.addNum(pageid)
 	if !ok {
 		pos = b.lru.freeNum(pageid)
-		b.slots[pos] = nil //frees' the slice in that slot
+		b.slots[pos] = new(internalSlots) //frees the rows of the page held in that slot
 	}
 	err := b.AllocatePage(pageid, pos)
 	if err != nil {
@@ -292,38 +606,15 @@ func (b *bufferPool) AllocatePage(pageid PageID, pos int) error {
 	if !bytes.Equal(checksum, checksumcheck[:]) {
 		return fmt.Errorf("page %d has been corrupted", pageid)
 	}
+	page := (*heapPage)(&b.slots[pos].buf)
 	rows := make([][]Cell, 0, rowNums)
-	offset := 26
-	numrows := 0
-	rowsize := 0
-	for i := range b.columns {
-		rowsize += int(b.columns[i].columnSize)
-	}
-	bitset := InitializeBitSet(uint64(len(b.columns) + 1))
-	bitsetsize := bitset.Size()
-
-	for ; offset < (PAGESIZE)-int(uint64(rowsize)+(bitsetsize)); offset += (int(rowsize) + int(bitsetsize)) {
-		row := make([]Cell, len(b.columns))
-		tmprow := buf[offset : offset+int(rowsize)+int(bitsetsize)]
-		var rowbitset BitSet
-		rowbitset.fromBytes(tmprow[:bitsetsize])
-		if !rowbitset.hasBit(len(b.columns) + 1) {
-			continue
-		}
-
-		for k, col := range b.columns {
-			if !rowbitset.hasBit(col.columnIndex) {
-				row[k] = nil
-				continue
+	for slot := range page.slotCount() {
+		if raw := page.row(slot); raw != nil {
+			row, err := b.decodeRow(raw)
+			if err != nil {
+				return err
 			}
-			celloffset := int(bitsetsize) + col.columnOffset
-			row[k] = Cell(tmprow[celloffset : celloffset+int(col.columnSize) : celloffset+int(col.columnSize)])
-		}
-		rows = append(rows, row)
-
-		numrows++
-		if numrows >= int(rowNums) {
-			break
+			rows = append(rows, row)
 		}
 	}
 
@@ -355,6 +646,19 @@ func (b *bufferPool) deletePage(num PageID) {
 	b.slots[pos].rows = nil
 }
 
+// switches the pool to the columns of its table after one was added, the pages decoded with the old columns are dropped
+func (b *bufferPool) setColumns(columns []Column) {
+	b.pagemx.Lock()
+	defer b.pagemx.Unlock()
+	for i := range b.lru.buffers {
+		if num := b.lru.buffers[i].num; num != -1 {
+			b.deletePage(num)
+		}
+	}
+	b.columns = columns
+	b.missing = missingCells(columns)
+}
+
 type internalSlots struct {
 	rows [][]Cell
 	buf  [PAGESIZE]byte
@@ -365,6 +669,9 @@ func (is *internalSlots) returnClone() [][]Cell {
 	for i := range is.rows {
 		row := make([]Cell, len(is.rows[i]))
 		for k := range row {
+			if is.rows[i][k] == nil { //keeps null cells null
+				continue
+			}
 			cloneCell := make(Cell, len(is.rows[i][k]))
 			copy(cloneCell, is.rows[i][k])
 			row[k] = cloneCell
@@ -2,7 +2,10 @@ package internal
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"math"
+	"time"
 )
 
 /*
@@ -10,7 +13,16 @@ Contain functions to convert byte slice to appropriate datatype as supported by
 */
 type Cell []byte
 
+// AsInt reads a signed integer of the width of the cell
 func (c *Cell) AsInt() int64 {
+	switch len(*c) {
+	case 1:
+		return int64(int8((*c)[0]))
+	case 2:
+		return int64(int16(binary.LittleEndian.Uint16(*c)))
+	case 4:
+		return int64(int32(binary.LittleEndian.Uint32(*c)))
+	}
 	return int64(binary.LittleEndian.Uint64(*c))
 }
 
@@ -25,3 +37,128 @@ func (c *Cell) AsBool() bool {
 func (c *Cell) AsString() string {
 	return string(*c)
 }
+
+// newCell encodes a go value the same way a column of the matching type stores it, nil stays a null cell
+func newCell(v any) Cell {
+	switch n := v.(type) {
+	case int64:
+		return binary.LittleEndian.AppendUint64(nil, uint64(n))
+	case uint64:
+		return binary.LittleEndian.AppendUint64(nil, n)
+	case float64:
+		return binary.LittleEndian.AppendUint64(nil, math.Float64bits(n))
+	case bool:
+		if n {
+			return Cell{1}
+		}
+		return Cell{0}
+	case string:
+		return Cell(n)
+	case time.Time:
+		return temporalCell(TIMESTAMP, n)
+	case interval:
+		return intervalCell(n)
+	case decimal:
+		return decimalCell(n, 16)
+	case jsonDoc:
+		return Cell(n)
+	case uuid:
+		return Cell(n[:])
+	}
+	return nil
+}
+
+// valueCell converts a value computed by an expression to a cell of col, strings are parsed for columns of other types
+func valueCell(col *Column, v any) (Cell, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if s, ok := v.(string); ok && !col.isString() && col.columnType != JSON {
+		var like any
+		switch col.columnType {
+		case INT, UINT:
+			like = int64(0)
+		case FLOAT:
+			like = float64(0)
+		case BOOL:
+			like = false
+		case DATE, TIME, TIMESTAMP:
+			like = time.Time{}
+		case INTERVAL:
+			like = interval{}
+		case DECIMAL:
+			like = decimal{}
+		case UUID:
+			like = uuid{}
+		}
+		converted, err := convertString(s, like)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert '%s' for column %s", s, col.columnName)
+		}
+		v = converted
+	}
+
+	switch col.columnType {
+	case INT, UINT:
+		switch v.(type) {
+		case int64, uint64:
+			return integerCell(col, v)
+		}
+	case FLOAT:
+		if f, ok := toFloat(v); ok {
+			return newCell(f), nil
+		}
+	case BOOL:
+		if b, ok := v.(bool); ok {
+			return newCell(b), nil
+		}
+	case CHAR, VARCHAR:
+		if s, ok := v.(string); ok {
+			if len(s) > int(col.columnSize) {
+				return nil, errors.New("string to insert larger than allowed")
+			}
+			return newCell(s), nil
+		}
+	case TEXT, BLOB:
+		if s, ok := v.(string); ok {
+			return newCell(s), nil
+		}
+	case DATE, TIME, TIMESTAMP:
+		if t, ok := v.(time.Time); ok {
+			return temporalCell(col.columnType, t), nil
+		}
+	case INTERVAL:
+		if iv, ok := v.(interval); ok {
+			return intervalCell(iv), nil
+		}
+	case UUID:
+		if u, ok := toUUID(v); ok {
+			return newCell(u), nil
+		}
+	case JSON:
+		doc, ok := v.(jsonDoc)
+		if s, isString := v.(string); isString { //text is validated
+			var err error
+			if doc, err = parseJSON(s); err != nil {
+				return nil, fmt.Errorf("column %s: %w", col.columnName, err)
+			}
+		} else if !ok {
+			buf, err := appendJSON(nil, v)
+			if err != nil {
+				return nil, err
+			}
+			doc = jsonDoc(buf)
+		}
+		return Cell(doc), nil
+	case DECIMAL:
+		switch v.(type) {
+		case int64, float64, decimal:
+			d, err := toDecimal(v, col.columnPrecision, col.columnScale)
+			if err != nil {
+				return nil, err
+			}
+			return decimalCell(d, int(col.columnSize)), nil
+		}
+	}
+	return nil, fmt.Errorf("value %v does not fit the type of column %s", v, col.columnName)
+}
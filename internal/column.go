@@ -1,24 +1,33 @@
 package internal
 
+import (
+	"encoding/binary"
+	"fmt"
+)
+
 const (
-	COL_ISUNIQUE  = 1 << 0
-	COL_ISNULL    = 1 << 1
-	COL_ISPRIMARY = 1 << 2
+	COL_ISUNIQUE    = 1 << 0
+	COL_ISNULL      = 1 << 1
+	COL_ISPRIMARY   = 1 << 2
+	COL_HASDEFAULT  = 1 << 3
+	COL_ISGENERATED = 1 << 4
 )
 
 // ColumnType values are define in parser.go
 //
 // Column values should always immutable after first creation
-// TODO: change allocate buffer to not use columnoffset
 type Column struct {
 	columnName       string //max size is maxuint8
 	columnType       uint8  //data type
-	columnSize       uint8  //size of column in database in bytes
+	columnSize       uint16 //size of column in database in bytes, the most bytes of a VARCHAR and 0 for TEXT and BLOB
+	columnPrecision  uint8  //digits of a DECIMAL
+	columnScale      uint8  //digits after the point of a DECIMAL
 	columnIsUnique   bool
 	columnIsNullable bool
 	columnIsPrimary  bool
-	columnOffset     int //dynamic at runtime
-	columnIndex      int //dynamic at runtime
+	columnDefault    Expr //value of the column when left out of an INSERT, nil if it has none
+	columnGenerated  Expr //computed value of a GENERATED ALWAYS AS column, nil for other columns
+	columnIndex      int  //dynamic at runtime
 }
 
 func (c *Column) toBytes() []byte {
@@ -35,7 +44,26 @@ func (c *Column) toBytes() []byte {
 	if c.columnIsPrimary {
 		constraintNum |= COL_ISPRIMARY
 	}
-	allbytes = append(allbytes, c.columnType, c.columnSize, constraintNum)
+	if c.columnDefault != nil {
+		constraintNum |= COL_HASDEFAULT
+	}
+	if c.columnGenerated != nil {
+		constraintNum |= COL_ISGENERATED
+	}
+	allbytes = append(allbytes, c.columnType)
+	allbytes = binary.LittleEndian.AppendUint16(allbytes, c.columnSize)
+	if c.columnType == DECIMAL {
+		allbytes = append(allbytes, c.columnPrecision, c.columnScale)
+	}
+	allbytes = append(allbytes, constraintNum)
+	//expressions are stored as sql text each prefixed with its length
+	for _, expr := range []Expr{c.columnDefault, c.columnGenerated} {
+		if expr != nil {
+			text := FormatExpr(expr)
+			allbytes = binary.LittleEndian.AppendUint16(allbytes, uint16(len(text)))
+			allbytes = append(allbytes, text...)
+		}
+	}
 	return allbytes
 }
 
@@ -49,33 +77,95 @@ func (c *Column) fromBytes(colBytes []byte) int {
 	offset += int(lengthName)
 	c.columnType = colBytes[offset]
 	offset++
-	c.columnSize = colBytes[offset]
-	offset++
+	c.columnSize = binary.LittleEndian.Uint16(colBytes[offset:])
+	offset += 2
+	if c.columnType == DECIMAL {
+		c.columnPrecision, c.columnScale = colBytes[offset], colBytes[offset+1]
+		offset += 2
+	}
 	constraintNum := colBytes[offset]
 	offset++
 	c.columnIsUnique = (constraintNum & COL_ISUNIQUE) > 0
 	c.columnIsNullable = (constraintNum & COL_ISNULL) > 0
 	c.columnIsPrimary = (constraintNum & COL_ISPRIMARY) > 0
+	for _, expr := range []struct {
+		flag byte
+		dst  *Expr
+	}{{COL_HASDEFAULT, &c.columnDefault}, {COL_ISGENERATED, &c.columnGenerated}} {
+		if constraintNum&expr.flag == 0 {
+			continue
+		}
+		size := int(binary.LittleEndian.Uint16(colBytes[offset:]))
+		offset += 2
+		parsed, err := ParseExpr(string(colBytes[offset : offset+size]))
+		if err != nil {
+			panic(fmt.Sprintf("catalog holds invalid expression for column %s: %v", c.columnName, err))
+		}
+		*expr.dst = parsed
+		offset += size
+	}
 	return offset
 }
 
+// VARCHAR, TEXT and BLOB values are stored with their length in front of them
+func (c *Column) isVariable() bool {
+	return c.columnType == VARCHAR || c.columnType == TEXT || c.columnType == BLOB || c.columnType == JSON
+}
+
+// whether the column holds strings, values of string columns can be compared and referenced by each other
+func (c *Column) isString() bool {
+	return c.columnType == CHAR || c.columnType == VARCHAR || c.columnType == TEXT || c.columnType == BLOB
+}
+
+// bytes a value takes in a row, VARCHAR, TEXT and BLOB values are assumed to be 32 bytes at most
+func (c *Column) width() int {
+	if !c.isVariable() {
+		return int(c.columnSize)
+	}
+	if c.columnType == VARCHAR {
+		return 2 + min(int(c.columnSize), 32)
+	}
+	return 2 + 32
+}
+
+// whether the default of the column has the same value every time, ie. calls no function like NOW(). No default is always NULL
+func (c *Column) fixedDefault() bool {
+	called := false
+	walkExpr(c.columnDefault, func(e Expr) bool {
+		_, called = e.(*FuncCall)
+		return !called
+	})
+	return !called
+}
+
+// value of the columns in a row written before they were added, the fixed default or NULL
+func missingCells(columns []Column) []Cell {
+	cells := make([]Cell, len(columns))
+	for i := range columns {
+		col := &columns[i]
+		if col.columnDefault == nil || !col.fixedDefault() {
+			continue //a column with another default was written to every row when added
+		}
+		if value, err := evalConstant(col.columnDefault); err == nil { //defaults are checked to fit when the column is added
+			cells[i], _ = valueCell(col, value)
+		}
+	}
+	return cells
+}
+
 type ResultColumn struct {
 	ColumnType uint8
 	Name       string
 	columnPos  int
+	scale      uint8   //digits after the point of a DECIMAL column
+	expr       Expr    //expression of a computed column, nil for a column of the table or an aggregate computed below the project
+	computed   *Column //column the values of expr are stored as
 }
-type insertType uint8
-
-const (
-	COL_I_PRIMARYNULL insertType = iota
-	COL_I_PRIMARYVALUED
-	COL_I_VALUED
-	COL_I_NULL
-)
 
-type InsertColumn struct {
-	columnSize  uint8
-	colType     insertType
-	dataType    uint8
-	insertIndex int //index of incoming Values slice
+// column of table the values of the result column are read as
+func (c *ResultColumn) column(table *Table) *Column {
+	if c.computed != nil {
+		return c.computed
+	}
+	return &table.Columns[c.columnPos]
 }
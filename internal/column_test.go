@@ -47,6 +47,37 @@ func TestColumnConversion(t *testing.T) {
 				columnIsNullable: true,
 				columnIsPrimary:  true,
 			}},
+		{"Varchar column wider than a byte",
+			Column{
+				columnName:       "body",
+				columnType:       VARCHAR,
+				columnSize:       1000,
+				columnIsNullable: true,
+			}},
+		{"Decimal column keeps precision and scale",
+			Column{
+				columnName:       "total",
+				columnType:       DECIMAL,
+				columnSize:       8,
+				columnPrecision:  12,
+				columnScale:      2,
+				columnIsNullable: true,
+			}},
+		{"Column with a default",
+			Column{
+				columnName:       "n",
+				columnType:       INT,
+				columnSize:       8,
+				columnIsNullable: true,
+				columnDefault:    &BinaryExpr{Operator: Minus, Left: &Literal{Kind: NumberLiteral, Value: "1"}, Right: &Literal{Kind: NumberLiteral, Value: "2"}},
+			}},
+		{"Generated column",
+			Column{
+				columnName:      "total",
+				columnType:      FLOAT,
+				columnSize:      8,
+				columnGenerated: &BinaryExpr{Operator: Multiply, Left: &Identifier{Name: "price"}, Right: &Identifier{Name: "qty"}},
+			}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
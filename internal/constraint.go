@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ConstraintError is returned when a write would break a constraint of a table, nothing of the write is stored
+type ConstraintError struct {
+	Kind   ConstraintKind
+	Table  string
+	Column string // violating column, columns of a composite key are separated by commas
+	Name   string // index enforcing a UNIQUE constraint or name of a CHECK or FOREIGN KEY constraint, empty otherwise
+	Parent string // table referenced by a FOREIGN KEY
+}
+
+func (e *ConstraintError) Error() string {
+	column := "column " + e.Column
+	if strings.Contains(e.Column, ",") {
+		column = "columns " + e.Column
+	}
+	switch e.Kind {
+	case NotNullConstraint:
+		return fmt.Sprintf("null value in %s of table %s violates not null constraint", column, e.Table)
+	case PrimaryKeyConstraint:
+		return fmt.Sprintf("duplicate key value in %s of table %s violates primary key", column, e.Table)
+	case UniqueConstraint:
+		return fmt.Sprintf("duplicate key value in %s of table %s violates unique index %q", column, e.Table, e.Name)
+	case CheckConstraint:
+		return fmt.Sprintf("row of table %s violates check constraint %q", e.Table, e.Name)
+	case ForeignKeyConstraint:
+		return fmt.Sprintf("%s of table %s violates foreign key %q referencing table %s", column, e.Table, e.Name, e.Parent)
+	}
+	return fmt.Sprintf("%s of table %s violates a constraint", column, e.Table)
+}
+
+// checks that row has a value for every column that isn't nullable
+func (t *Table) checkNotNull(row []Cell) error {
+	for i := range t.Columns {
+		if row[i] == nil && !t.Columns[i].columnIsNullable {
+			return &ConstraintError{Kind: NotNullConstraint, Table: t.Name, Column: t.Columns[i].columnName}
+		}
+	}
+	return nil
+}
+
+// checkDef is a CHECK constraint of a table, stored in the catalog as sql text
+type checkDef struct {
+	name string
+	expr Expr
+}
+
+func (c *checkDef) toBytes() []byte {
+	text := FormatExpr(c.expr)
+	buf := make([]byte, 1, 3+len(c.name)+len(text))
+	buf[0] = uint8(len(c.name))
+	buf = append(buf, c.name...)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(text)))
+	return append(buf, text...)
+}
+
+// returns number of bytes read
+func (c *checkDef) fromBytes(buf []byte) (int, error) {
+	if len(buf) < 1 || len(buf) < 3+int(buf[0]) {
+		return 0, errors.New("catalog holds a truncated check constraint")
+	}
+	nameSize := int(buf[0])
+	c.name = string(buf[1 : 1+nameSize])
+	offset := 1 + nameSize
+	size := int(binary.LittleEndian.Uint16(buf[offset:]))
+	offset += 2
+	if len(buf) < offset+size {
+		return 0, fmt.Errorf("catalog holds a truncated check constraint %s", c.name)
+	}
+	expr, err := ParseExpr(string(buf[offset : offset+size]))
+	if err != nil {
+		return 0, fmt.Errorf("catalog holds invalid check constraint %s: %v", c.name, err)
+	}
+	c.expr = expr
+	return offset + size, nil
+}
+
+// longest name of a constraint or index, the catalog stores names after a one byte length
+const maxNameSize = 254
+
+// shortens a generated name so reserve more bytes still fit in maxNameSize,
+// bytes are cut in front of its last part so the name keeps its _check, _key or _fkey ending
+func fitName(name string, reserve int) string {
+	size := maxNameSize - reserve
+	if len(name) <= size {
+		return name
+	}
+	tail := ""
+	if i := strings.LastIndexByte(name, '_'); i > 0 && len(name)-i < size {
+		tail = name[i:]
+	}
+	head := name[:size-len(tail)]
+	for len(head) > 0 && !utf8.RuneStart(name[len(head)]) {
+		head = head[:len(head)-1]
+	}
+	return head + tail
+}
+
+// fits a generated name in maxNameSize, numbering it while taken reports the name in use
+func uniqueName(name string, taken func(string) bool) string {
+	unique := fitName(name, 0)
+	for i := 1; taken(unique); i++ {
+		number := strconv.Itoa(i)
+		unique = fitName(name, len(number)) + number
+	}
+	return unique
+}
+
+// appends a check named name, numbering the name when an earlier check already uses it
+func addCheck(checks []checkDef, name string, expr Expr) []checkDef {
+	unique := uniqueName(name, func(name string) bool {
+		return slices.ContainsFunc(checks, func(c checkDef) bool { return c.name == name })
+	})
+	return append(checks, checkDef{name: unique, expr: expr})
+}
+
+// checks that no CHECK constraint of the table is FALSE for row, NULL satisfies a check
+func (t *Table) checkRow(row []Cell) error {
+	if len(t.checks) == 0 {
+		return nil
+	}
+	scope := newRowScope(t, nil)
+	for _, check := range t.checks {
+		v, err := scope.eval(check.expr, row)
+		if err != nil {
+			return err
+		}
+		b, ok := v.(bool)
+		if v != nil && !ok {
+			return fmt.Errorf("check constraint %q is not a boolean", check.name)
+		}
+		if ok && !b {
+			return &ConstraintError{Kind: CheckConstraint, Table: t.Name, Name: check.name}
+		}
+	}
+	return nil
+}
+
+// name of the index created for a UNIQUE column of table, numbered when another index already uses the name
+func (b *Backend) uniqueIndexName(table *Table, column string) string {
+	return uniqueName(table.Name+"_"+column+"_key", func(name string) bool {
+		_, _, exists := b.findIndex(name)
+		return exists || slices.ContainsFunc(table.indexes, func(d indexDef) bool { return d.name == name })
+	})
+}
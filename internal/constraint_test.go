@@ -0,0 +1,211 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstraints(t *testing.T) {
+	dir := t.TempDir()
+	b := CreateNewDatabase(dir)
+	for _, sql := range []string{
+		"CREATE TABLE t (id int primary key, email char(20) unique, n int not null);",
+		"INSERT INTO t (id, email, n) VALUES (1, 'a@x', 1), (2, NULL, 2), (3, NULL, 1 + 2);",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	require.Equal(t, []indexDef{{name: "t_email_key", unique: true, constraint: true, columns: []int{1}}}, b.tables[0].indexes)
+	require.FileExists(t, indexFile(dir, "t", "t_email_key"))
+
+	violations := []struct {
+		SQL      string
+		Expected ConstraintError
+	}{
+		{"INSERT INTO t (id, email, n) VALUES (4, 'b@x', 4), (5, 'a@x', 5);", ConstraintError{Kind: UniqueConstraint, Table: "t", Column: "email", Name: "t_email_key"}},
+		{"INSERT INTO t (id, email, n) VALUES (4, 'b@x', NULL);", ConstraintError{Kind: NotNullConstraint, Table: "t", Column: "n"}},
+		{"INSERT INTO t (id, email) VALUES (4, 'b@x');", ConstraintError{Kind: NotNullConstraint, Table: "t", Column: "n"}},
+		{"INSERT INTO t (id, email, n) VALUES (NULL, 'b@x', 4);", ConstraintError{Kind: NotNullConstraint, Table: "t", Column: "id"}},
+		{"UPDATE t SET id = 3 WHERE id = 2;", ConstraintError{Kind: PrimaryKeyConstraint, Table: "t", Column: "id"}},
+		{"UPDATE t SET email = 'a@x' WHERE id = 2;", ConstraintError{Kind: UniqueConstraint, Table: "t", Column: "email", Name: "t_email_key"}},
+		{"UPDATE t SET n = NULL WHERE id > 1;", ConstraintError{Kind: NotNullConstraint, Table: "t", Column: "n"}},
+	}
+	for _, tc := range violations {
+		t.Run(tc.SQL, func(t *testing.T) {
+			_, err := execSQL(b, tc.SQL)
+			var cerr *ConstraintError
+			require.ErrorAs(t, err, &cerr)
+			require.Equal(t, tc.Expected, *cerr)
+		})
+	}
+	require.Equal(t, [][]any{{int64(1), "a@x", int64(1)}, {int64(2), nil, int64(2)}, {int64(3), nil, int64(3)}},
+		trimRows(queryRows(t, b, "SELECT id, email, n FROM t WHERE id > 0;")), "nothing of a failing write is stored")
+
+	_, err := execSQL(b, "DROP INDEX t_email_key;")
+	require.EqualError(t, err, `cannot drop index "t_email_key", it enforces the UNIQUE constraint of column email`)
+	_, err = execSQL(b, "INSERT INTO t (id, n) VALUES (4, n);")
+	require.EqualError(t, err, `insert: column "n" cannot be used in a constant expression`)
+	b.Close()
+
+	b, err = OpenExistingDatabase(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	_, err = execSQL(b, "UPDATE t SET email = 'a@x' WHERE id = 3;")
+	require.EqualError(t, err, `duplicate key value in column email of table t violates unique index "t_email_key"`)
+	_, err = execSQL(b, "UPDATE t SET email = 'c@x' WHERE id = 1;")
+	require.NoError(t, err)
+	require.Equal(t, [][]any{{int64(1)}}, queryRows(t, b, "SELECT id FROM t WHERE email = 'c@x';"))
+}
+
+func TestDefaultsAndGenerated(t *testing.T) {
+	dir := t.TempDir()
+	b := CreateNewDatabase(dir)
+	for _, sql := range []string{
+		"CREATE TABLE t (id int primary key, qty int default 1 not null, price float default 2.5, total float generated always as (qty * price) stored);",
+		"INSERT INTO t (id) VALUES (1);",
+		"INSERT INTO t (id, qty, price) VALUES (2, 4, 1.5), (3, 2, NULL);",
+		"UPDATE t SET qty = qty + 1 WHERE id = 2;",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	expected := [][]any{{int64(1), int64(1), 2.5, 2.5}, {int64(2), int64(5), 1.5, 7.5}, {int64(3), int64(2), nil, nil}}
+	require.Equal(t, expected, queryRows(t, b, "SELECT id, qty, price, total FROM t WHERE id > 0;"))
+
+	errs := []struct {
+		SQL      string
+		Expected string
+	}{
+		{"INSERT INTO t (id, total) VALUES (4, 1.0);", "cannot insert a value into generated column total"},
+		{"UPDATE t SET total = 1.0 WHERE id = 1;", "cannot update generated column total"},
+		{"CREATE TABLE u (id int primary key, n int default 1 generated always as (id) stored);", "both default and generation expression specified for column n"},
+		{"CREATE TABLE u (id int primary key, n int default 'x');", "invalid default for column n: cannot convert 'x' for column n"},
+		{"CREATE TABLE u (id int primary key, n int default id);", `invalid default for column n: column "id" cannot be used in a constant expression`},
+		{"CREATE TABLE u (id int primary key, n int generated always as (m) stored, m int generated always as (id) stored);", "generation expression of column n cannot use generated column m"},
+	}
+	for _, tc := range errs {
+		t.Run(tc.SQL, func(t *testing.T) {
+			_, err := execSQL(b, tc.SQL)
+			require.EqualError(t, err, tc.Expected)
+		})
+	}
+	b.Close()
+
+	b, err := OpenExistingDatabase(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	_, err = execSQL(b, "INSERT INTO t (id, qty) VALUES (4, 3);")
+	require.NoError(t, err)
+	require.Equal(t, [][]any{{int64(4), int64(3), 2.5, 7.5}}, queryRows(t, b, "SELECT id, qty, price, total FROM t WHERE id = 4;"))
+}
+
+func TestCheckConstraints(t *testing.T) {
+	dir := t.TempDir()
+	b := CreateNewDatabase(dir)
+	for _, sql := range []string{
+		"CREATE TABLE t (id int primary key, price float check (price >= 0), status char(1) check (status in ('a', 'b')), check (price < 100 or status = 'b'));",
+		"INSERT INTO t (id, price, status) VALUES (1, 10.0, 'a'), (2, 200.0, 'b'), (3, NULL, NULL);",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	violations := []struct {
+		SQL  string
+		Name string
+	}{
+		{"INSERT INTO t (id, price, status) VALUES (4, -1.0, 'a');", "t_price_check"},
+		{"INSERT INTO t (id, price, status) VALUES (4, 1.0, 'c');", "t_status_check"},
+		{"INSERT INTO t (id, price, status) VALUES (4, 1.0, 'a'), (5, 150.0, 'a');", "t_check"},
+		{"UPDATE t SET status = 'a' WHERE id = 2;", "t_check"},
+		{"UPDATE t SET price = price - 20 WHERE id > 0;", "t_price_check"},
+	}
+	for _, tc := range violations {
+		t.Run(tc.SQL, func(t *testing.T) {
+			_, err := execSQL(b, tc.SQL)
+			var cerr *ConstraintError
+			require.ErrorAs(t, err, &cerr)
+			require.Equal(t, ConstraintError{Kind: CheckConstraint, Table: "t", Name: tc.Name}, *cerr)
+		})
+	}
+	require.Equal(t, [][]any{{int64(1), 10.0}, {int64(2), 200.0}, {int64(3), nil}}, queryRows(t, b, "SELECT id, price FROM t WHERE id > 0;"))
+
+	_, err := execSQL(b, "CREATE TABLE u (id int primary key, check (n > 0));")
+	require.EqualError(t, err, "invalid check constraint u_check: Columns not in table: n")
+	_, err = execSQL(b, "CREATE TABLE u (id int primary key check (id + 1));")
+	require.NoError(t, err)
+	_, err = execSQL(b, "INSERT INTO u (id) VALUES (1);")
+	require.EqualError(t, err, `check constraint "u_id_check" is not a boolean`)
+	b.Close()
+
+	b, err = OpenExistingDatabase(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	_, err = execSQL(b, "UPDATE t SET price = -1.0 WHERE id = 3;")
+	require.EqualError(t, err, `row of table t violates check constraint "t_price_check"`)
+	_, err = execSQL(b, "UPDATE t SET price = 1.0 WHERE id = 3;")
+	require.NoError(t, err)
+}
+
+func TestLongCheckNames(t *testing.T) {
+	dir := t.TempDir()
+	b := CreateNewDatabase(dir)
+	table, column := "t"+strings.Repeat("a", 200), `"c`+strings.Repeat("é", 100)+`"`
+	_, err := execSQL(b, "CREATE TABLE "+table+" (id int primary key, "+column+" int check ("+column+" > 0) check ("+column+" < 10));")
+	require.NoError(t, err)
+	names := []string{}
+	for _, check := range b.tables[0].checks {
+		require.LessOrEqual(t, len(check.name), maxNameSize)
+		require.True(t, utf8.ValidString(check.name))
+		names = append(names, check.name)
+	}
+	require.Len(t, names, 2)
+	require.True(t, strings.HasSuffix(names[0], "_check"))
+	require.True(t, strings.HasSuffix(names[1], "_check1"), "a second check on the column is numbered")
+	b.Close()
+
+	b, err = OpenExistingDatabase(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	for i, value := range []string{"0", "10"} {
+		_, err = execSQL(b, "INSERT INTO "+table+" (id, "+column+") VALUES (1, "+value+");")
+		var cerr *ConstraintError
+		require.ErrorAs(t, err, &cerr)
+		require.Equal(t, names[i], cerr.Name)
+	}
+}
+
+func TestUniqueIndexNames(t *testing.T) {
+	dir := t.TempDir()
+	b := CreateNewDatabase(dir)
+	for _, sql := range []string{
+		"CREATE TABLE a_b (id int primary key, c int unique);",
+		"CREATE TABLE a (id int primary key, b_c int unique);",
+		"CREATE INDEX x_y_z_key ON a (id);",
+		"CREATE TABLE x (id int primary key, y int);",
+		"ALTER TABLE x ADD COLUMN z int unique;",
+		"ALTER TABLE x ADD COLUMN y_z int unique;",
+		"INSERT INTO a_b (id, c) VALUES (1, 1);",
+		"INSERT INTO a (id, b_c) VALUES (1, 1);",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	require.Equal(t, "a_b_c_key", b.tables[0].indexes[0].name)
+	require.Equal(t, "a_b_c_key1", b.tables[1].indexes[0].name)
+	require.Equal(t, []string{"x_z_key", "x_y_z_key1"}, []string{b.tables[2].indexes[0].name, b.tables[2].indexes[1].name})
+	long := strings.Repeat("a", 250) + "_" + strings.Repeat("é", 20) + "_key"
+	require.Equal(t, long[:maxNameSize-4]+"_key", uniqueName(long, func(string) bool { return false }))
+	require.Equal(t, long[:maxNameSize-6]+"_key12", uniqueName(long, func(name string) bool { return !strings.HasSuffix(name, "12") }))
+	b.Close()
+
+	b, err := OpenExistingDatabase(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	_, err = execSQL(b, "INSERT INTO a (id, b_c) VALUES (2, 1);")
+	require.EqualError(t, err, `insert: duplicate key value in column b_c of table a violates unique index "a_b_c_key1"`)
+	_, err = execSQL(b, "INSERT INTO a_b (id, c) VALUES (2, 1);")
+	require.EqualError(t, err, `insert: duplicate key value in column c of table a_b violates unique index "a_b_c_key"`)
+}
@@ -15,7 +15,10 @@ import (
 	"sync"
 )
 
-//write indexing connection and fix data types for uint
+//write indexing connection
+
+// file of the system catalog holding the definition of every table
+const CATALOGFILE = "main.db"
 
 type Backend struct {
 	dir        string
@@ -27,9 +30,10 @@ func CreateNewDatabase(dir string) *Backend {
 	buf := make([]byte, 100) //reserves first hundred bytes of main file for header
 	headername := []byte("RootDB MAINFILE\x00")
 	copy(buf[0:16], headername)
-	binary.LittleEndian.PutUint16(buf[16:18], uint16(PAGESIZE))
+	binary.LittleEndian.PutUint16(buf[16:18], 0) //number of tables
+	binary.LittleEndian.PutUint16(buf[18:20], uint16(PAGESIZE))
 
-	f, err := os.Create(filepath.Join(dir, "main.db"))
+	f, err := os.Create(filepath.Join(dir, CATALOGFILE))
 	if err != nil {
 		panic("unexpected error creating new main database file")
 	}
@@ -44,7 +48,7 @@ func CreateNewDatabase(dir string) *Backend {
 func OpenExistingDatabase(dir string) (*Backend, error) {
 	b := Backend{dir: dir, bufferPool: NewBufferPoolManager(dir)}
 
-	allContent, err := os.ReadFile(filepath.Join(dir, "main.db"))
+	allContent, err := os.ReadFile(filepath.Join(dir, CATALOGFILE))
 	if os.IsNotExist(err) {
 		return nil, errors.New("database directory was tampered with, main file gone")
 	} else if err != nil {
@@ -52,7 +56,7 @@ func OpenExistingDatabase(dir string) (*Backend, error) {
 	}
 
 	numTables := binary.LittleEndian.Uint16(allContent[16:18])
-	b.tables = make([]Table, numTables+5)
+	b.tables = make([]Table, numTables)
 	if numTables == 0 {
 		return &b, nil
 	}
@@ -60,133 +64,399 @@ func OpenExistingDatabase(dir string) (*Backend, error) {
 	offset := 100
 	for i := 0; i < int(numTables); i++ {
 		newtable := Table{}
-		lenTable := newtable.fromBytes(allContent[offset:])
+		lenTable, err := newtable.fromBytes(allContent[offset:])
+		if err != nil {
+			return nil, err
+		}
 		newtable.GenerateFields()
+		newtable.tableLock = new(sync.RWMutex)
 		offset += lenTable
 		b.tables[i] = newtable
 	}
 
 	for i, tab := range b.tables {
-		lr, lp := b.bufferPool.newPool(tab.Name, b.dir, b.tables[i].Columns)
+		lr, lp := b.bufferPool.newPool(tab.Name, tab.files(), b.dir, b.tables[i].Columns)
 		b.tables[i].lastPage = lp
 		b.tables[i].lastRowId = lr
+		b.tables[i].indices = newIndexManager()
+		if err := b.tables[i].indices.addIndex(b.dir, tab.files(), b.tables[i].primaryIndexName()); err != nil {
+			return nil, err
+		}
+		for _, def := range tab.indexes {
+			if _, err := b.tables[i].indices.addSecondary(b.dir, tab.files(), def.name); err != nil {
+				return nil, err
+			}
+		}
 	}
 
+	if err := b.readStatsFromDisk(); err != nil {
+		return nil, err
+	}
 	return &b, nil
 }
 
 func (b *Backend) CreateTable(q Query) error {
+	stmt, _ := q.Statement.(*CreateTableStatement)
 	_, exists := b.checkTableExist(q)
 	if exists {
+		if stmt != nil && stmt.IfNotExists {
+			return nil
+		}
 		return errors.New("Table already exist")
 	}
-	newtable := Table{lastRowId: 0} //lowest number for primary key must be 1 and nonzero
-	if !(len(q.TableName) > 0 && len(q.TableName) < 255) {
-		return errors.New("table name too large in size")
+	if stmt != nil && stmt.Query != nil {
+		return b.createTableAs(q.TableName, stmt.Query)
+	}
+	newtable := Table{lastRowId: 0} //lowest number handed out to an integer primary key is 1
+	if err := checkTableName(q.TableName); err != nil {
+		return err
 	}
 	newtable.Name = q.TableName
 	newtable.Columns = make([]Column, len(q.Fields))
 	newtable.tableLock = new(sync.RWMutex)
 
-	if len(q.TableConstruction.primary) != 1 {
-		return errors.New("currently only exactly one primary key supported")
-	}
-	var primaryName string
-	for _, field := range q.TableConstruction.fieldsWTypes {
-		if field[0] == q.TableConstruction.primary[0] {
-			primaryName = field[0]
-			if field[1] != "INT" {
-				return errors.New("primary key must be Integer at this moment")
-			}
-		}
+	if len(q.TableConstruction.primary) == 0 {
+		return errors.New("table must have a primary key")
 	}
 
-	totalRowSize := 0
 	for i, construct := range q.TableConstruction.fieldsWTypes {
-		newColumn := Column{}
-		if !(len(construct[0]) > 0 && len(construct[0]) < 255) {
-			return errors.New("table name too large in size")
-		}
-		newColumn.columnName = construct[0]
-		isUnique := slices.Contains(q.TableConstruction.unique, newColumn.columnName)
-		isNotNullable := slices.Contains(q.TableConstruction.notnullable, newColumn.columnName)
-		isNullable := slices.Contains(q.TableConstruction.nullable, newColumn.columnName)
-
-		if isNullable && isNotNullable {
-			return errors.New("column cannot be both nullable and non-nullable")
-		}
-		newColumn.columnIsUnique = isUnique
-		newColumn.columnIsNullable = !isNotNullable
-		switch construct[1] { //Uses reserved types list in parser.go
-		case "INT":
-			newColumn.columnType = INT
-			newColumn.columnSize = 8 //(bytes)
-			if slices.Contains(q.TableConstruction.primary, newColumn.columnName) {
-				newColumn.columnIsPrimary = true
-				newColumn.columnIsUnique = true
-				newColumn.columnIsNullable = false
-			}
-		case "FLOAT":
-			newColumn.columnType = FLOAT
-			newColumn.columnSize = 8 //(bytes)
-		case "BOOL":
-			newColumn.columnType = BOOL
-			newColumn.columnSize = 1 //(bytes)
-		case "CHAR":
-			newColumn.columnType = CHAR
-			if len(construct) != 3 {
-				return errors.New("size needed for char field in table")
-			}
-			fieldSize, err := strconv.ParseInt(construct[2], 10, 64)
-			if err != nil {
-				return errors.Join(errors.New("error in table construction of size of CHAR field: "), err)
-			}
-			if !(fieldSize >= 1 && fieldSize <= 255) {
-				return errors.New("size for char field must be between 1 and 255")
-			}
-			newColumn.columnSize = uint8(fieldSize)
-		default:
-			return errors.ErrUnsupported
+		newColumn, err := buildColumn(construct, &q.TableConstruction)
+		if err != nil {
+			return err
 		}
-		totalRowSize += int(newColumn.columnSize)
 		newtable.Columns[i] = newColumn
 	}
 
-	if totalRowSize >= 4070 {
-		return errors.New("row size for this table exceeds max row size")
+	if err := newtable.checkRowSize(); err != nil {
+		return err
 	}
-
-	f, err := os.Create(filepath.Join(b.dir, fmt.Sprintf("%s.db", newtable.Name)))
+	var err error
+	newtable.checks = q.TableConstruction.checks
+	newtable.primaryKey, err = newtable.columnPositions(q.TableConstruction.primary)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	if err := newtable.checkColumnValues(); err != nil {
+		return err
+	}
+	for _, ref := range q.TableConstruction.foreignKeys {
+		fk, err := b.foreignKey(&newtable, ref)
+		if err != nil {
+			return err
+		}
+		newtable.foreignKeys = append(newtable.foreignKeys, fk)
+	}
+	for i, col := range newtable.Columns {
+		if !col.columnIsUnique || (col.columnIsPrimary && len(newtable.primaryKey) == 1) {
+			continue
+		}
+		name := b.uniqueIndexName(&newtable, col.columnName)
+		newtable.indexes = append(newtable.indexes, indexDef{name: name, unique: true, constraint: true, columns: []int{i}})
+	}
+	return b.addTable(newtable)
+}
 
-	buf := [PAGESIZE]byte{}                     //should remove once index values are pages
-	binary.LittleEndian.PutUint64(buf[0:8], 0)  //pagenum
-	binary.LittleEndian.PutUint16(buf[8:10], 0) //rownums
-	checksum := md5.Sum(buf[26:])
-	copy(buf[10:26], checksum[:])
-	_, err = f.Write(buf[:])
-	if err != nil {
+// creates the files of a new table and adds it to the catalog
+func (b *Backend) addTable(newtable Table) error {
+	if err := createTableFile(tableFile(b.dir, newtable.files())); err != nil {
 		return err
 	}
+	os.Remove(overflowFile(b.dir, newtable.files())) //left behind if a previous create was interrupted
 
 	newtable.lastPage = 0
 	newtable.GenerateFields()
 	newtable.indices = newIndexManager()
-	err = newtable.indices.addIndex(b.dir, newtable.Name, primaryName)
+	err := newtable.indices.addIndex(b.dir, newtable.files(), newtable.primaryIndexName())
+	for _, def := range newtable.indexes {
+		if err != nil {
+			break
+		}
+		os.Remove(indexFile(b.dir, newtable.files(), def.name)) //left behind if a previous create was interrupted
+		_, err = newtable.indices.addSecondary(b.dir, newtable.files(), def.name)
+	}
 	if err != nil {
-		f.Close()
-		os.Remove(filepath.Join(b.dir, fmt.Sprintf("%s.db", newtable.Name)))
+		os.Remove(tableFile(b.dir, newtable.files()))
 		return err
 	}
 	b.tables = append(b.tables, newtable)
 	b.writeTablesToDisk()
-	b.bufferPool.newPool(newtable.Name, b.dir, newtable.Columns)
+	b.bufferPool.newPool(newtable.Name, newtable.files(), b.dir, newtable.Columns)
 	return nil
 }
 
+/*
+createTableAs creates a table holding the result of a SELECT. Columns take the name, type and size of the
+selected columns without their constraints, the primary key of the selected table, which must be selected,
+becomes the primary key of the new table
+*/
+func (b *Backend) createTableAs(name string, sel *SelectStatement) error {
+	if err := checkTableName(name); err != nil {
+		return err
+	}
+	sq := lowerStatement(sel)
+	source, ok := b.checkTableExist(sq)
+	if !ok {
+		return errors.New("Table does not exist")
+	}
+	source.tableLock.RLock()
+	plan, err := b.planSelect(source, sq)
+	var rows [][]Cell
+	if err == nil {
+		rows, err = drain(plan)
+	}
+	source.tableLock.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	newtable := Table{Name: name, tableLock: new(sync.RWMutex)}
+	for _, col := range plan.columns {
+		if slices.ContainsFunc(newtable.Columns, func(c Column) bool { return c.columnName == col.Name }) {
+			return fmt.Errorf("column %s appears more than once", col.Name)
+		}
+		src := col.column(source)
+		newtable.Columns = append(newtable.Columns, Column{columnName: col.Name, columnType: src.columnType, columnSize: src.columnSize,
+			columnPrecision: src.columnPrecision, columnScale: src.columnScale, columnIsNullable: true})
+	}
+	for _, pos := range source.primaryKey {
+		i := slices.IndexFunc(plan.columns, func(col ResultColumn) bool { return col.columnPos == pos })
+		if i == -1 {
+			return fmt.Errorf("primary key column %s of table %s must be selected", source.Columns[pos].columnName, source.Name)
+		}
+		newtable.primaryKey = append(newtable.primaryKey, i)
+		newtable.Columns[i].columnIsPrimary = true
+		newtable.Columns[i].columnIsUnique = len(source.primaryKey) == 1
+		newtable.Columns[i].columnIsNullable = false
+	}
+	if err := b.addTable(newtable); err != nil {
+		return err
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+	table, _ := b.checkTableExist(Query{TableName: name})
+	defer b.lockTables(table)()
+	for i, row := range rows {
+		newRow := make([]Cell, len(plan.columns))
+		for j, col := range plan.columns {
+			newRow[j] = row[col.columnPos]
+		}
+		rows[i] = newRow
+	}
+	n, locations, err := b.bufferPool.InsertData(table.Name, rows)
+	if err != nil {
+		return err
+	}
+	for i := range rows {
+		if err := table.insertIndexEntries(rows[i], locations[i]); err != nil {
+			return err
+		}
+	}
+	table.lastPage = uint64(n)
+	if pos := table.rowIdColumn(); pos != -1 {
+		for _, row := range rows {
+			table.lastRowId = max(table.lastRowId, row[pos].AsInt())
+		}
+	}
+	return nil
+}
+
+// builds a column from its name, type and optional size, tc holds the constraints of every column by name
+func buildColumn(construct []string, tc *createQuery) (Column, error) {
+	newColumn := Column{}
+	if !(len(construct[0]) > 0 && len(construct[0]) < 255) {
+		return newColumn, errors.New("table name too large in size")
+	}
+	newColumn.columnName = construct[0]
+	isUnique := slices.Contains(tc.unique, newColumn.columnName)
+	isNotNullable := slices.Contains(tc.notnullable, newColumn.columnName)
+	isNullable := slices.Contains(tc.nullable, newColumn.columnName)
+
+	if isNullable && isNotNullable {
+		return newColumn, errors.New("column cannot be both nullable and non-nullable")
+	}
+	newColumn.columnIsUnique = isUnique
+	newColumn.columnIsNullable = !isNotNullable
+	typeName, unsigned := strings.CutSuffix(construct[1], " UNSIGNED")
+	if unsigned && integerSizes[typeName] == 0 {
+		return newColumn, fmt.Errorf("%s field cannot be unsigned", typeName)
+	}
+	switch typeName { //Uses reserved types list in parser.go
+	case "INT", "TINYINT", "SMALLINT", "INT32", "BIGINT":
+		newColumn.columnType = INT
+		if unsigned {
+			newColumn.columnType = UINT
+		}
+		newColumn.columnSize = integerSizes[typeName] //(bytes)
+	case "FLOAT":
+		newColumn.columnType = FLOAT
+		newColumn.columnSize = 8 //(bytes)
+	case "BOOL":
+		newColumn.columnType = BOOL
+		newColumn.columnSize = 1 //(bytes)
+	case "CHAR":
+		newColumn.columnType = CHAR
+		if len(construct) != 3 {
+			return newColumn, errors.New("size needed for char field in table")
+		}
+		fieldSize, err := strconv.ParseInt(construct[2], 10, 64)
+		if err != nil {
+			return newColumn, errors.Join(errors.New("error in table construction of size of CHAR field: "), err)
+		}
+		if !(fieldSize >= 1 && fieldSize <= 255) {
+			return newColumn, errors.New("size for char field must be between 1 and 255")
+		}
+		newColumn.columnSize = uint16(fieldSize)
+	case "VARCHAR":
+		newColumn.columnType = VARCHAR
+		if len(construct) != 3 {
+			return newColumn, errors.New("size needed for varchar field in table")
+		}
+		fieldSize, err := strconv.ParseInt(construct[2], 10, 64)
+		if err != nil {
+			return newColumn, errors.Join(errors.New("error in table construction of size of VARCHAR field: "), err)
+		}
+		if !(fieldSize >= 1 && fieldSize <= math.MaxUint16) {
+			return newColumn, errors.New("size for varchar field must be between 1 and 65535")
+		}
+		newColumn.columnSize = uint16(fieldSize)
+	case "TEXT":
+		newColumn.columnType = TEXT
+		if len(construct) != 2 {
+			return newColumn, errors.New("text field takes no size")
+		}
+	case "BLOB", "BYTEA":
+		newColumn.columnType = BLOB
+		if len(construct) != 2 {
+			return newColumn, errors.New("blob field takes no size")
+		}
+	case "JSON":
+		newColumn.columnType = JSON
+		if len(construct) != 2 {
+			return newColumn, errors.New("json field takes no size")
+		}
+	case "UUID":
+		newColumn.columnType = UUID
+		newColumn.columnSize = 16 //(bytes)
+	case "DATE":
+		newColumn.columnType = DATE
+		newColumn.columnSize = 4 //days since 1970 (bytes)
+	case "TIME":
+		newColumn.columnType = TIME
+		newColumn.columnSize = 8 //microseconds since midnight (bytes)
+	case "TIMESTAMP":
+		newColumn.columnType = TIMESTAMP
+		newColumn.columnSize = 8 //microseconds since 1970 (bytes)
+	case "INTERVAL":
+		newColumn.columnType = INTERVAL
+		newColumn.columnSize = 16 //months, days and microseconds (bytes)
+	case "DECIMAL", "NUMERIC":
+		newColumn.columnType = DECIMAL
+		if len(construct) != 3 && len(construct) != 4 {
+			return newColumn, errors.New("precision needed for decimal field in table")
+		}
+		precision, err := strconv.ParseInt(construct[2], 10, 64)
+		if err != nil {
+			return newColumn, errors.Join(errors.New("error in table construction of precision of DECIMAL field: "), err)
+		}
+		if !(precision >= 1 && precision <= maxDecimalPrecision) {
+			return newColumn, fmt.Errorf("precision for decimal field must be between 1 and %d", maxDecimalPrecision)
+		}
+		scale := int64(0)
+		if len(construct) == 4 {
+			if scale, err = strconv.ParseInt(construct[3], 10, 64); err != nil {
+				return newColumn, errors.Join(errors.New("error in table construction of scale of DECIMAL field: "), err)
+			}
+		}
+		if !(scale >= 0 && scale <= precision) {
+			return newColumn, errors.New("scale for decimal field must be between 0 and its precision")
+		}
+		newColumn.columnPrecision, newColumn.columnScale = uint8(precision), uint8(scale)
+		newColumn.columnSize = decimalSize(newColumn.columnPrecision) //unscaled value (bytes)
+	default:
+		return newColumn, errors.ErrUnsupported
+	}
+	newColumn.columnDefault = tc.defaults[newColumn.columnName]
+	newColumn.columnGenerated = tc.generated[newColumn.columnName]
+	if newColumn.columnDefault != nil && newColumn.columnGenerated != nil {
+		return newColumn, fmt.Errorf("both default and generation expression specified for column %s", newColumn.columnName)
+	}
+	if slices.Contains(tc.primary, newColumn.columnName) {
+		newColumn.columnIsPrimary = true
+		newColumn.columnIsUnique = len(tc.primary) == 1
+		newColumn.columnIsNullable = false
+	}
+	return newColumn, nil
+}
+
+// checks that name fits the catalog and that the data file of the table is not the catalog file
+func checkTableName(name string) error {
+	if !(len(name) > 0 && len(name) < 255) {
+		return errors.New("table name too large in size")
+	}
+	if tableFile("", fileName(name)) == CATALOGFILE {
+		return fmt.Errorf("table name %s is reserved", name)
+	}
+	return nil
+}
+
+/*
+fileName escapes a table, column or index name for use in a file name. Dots, percent signs, path separators
+and control characters are written as %XX, so escaped names never hold a dot and names joined by dots
+always give distinct file names
+*/
+func fileName(name string) string {
+	var sb strings.Builder
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; {
+		case c == '.' || c == '%' || c == '/' || c == '\\' || c < 0x20:
+			fmt.Fprintf(&sb, "%%%02X", c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+/*
+files is the base name of the files of the table, its escaped name followed by the version of the files once
+the table was rewritten. A rewrite writes the files of the next version and the catalog naming that version
+replaces main.db before the files of the earlier version are removed
+*/
+func (t *Table) files() string {
+	return tableFiles(t.Name, t.version)
+}
+
+func tableFiles(name string, version uint32) string {
+	if version == 0 {
+		return fileName(name)
+	}
+	return fileName(name) + "." + strconv.FormatUint(uint64(version), 10)
+}
+
+// file holding the rows of a table with the base name files
+func tableFile(dir, files string) string {
+	return filepath.Join(dir, files+".db")
+}
+
+// creates the data file of a table holding a single empty page
+func createTableFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buf := [PAGESIZE]byte{}                     //should remove once index values are pages
+	binary.LittleEndian.PutUint64(buf[0:8], 0)  //pagenum
+	binary.LittleEndian.PutUint16(buf[8:10], 0) //rownums
+	checksum := md5.Sum(buf[26:])
+	copy(buf[10:26], checksum[:])
+	_, err = f.Write(buf[:])
+	return err
+}
+
 func (b *Backend) writeTablesToDisk() {
 	buf := make([]byte, 0, PAGESIZE)
 
@@ -194,8 +464,8 @@ func (b *Backend) writeTablesToDisk() {
 		buf = append(buf, table.toBytes()...)
 	}
 
-	oldfile := filepath.Join(b.dir, "main.db")
-	newfile := filepath.Join(b.dir, "temp-main.db")
+	oldfile := filepath.Join(b.dir, CATALOGFILE)
+	newfile := filepath.Join(b.dir, CATALOGFILE+".tmp")
 
 	f, err := os.OpenFile(oldfile, os.O_RDONLY, 0700)
 	if err != nil {
@@ -224,135 +494,83 @@ func (b *Backend) writeTablesToDisk() {
 	os.Rename(newfile, oldfile)
 }
 
-func (b *Backend) Insert(q Query) error {
+func (b *Backend) Insert(q Query) (driver.Rows, error) {
+	stmt, ok := q.Statement.(*InsertStatement)
+	if !ok {
+		return nil, errors.New("not an INSERT statement")
+	}
 	tableToInsert, ok := b.checkTableExist(q)
 	if !ok {
-		return errors.New("Table does not exist")
+		return nil, errors.New("Table does not exist")
 	}
 
-	allrows := make([][]Cell, 0, len(q.Inserts))
-	insertColumns := make([]InsertColumn, len(tableToInsert.Columns)) //same length as table columns
-	queryCols := make([]string, len(q.Fields))
-	copy(queryCols, q.Fields)
-
-	for i, col := range tableToInsert.Columns {
-		insertColumns[i].columnSize = col.columnSize
-		insertColumns[i].dataType = col.columnType
-		isNull := true
-		for j := range queryCols {
-			if queryCols[j] == col.columnName {
-				isNull = false
-				for k := range q.Fields {
-					if q.Fields[k] == col.columnName {
-						insertColumns[i].insertIndex = k
-						break
-					}
-				}
-				queryCols = removeColFieldGen[string](queryCols, j)
-				break
-			}
+	positions := make([]int, len(stmt.Columns)) //position in the table of every inserted column
+	missing := make([]string, 0)
+	for i, name := range stmt.Columns {
+		positions[i] = slices.IndexFunc(tableToInsert.Columns, func(col Column) bool { return col.columnName == name })
+		if positions[i] == -1 {
+			missing = append(missing, name)
+		} else if slices.Contains(positions[:i], positions[i]) {
+			return nil, fmt.Errorf("column %s appears more than once", name)
+		} else if tableToInsert.Columns[positions[i]].columnGenerated != nil {
+			return nil, fmt.Errorf("cannot insert a value into generated column %s", name)
 		}
-		if isNull && col.columnIsPrimary { //supposes primary key is rowid
-			insertColumns[i].colType = COL_I_PRIMARYNULL
-		} else if !isNull && col.columnIsPrimary {
-			insertColumns[i].colType = COL_I_PRIMARYVALUED
-		} else if isNull {
-			if !col.columnIsNullable {
-				return fmt.Errorf("column %s may not be null", col.columnName)
-			}
-			insertColumns[i].colType = COL_I_NULL
-		} else if !isNull {
-			insertColumns[i].colType = COL_I_VALUED
-		}
-	}
-	if len(queryCols) > 0 {
-		return fmt.Errorf("Columns may not exist: %s", strings.Join(queryCols, " - "))
-	}
-
-	tableToInsert.tableLock.Lock()
-	defer tableToInsert.tableLock.Unlock()
-
-	indexInserts := make([][2]int64, 0, len(q.Inserts))
-	pageid := PageID(tableToInsert.lastPage) // must lock table before accessing these variables
-	lastrownum := tableToInsert.lastRowId    // must lock table before accessing
-
-	for _, val := range q.Inserts {
-		cellRow := make([]Cell, len(insertColumns))
-
-		for j := range insertColumns {
-			if insertColumns[j].colType == COL_I_PRIMARYVALUED {
-				num, err := strconv.ParseInt(val[insertColumns[j].insertIndex], 10, 64)
-				if err != nil {
-					return errors.Join(errors.New("Insert Query failed: "), err)
-				}
-				if num <= tableToInsert.lastRowId {
-					return errors.New("Insert Query failed: non valid primary key provided")
-				}
-				lastrownum = num
-				newIndexTuple := [2]int64{lastrownum, 0}
-				indexInserts = append(indexInserts, newIndexTuple)
-				cellRow[j] = make(Cell, insertColumns[j].columnSize)
-				binary.LittleEndian.PutUint64(cellRow[j], uint64(num))
-			} else if insertColumns[j].colType == COL_I_PRIMARYNULL {
-				lastrownum++ //should always be valid
-				cellRow[j] = make(Cell, insertColumns[j].columnSize)
-				binary.LittleEndian.PutUint64(cellRow[j], uint64(lastrownum))
-				newIndexTuple := [2]int64{lastrownum, 0}
-				indexInserts = append(indexInserts, newIndexTuple)
-			} else if insertColumns[j].colType == COL_I_VALUED {
-				cellRow[j] = make(Cell, insertColumns[j].columnSize)
-				switch insertColumns[j].dataType {
-				case INT:
-					n, err := strconv.ParseInt(val[insertColumns[j].insertIndex], 10, 64)
-					if err != nil {
-						return errors.Join(errors.New("Insert Query failed: "), err)
-					}
-					binary.LittleEndian.PutUint64(cellRow[j], uint64(n))
-				case FLOAT:
-					n, err := strconv.ParseFloat(val[insertColumns[j].insertIndex], 64)
-					if err != nil {
-						return errors.Join(errors.New("Insert Query failed: "), err)
-					}
-					binary.LittleEndian.PutUint64(cellRow[j], math.Float64bits(n))
-				case BOOL:
-					n, err := strconv.ParseBool(val[insertColumns[j].insertIndex])
-					if err != nil {
-						return errors.Join(errors.New("Insert Query failed: "), err)
-					}
-					if n {
-						cellRow[j] = Cell([]byte{1})
-					} else {
-						cellRow[j] = Cell([]byte{0})
-					}
-				case CHAR:
-					n := []byte(val[insertColumns[j].insertIndex])
-					if len(n) > int(insertColumns[j].columnSize) {
-						return errors.Join(errors.New("Insert Query failed: "), errors.New("string to insert larger than allowed"))
-					}
-					cellRow[j] = Cell(n)
-				}
-			} else if insertColumns[j].colType == COL_I_NULL {
-				cellRow[j] = nil
-			}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("Columns may not exist: %s", strings.Join(missing, " - "))
+	}
+	ins := &rowInserter{table: tableToInsert, positions: positions, rowIdColumn: tableToInsert.rowIdColumn()}
+	if slices.Contains(positions, ins.rowIdColumn) {
+		ins.rowIdColumn = -1 //keys are given
+	}
+	if stmt.OnConflict != nil {
+		if err := ins.prepareConflict(stmt.OnConflict); err != nil {
+			return nil, err
 		}
-		allrows = append(allrows, cellRow)
 	}
-
-	n, err := b.bufferPool.InsertData(tableToInsert.Name, pageid, allrows, &indexInserts)
+	returning, err := resultColumns(tableToInsert, stmt.Returning)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if stmt.Query != nil {
+		if err := b.insertSelect(ins, stmt.Query); err != nil {
+			return nil, err
+		}
+		return ins.result(returning)
 	}
-	for i := range indexInserts {
-		// fmt.Println(indexInserts[i])
-		err := tableToInsert.indices.primaryTree.insertNode(indexInserts[i][0], indexInserts[i][1])
+
+	defer b.lockTables(tableToInsert)()
+	ins.lastRowId = tableToInsert.lastRowId // must lock table before accessing
+
+	for _, values := range stmt.Rows {
+		row := make([]any, len(values))
+		for i := range values {
+			var err error
+			if row[i], err = insertValue(values[i]); err != nil {
+				return nil, fmt.Errorf("insert: %w", err)
+			}
+		}
+		cellRow, err := ins.row(row)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if err := b.addRow(ins, cellRow); err != nil {
+			return nil, err
 		}
 	}
+	if err := b.flushRows(ins); err != nil {
+		return nil, err
+	}
+	return ins.result(returning)
+}
 
-	tableToInsert.lastPage = uint64(n)
-	tableToInsert.lastRowId = int64(lastrownum)
-	return nil
+// value of an expression in VALUES, literals are passed on as text and converted to the type of their column
+func insertValue(expr Expr) (any, error) {
+	if lit, ok := expr.(*Literal); ok && lit.Kind != NullLiteral && lit.Kind != HexLiteral {
+		return lit.Value, nil
+	}
+	return evalConstant(expr)
 }
 
 func (b *Backend) Select(q Query) (driver.Rows, error) {
@@ -363,350 +581,200 @@ func (b *Backend) Select(q Query) (driver.Rows, error) {
 	tmpTable.tableLock.RLock()
 	defer tmpTable.tableLock.RUnlock()
 
-	rows := &Rows{index: 0}
-	rows.rows = make([][]Cell, 0)
-
-	if q.Fields[0] == "*" {
-		rows.columns = make([]ResultColumn, len(tmpTable.Columns))
-		for i := range tmpTable.Columns {
-			rows.columns[i] = ResultColumn{Name: tmpTable.Columns[i].columnName, ColumnType: tmpTable.Columns[i].columnType, columnPos: i}
-		}
-	} else {
-		rows.columns = make([]ResultColumn, 0, len(q.Fields))
-		for i := range tmpTable.Columns {
-			for j := range q.Fields {
-				if tmpTable.Columns[i].columnName == q.Fields[j] {
-					rows.columns = append(rows.columns, ResultColumn{Name: tmpTable.Columns[i].columnName, ColumnType: tmpTable.Columns[i].columnType, columnPos: i})
-					q.Fields = removeColFieldGen[string](q.Fields, j)
-					break
-				}
-			}
-		}
-		if len(q.Fields) != 0 {
-			return nil, fmt.Errorf("Columns not in table: %s", strings.Join(q.Fields, "|"))
-		}
-	}
-
-	if len(q.Conditions) == 0 { //all pages queried in PageID order
-		startPage := PageID(0)
-		endPage := PageID(tmpTable.lastPage)
-		allrows := b.bufferPool.SelectDataRange(tmpTable.Name, startPage, endPage)
-		rows.rows = allrows
-	} else {
-		tmpConditions := make([]struct {
-			Condition
-			operand1Index int
-			operand2Index int
-		}, len(q.Conditions))
-		for i := range q.Conditions { //check
-			tmpConditions[i].Condition = q.Conditions[i]
-			for j := range tmpTable.Columns {
-				if q.Conditions[i].Operand1 == tmpTable.Columns[j].columnName {
-					tmpConditions[i].operand1Index = j
-				}
-				if q.Conditions[i].Operand2IsField && q.Conditions[i].Operand2 == tmpTable.Columns[j].columnName {
-					tmpConditions[i].operand2Index = j
-				}
-			}
-			cond := tmpConditions[i]
-			if cond.Operand2IsField && tmpTable.Columns[cond.operand1Index].columnType != tmpTable.Columns[cond.operand2Index].columnType {
-				return nil, errors.New("cannot compare columns of different type")
-			}
+	plan, err := b.planSelect(tmpTable, q)
+	if err != nil {
+		return nil, err
+	}
+	rows := &Rows{index: 0, columns: plan.columns}
+	rows.rows, err = drain(plan)
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
 
-			if (tmpTable.Columns[cond.operand1Index].columnType == BOOL) && (cond.Operator == Gt || cond.Operator == Gte || cond.Operator == Lt || cond.Operator == Lte) {
-				return nil, errors.New("cannot use this operator for comparing booleans")
-			}
+// Update sets the assigned columns of every row matching the WHERE clause, values are computed from the row before the update
+func (b *Backend) Update(q Query) (driver.Rows, error) {
+	stmt, ok := q.Statement.(*UpdateStatement)
+	if !ok {
+		return nil, errors.New("not an UPDATE statement")
+	}
+	table, ok := b.checkTableExist(q)
+	if !ok {
+		return nil, errors.New("Table does not exist")
+	}
 
-			if !cond.Operand2IsField {
-				switch tmpTable.Columns[cond.operand1Index].columnType {
-				case INT:
-					_, err := strconv.ParseInt(cond.Operand2, 10, 64)
-					if err != nil {
-						return nil, err
-					}
-				case FLOAT:
-					_, err := strconv.ParseFloat(cond.Operand2, 64)
-					if err != nil {
-						return nil, err
-					}
-				case BOOL:
-					_, err := strconv.ParseBool(cond.Operand2)
-					if err != nil {
-						return nil, err
-					}
-				case CHAR:
-				}
-			}
-		}
+	defer b.lockTables(table)()
 
-		tmprows := make([][]Cell, 0)
-		var hasIndex bool = false
-
-		for i := range tmpConditions { //checking for any indexes first
-			cond := tmpConditions[i]
-			if cond.Operand1 == tmpTable.indices.columnName {
-				if cond.Operand2IsField {
-					return nil, errors.New("cannot compare primary key to non-integer for now: ")
-				}
-				num, err := strconv.ParseInt(cond.Operand2, 10, 32)
-				if err != nil {
-					return nil, errors.New("primary key not an integer: ")
-				}
-				switch cond.Operator {
-				case Eq:
-					val, ok := tmpTable.indices.primaryTree.findKeyValue(num)
-					if ok {
-						var tmpPageID uint64 = uint64(val / PAGESIZE)
-						cellRows := b.bufferPool.SelectDataPages(tmpTable.Name, []PageID{PageID(tmpPageID)})
-						for i := range cellRows {
-							if cellRows[i][cond.operand1Index].AsInt() == num {
-								tmprows = append(tmprows, cellRows[i])
-								break
-							}
-						}
-					}
-				case Gt, Gte:
-					potentialLeaf := tmpTable.indices.primaryTree.findLeaf(tmpTable.indices.primaryTree.root, num)
-					pageSets := make(map[int64]struct{})
-					values := make(map[int64]struct{})
-					for potentialLeaf != nil {
-						for i := range potentialLeaf.keys {
-							if num == potentialLeaf.keys[i] && cond.Operator == Gte {
-								pageSets[potentialLeaf.values[i]/PAGESIZE] = struct{}{}
-								values[potentialLeaf.keys[i]] = struct{}{}
-							} else if potentialLeaf.keys[i] > num {
-								pageSets[potentialLeaf.values[i]/PAGESIZE] = struct{}{}
-								values[potentialLeaf.keys[i]] = struct{}{}
-							}
-						}
-						potentialLeaf = potentialLeaf.next
-					}
-					pageNums := make([]PageID, 0, len(pageSets))
-					for i := range pageSets { //map iterates over key not indices
-						pageNums = append(pageNums, PageID(i))
-					}
-					slices.Sort(pageNums)
-					cellRows := b.bufferPool.SelectDataPages(tmpTable.Name, pageNums)
-
-					for i := range cellRows {
-						if _, ok := values[cellRows[i][cond.operand1Index].AsInt()]; !ok {
-							continue
-						} else {
-							delete(values, cellRows[i][cond.operand1Index].AsInt())
-						}
-						tmprows = append(tmprows, cellRows[i])
-					}
-				case Lt, Lte:
-					potentialLeaf := tmpTable.indices.primaryTree.findLeaf(tmpTable.indices.primaryTree.root, num)
-					firstLeaf := tmpTable.indices.primaryTree.findFirstLeaf()
-					pageSets := make(map[int64]struct{})
-					values := make(map[int64]struct{})
-					for firstLeaf != nil && firstLeaf != potentialLeaf {
-						for i := range firstLeaf.keys {
-							if num == firstLeaf.keys[i] && cond.Operator == Lte {
-								pageSets[firstLeaf.values[i]/PAGESIZE] = struct{}{}
-								values[firstLeaf.keys[i]] = struct{}{}
-							} else if firstLeaf.keys[i] < num {
-								pageSets[firstLeaf.values[i]/PAGESIZE] = struct{}{}
-								values[firstLeaf.keys[i]] = struct{}{}
-							}
-						}
-						firstLeaf = firstLeaf.next
-					}
-					pageNums := make([]PageID, 0, len(pageSets))
-					for i := range pageSets { //map iterates over key not indices
-						pageNums = append(pageNums, PageID(i))
-					}
-					slices.Sort(pageNums)
-					cellRows := b.bufferPool.SelectDataPages(tmpTable.Name, pageNums)
-
-					for i := range cellRows {
-						if _, ok := values[cellRows[i][cond.operand1Index].AsInt()]; !ok {
-							continue
-						} else {
-							delete(values, cellRows[i][cond.operand1Index].AsInt())
-						}
-						tmprows = append(tmprows, cellRows[i])
-					}
-				case Ne: //iterate over all rows and just remove one not needed
-					startPage := PageID(0)
-					endPage := PageID(tmpTable.lastPage)
-					cellRows := b.bufferPool.SelectDataRange(tmpTable.Name, startPage, endPage)
-					for i := range cellRows {
-						if num == cellRows[i][cond.operand1Index].AsInt() {
-							continue
-						}
-						tmprows = append(tmprows, cellRows[i])
-					}
-				}
-				tmpConditions = removeColFieldGen(tmpConditions, i)
-				hasIndex = true
-				break
-			}
+	plan, returning, err := b.planUpdate(table, stmt)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := drain(plan)
+	if err != nil {
+		return nil, err
+	}
+	return returnRows(table, returning, updated)
+}
+
+// plans an UPDATE of table and resolves its RETURNING clause. The linked tables must be locked
+func (b *Backend) planUpdate(table *Table, stmt *UpdateStatement) (*modify, []ResultColumn, error) {
+	scope := newRowScope(table, stmt.Table)
+	positions := make([]int, len(stmt.Set))
+	missing := make([]string, 0)
+	for i, set := range stmt.Set {
+		positions[i] = slices.IndexFunc(table.Columns, func(col Column) bool { return col.columnName == set.Column })
+		if positions[i] == -1 {
+			missing = append(missing, set.Column)
+			continue
+		}
+		if table.Columns[positions[i]].columnGenerated != nil {
+			return nil, nil, fmt.Errorf("cannot update generated column %s", set.Column)
 		}
-		if !hasIndex { //fetch all rows
-			startPage := PageID(0)
-			endPage := PageID(tmpTable.lastPage)
-			cellRows := b.bufferPool.SelectDataRange(tmpTable.Name, startPage, endPage)
-			tmprows = cellRows
-		}
-
-		for i := range tmprows {
-			row := tmprows[i]
-			passes := true
-		condLoop:
-			for j := range tmpConditions {
-				cond := tmpConditions[j]
-				switch tmpTable.Columns[cond.operand1Index].columnType {
-				case INT:
-					var leftVal int64 = row[cond.operand1Index].AsInt()
-					var rightVal int64
-					if cond.Operand2IsField {
-						rightVal = row[cond.operand2Index].AsInt()
-					} else {
-						rightVal, _ = strconv.ParseInt(cond.Operand2, 10, 64)
-					}
-					switch cond.Operator {
-					case Eq:
-						if !(leftVal == rightVal) {
-							passes = false
-							break condLoop
-						}
-					case Ne:
-						if leftVal == rightVal {
-							passes = false
-							break condLoop
-						}
-					case Gt:
-						if !(leftVal > rightVal) {
-							passes = false
-							break condLoop
-						}
-					case Lt:
-						if !(leftVal < rightVal) {
-							passes = false
-							break condLoop
-						}
-					case Gte:
-						if !(leftVal >= rightVal) {
-							passes = false
-							break condLoop
-						}
-					case Lte:
-						if !(leftVal <= rightVal) {
-							passes = false
-							break condLoop
-						}
-					}
-				case FLOAT:
-					var leftVal float64 = row[cond.operand1Index].AsFloat()
-					var rightVal float64
-					if cond.Operand2IsField {
-						rightVal = row[cond.operand2Index].AsFloat()
-					} else {
-						rightVal, _ = strconv.ParseFloat(cond.Operand2, 64)
-					}
-					switch cond.Operator {
-					case Eq:
-						if !(leftVal == rightVal) {
-							passes = false
-							break condLoop
-						}
-					case Ne:
-						if leftVal == rightVal {
-							passes = false
-							break condLoop
-						}
-					case Gt:
-						if !(leftVal > rightVal) {
-							passes = false
-							break condLoop
-						}
-					case Lt:
-						if !(leftVal < rightVal) {
-							passes = false
-							break condLoop
-						}
-					case Gte:
-						if !(leftVal >= rightVal) {
-							passes = false
-							break condLoop
-						}
-					case Lte:
-						if !(leftVal <= rightVal) {
-							passes = false
-							break condLoop
-						}
-					}
-				case BOOL:
-					var leftVal bool = row[cond.operand1Index].AsBool()
-					var rightVal bool
-					if cond.Operand2IsField {
-						rightVal = row[cond.operand2Index].AsBool()
-					} else {
-						rightVal, _ = strconv.ParseBool(cond.Operand2)
-					}
-					switch cond.Operator {
-					case Eq:
-						if !(leftVal == rightVal) {
-							passes = false
-							break condLoop
-						}
-					case Ne:
-						if leftVal == rightVal {
-							passes = false
-							break condLoop
-						}
-					}
-				case CHAR:
-					var leftVal string = row[cond.operand1Index].AsString()
-					var rightVal string
-					if cond.Operand2IsField {
-						rightVal = row[cond.operand2Index].AsString()
-					} else {
-						rightVal = cond.Operand2
-					}
-					switch cond.Operator {
-					case Eq:
-						if !(leftVal == rightVal) {
-							passes = false
-							break condLoop
-						}
-					case Ne:
-						if leftVal == rightVal {
-							passes = false
-							break condLoop
-						}
-					case Gt:
-						if !(leftVal > rightVal) {
-							passes = false
-							break condLoop
-						}
-					case Lt:
-						if !(leftVal < rightVal) {
-							passes = false
-							break condLoop
-						}
-					case Gte:
-						if !(leftVal >= rightVal) {
-							passes = false
-							break condLoop
-						}
-					case Lte:
-						if !(leftVal <= rightVal) {
-							passes = false
-							break condLoop
-						}
-					}
-				}
+		if err := scope.bind(set.Value); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(missing) != 0 {
+		return nil, nil, fmt.Errorf("Columns not in table: %s", strings.Join(missing, "|"))
+	}
+	returning, err := resultColumns(table, stmt.Returning)
+	if err != nil {
+		return nil, nil, err
+	}
+	scan, err := b.planScan(table, scope, stmt.Where)
+	if err != nil {
+		return nil, nil, err
+	}
+	change := func(row []Cell) ([]Cell, error) {
+		updated := slices.Clone(row)
+		for j, set := range stmt.Set {
+			col := &table.Columns[positions[j]]
+			value, err := scope.eval(set.Value, row)
+			if err != nil {
+				return nil, err
 			}
-			if passes {
-				rows.rows = append(rows.rows, row)
+			updated[positions[j]], err = valueCell(col, value)
+			if err != nil {
+				return nil, fmt.Errorf("update: %w", err)
 			}
 		}
+		if err := table.computeGenerated(updated); err != nil {
+			return nil, fmt.Errorf("update: %w", err)
+		}
+		if err := table.checkNotNull(updated); err != nil {
+			return nil, err
+		}
+		if err := table.checkRow(updated); err != nil {
+			return nil, err
+		}
+		return updated, nil
 	}
+	plan := &modify{child: scan, table: table, backend: b, change: change}
+	plan.estRows, plan.cost = scan.stats().estRows, scan.stats().cost
+	return plan, returning, nil
+}
 
-	return rows, nil
+// Delete removes every row matching the WHERE clause
+func (b *Backend) Delete(q Query) (driver.Rows, error) {
+	stmt, ok := q.Statement.(*DeleteStatement)
+	if !ok {
+		return nil, errors.New("not a DELETE statement")
+	}
+	table, ok := b.checkTableExist(q)
+	if !ok {
+		return nil, errors.New("Table does not exist")
+	}
+
+	defer b.lockTables(table)()
+
+	plan, returning, err := b.planDelete(table, stmt)
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := drain(plan)
+	if err != nil {
+		return nil, err
+	}
+	return returnRows(table, returning, deleted)
+}
+
+// plans a DELETE from table and resolves its RETURNING clause. The linked tables must be locked
+func (b *Backend) planDelete(table *Table, stmt *DeleteStatement) (*modify, []ResultColumn, error) {
+	returning, err := resultColumns(table, stmt.Returning)
+	if err != nil {
+		return nil, nil, err
+	}
+	scan, err := b.planScan(table, newRowScope(table, stmt.Table), stmt.Where)
+	if err != nil {
+		return nil, nil, err
+	}
+	plan := &modify{child: scan, table: table, backend: b, delete: true}
+	plan.estRows, plan.cost = scan.stats().estRows, scan.stats().cost
+	return plan, returning, nil
+}
+
+// replaceRows writes the new version of every old row in its place, a nil new row deletes the row and a nil old row
+// inserts the new one. Rows growing too large for their page are moved and indexed at their new location. The table must be locked
+func (b *Backend) replaceRows(table *Table, old, new [][]Cell) error {
+	if len(old) == 0 {
+		return nil
+	}
+	if err := table.checkUnique(old, new); err != nil {
+		return err
+	}
+	if err := table.checkKeySizes(new); err != nil {
+		return err
+	}
+	var replaced, written [][]Cell
+	var locations []int64
+	var inserted [][]Cell
+	for i := range old {
+		if old[i] == nil {
+			inserted = append(inserted, new[i])
+			continue
+		}
+		loc, err := table.rowLocation(old[i])
+		if err != nil {
+			return err
+		}
+		replaced, written, locations = append(replaced, old[i]), append(written, new[i]), append(locations, loc)
+	}
+	if len(replaced) > 0 {
+		lastPage, moved, err := b.bufferPool.WriteRows(table.Name, locations, written)
+		if err != nil {
+			return err
+		}
+		table.lastPage = uint64(lastPage)
+		locations = moved
+	}
+	for i := range replaced {
+		table.deleteIndexEntries(replaced[i])
+	}
+	if len(inserted) > 0 {
+		lastPage, insertedAt, err := b.bufferPool.InsertData(table.Name, inserted)
+		if err != nil {
+			return err
+		}
+		table.lastPage = uint64(lastPage)
+		written, locations = append(written, inserted...), append(locations, insertedAt...)
+	}
+	rowIdColumn := table.rowIdColumn()
+	for i := range written {
+		if written[i] == nil {
+			continue
+		}
+		if err := table.insertIndexEntries(written[i], locations[i]); err != nil {
+			return err
+		}
+		if rowIdColumn == -1 {
+			continue
+		}
+		if id := written[i][rowIdColumn].AsInt(); id > table.lastRowId { //keys handed out later must stay above it
+			table.lastRowId = id
+		}
+	}
+	return nil
 }
 
 func (b *Backend) Close() {
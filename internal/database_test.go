@@ -1,6 +1,14 @@
 package internal
 
-import "testing"
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
 
 func TestRemoveColumns(t *testing.T) {
 	mystrings := []string{"some", "two", "last"}
@@ -19,3 +27,125 @@ func TestRemoveColumns(t *testing.T) {
 		t.Error("mystrings did not shrink")
 	}
 }
+
+func newTestBackend(t *testing.T, setup ...string) *Backend {
+	t.Helper()
+	b := CreateNewDatabase(t.TempDir())
+	t.Cleanup(b.Close)
+	for _, sql := range setup {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	return b
+}
+
+// runs sql against the backend the same way the driver does
+func execSQL(b *Backend, sql string) (driver.Rows, error) {
+	q, err := Parse(sql)
+	if err != nil {
+		return nil, err
+	}
+	switch q.Type {
+	case Create:
+		return nil, b.CreateTable(q)
+	case Insert:
+		return b.Insert(q)
+	case Select:
+		return b.Select(q)
+	case Explain:
+		return b.Explain(q)
+	case Analyze:
+		return nil, b.Analyze(q)
+	case Update:
+		return b.Update(q)
+	case Delete:
+		return b.Delete(q)
+	case CreateIndex:
+		return nil, b.CreateIndex(q)
+	case DropIndex:
+		return nil, b.DropIndex(q)
+	case AlterTable:
+		return nil, b.AlterTable(q)
+	}
+	return nil, errors.ErrUnsupported
+}
+
+// returns every row of the query as go values
+func queryRows(t *testing.T, b *Backend, sql string) [][]any {
+	t.Helper()
+	rows, err := execSQL(b, sql)
+	require.NoError(t, err, sql)
+	all := make([][]any, 0)
+	for {
+		dest := make([]driver.Value, len(rows.Columns()))
+		if err := rows.Next(dest); err == io.EOF {
+			return all
+		} else {
+			require.NoError(t, err)
+		}
+		row := make([]any, len(dest))
+		for i := range dest {
+			row[i] = dest[i]
+		}
+		all = append(all, row)
+	}
+}
+
+func TestSelectWhere(t *testing.T) {
+	b := newTestBackend(t,
+		"CREATE TABLE t (id int primary key, name char(5), ok bool, f float);",
+		"INSERT INTO t (id, name, ok, f) VALUES (1, 'a', true, 1.5), (2, 'b', false, 2.5), (3, 'c', true, 3.5), (4, 'd', false, 4.5);",
+		"INSERT INTO t (id, ok) VALUES (5, true);",
+	)
+	ts := []struct {
+		SQL      string
+		Expected [][]any
+	}{
+		{"SELECT id FROM t WHERE id < 3;", [][]any{{int64(1)}, {int64(2)}}},
+		{"SELECT id FROM t WHERE id <= 3 AND id > 1;", [][]any{{int64(2)}, {int64(3)}}},
+		{"SELECT id FROM t WHERE id = 4;", [][]any{{int64(4)}}},
+		{"SELECT id FROM t WHERE id != 4 AND ok = false;", [][]any{{int64(2)}}},
+		{"SELECT id FROM t WHERE name = 'c' OR f > 4;", [][]any{{int64(3)}, {int64(4)}}},
+		{"SELECT id FROM t WHERE NOT (id IN (1, 2, 3));", [][]any{{int64(4)}, {int64(5)}}},
+		{"SELECT id, name FROM t WHERE name IS NULL;", [][]any{{int64(5), nil}}},
+		{"SELECT name AS n, id FROM t WHERE f * 2 = id + 2;", [][]any{{"a", int64(1)}}},
+	}
+	for _, tc := range ts {
+		t.Run(tc.SQL, func(t *testing.T) {
+			require.Equal(t, tc.Expected, trimRows(queryRows(t, b, tc.SQL)))
+		})
+	}
+
+	_, err := execSQL(b, "SELECT id FROM t WHERE missing = 1;")
+	require.EqualError(t, err, "Columns not in table: missing")
+}
+
+// strips the padding of CHAR values
+func trimRows(rows [][]any) [][]any {
+	for i := range rows {
+		for j := range rows[i] {
+			if s, ok := rows[i][j].(string); ok {
+				rows[i][j] = strings.TrimRight(s, "\x00")
+			}
+		}
+	}
+	return rows
+}
+
+func TestUpdateDelete(t *testing.T) {
+	b := newTestBackend(t,
+		"CREATE TABLE t (id int primary key, n int not null, f float);",
+		"INSERT INTO t (id, n, f) VALUES (1, 10, 1.5), (2, 20, 2.5), (3, 30, 3.5);",
+		"UPDATE t SET n = n + 1, f = 0 WHERE id >= 2;",
+		"DELETE FROM t WHERE n = 10;",
+	)
+	require.Equal(t, [][]any{{int64(2), int64(21), 0.0}, {int64(3), int64(31), 0.0}}, queryRows(t, b, "SELECT id, n, f FROM t WHERE id > 0;"))
+
+	_, err := execSQL(b, "UPDATE t SET n = f WHERE id = 2;")
+	require.EqualError(t, err, "update: value 0 does not fit the type of column n")
+	_, err = execSQL(b, "UPDATE t SET missing = 1 WHERE id = 2;")
+	require.EqualError(t, err, "Columns not in table: missing")
+	_, err = execSQL(b, "UPDATE t SET id = 3 WHERE id = 2;")
+	require.EqualError(t, err, "duplicate key value in column id of table t violates primary key")
+	require.Equal(t, [][]any{{int64(2)}, {int64(3)}}, queryRows(t, b, "SELECT id FROM t WHERE id > 0;"))
+}
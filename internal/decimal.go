@@ -0,0 +1,209 @@
+package internal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+/*
+DECIMAL(p,s) values are exact: an integer unscaled value and the number of digits after the point.
+Columns store the unscaled value at the scale of the column, in 8 bytes up to 18 digits and 16 bytes
+up to the maximum of 38 digits. Values are rounded half away from zero to the scale of their column.
+*/
+
+const (
+	maxDecimalPrecision = 38
+	divisionScale       = 16 //digits kept after the point of a quotient beyond the digits of its operands
+)
+
+type decimal struct {
+	unscaled *big.Int
+	scale    int32
+}
+
+var bigTen = big.NewInt(10)
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+func decimalFromInt(n int64) decimal {
+	return decimal{unscaled: big.NewInt(n)}
+}
+
+func decimalFromFloat(f float64) (decimal, error) {
+	return parseDecimal(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+// parses [+-]digits[.digits]
+func parseDecimal(s string) (decimal, error) {
+	s = strings.TrimSpace(s)
+	digits := strings.TrimLeft(s, "+-")
+	if len(s)-len(digits) > 1 {
+		return decimal{}, fmt.Errorf("cannot read '%s' as a decimal", s)
+	}
+	whole, frac, _ := strings.Cut(digits, ".")
+	if whole+frac == "" || strings.Trim(whole+frac, "0123456789") != "" {
+		return decimal{}, fmt.Errorf("cannot read '%s' as a decimal", s)
+	}
+	unscaled, _ := new(big.Int).SetString(whole+frac, 10)
+	if strings.HasPrefix(s, "-") {
+		unscaled.Neg(unscaled)
+	}
+	return decimal{unscaled: unscaled, scale: int32(len(frac))}, nil
+}
+
+// formats the value with every digit of its scale
+func (d decimal) String() string {
+	digits := new(big.Int).Abs(d.unscaled).String()
+	if d.scale > 0 {
+		if pad := int(d.scale) + 1 - len(digits); pad > 0 {
+			digits = strings.Repeat("0", pad) + digits
+		}
+		digits = digits[:len(digits)-int(d.scale)] + "." + digits[len(digits)-int(d.scale):]
+	}
+	if d.unscaled.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// value with scale digits after the point, rounded half away from zero
+func (d decimal) rescale(scale int32) decimal {
+	if scale >= d.scale {
+		return decimal{unscaled: new(big.Int).Mul(d.unscaled, pow10(scale-d.scale)), scale: scale}
+	}
+	divisor := pow10(d.scale - scale)
+	quotient, remainder := new(big.Int).QuoRem(d.unscaled, divisor, new(big.Int))
+	if remainder.Abs(remainder).Lsh(remainder, 1).Cmp(divisor) >= 0 {
+		if d.unscaled.Sign() < 0 {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	return decimal{unscaled: quotient, scale: scale}
+}
+
+func (d decimal) cmp(other decimal) int {
+	scale := max(d.scale, other.scale)
+	return d.rescale(scale).unscaled.Cmp(other.rescale(scale).unscaled)
+}
+
+func (d decimal) float() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+func (d decimal) arithmetic(op Operator, other decimal) (decimal, error) {
+	scale := max(d.scale, other.scale)
+	l, r := d.rescale(scale).unscaled, other.rescale(scale).unscaled
+	switch op {
+	case Plus:
+		return decimal{unscaled: new(big.Int).Add(l, r), scale: scale}, nil
+	case Minus:
+		return decimal{unscaled: new(big.Int).Sub(l, r), scale: scale}, nil
+	case Multiply:
+		return decimal{unscaled: new(big.Int).Mul(d.unscaled, other.unscaled), scale: d.scale + other.scale}, nil
+	}
+	if other.unscaled.Sign() == 0 {
+		return decimal{}, errors.New("division by zero")
+	}
+	//the quotient is worked out with one more digit than kept so it can be rounded
+	quotientScale := scale + divisionScale
+	numerator := new(big.Int).Mul(l, pow10(quotientScale+1))
+	quotient := decimal{unscaled: numerator.Quo(numerator, r), scale: quotientScale + 1}
+	return quotient.rescale(quotientScale), nil
+}
+
+// operands of exact arithmetic, a decimal with another decimal or an integer
+func decimalOperands(left, right any) (decimal, decimal, bool) {
+	l, lok := left.(decimal)
+	r, rok := right.(decimal)
+	if !lok && !rok {
+		return decimal{}, decimal{}, false
+	}
+	if n, ok := bigInteger(left); ok {
+		l, lok = decimal{unscaled: n}, true
+	}
+	if n, ok := bigInteger(right); ok {
+		r, rok = decimal{unscaled: n}, true
+	}
+	return l, r, lok && rok
+}
+
+// converts v to a decimal of DECIMAL(precision, scale), failing when it has too many digits before the point
+func toDecimal(v any, precision, scale uint8) (decimal, error) {
+	var d decimal
+	switch n := v.(type) {
+	case decimal:
+		d = n
+	case int64:
+		d = decimalFromInt(n)
+	case uint64:
+		d = decimal{unscaled: new(big.Int).SetUint64(n)}
+	case float64:
+		var err error
+		if d, err = decimalFromFloat(n); err != nil {
+			return decimal{}, err
+		}
+	default:
+		return decimal{}, fmt.Errorf("value %v is not a number", v)
+	}
+	d = d.rescale(int32(scale))
+	if new(big.Int).Abs(d.unscaled).Cmp(pow10(int32(precision))) >= 0 {
+		return decimal{}, fmt.Errorf("value %s does not fit DECIMAL(%d,%d)", d, precision, scale)
+	}
+	return d, nil
+}
+
+// bytes a DECIMAL column of precision takes in a row
+func decimalSize(precision uint8) uint16 {
+	if precision <= 18 {
+		return 8
+	}
+	return 16
+}
+
+// encodes the unscaled value of d as a little endian two's complement integer of size bytes
+func decimalCell(d decimal, size int) Cell {
+	n := new(big.Int).Set(d.unscaled)
+	if n.Sign() < 0 {
+		n.Add(n, new(big.Int).Lsh(big.NewInt(1), uint(size*8)))
+	}
+	buf := make(Cell, size)
+	n.FillBytes(buf)
+	for i, j := 0, len(buf)-1; i < j; i, j = i+1, j-1 {
+		buf[i], buf[j] = buf[j], buf[i]
+	}
+	return buf
+}
+
+func (c *Cell) asDecimal(scale uint8) decimal {
+	if len(*c) == 8 {
+		return decimal{unscaled: big.NewInt(int64(binary.LittleEndian.Uint64(*c))), scale: int32(scale)}
+	}
+	bigEndian := make([]byte, len(*c))
+	for i := range bigEndian {
+		bigEndian[i] = (*c)[len(*c)-1-i]
+	}
+	n := new(big.Int).SetBytes(bigEndian)
+	if bigEndian[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(bigEndian)*8)))
+	}
+	return decimal{unscaled: n, scale: int32(scale)}
+}
+
+// order preserving key of d, values of a column share its scale so only the unscaled value is encoded
+func appendDecimalKey(buf []byte, d decimal) []byte {
+	key := decimalCell(d, 16)
+	for i, j := 0, len(key)-1; i < j; i, j = i+1, j-1 {
+		key[i], key[j] = key[j], key[i]
+	}
+	key[0] ^= 0x80 //flipping the sign bit puts negatives first
+	return append(buf, key...)
+}
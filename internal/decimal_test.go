@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecimal(t *testing.T) {
+	dec := func(s string) decimal {
+		d, err := parseDecimal(s)
+		require.NoError(t, err, s)
+		return d
+	}
+	for _, s := range []string{"0", "12.50", "-0.05", "123456789012345678901234567890.12345678"} {
+		require.Equal(t, s, dec(s).String())
+	}
+	require.Equal(t, "0.5", dec(".5").String())
+	_, err := parseDecimal("1.2.3")
+	require.EqualError(t, err, "cannot read '1.2.3' as a decimal")
+
+	require.Equal(t, "1.01", dec("1.005").rescale(2).String(), "halves round away from zero")
+	require.Equal(t, "-1.01", dec("-1.005").rescale(2).String())
+	require.Equal(t, "1.00", dec("1.004").rescale(2).String())
+
+	ts := []struct {
+		Left     string
+		Op       Operator
+		Right    string
+		Expected string
+	}{
+		{"0.1", Plus, "0.2", "0.3"},
+		{"10.00", Minus, "0.015", "9.985"},
+		{"1.5", Multiply, "-1.25", "-1.875"},
+		{"1", Divide, "3", "0.3333333333333333"},
+		{"2.00", Divide, "3", "0.666666666666666667"},
+	}
+	for _, tc := range ts {
+		got, err := dec(tc.Left).arithmetic(tc.Op, dec(tc.Right))
+		require.NoError(t, err)
+		require.Equal(t, tc.Expected, got.String(), "%s %s %s", tc.Left, tc.Op, tc.Right)
+	}
+	_, err = dec("1").arithmetic(Divide, dec("0.00"))
+	require.EqualError(t, err, "division by zero")
+	require.Equal(t, 0, dec("1.50").cmp(dec("1.5")))
+
+	for _, precision := range []uint8{5, 38} {
+		for _, s := range []string{"0.00", "-999.99", "999.99", "0.01", "-0.01"} {
+			d, err := toDecimal(dec(s), precision, 2)
+			require.NoError(t, err)
+			cell := decimalCell(d, int(decimalSize(precision)))
+			require.Equal(t, s, cell.asDecimal(2).String(), "DECIMAL(%d,2) keeps %s", precision, s)
+		}
+	}
+	_, err = toDecimal(dec("1000"), 5, 2)
+	require.EqualError(t, err, "value 1000.00 does not fit DECIMAL(5,2)")
+	d, err := toDecimal(0.1, 10, 3)
+	require.NoError(t, err)
+	require.Equal(t, "0.100", d.String(), "floats convert through their shortest representation")
+}
+
+func TestDecimalType(t *testing.T) {
+	dir := t.TempDir()
+	b := CreateNewDatabase(dir)
+	for _, sql := range []string{
+		"CREATE TABLE invoices (id int primary key, total decimal(10, 2), rate numeric(30, 8), qty decimal(4));",
+		"CREATE INDEX invoices_total ON invoices (total);",
+		"INSERT INTO invoices (id, total, rate, qty) VALUES (1, 0.1, '1.5', 3), (2, -12.345, 0.00000001, -2), (3, 1.005, 123456789012345678901.12345678, 0), (4, NULL, NULL, NULL);",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	require.Equal(t, [][]any{
+		{"0.10", "1.50000000", "3"},
+		{"-12.35", "0.00000001", "-2"},
+		{"1.01", "123456789012345678901.12345678", "0"},
+		{nil, nil, nil},
+	}, queryRows(t, b, "SELECT total, rate, qty FROM invoices;"))
+
+	ts := []struct {
+		SQL      string
+		Expected [][]any
+	}{
+		{"SELECT id FROM invoices WHERE total < 0;", [][]any{{int64(2)}}},
+		{"SELECT id FROM invoices WHERE total > -100 AND total <= 1.01;", [][]any{{int64(1)}, {int64(2)}, {int64(3)}}},
+		{"SELECT id FROM invoices WHERE total > 1.005;", [][]any{{int64(3)}}},
+		{"SELECT id FROM invoices WHERE total = '0.1';", [][]any{{int64(1)}}},
+		{"SELECT id FROM invoices WHERE total + 0.2 = 0.3;", [][]any{{int64(1)}}},
+		{"SELECT id FROM invoices WHERE rate * qty = 4.5;", [][]any{{int64(1)}}},
+		{"SELECT total * 3, total / 3, rate - total, total * 0.5 + qty FROM invoices WHERE id <= 2;",
+			[][]any{{"0.30", "0.033333333333333333", "1.40000000", "3.050"}, {"-37.05", "-4.116666666666666667", "12.35000001", "-8.175"}}},
+		{"UPDATE invoices SET total = total * 3 + 0.001, rate = rate / 3 WHERE id = 1 RETURNING total, rate;", [][]any{{"0.30", "0.50000000"}}},
+		{"UPDATE invoices SET total = total + 0.1 + 0.1 + 0.1 WHERE id = 1 RETURNING total;", [][]any{{"0.60"}}},
+	}
+	for _, tc := range ts {
+		require.Equal(t, tc.Expected, queryRows(t, b, tc.SQL), tc.SQL)
+	}
+
+	for sql, expected := range map[string]string{
+		"INSERT INTO invoices (id, total) VALUES (5, 123456789.1);":  "insert: value 123456789.10 does not fit DECIMAL(10,2)",
+		"INSERT INTO invoices (id, qty) VALUES (5, 'many');":         "insert: cannot convert 'many' for column qty",
+		"SELECT id FROM invoices WHERE total / 0.0 > 1;":             "division by zero",
+		"CREATE TABLE bad (id int primary key, n decimal);":          "precision needed for decimal field in table",
+		"CREATE TABLE bad (id int primary key, n decimal(39, 2));":   "precision for decimal field must be between 1 and 38",
+		"CREATE TABLE bad (id int primary key, n numeric(4, 5));":    "scale for decimal field must be between 0 and its precision",
+		"CREATE TABLE bad (id int primary key, n decimal(4, 1, 1));": "precision needed for decimal field in table",
+	} {
+		_, err := execSQL(b, sql)
+		require.EqualError(t, err, expected, sql)
+	}
+	b.Close()
+
+	b, err := OpenExistingDatabase(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	require.Equal(t, [][]any{{int64(1), "0.60"}, {int64(2), "-12.35"}}, queryRows(t, b, "SELECT id, total FROM invoices WHERE total < 1;"), "precision and scale survive a reopen")
+}
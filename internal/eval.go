@@ -0,0 +1,732 @@
+package internal
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Evaluates expressions of the syntax tree against a single row.
+Values are plain go values: nil (sql NULL), int64, uint64 (above math.MaxInt64), float64, bool, string, time.Time, interval, decimal, jsonDoc and uuid.
+Comparisons follow sql three valued logic, anything compared to NULL is NULL.
+*/
+
+// rowScope resolves column references of an expression to positions in a row of table
+type rowScope struct {
+	table    *Table
+	alias    string
+	excluded bool // excluded.column refers to a second row of the table following the first, ie. the row proposed by INSERT ... ON CONFLICT
+}
+
+func newRowScope(table *Table, ref *TableName) *rowScope {
+	scope := &rowScope{table: table}
+	if ref != nil {
+		scope.alias = ref.Alias
+	}
+	return scope
+}
+
+// returns position of the referenced column in a row of the table
+func (s *rowScope) lookup(ident *Identifier) (int, error) {
+	offset := 0
+	if s.excluded && ident.Table == "excluded" {
+		offset = len(s.table.Columns)
+	} else if ident.Table != "" && ident.Table != s.table.Name && ident.Table != s.alias {
+		return 0, fmt.Errorf("unknown table %q in column reference %s", ident.Table, FormatExpr(ident))
+	}
+	for i := range s.table.Columns {
+		if s.table.Columns[i].columnName == ident.Name {
+			return offset + i, nil
+		}
+	}
+	return 0, fmt.Errorf("Columns not in table: %s", ident.Name)
+}
+
+// checks that every column referenced in expr exists so errors are reported even when no rows are read
+func (s *rowScope) bind(expr Expr) error {
+	var err error
+	walkExpr(expr, func(e Expr) bool {
+		if ident, ok := e.(*Identifier); ok && err == nil {
+			_, err = s.lookup(ident)
+		}
+		return err == nil
+	})
+	return err
+}
+
+// column the values of a computed expression are read as, false for expressions with no known type
+func (s *rowScope) exprColumn(expr Expr) (Column, bool) {
+	switch e := expr.(type) {
+	case *Identifier:
+		pos, err := s.lookup(e)
+		if err != nil || pos >= len(s.table.Columns) {
+			return Column{}, false
+		}
+		return s.table.Columns[pos], true
+	}
+	col, ok := exprType(expr)
+	col.columnName, col.columnIsNullable = FormatExpr(expr), true
+	return col, ok
+}
+
+// type of the values of the JSON path operators and the functions
+func exprType(expr Expr) (Column, bool) {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		switch e.Operator {
+		case JSONGet:
+			return Column{columnType: JSON}, true
+		case JSONGetText:
+			return Column{columnType: TEXT}, true
+		}
+	case *FuncCall:
+		switch e.Name {
+		case "JSON_EXTRACT", "JSON_SET":
+			return Column{columnType: JSON}, true
+		case "JSON_ARRAY_LENGTH":
+			return Column{columnType: INT, columnSize: 8}, true
+		case "NOW", "DATE_TRUNC":
+			return Column{columnType: TIMESTAMP, columnSize: 8}, true
+		case "GEN_RANDOM_UUID":
+			return Column{columnType: UUID, columnSize: 16}, true
+		}
+	}
+	return Column{}, false
+}
+
+// column the values of a selected expression are read as, false when its operands cannot be combined
+func (s *rowScope) valueColumn(expr Expr) (Column, bool) {
+	col, ok := s.valueType(expr)
+	if col.columnType == 0 { //NULL
+		col = Column{columnType: TEXT}
+	}
+	col.columnName, col.columnIsNullable = FormatExpr(expr), true
+	return col, ok
+}
+
+/*
+valueType works out the type of the values of expr following the rules of eval, the NULL literal has type 0.
+Arithmetic on JSON values is read as FLOAT since the numbers of a document have no declared type
+*/
+func (s *rowScope) valueType(expr Expr) (Column, bool) {
+	boolean := Column{columnType: BOOL, columnSize: 1}
+	switch e := expr.(type) {
+	case *Identifier, *FuncCall:
+		return s.exprColumn(expr)
+	case *Literal:
+		return literalType(e)
+	case *InExpr, *IsNullExpr:
+		return boolean, true
+	case *UnaryExpr:
+		if e.Operator == Not {
+			return boolean, true
+		}
+		col, ok := s.valueType(e.Expr)
+		switch col.columnType {
+		case UINT:
+			return Column{columnType: INT, columnSize: 8}, ok
+		case JSON:
+			return Column{}, false
+		}
+		return col, ok && (col.columnType == 0 || numericType(col.columnType) || col.columnType == INTERVAL)
+	case *BinaryExpr:
+		switch e.Operator {
+		case JSONGet, JSONGetText:
+			return exprType(e)
+		case Plus, Minus, Multiply, Divide:
+			left, lok := s.valueType(e.Left)
+			right, rok := s.valueType(e.Right)
+			if !lok || !rok {
+				return Column{}, false
+			}
+			return arithmeticType(e.Operator, left, right)
+		}
+		return boolean, true
+	}
+	return Column{}, false
+}
+
+// type of a literal, 0 for NULL
+func literalType(lit *Literal) (Column, bool) {
+	switch lit.Kind {
+	case StringLiteral:
+		return Column{columnType: TEXT}, true
+	case HexLiteral:
+		return Column{columnType: BLOB}, true
+	case BoolLiteral:
+		return Column{columnType: BOOL, columnSize: 1}, true
+	case NullLiteral:
+		return Column{}, true
+	case DateLiteral:
+		return Column{columnType: DATE, columnSize: 4}, true
+	case TimeLiteral:
+		return Column{columnType: TIME, columnSize: 8}, true
+	case TimestampLiteral:
+		return Column{columnType: TIMESTAMP, columnSize: 8}, true
+	case IntervalLiteral:
+		return Column{columnType: INTERVAL, columnSize: 16}, true
+	}
+	v, err := literalValue(lit)
+	if err != nil {
+		return Column{}, false
+	}
+	switch n := v.(type) {
+	case int64:
+		return Column{columnType: INT, columnSize: 8}, true
+	case uint64:
+		return Column{columnType: UINT, columnSize: 8}, true
+	case decimal:
+		return decimalColumn(n.scale), true
+	}
+	return Column{columnType: FLOAT, columnSize: 8}, true
+}
+
+// DECIMAL of the largest precision holding values of scale
+func decimalColumn(scale int32) Column {
+	return Column{columnType: DECIMAL, columnPrecision: maxDecimalPrecision, columnScale: uint8(min(scale, maxDecimalPrecision)), columnSize: decimalSize(maxDecimalPrecision)}
+}
+
+func numericType(columnType uint8) bool {
+	switch columnType {
+	case INT, UINT, FLOAT, DECIMAL:
+		return true
+	}
+	return false
+}
+
+func temporalType(columnType uint8) bool {
+	return columnType == DATE || columnType == TIME || columnType == TIMESTAMP
+}
+
+/*
+arithmeticType is the type of left op right, in the order of arithmetic: temporal values, decimals, integers and floats.
+Like in Postgres a DATE moved by an INTERVAL is a TIMESTAMP and by a number of days a DATE. An operand of type 0 is NULL,
+the result is NULL of the type of the other operand
+*/
+func arithmeticType(op Operator, left, right Column) (Column, bool) {
+	lt, rt := left.columnType, right.columnType
+	if lt == 0 {
+		return right, true
+	} else if rt == 0 {
+		return left, true
+	}
+	intervalColumn := Column{columnType: INTERVAL, columnSize: 16}
+	moves := op == Plus || op == Minus
+	switch {
+	case temporalType(lt) && rt == INTERVAL && moves, lt == INTERVAL && temporalType(rt) && op == Plus:
+		moved := left
+		if lt == INTERVAL {
+			moved = right
+		}
+		if moved.columnType == DATE {
+			return Column{columnType: TIMESTAMP, columnSize: 8}, true
+		}
+		return moved, true
+	case temporalType(lt) && rt == INT && moves:
+		return left, true
+	case lt == INT && temporalType(rt) && op == Plus:
+		return right, true
+	case temporalType(lt) && temporalType(rt) && op == Minus,
+		lt == INTERVAL && rt == INTERVAL && moves,
+		lt == INTERVAL && rt == INT && op == Multiply:
+		return intervalColumn, true
+	case temporalType(lt) || temporalType(rt) || lt == INTERVAL || rt == INTERVAL:
+		return Column{}, false
+	}
+	if (!numericType(lt) && lt != JSON) || (!numericType(rt) && rt != JSON) {
+		return Column{}, false
+	}
+	switch {
+	case lt == JSON || rt == JSON || lt == FLOAT || rt == FLOAT:
+		return Column{columnType: FLOAT, columnSize: 8}, true
+	case lt == DECIMAL || rt == DECIMAL:
+		ls, rs := int32(left.columnScale), int32(right.columnScale) //integers have scale 0
+		switch op {
+		case Multiply:
+			return decimalColumn(ls + rs), true
+		case Divide:
+			return decimalColumn(max(ls, rs) + divisionScale), true
+		}
+		return decimalColumn(max(ls, rs)), true
+	}
+	return Column{columnType: INT, columnSize: 8}, true
+}
+
+// evaluates an expression that may not reference columns, ie. a value of an INSERT
+func evalConstant(expr Expr) (any, error) {
+	var column *Identifier
+	walkExpr(expr, func(e Expr) bool {
+		if ident, ok := e.(*Identifier); ok && column == nil {
+			column = ident
+		}
+		return column == nil
+	})
+	if column != nil {
+		return nil, fmt.Errorf("column %s cannot be used in a constant expression", FormatExpr(column))
+	}
+	return (&rowScope{table: &Table{}}).eval(expr, nil)
+}
+
+// cellValue decodes a stored cell of col into its go value
+func cellValue(col *Column, cell Cell) any {
+	if cell == nil {
+		return nil
+	}
+	switch col.columnType {
+	case INT:
+		return cell.AsInt()
+	case UINT:
+		if len(cell) == 8 {
+			return cell.asUint()
+		}
+		return int64(cell.asUint())
+	case FLOAT:
+		return cell.AsFloat()
+	case BOOL:
+		return cell.AsBool()
+	case CHAR:
+		return strings.TrimRight(cell.AsString(), "\x00")
+	case VARCHAR, TEXT, BLOB:
+		return cell.AsString()
+	case DATE, TIME, TIMESTAMP:
+		return cell.AsTime(col.columnType)
+	case INTERVAL:
+		return cell.asInterval()
+	case DECIMAL:
+		return cell.asDecimal(col.columnScale)
+	case JSON:
+		return jsonDoc(cell)
+	case UUID:
+		return uuid(cell)
+	}
+	return nil
+}
+
+func literalValue(lit *Literal) (any, error) {
+	switch lit.Kind {
+	case StringLiteral:
+		return lit.Value, nil
+	case NumberLiteral:
+		if n, err := strconv.ParseInt(lit.Value, 10, 64); err == nil {
+			return n, nil
+		}
+		if n, err := strconv.ParseUint(lit.Value, 10, 64); err == nil {
+			return n, nil
+		}
+		if d, err := parseDecimal(lit.Value); err == nil { //numbers with a point are exact
+			return d, nil
+		}
+		return strconv.ParseFloat(lit.Value, 64)
+	case BoolLiteral:
+		return lit.Value == "TRUE", nil
+	case NullLiteral:
+		return nil, nil
+	case DateLiteral, TimeLiteral, TimestampLiteral:
+		t, err := parseTemporal(lit.Value)
+		if err != nil {
+			return nil, err
+		}
+		switch lit.Kind {
+		case DateLiteral:
+			return dateTrunc("day", t)
+		case TimeLiteral:
+			return time.Date(0, 1, 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC), nil
+		}
+		return t, nil
+	case IntervalLiteral:
+		return parseInterval(lit.Value)
+	case HexLiteral:
+		b, err := hex.DecodeString(lit.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex literal X'%s'", lit.Value)
+		}
+		return string(b), nil
+	}
+	return nil, fmt.Errorf("unknown literal %q", lit.Value)
+}
+
+// valueLiteral is the literal written for a value
+func valueLiteral(v any) *Literal {
+	switch n := v.(type) {
+	case int64:
+		return &Literal{Kind: NumberLiteral, Value: strconv.FormatInt(n, 10)}
+	case uint64:
+		return &Literal{Kind: NumberLiteral, Value: strconv.FormatUint(n, 10)}
+	case float64:
+		return &Literal{Kind: NumberLiteral, Value: strconv.FormatFloat(n, 'f', -1, 64)}
+	case bool:
+		return &Literal{Kind: BoolLiteral, Value: strings.ToUpper(strconv.FormatBool(n))}
+	case string:
+		return &Literal{Kind: StringLiteral, Value: n}
+	case time.Time:
+		return &Literal{Kind: TimestampLiteral, Value: n.Format(time.RFC3339Nano)}
+	case interval:
+		return &Literal{Kind: IntervalLiteral, Value: n.String()}
+	case decimal:
+		return &Literal{Kind: NumberLiteral, Value: n.String()}
+	case jsonDoc:
+		return &Literal{Kind: StringLiteral, Value: n.String()}
+	case uuid:
+		return &Literal{Kind: StringLiteral, Value: n.String()}
+	}
+	return &Literal{Kind: NullLiteral, Value: "NULL"}
+}
+
+// evaluates expr for row, row holds the cells of every column of the scope's table
+func (s *rowScope) eval(expr Expr, row []Cell) (any, error) {
+	switch e := expr.(type) {
+	case *Identifier:
+		i, err := s.lookup(e)
+		if err != nil {
+			return nil, err
+		}
+		return cellValue(&s.table.Columns[i%len(s.table.Columns)], row[i]), nil
+	case *Literal:
+		return literalValue(e)
+	case *UnaryExpr:
+		v, err := s.eval(e.Expr, row)
+		if err != nil || v == nil {
+			return nil, err
+		}
+		switch e.Operator {
+		case Not:
+			b, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("NOT expects a boolean, got %v", v)
+			}
+			return !b, nil
+		case Minus:
+			switch n := v.(type) {
+			case int64:
+				return -n, nil
+			case uint64:
+				return integerValue(new(big.Int).Neg(new(big.Int).SetUint64(n)))
+			case float64:
+				return -n, nil
+			case interval:
+				return n.negate(), nil
+			case decimal:
+				return decimal{unscaled: new(big.Int).Neg(n.unscaled), scale: n.scale}, nil
+			}
+			return nil, fmt.Errorf("cannot negate %v", v)
+		}
+	case *BinaryExpr:
+		if e.Operator == And || e.Operator == Or {
+			return s.evalLogical(e, row)
+		}
+		left, err := s.eval(e.Left, row)
+		if err != nil {
+			return nil, err
+		}
+		right, err := s.eval(e.Right, row)
+		if err != nil {
+			return nil, err
+		}
+		return binaryValue(e.Operator, left, right)
+	case *InExpr:
+		v, err := s.eval(e.Expr, row)
+		if err != nil || v == nil {
+			return nil, err
+		}
+		var result any = false
+		for _, item := range e.List {
+			candidate, err := s.eval(item, row)
+			if err != nil {
+				return nil, err
+			}
+			if candidate == nil {
+				result = nil
+				continue
+			}
+			order, err := compareValues(v, candidate)
+			if err != nil {
+				return nil, err
+			}
+			if order == 0 {
+				result = true
+				break
+			}
+		}
+		if b, ok := result.(bool); ok && e.Not {
+			return !b, nil
+		}
+		return result, nil
+	case *IsNullExpr:
+		v, err := s.eval(e.Expr, row)
+		if err != nil {
+			return nil, err
+		}
+		return (v == nil) != e.Not, nil
+	case *FuncCall:
+		if e.Star {
+			return nil, fmt.Errorf("%s(*) is not supported here", e.Name)
+		}
+		args := make([]any, len(e.Args))
+		for i := range e.Args {
+			var err error
+			if args[i], err = s.eval(e.Args[i], row); err != nil {
+				return nil, err
+			}
+		}
+		return callFunction(e.Name, args)
+	}
+	return nil, fmt.Errorf("cannot evaluate %s", FormatExpr(expr))
+}
+
+// AND / OR with NULL treated as unknown
+func (s *rowScope) evalLogical(e *BinaryExpr, row []Cell) (any, error) {
+	left, err := s.eval(e.Left, row)
+	if err != nil {
+		return nil, err
+	}
+	lb, ok := left.(bool)
+	if left != nil && !ok {
+		return nil, fmt.Errorf("%s expects booleans, got %v", e.Operator, left)
+	}
+	if left != nil && lb == (e.Operator == Or) { //short circuit on FALSE AND ..., TRUE OR ...
+		return lb, nil
+	}
+	right, err := s.eval(e.Right, row)
+	if err != nil {
+		return nil, err
+	}
+	rb, ok := right.(bool)
+	if right != nil && !ok {
+		return nil, fmt.Errorf("%s expects booleans, got %v", e.Operator, right)
+	}
+	if right != nil && rb == (e.Operator == Or) {
+		return rb, nil
+	}
+	if left == nil || right == nil {
+		return nil, nil
+	}
+	return rb, nil
+}
+
+// reports whether expr is TRUE for row, NULL counts as not matching
+func (s *rowScope) matches(expr Expr, row []Cell) (bool, error) {
+	if expr == nil {
+		return true, nil
+	}
+	v, err := s.eval(expr, row)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if v != nil && !ok {
+		return false, fmt.Errorf("condition %s is not a boolean", FormatExpr(expr))
+	}
+	return ok && b, nil
+}
+
+func binaryValue(op Operator, left, right any) (any, error) {
+	if left == nil || right == nil {
+		return nil, nil
+	}
+	switch op {
+	case JSONGet:
+		return jsonExtract(left, right)
+	case JSONGetText:
+		value, err := jsonExtract(left, right)
+		if doc, ok := value.(jsonDoc); ok {
+			return doc.text(), err
+		}
+		return value, err
+	}
+	if left, right = jsonScalars(op, left, right); left == nil || right == nil {
+		return nil, nil //JSON null
+	}
+	switch op {
+	case Eq, Ne, Gt, Lt, Gte, Lte:
+		if _, ok := left.(bool); ok && op != Eq && op != Ne {
+			return nil, errors.New("cannot use this operator for comparing booleans")
+		}
+		order, err := compareValues(left, right)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case Eq:
+			return order == 0, nil
+		case Ne:
+			return order != 0, nil
+		case Gt:
+			return order > 0, nil
+		case Lt:
+			return order < 0, nil
+		case Gte:
+			return order >= 0, nil
+		default:
+			return order <= 0, nil
+		}
+	case Plus, Minus, Multiply, Divide:
+		return arithmetic(op, left, right)
+	}
+	return nil, fmt.Errorf("unknown operator %s", op)
+}
+
+func arithmetic(op Operator, left, right any) (any, error) {
+	if v, ok, err := temporalArithmetic(op, left, right); ok {
+		return v, err
+	}
+	if l, r, ok := decimalOperands(left, right); ok {
+		return l.arithmetic(op, r)
+	}
+	if l, r, ok := integerOperands(left, right); ok {
+		return integerArithmetic(op, l, r)
+	}
+	li, lok := left.(int64)
+	ri, rok := right.(int64)
+	if lok && rok {
+		switch op {
+		case Plus:
+			return li + ri, nil
+		case Minus:
+			return li - ri, nil
+		case Multiply:
+			return li * ri, nil
+		default:
+			if ri == 0 {
+				return nil, errors.New("division by zero")
+			}
+			return li / ri, nil
+		}
+	}
+	lf, lok := toFloat(left)
+	rf, rok := toFloat(right)
+	if !lok || !rok {
+		return nil, fmt.Errorf("cannot apply %s to %v and %v", op, left, right)
+	}
+	switch op {
+	case Plus:
+		return lf + rf, nil
+	case Minus:
+		return lf - rf, nil
+	case Multiply:
+		return lf * rf, nil
+	default:
+		if rf == 0 {
+			return nil, errors.New("division by zero")
+		}
+		return lf / rf, nil
+	}
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case decimal:
+		return n.float(), true
+	}
+	return 0, false
+}
+
+// compareValues orders two non NULL values, a string compared to another type is converted to that type first
+func compareValues(left, right any) (int, error) {
+	if ls, ok := left.(string); ok {
+		if _, ok := right.(string); !ok {
+			converted, err := convertString(ls, right)
+			if err != nil {
+				return 0, err
+			}
+			left = converted
+		}
+	} else if rs, ok := right.(string); ok {
+		converted, err := convertString(rs, left)
+		if err != nil {
+			return 0, err
+		}
+		right = converted
+	}
+
+	if order, ok := compareTemporal(left, right); ok {
+		return order, nil
+	}
+	if l, ok := left.(jsonDoc); ok {
+		if r, ok := right.(jsonDoc); ok {
+			return compareJSON(l, r), nil
+		}
+	}
+	if l, r, ok := decimalOperands(left, right); ok {
+		return l.cmp(r), nil
+	}
+	if l, r, ok := integerOperands(left, right); ok {
+		return l.Cmp(r), nil
+	}
+	switch l := left.(type) {
+	case int64:
+		if r, ok := right.(int64); ok {
+			return cmp.Compare(l, r), nil
+		}
+	case uuid:
+		if r, ok := right.(uuid); ok {
+			return bytes.Compare(l[:], r[:]), nil
+		}
+	case string:
+		if r, ok := right.(string); ok {
+			return strings.Compare(l, r), nil
+		}
+	case bool:
+		if r, ok := right.(bool); ok {
+			if l == r {
+				return 0, nil
+			} else if !l {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	lf, lok := toFloat(left)
+	rf, rok := toFloat(right)
+	if lok && rok {
+		return cmp.Compare(lf, rf), nil
+	}
+	return 0, fmt.Errorf("cannot compare %v to %v", left, right)
+}
+
+// converts string literal s to the type of like
+func convertString(s string, like any) (any, error) {
+	switch like.(type) {
+	case int64, uint64:
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n, nil
+		}
+		if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return n, nil
+		}
+		if d, err := parseDecimal(s); err == nil { //read the way number literals are
+			return d, nil
+		}
+		return strconv.ParseFloat(s, 64)
+	case float64:
+		return strconv.ParseFloat(s, 64)
+	case bool:
+		return strconv.ParseBool(s)
+	case time.Time:
+		return parseTemporal(s)
+	case interval:
+		return parseInterval(s)
+	case decimal:
+		return parseDecimal(s)
+	case jsonDoc:
+		return parseJSON(s)
+	case uuid:
+		if u, ok := toUUID(s); ok {
+			return u, nil
+		}
+		return parseUUID(s)
+	}
+	return s, nil
+}
@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"database/sql/driver"
+	"errors"
+	"math"
+)
+
+/*
+EXPLAIN returns one row per operator of the plan in depth first order.
+Each row has an id and the id of its parent (0 for the root) so the tree can be rebuilt,
+followed by the rows and cost the planner estimated for the operator.
+EXPLAIN ANALYZE runs the statement first and adds what every operator actually did,
+the changes of an explained UPDATE or DELETE are written like those of the statement itself.
+*/
+
+var explainColumns = []ResultColumn{
+	{Name: "id", ColumnType: INT, columnPos: 0},
+	{Name: "parent", ColumnType: INT, columnPos: 1},
+	{Name: "operator", ColumnType: CHAR, columnPos: 2},
+	{Name: "detail", ColumnType: CHAR, columnPos: 3},
+	{Name: "est_rows", ColumnType: INT, columnPos: 4},
+	{Name: "cost", ColumnType: FLOAT, columnPos: 5},
+}
+
+var analyzeColumns = []ResultColumn{
+	{Name: "rows", ColumnType: INT, columnPos: 6},
+	{Name: "pages", ColumnType: INT, columnPos: 7},
+	{Name: "hits", ColumnType: INT, columnPos: 8},
+	{Name: "misses", ColumnType: INT, columnPos: 9},
+	{Name: "time_ms", ColumnType: FLOAT, columnPos: 10},
+}
+
+func (b *Backend) Explain(q Query) (driver.Rows, error) {
+	stmt, ok := q.Statement.(*ExplainStatement)
+	if !ok {
+		return nil, errors.New("not an EXPLAIN statement")
+	}
+	inner := lowerStatement(stmt.Statement)
+	if inner.Type != Select && inner.Type != Update && inner.Type != Delete {
+		return nil, errors.New("only SELECT, UPDATE and DELETE statements can be explained")
+	}
+	table, ok := b.checkTableExist(inner)
+	if !ok {
+		return nil, errors.New("Table does not exist")
+	}
+
+	var plan planNode
+	var err error
+	switch s := inner.Statement.(type) {
+	case *UpdateStatement:
+		defer b.lockTables(table)()
+		plan, _, err = b.planUpdate(table, s)
+	case *DeleteStatement:
+		defer b.lockTables(table)()
+		plan, _, err = b.planDelete(table, s)
+	default:
+		table.tableLock.RLock()
+		defer table.tableLock.RUnlock()
+		plan, err = b.planSelect(table, inner)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stmt.Analyze {
+		if _, err := drain(plan); err != nil {
+			return nil, err
+		}
+	}
+
+	rows := &Rows{columns: explainColumns}
+	if stmt.Analyze {
+		rows.columns = append(explainColumns[:len(explainColumns):len(explainColumns)], analyzeColumns...)
+	}
+	rows.rows = explainRows(plan, 0, stmt.Analyze, nil)
+	return rows, nil
+}
+
+// appends a row for node and each operator below it to rows
+func explainRows(node planNode, parent int64, analyze bool, rows [][]Cell) [][]Cell {
+	id := int64(len(rows) + 1)
+	operator, detail := node.explain()
+	st := node.stats()
+	row := []Cell{newCell(id), newCell(parent), newCell(operator), newCell(detail), newCell(int64(math.Round(st.estRows))), newCell(math.Round(st.cost*100) / 100)}
+	if analyze {
+		row = append(row,
+			newCell(st.rows),
+			newCell(st.buffer.hits+st.buffer.misses),
+			newCell(st.buffer.hits),
+			newCell(st.buffer.misses),
+			newCell(float64(st.elapsed.Microseconds())/1000),
+		)
+	}
+	rows = append(rows, row)
+	for _, child := range node.children() {
+		rows = explainRows(child, id, analyze, rows)
+	}
+	return rows
+}
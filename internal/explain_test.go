@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	values := make([]string, 0, 600)
+	for i := 1; i <= 600; i++ {
+		values = append(values, fmt.Sprintf("(%d, %d)", i, i%7))
+	}
+	b := newTestBackend(t,
+		"CREATE TABLE t (id int primary key, n int);",
+		fmt.Sprintf("INSERT INTO t (id, n) VALUES %s;", strings.Join(values, ", ")),
+		"ANALYZE t;",
+	)
+
+	ts := []struct {
+		Name     string
+		SQL      string
+		Expected [][]any
+	}{
+		{
+			Name: "full scan without WHERE",
+			SQL:  "EXPLAIN SELECT * FROM t;",
+			Expected: [][]any{
+				{int64(1), int64(0), "Project", `"id", "n"`},
+				{int64(2), int64(1), "Seq Scan", `on "t"`},
+			},
+		},
+		{
+			Name: "primary key comparison uses the index",
+			SQL:  "EXPLAIN SELECT n FROM t WHERE n = 3 AND id >= 590;",
+			Expected: [][]any{
+				{int64(1), int64(0), "Project", `"n"`},
+				{int64(2), int64(1), "Filter", `"n" = 3`},
+				{int64(3), int64(2), "Index Scan", `on "t" using primary key ("id" >= 590)`},
+			},
+		},
+		{
+			Name: "not equal falls back to a scan",
+			SQL:  "EXPLAIN SELECT id FROM t WHERE id != 4;",
+			Expected: [][]any{
+				{int64(1), int64(0), "Project", `"id"`},
+				{int64(2), int64(1), "Filter", `"id" != 4`},
+				{int64(3), int64(2), "Seq Scan", `on "t"`},
+			},
+		},
+		{
+			Name: "UPDATE reads the rows it changes like a SELECT",
+			SQL:  "EXPLAIN UPDATE t SET n = n + 1 WHERE id = 4;",
+			Expected: [][]any{
+				{int64(1), int64(0), "Update", `on "t"`},
+				{int64(2), int64(1), "Index Scan", `on "t" using primary key ("id" = 4)`},
+			},
+		},
+		{
+			Name: "DELETE",
+			SQL:  "EXPLAIN DELETE FROM t WHERE n = 9;",
+			Expected: [][]any{
+				{int64(1), int64(0), "Delete", `on "t"`},
+				{int64(2), int64(1), "Filter", `"n" = 9`},
+				{int64(3), int64(2), "Seq Scan", `on "t"`},
+			},
+		},
+	}
+	for _, tc := range ts {
+		t.Run(tc.Name, func(t *testing.T) {
+			require.Equal(t, tc.Expected, planShape(queryRows(t, b, tc.SQL)))
+		})
+	}
+
+	t.Run("ANALYZE reports what each operator did", func(t *testing.T) {
+		rows, err := execSQL(b, "EXPLAIN ANALYZE SELECT id FROM t WHERE id > 595 AND n = 1;")
+		require.NoError(t, err)
+		require.Equal(t, []string{"id", "parent", "operator", "detail", "est_rows", "cost", "rows", "pages", "hits", "misses", "time_ms"}, rows.Columns())
+
+		plan := queryRows(t, b, "EXPLAIN ANALYZE SELECT id FROM t WHERE id > 595 AND n = 1;")
+		require.Len(t, plan, 3)
+		require.Equal(t, "Index Scan", plan[2][2])
+		require.Equal(t, int64(5), plan[2][6]) // ids 596 to 600
+		require.Equal(t, int64(1), plan[2][7]) // all on the last page
+		require.Equal(t, int64(1), plan[1][6]) // only 596 has n = 1
+		require.Equal(t, int64(1), plan[0][6])
+		require.Equal(t, plan[2][7], plan[2][8].(int64)+plan[2][9].(int64))
+
+		scan := queryRows(t, b, "EXPLAIN ANALYZE SELECT * FROM t;")
+		require.Equal(t, int64(600), scan[1][6])
+		require.Greater(t, scan[1][7], int64(1))
+		require.Equal(t, int64(0), scan[0][7]) // project reads no pages itself
+	})
+
+	t.Run("ANALYZE writes the changes of UPDATE and DELETE", func(t *testing.T) {
+		plan := queryRows(t, b, "EXPLAIN ANALYZE UPDATE t SET n = 10 WHERE id = 600;")
+		require.Equal(t, "Update", plan[0][2])
+		require.Equal(t, int64(1), plan[0][6])
+		require.Equal(t, int64(1), plan[1][6])
+		require.Equal(t, [][]any{{int64(600)}}, queryRows(t, b, "SELECT id FROM t WHERE n = 10;"))
+
+		plan = queryRows(t, b, "EXPLAIN ANALYZE DELETE FROM t WHERE n = 10;")
+		require.Equal(t, "Delete", plan[0][2])
+		require.Equal(t, int64(1), plan[0][6])
+		require.Equal(t, int64(600), plan[2][6], "every row is scanned")
+		require.Empty(t, queryRows(t, b, "SELECT id FROM t WHERE id = 600;"))
+	})
+
+	_, err := execSQL(b, "EXPLAIN SELECT * FROM missing;")
+	require.EqualError(t, err, "Table does not exist")
+	_, err = execSQL(b, "EXPLAIN UPDATE t SET missing = 1 WHERE id = 1;")
+	require.EqualError(t, err, "Columns not in table: missing")
+	_, err = execSQL(b, "EXPLAIN INSERT INTO t (id, n) VALUES (601, 1);")
+	require.EqualError(t, err, "only SELECT, UPDATE and DELETE statements can be explained")
+}
+
+// keeps the id, parent, operator and detail of every row of an EXPLAIN
+func planShape(rows [][]any) [][]any {
+	for i := range rows {
+		rows[i] = rows[i][:4]
+	}
+	return rows
+}
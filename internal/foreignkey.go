@@ -0,0 +1,353 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// foreignKey is a FOREIGN KEY of a table, its columns reference the primary key of the parent table
+type foreignKey struct {
+	name     string
+	columns  []int //positions of the referencing columns, in the order of the parent's key columns
+	parent   string
+	onDelete ReferentialAction
+	onUpdate ReferentialAction
+}
+
+func (f *foreignKey) toBytes() []byte {
+	buf := make([]byte, 1, 5+len(f.name)+len(f.parent)+2*len(f.columns))
+	buf[0] = uint8(len(f.name))
+	buf = append(buf, f.name...)
+	buf = append(buf, uint8(len(f.parent)))
+	buf = append(buf, f.parent...)
+	buf = append(buf, uint8(f.onDelete), uint8(f.onUpdate), uint8(len(f.columns)))
+	for _, pos := range f.columns {
+		buf = append(buf, uint8(pos), uint8(pos>>8))
+	}
+	return buf
+}
+
+// returns number of bytes read
+func (f *foreignKey) fromBytes(buf []byte) (int, error) {
+	truncated := errors.New("catalog holds a truncated foreign key")
+	if len(buf) < 1 || len(buf) < 2+int(buf[0]) {
+		return 0, truncated
+	}
+	nameSize := int(buf[0])
+	f.name = string(buf[1 : 1+nameSize])
+	offset := 1 + nameSize
+	parentSize := int(buf[offset])
+	if len(buf) < offset+4+parentSize {
+		return 0, truncated
+	}
+	f.parent = string(buf[offset+1 : offset+1+parentSize])
+	offset += 1 + parentSize
+	f.onDelete = ReferentialAction(buf[offset])
+	f.onUpdate = ReferentialAction(buf[offset+1])
+	f.columns = make([]int, buf[offset+2])
+	offset += 3
+	if len(buf) < offset+2*len(f.columns) {
+		return 0, truncated
+	}
+	for i := range f.columns {
+		f.columns[i] = int(buf[offset]) | int(buf[offset+1])<<8
+		offset += 2
+	}
+	return offset, nil
+}
+
+func (f *foreignKey) violation(table *Table) error {
+	return &ConstraintError{Kind: ForeignKeyConstraint, Table: table.Name, Column: table.columnNames(f.columns), Name: f.name, Parent: f.parent}
+}
+
+// resolves a FOREIGN KEY of table, which may reference itself
+func (b *Backend) foreignKey(table *Table, ref ForeignKey) (foreignKey, error) {
+	fk := foreignKey{parent: ref.Table, onDelete: ref.OnDelete, onUpdate: ref.OnUpdate}
+	var err error
+	if fk.columns, err = table.columnPositions(ref.Columns); err != nil {
+		return fk, err
+	}
+	fk.name = uniqueName(table.Name+"_"+strings.Join(ref.Columns, "_")+"_fkey", func(name string) bool {
+		return slices.ContainsFunc(table.foreignKeys, func(f foreignKey) bool { return f.name == name })
+	})
+
+	parent := table
+	if ref.Table != table.Name {
+		var ok bool
+		if parent, ok = b.checkTableExist(Query{TableName: ref.Table}); !ok {
+			return fk, fmt.Errorf("referenced table %s does not exist", ref.Table)
+		}
+	}
+	if len(ref.RefColumns) > 0 {
+		refColumns, err := parent.columnPositions(ref.RefColumns)
+		if err != nil {
+			return fk, err
+		}
+		if !slices.Equal(refColumns, parent.primaryKey) {
+			return fk, fmt.Errorf("foreign key %s must reference the primary key of table %s", fk.name, parent.Name)
+		}
+	}
+	if len(fk.columns) != len(parent.primaryKey) {
+		return fk, fmt.Errorf("foreign key %s has %d columns but the primary key of table %s has %d", fk.name, len(fk.columns), parent.Name, len(parent.primaryKey))
+	}
+	for i, pos := range fk.columns {
+		col, refCol := &table.Columns[pos], &parent.Columns[parent.primaryKey[i]]
+		if col.columnType != refCol.columnType && !(col.isString() && refCol.isString()) {
+			return fk, fmt.Errorf("foreign key %s: column %s and referenced column %s have different types", fk.name, col.columnName, refCol.columnName)
+		}
+	}
+	return fk, nil
+}
+
+// locks the tables and every table linked to them through foreign keys, in name order so writers never wait on each other in a cycle.
+// Returns the unlock function
+func (b *Backend) lockTables(tables ...*Table) func() {
+	references := func(child, parent *Table) bool {
+		return slices.ContainsFunc(child.foreignKeys, func(f foreignKey) bool { return f.parent == parent.Name })
+	}
+	var linked []*Table
+	for _, table := range tables {
+		if !slices.Contains(linked, table) {
+			linked = append(linked, table)
+		}
+	}
+	for i := 0; i < len(linked); i++ {
+		for j := range b.tables {
+			other := &b.tables[j]
+			if slices.Contains(linked, other) {
+				continue
+			}
+			if references(linked[i], other) || references(other, linked[i]) {
+				linked = append(linked, other)
+			}
+		}
+	}
+	slices.SortFunc(linked, func(a, b *Table) int { return strings.Compare(a.Name, b.Name) })
+	for _, t := range linked {
+		t.tableLock.Lock()
+	}
+	return func() {
+		for _, t := range linked {
+			t.tableLock.Unlock()
+		}
+	}
+}
+
+// rowChanges are the rows of a table a write replaces, a nil old row is inserted and a nil new row deleted
+type rowChanges struct {
+	table    *Table
+	old, new [][]Cell
+	changed  map[string]int //primary key of an old row to its position
+}
+
+func (c *rowChanges) add(old, new []Cell) {
+	if old != nil {
+		c.changed[string(c.table.rowKey(old))] = len(c.old)
+	}
+	c.old = append(c.old, old)
+	c.new = append(c.new, new)
+}
+
+// changeSet holds every change of a write, including the ones made by foreign key actions
+type changeSet []*rowChanges
+
+// changes of table, added to the set on first use
+func (s *changeSet) of(table *Table) *rowChanges {
+	for _, c := range *s {
+		if c.table == table {
+			return c
+		}
+	}
+	c := &rowChanges{table: table, changed: make(map[string]int)}
+	*s = append(*s, c)
+	return c
+}
+
+// reports whether parent has a row with the primary key key once the changes are written
+func (s changeSet) hasKey(parent *Table, key []byte) bool {
+	for _, c := range s {
+		if c.table != parent {
+			continue
+		}
+		for _, row := range c.new {
+			if row != nil && bytes.Equal(parent.rowKey(row), key) {
+				return true
+			}
+		}
+		if _, ok := c.changed[string(key)]; ok {
+			return false
+		}
+	}
+	_, ok := parent.indices.primaryTree.findKeyValue(key)
+	return ok
+}
+
+// checks that every foreign key of the changed rows of c references an existing row, keys left unchanged by an update aren't checked again
+func (b *Backend) checkReferences(s changeSet, c *rowChanges) error {
+	table := c.table
+	for i := range table.foreignKeys {
+		fk := &table.foreignKeys[i]
+		parent, ok := b.checkTableExist(Query{TableName: fk.parent})
+		if !ok {
+			return fmt.Errorf("referenced table %s does not exist", fk.parent)
+		}
+		for j, row := range c.new {
+			if row == nil || slices.ContainsFunc(fk.columns, func(pos int) bool { return row[pos] == nil }) {
+				continue
+			}
+			key := table.appendColumnsKey(nil, row, fk.columns)
+			if c.old[j] != nil && bytes.Equal(key, table.appendColumnsKey(nil, c.old[j], fk.columns)) {
+				continue
+			}
+			if !s.hasKey(parent, key) {
+				return fk.violation(table)
+			}
+		}
+	}
+	return nil
+}
+
+// adds to s what the foreign keys referencing table do to their rows when the old rows of table are replaced by the new ones
+func (b *Backend) cascade(s *changeSet, table *Table, old, new [][]Cell) error {
+	for i := range b.tables {
+		child := &b.tables[i]
+		for j := range child.foreignKeys {
+			fk := &child.foreignKeys[j]
+			if fk.parent != table.Name {
+				continue
+			}
+			for k := range old {
+				if old[k] == nil {
+					continue //inserted rows are referenced by nothing yet
+				}
+				key := table.rowKey(old[k])
+				action := fk.onDelete
+				if new[k] != nil {
+					if bytes.Equal(key, table.rowKey(new[k])) {
+						continue
+					}
+					action = fk.onUpdate
+				}
+				if err := b.applyAction(s, child, fk, action, table, old[k], new[k]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// applies action to the rows of child referencing the old parent row
+func (b *Backend) applyAction(s *changeSet, child *Table, fk *foreignKey, action ReferentialAction, parent *Table, old, new []Cell) error {
+	var where Expr
+	for i, pos := range fk.columns {
+		refCol := &parent.Columns[parent.primaryKey[i]]
+		eq := &BinaryExpr{Operator: Eq, Left: &Identifier{Name: child.Columns[pos].columnName}, Right: valueLiteral(cellValue(refCol, old[parent.primaryKey[i]]))}
+		if where == nil {
+			where = eq
+		} else {
+			where = &BinaryExpr{Operator: And, Left: where, Right: eq}
+		}
+	}
+	scan, err := b.planScan(child, newRowScope(child, nil), where)
+	if err != nil {
+		return err
+	}
+	rows, err := drain(scan)
+	if err != nil {
+		return err
+	}
+
+	key := parent.rowKey(old)
+	changes := s.of(child)
+	var childOld, childNew [][]Cell
+	for _, row := range rows {
+		current := row
+		pos, pending := changes.changed[string(child.rowKey(row))]
+		if pending {
+			current = changes.new[pos]
+		}
+		if current == nil || !bytes.Equal(child.appendColumnsKey(nil, current, fk.columns), key) {
+			continue //already deleted or pointed elsewhere by this write
+		}
+
+		var updated []Cell
+		switch {
+		case action == Cascade && new == nil:
+		case action == Cascade:
+			updated = slices.Clone(current)
+			for i, pos := range fk.columns {
+				refCol := &parent.Columns[parent.primaryKey[i]]
+				if updated[pos], err = valueCell(&child.Columns[pos], cellValue(refCol, new[parent.primaryKey[i]])); err != nil {
+					return err
+				}
+			}
+		case action == SetNull:
+			updated = slices.Clone(current)
+			for _, pos := range fk.columns {
+				updated[pos] = nil
+			}
+		default:
+			return fk.violation(child)
+		}
+		if updated != nil {
+			if err := child.computeGenerated(updated); err != nil {
+				return err
+			}
+			if err := child.checkNotNull(updated); err != nil {
+				return err
+			}
+			if err := child.checkRow(updated); err != nil {
+				return err
+			}
+		}
+
+		if pending {
+			changes.new[pos] = updated
+		} else {
+			changes.add(row, updated)
+		}
+		childOld = append(childOld, row)
+		childNew = append(childNew, updated)
+	}
+	if len(childOld) == 0 {
+		return nil
+	}
+	return b.cascade(s, child, childOld, childNew)
+}
+
+/*
+writeRows replaces the old rows of table with the new ones like replaceRows, after applying the actions of
+the foreign keys referencing changed rows and checking the references of the new rows. A nil old row inserts the new one.
+Nothing is written when any of the changes breaks a constraint. The linked tables must be locked
+*/
+func (b *Backend) writeRows(table *Table, old, new [][]Cell) error {
+	var s changeSet
+	changes := s.of(table)
+	for i := range old {
+		changes.add(old[i], new[i])
+	}
+	if err := b.cascade(&s, table, old, new); err != nil {
+		return err
+	}
+	if err := b.checkReferences(s, changes); err != nil {
+		return err
+	}
+	for _, c := range s {
+		if err := c.table.checkUnique(c.old, c.new); err != nil {
+			return err
+		}
+		if err := c.table.checkKeySizes(c.new); err != nil {
+			return err
+		}
+	}
+	for _, c := range s {
+		if err := b.replaceRows(c.table, c.old, c.new); err != nil {
+			return err
+		}
+	}
+	return nil
+}
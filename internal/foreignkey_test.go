@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestForeignKeys(t *testing.T) {
+	dir := t.TempDir()
+	b := CreateNewDatabase(dir)
+	for _, sql := range []string{
+		"CREATE TABLE author (id int primary key, name char(10));",
+		"CREATE TABLE book (id int primary key, author int references author on delete cascade on update cascade, editor int, FOREIGN KEY (editor) REFERENCES author (id) ON DELETE SET NULL);",
+		"CREATE TABLE review (id int primary key, book int not null references book (id));",
+		"INSERT INTO author (id, name) VALUES (1, 'ann'), (2, 'bob'), (3, 'cid');",
+		"INSERT INTO book (id, author, editor) VALUES (1, 1, 2), (2, 1, NULL), (3, 2, 1);",
+		"INSERT INTO review (id, book) VALUES (1, 3);",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	require.Equal(t, []foreignKey{
+		{name: "book_author_fkey", columns: []int{1}, parent: "author", onDelete: Cascade, onUpdate: Cascade},
+		{name: "book_editor_fkey", columns: []int{2}, parent: "author", onDelete: SetNull},
+	}, b.tables[1].foreignKeys)
+
+	violations := []struct {
+		SQL      string
+		Expected ConstraintError
+	}{
+		{"INSERT INTO book (id, author) VALUES (4, 9);", ConstraintError{Kind: ForeignKeyConstraint, Table: "book", Column: "author", Name: "book_author_fkey", Parent: "author"}},
+		{"UPDATE review SET book = 2 + 2 WHERE id = 1;", ConstraintError{Kind: ForeignKeyConstraint, Table: "review", Column: "book", Name: "review_book_fkey", Parent: "book"}},
+		{"DELETE FROM author WHERE id = 2;", ConstraintError{Kind: ForeignKeyConstraint, Table: "review", Column: "book", Name: "review_book_fkey", Parent: "book"}},
+		{"UPDATE book SET id = 5 WHERE id = 3;", ConstraintError{Kind: ForeignKeyConstraint, Table: "review", Column: "book", Name: "review_book_fkey", Parent: "book"}},
+		{"UPDATE author SET id = 10 WHERE id = 1;", ConstraintError{Kind: ForeignKeyConstraint, Table: "book", Column: "editor", Name: "book_editor_fkey", Parent: "author"}},
+	}
+	for _, tc := range violations {
+		t.Run(tc.SQL, func(t *testing.T) {
+			_, err := execSQL(b, tc.SQL)
+			var cerr *ConstraintError
+			require.ErrorAs(t, err, &cerr)
+			require.Equal(t, tc.Expected, *cerr)
+		})
+	}
+	require.Equal(t, [][]any{{int64(1), int64(1), int64(2)}, {int64(2), int64(1), nil}, {int64(3), int64(2), int64(1)}},
+		queryRows(t, b, "SELECT id, author, editor FROM book WHERE id > 0;"), "a restricted delete leaves the cascades undone")
+
+	for _, sql := range []string{
+		"DELETE FROM review WHERE id = 1;",
+		"DELETE FROM author WHERE id = 2;",
+		"UPDATE author SET id = 10 WHERE id = 1;",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	require.Equal(t, [][]any{{int64(1), int64(10), nil}, {int64(2), int64(10), nil}}, queryRows(t, b, "SELECT id, author, editor FROM book WHERE id > 0;"))
+	_, err := execSQL(b, "DELETE FROM author WHERE id = 10;")
+	require.NoError(t, err)
+	require.Empty(t, queryRows(t, b, "SELECT id FROM book WHERE id > 0;"))
+
+	_, err = execSQL(b, "CREATE TABLE x (id int primary key, a char(5) references author);")
+	require.EqualError(t, err, "foreign key x_a_fkey: column a and referenced column id have different types")
+	_, err = execSQL(b, "CREATE TABLE x (id int primary key, a int references author (name));")
+	require.EqualError(t, err, "foreign key x_a_fkey must reference the primary key of table author")
+	_, err = execSQL(b, "CREATE TABLE x (id int primary key, a int references nope);")
+	require.EqualError(t, err, "referenced table nope does not exist")
+	b.Close()
+
+	b, err = OpenExistingDatabase(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	_, err = execSQL(b, "INSERT INTO book (id, author) VALUES (4, 1);")
+	require.EqualError(t, err, `insert: column author of table book violates foreign key "book_author_fkey" referencing table author`)
+	_, err = execSQL(b, "INSERT INTO book (id, author, editor) VALUES (4, 3, 3);")
+	require.NoError(t, err)
+}
+
+func TestSelfReferencingForeignKey(t *testing.T) {
+	b := CreateNewDatabase(t.TempDir())
+	defer b.Close()
+	for _, sql := range []string{
+		"CREATE TABLE node (id int primary key, parent int references node on delete cascade on update cascade);",
+		"INSERT INTO node (id, parent) VALUES (1, NULL), (2, 1), (3, 2), (4, 4), (5, 1);",
+		"UPDATE node SET id = 40 WHERE id = 4;",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	require.Equal(t, [][]any{{int64(1), nil}, {int64(2), int64(1)}, {int64(3), int64(2)}, {int64(40), int64(40)}, {int64(5), int64(1)}},
+		queryRows(t, b, "SELECT id, parent FROM node WHERE id > 0;"))
+
+	_, err := execSQL(b, "INSERT INTO node (id, parent) VALUES (6, 7);")
+	require.Error(t, err)
+	_, err = execSQL(b, "DELETE FROM node WHERE id = 2;")
+	require.NoError(t, err)
+	require.Equal(t, [][]any{{int64(1)}, {int64(40)}, {int64(5)}}, queryRows(t, b, "SELECT id FROM node WHERE id > 0;"))
+	_, err = execSQL(b, "DELETE FROM node WHERE id = 1;")
+	require.NoError(t, err)
+	require.Equal(t, [][]any{{int64(40)}}, queryRows(t, b, "SELECT id FROM node WHERE id > 0;"))
+}
+
+func TestLongForeignKeyNames(t *testing.T) {
+	dir := t.TempDir()
+	b := CreateNewDatabase(dir)
+	child, column := "c"+strings.Repeat("a", 200), "p"+strings.Repeat("b", 100)
+	for _, sql := range []string{
+		"CREATE TABLE parent (id int primary key);",
+		"CREATE TABLE " + child + " (id int primary key, " + column + " int references parent, FOREIGN KEY (" + column + ") REFERENCES parent ON DELETE CASCADE);",
+		"INSERT INTO parent (id) VALUES (1);",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	names := []string{}
+	for _, fk := range b.tables[1].foreignKeys {
+		require.LessOrEqual(t, len(fk.name), maxNameSize)
+		names = append(names, fk.name)
+	}
+	require.True(t, strings.HasSuffix(names[0], "_fkey"))
+	require.True(t, strings.HasSuffix(names[1], "_fkey1"), "a second key on the columns is numbered")
+	b.Close()
+
+	b, err := OpenExistingDatabase(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	require.Equal(t, names[1], b.tables[1].foreignKeys[1].name)
+	_, err = execSQL(b, "INSERT INTO "+child+" (id, "+column+") VALUES (1, 2);")
+	var cerr *ConstraintError
+	require.ErrorAs(t, err, &cerr)
+	require.Equal(t, names[0], cerr.Name)
+
+	buf := b.tables[1].foreignKeys[0].toBytes()
+	for size := range buf {
+		_, err := (&foreignKey{}).fromBytes(buf[:size])
+		require.EqualError(t, err, "catalog holds a truncated foreign key", size)
+	}
+}
@@ -0,0 +1,490 @@
+package internal
+
+import (
+	"strings"
+
+	"github.com/treeform-system/rootdb/internal/token"
+)
+
+/*
+Turns a syntax tree back into canonical sql text.
+Keywords are upper case, identifiers are always double quoted, single spaces separate tokens
+and parentheses are only written where precedence requires them so Parse(Format(q)) gives back the same tree.
+*/
+
+// Format returns canonical sql for the statement the query was parsed from
+func Format(q Query) string {
+	return FormatStatement(q.Statement)
+}
+
+func FormatStatement(stmt Statement) string {
+	var sb strings.Builder
+	writeStatement(&sb, stmt)
+	sb.WriteByte(';')
+	return sb.String()
+}
+
+// FormatExpr returns canonical sql for a single expression
+func FormatExpr(expr Expr) string {
+	var sb strings.Builder
+	writeExpr(&sb, expr)
+	return sb.String()
+}
+
+func (o Operator) String() string {
+	switch o {
+	case Eq:
+		return "="
+	case Ne:
+		return "!="
+	case Gt:
+		return ">"
+	case Lt:
+		return "<"
+	case Gte:
+		return ">="
+	case Lte:
+		return "<="
+	case And:
+		return "AND"
+	case Or:
+		return "OR"
+	case Not:
+		return "NOT"
+	case Plus:
+		return "+"
+	case Minus:
+		return "-"
+	case Multiply:
+		return "*"
+	case Divide:
+		return "/"
+	case JSONGet:
+		return "->"
+	case JSONGetText:
+		return "->>"
+	}
+	return "?"
+}
+
+func writeStatement(sb *strings.Builder, stmt Statement) {
+	switch s := stmt.(type) {
+	case *SelectStatement:
+		sb.WriteString("SELECT ")
+		writeSelectItems(sb, s.Items)
+		sb.WriteString(" FROM ")
+		writeTableRef(sb, s.From)
+		writeWhere(sb, s.Where)
+	case *InsertStatement:
+		sb.WriteString("INSERT INTO ")
+		writeTableRef(sb, s.Table)
+		sb.WriteString(" (")
+		writeIdentList(sb, s.Columns)
+		if s.Query != nil {
+			sb.WriteString(") ")
+			writeStatement(sb, s.Query)
+		} else {
+			sb.WriteString(") VALUES ")
+			for i, row := range s.Rows {
+				if i > 0 {
+					sb.WriteString(", ")
+				}
+				sb.WriteByte('(')
+				writeExprList(sb, row)
+				sb.WriteByte(')')
+			}
+		}
+		if c := s.OnConflict; c != nil {
+			sb.WriteString(" ON CONFLICT ")
+			if len(c.Columns) > 0 {
+				sb.WriteByte('(')
+				writeIdentList(sb, c.Columns)
+				sb.WriteString(") ")
+			}
+			if c.DoUpdate {
+				sb.WriteString("DO UPDATE SET ")
+				writeAssignments(sb, c.Set)
+				writeWhere(sb, c.Where)
+			} else {
+				sb.WriteString("DO NOTHING")
+			}
+		}
+		writeReturning(sb, s.Returning)
+	case *UpdateStatement:
+		sb.WriteString("UPDATE ")
+		writeTableRef(sb, s.Table)
+		sb.WriteString(" SET ")
+		writeAssignments(sb, s.Set)
+		writeWhere(sb, s.Where)
+		writeReturning(sb, s.Returning)
+	case *DeleteStatement:
+		sb.WriteString("DELETE FROM ")
+		writeTableRef(sb, s.Table)
+		writeWhere(sb, s.Where)
+		writeReturning(sb, s.Returning)
+	case *CreateTableStatement:
+		sb.WriteString("CREATE TABLE ")
+		if s.IfNotExists {
+			sb.WriteString("IF NOT EXISTS ")
+		}
+		writeIdent(sb, s.Name)
+		if s.Query != nil {
+			sb.WriteString(" AS ")
+			writeStatement(sb, s.Query)
+			break
+		}
+		sb.WriteString(" (")
+		for i, col := range s.Columns {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			writeColumnDef(sb, col)
+		}
+		if len(s.PrimaryKey) > 0 {
+			sb.WriteString(", PRIMARY KEY (")
+			writeIdentList(sb, s.PrimaryKey)
+			sb.WriteByte(')')
+		}
+		for _, check := range s.Checks {
+			sb.WriteString(", CHECK (")
+			writeExpr(sb, check)
+			sb.WriteByte(')')
+		}
+		for i := range s.ForeignKeys {
+			sb.WriteString(", FOREIGN KEY (")
+			writeIdentList(sb, s.ForeignKeys[i].Columns)
+			sb.WriteByte(')')
+			writeReferences(sb, &s.ForeignKeys[i])
+		}
+		sb.WriteByte(')')
+	case *DropTableStatement:
+		sb.WriteString("DROP TABLE ")
+		writeIdent(sb, s.Name)
+	case *ExplainStatement:
+		sb.WriteString("EXPLAIN ")
+		if s.Analyze {
+			sb.WriteString("ANALYZE ")
+		}
+		writeStatement(sb, s.Statement)
+	case *AnalyzeStatement:
+		sb.WriteString("ANALYZE")
+		if s.Table != "" {
+			sb.WriteByte(' ')
+			writeIdent(sb, s.Table)
+		}
+	case *CreateIndexStatement:
+		sb.WriteString("CREATE ")
+		if s.Unique {
+			sb.WriteString("UNIQUE ")
+		}
+		sb.WriteString("INDEX ")
+		writeIdent(sb, s.Name)
+		sb.WriteString(" ON ")
+		writeIdent(sb, s.Table)
+		sb.WriteString(" (")
+		for i, column := range s.Columns {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			if column == "" {
+				sb.WriteByte('(')
+				writeExpr(sb, s.Exprs[i])
+				sb.WriteByte(')')
+				continue
+			}
+			writeIdent(sb, column)
+		}
+		sb.WriteByte(')')
+	case *DropIndexStatement:
+		sb.WriteString("DROP INDEX ")
+		writeIdent(sb, s.Name)
+	case *AlterTableStatement:
+		sb.WriteString("ALTER TABLE ")
+		writeIdent(sb, s.Table)
+		switch s.Action {
+		case AddColumn:
+			sb.WriteString(" ADD COLUMN ")
+			writeColumnDef(sb, s.Column)
+		case DropColumn:
+			sb.WriteString(" DROP COLUMN ")
+			writeIdent(sb, s.Name)
+		case RenameColumn:
+			sb.WriteString(" RENAME COLUMN ")
+			writeIdent(sb, s.Name)
+			sb.WriteString(" TO ")
+			writeIdent(sb, s.NewName)
+		case RenameTable:
+			sb.WriteString(" RENAME TO ")
+			writeIdent(sb, s.NewName)
+		}
+	}
+}
+
+func writeSelectItems(sb *strings.Builder, items []SelectItem) {
+	for i, item := range items {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		if item.Star {
+			sb.WriteByte('*')
+		} else {
+			writeExpr(sb, item.Expr)
+		}
+		if item.Alias != "" {
+			sb.WriteString(" AS ")
+			writeIdent(sb, item.Alias)
+		}
+	}
+}
+
+func writeReturning(sb *strings.Builder, items []SelectItem) {
+	if len(items) == 0 {
+		return
+	}
+	sb.WriteString(" RETURNING ")
+	writeSelectItems(sb, items)
+}
+
+func writeAssignments(sb *strings.Builder, set []Assignment) {
+	for i, assignment := range set {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		writeIdent(sb, assignment.Column)
+		sb.WriteString(" = ")
+		writeExpr(sb, assignment.Value)
+	}
+}
+
+func writeColumnDef(sb *strings.Builder, col ColumnDef) {
+	writeIdent(sb, col.Name)
+	sb.WriteByte(' ')
+	sb.WriteString(col.Type.Name)
+	if len(col.Type.Args) > 0 {
+		sb.WriteByte('(')
+		sb.WriteString(strings.Join(col.Type.Args, ", "))
+		sb.WriteByte(')')
+	}
+	for _, constraint := range col.Constraints {
+		switch constraint.Kind {
+		case PrimaryKeyConstraint:
+			sb.WriteString(" PRIMARY KEY")
+		case UniqueConstraint:
+			sb.WriteString(" UNIQUE")
+		case NotNullConstraint:
+			sb.WriteString(" NOT NULL")
+		case NullConstraint:
+			sb.WriteString(" NULL")
+		case DefaultConstraint:
+			sb.WriteString(" DEFAULT ")
+			writeExpr(sb, constraint.Expr)
+		case GeneratedConstraint:
+			sb.WriteString(" GENERATED ALWAYS AS (")
+			writeExpr(sb, constraint.Expr)
+			sb.WriteString(") STORED")
+		case CheckConstraint:
+			sb.WriteString(" CHECK (")
+			writeExpr(sb, constraint.Expr)
+			sb.WriteByte(')')
+		case ForeignKeyConstraint:
+			writeReferences(sb, constraint.ForeignKey)
+		}
+	}
+}
+
+func writeReferences(sb *strings.Builder, fk *ForeignKey) {
+	sb.WriteString(" REFERENCES ")
+	writeIdent(sb, fk.Table)
+	if len(fk.RefColumns) > 0 {
+		sb.WriteString(" (")
+		writeIdentList(sb, fk.RefColumns)
+		sb.WriteByte(')')
+	}
+	for _, on := range []struct {
+		event  string
+		action ReferentialAction
+	}{{"DELETE", fk.OnDelete}, {"UPDATE", fk.OnUpdate}} {
+		if on.action != NoAction {
+			sb.WriteString(" ON " + on.event + " " + on.action.String())
+		}
+	}
+}
+
+func writeWhere(sb *strings.Builder, where Expr) {
+	if where == nil {
+		return
+	}
+	sb.WriteString(" WHERE ")
+	writeExpr(sb, where)
+}
+
+func writeTableRef(sb *strings.Builder, ref TableRef) {
+	switch r := ref.(type) {
+	case *TableName:
+		writeIdent(sb, r.Name)
+		if r.Alias != "" {
+			sb.WriteString(" AS ")
+			writeIdent(sb, r.Alias)
+		}
+	}
+}
+
+func writeIdent(sb *strings.Builder, name string) {
+	sb.WriteByte('"')
+	sb.WriteString(name)
+	sb.WriteByte('"')
+}
+
+func writeIdentList(sb *strings.Builder, names []string) {
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		writeIdent(sb, name)
+	}
+}
+
+func writeExprList(sb *strings.Builder, exprs []Expr) {
+	for i, expr := range exprs {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		writeExpr(sb, expr)
+	}
+}
+
+// precedence an expression was parsed at, matches the precedence table used by the parser
+func exprPrecedence(expr Expr) int {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		switch e.Operator {
+		case Or:
+			return precedenceOr
+		case And:
+			return precedenceAnd
+		case Plus, Minus:
+			return precedenceSum
+		case Multiply, Divide:
+			return precedenceProduct
+		case JSONGet, JSONGetText:
+			return precedenceJSON
+		}
+		return precedenceCompare
+	case *UnaryExpr:
+		if e.Operator == Not {
+			return precedenceNot
+		}
+		return precedencePrefix
+	case *InExpr, *IsNullExpr:
+		return precedenceCompare
+	}
+	return precedenceJSON + 1
+}
+
+// writes expression wrapped in parentheses when its precedence is below min
+func writeOperand(sb *strings.Builder, expr Expr, min int) {
+	if exprPrecedence(expr) < min {
+		sb.WriteByte('(')
+		writeExpr(sb, expr)
+		sb.WriteByte(')')
+		return
+	}
+	writeExpr(sb, expr)
+}
+
+func writeExpr(sb *strings.Builder, expr Expr) {
+	switch e := expr.(type) {
+	case *Identifier:
+		if e.Table != "" {
+			writeIdent(sb, e.Table)
+			sb.WriteByte('.')
+		}
+		writeIdent(sb, e.Name)
+	case *Literal:
+		switch e.Kind {
+		case StringLiteral:
+			sb.WriteByte('\'')
+			sb.WriteString(e.Value)
+			sb.WriteByte('\'')
+		case NullLiteral:
+			sb.WriteString("NULL")
+		case HexLiteral:
+			sb.WriteString("X'")
+			sb.WriteString(e.Value)
+			sb.WriteByte('\'')
+		case DateLiteral, TimeLiteral, TimestampLiteral, IntervalLiteral:
+			for keyword, kind := range typedLiteralKinds {
+				if kind == e.Kind {
+					sb.WriteString(string(keyword))
+				}
+			}
+			sb.WriteString(" '")
+			sb.WriteString(e.Value)
+			sb.WriteByte('\'')
+		default:
+			sb.WriteString(e.Value)
+		}
+	case *UnaryExpr:
+		if e.Operator == Not {
+			sb.WriteString("NOT ")
+			writeOperand(sb, e.Expr, precedenceNot)
+			return
+		}
+		sb.WriteString(e.Operator.String())
+		if lit, ok := e.Expr.(*Literal); ok && lit.Kind == NumberLiteral && !strings.HasPrefix(lit.Value, "-") {
+			// -5 would be read back as a negative literal instead of a negated one
+			sb.WriteByte('(')
+			writeExpr(sb, lit)
+			sb.WriteByte(')')
+			return
+		}
+		writeOperand(sb, e.Expr, precedencePrefix)
+	case *BinaryExpr:
+		precedence := exprPrecedence(e)
+		writeOperand(sb, e.Left, precedence)
+		sb.WriteByte(' ')
+		sb.WriteString(e.Operator.String())
+		sb.WriteByte(' ')
+		writeOperand(sb, e.Right, precedence+1) // operators are left associative
+	case *InExpr:
+		writeOperand(sb, e.Expr, precedenceCompare)
+		if e.Not {
+			sb.WriteString(" NOT")
+		}
+		sb.WriteString(" IN (")
+		writeExprList(sb, e.List)
+		sb.WriteByte(')')
+	case *IsNullExpr:
+		writeOperand(sb, e.Expr, precedenceCompare)
+		if e.Not {
+			sb.WriteString(" IS NOT NULL")
+		} else {
+			sb.WriteString(" IS NULL")
+		}
+	case *FuncCall:
+		if token.LookupIdent(e.Name) == token.IDENT && isPlainIdent(e.Name) {
+			sb.WriteString(e.Name)
+		} else {
+			writeIdent(sb, e.Name)
+		}
+		sb.WriteByte('(')
+		if e.Star {
+			sb.WriteByte('*')
+		}
+		writeExprList(sb, e.Args)
+		sb.WriteByte(')')
+	}
+}
+
+// reports whether name lexes as a single unquoted identifier
+func isPlainIdent(name string) bool {
+	if name == "" || !isLetter(name[0]) {
+		return false
+	}
+	for i := 1; i < len(name); i++ {
+		if !isLetter(name[i]) && !('0' <= name[i] && name[i] <= '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,214 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	ts := []struct {
+		Name     string
+		SQL      string
+		Expected string
+	}{
+		{
+			Name:     "SELECT normalizes case, quoting and spacing",
+			SQL:      "select a as z,b,  *  from t where a>=1 and b!=c;",
+			Expected: `SELECT "a" AS "z", "b", * FROM "t" WHERE "a" >= 1 AND "b" != "c";`,
+		},
+		{
+			Name:     "parentheses only where precedence needs them",
+			SQL:      "SELECT (a + b) * c, a + (b * c), a - (b - c), (a - b) - c FROM t WHERE (a = 1 OR b = 2) AND NOT (c = 3 AND d = 4);",
+			Expected: `SELECT ("a" + "b") * "c", "a" + "b" * "c", "a" - ("b" - "c"), "a" - "b" - "c" FROM "t" WHERE ("a" = 1 OR "b" = 2) AND NOT ("c" = 3 AND "d" = 4);`,
+		},
+		{
+			Name:     "IN, IS NULL, functions and literals",
+			SQL:      "SELECT count(*), \"select\"(x) FROM t x WHERE x.a not in (1, -2.5, 'q') AND b is not null AND c = false AND d = NULL;",
+			Expected: `SELECT COUNT(*), "SELECT"("x") FROM "t" AS "x" WHERE "x"."a" NOT IN (1, -2.5, 'q') AND "b" IS NOT NULL AND "c" = FALSE AND "d" = NULL;`,
+		},
+		{
+			Name:     "INSERT",
+			SQL:      "insert into t (a,b) values (1,'x'),(- a, true);",
+			Expected: `INSERT INTO "t" ("a", "b") VALUES (1, 'x'), (-"a", TRUE);`,
+		},
+		{
+			Name:     "UPDATE",
+			SQL:      "update t set a = 1, b = b + 1 where id = 2;",
+			Expected: `UPDATE "t" SET "a" = 1, "b" = "b" + 1 WHERE "id" = 2;`,
+		},
+		{
+			Name:     "DELETE",
+			SQL:      "delete from t where id <= 2;",
+			Expected: `DELETE FROM "t" WHERE "id" <= 2;`,
+		},
+		{
+			Name:     "CREATE TABLE",
+			SQL:      "create table t (id int primary key, name char(10) not null unique, f float null);",
+			Expected: `CREATE TABLE "t" ("id" INT PRIMARY KEY, "name" CHAR(10) NOT NULL UNIQUE, "f" FLOAT NULL);`,
+		},
+		{
+			Name:     "typed literals and date functions",
+			SQL:      "select now(), date_trunc('month', d) from t where d > date '2024-01-31' and t - interval '1 day' < timestamp '2024-02-01 10:00:00';",
+			Expected: `SELECT NOW(), DATE_TRUNC('month', "d") FROM "t" WHERE "d" > DATE '2024-01-31' AND "t" - INTERVAL '1 day' < TIMESTAMP '2024-02-01 10:00:00';`,
+		},
+		{
+			Name:     "EXPLAIN",
+			SQL:      "explain analyze select a from t;",
+			Expected: `EXPLAIN ANALYZE SELECT "a" FROM "t";`,
+		},
+		{
+			Name:     "ANALYZE",
+			SQL:      "analyze t;",
+			Expected: `ANALYZE "t";`,
+		},
+		{
+			Name:     "CREATE TABLE with VARCHAR and TEXT",
+			SQL:      "create table t (id int primary key, title varchar(300) unique, body text);",
+			Expected: `CREATE TABLE "t" ("id" INT PRIMARY KEY, "title" VARCHAR(300) UNIQUE, "body" TEXT);`,
+		},
+		{
+			Name:     "CREATE TABLE with DECIMAL and NUMERIC",
+			SQL:      "create table t (id int primary key, total decimal(10,2), n numeric(5));",
+			Expected: `CREATE TABLE "t" ("id" INT PRIMARY KEY, "total" DECIMAL(10, 2), "n" NUMERIC(5));`,
+		},
+		{
+			Name:     "CREATE TABLE with integer widths",
+			SQL:      "create table t (a tinyint, b smallint unsigned, c int32, d bigint unsigned);",
+			Expected: `CREATE TABLE "t" ("a" TINYINT, "b" SMALLINT UNSIGNED, "c" INT32, "d" BIGINT UNSIGNED);`,
+		},
+		{
+			Name:     "CREATE TABLE with UUID and BYTEA",
+			SQL:      "create table t (id uuid primary key default gen_random_uuid(), data bytea default x'00ff');",
+			Expected: `CREATE TABLE "t" ("id" UUID PRIMARY KEY DEFAULT GEN_RANDOM_UUID(), "data" BYTEA DEFAULT X'00ff');`,
+		},
+		{
+			Name:     "CREATE TABLE with a table level primary key",
+			SQL:      "create table t (primary key (b, a), a int, b int);",
+			Expected: `CREATE TABLE "t" ("a" INT, "b" INT, PRIMARY KEY ("b", "a"));`,
+		},
+		{
+			Name:     "CREATE TABLE with default and generated columns",
+			SQL:      "create table t (id int primary key, a char(5) default 'x' not null, b int generated always as (id * 2) stored);",
+			Expected: `CREATE TABLE "t" ("id" INT PRIMARY KEY, "a" CHAR(5) DEFAULT 'x' NOT NULL, "b" INT GENERATED ALWAYS AS ("id" * 2) STORED);`,
+		},
+		{
+			Name:     "CREATE TABLE with checks",
+			SQL:      "create table t (id int primary key check (id > 0), s char(1) check (s in ('a','b')), check (id < 10 or s = 'a'));",
+			Expected: `CREATE TABLE "t" ("id" INT PRIMARY KEY CHECK ("id" > 0), "s" CHAR(1) CHECK ("s" IN ('a', 'b')), CHECK ("id" < 10 OR "s" = 'a'));`,
+		},
+		{
+			Name:     "CREATE TABLE with foreign keys",
+			SQL:      "create table t (id int primary key references p on delete no action, a int, foreign key (a) references t (id) on update cascade on delete set null);",
+			Expected: `CREATE TABLE "t" ("id" INT PRIMARY KEY REFERENCES "p", "a" INT, FOREIGN KEY ("a") REFERENCES "t" ("id") ON DELETE SET NULL ON UPDATE CASCADE);`,
+		},
+		{
+			Name:     "INSERT SELECT",
+			SQL:      "insert into t (a, b) select * from s where x = 'q';",
+			Expected: `INSERT INTO "t" ("a", "b") SELECT * FROM "s" WHERE "x" = 'q';`,
+		},
+		{
+			Name:     "INSERT ON CONFLICT",
+			SQL:      "insert into t (id, n) values (1, 2) on conflict (id) do update set n = excluded.n + 1, m = 0 where t.n < 5;",
+			Expected: `INSERT INTO "t" ("id", "n") VALUES (1, 2) ON CONFLICT ("id") DO UPDATE SET "n" = "excluded"."n" + 1, "m" = 0 WHERE "t"."n" < 5;`,
+		},
+		{
+			Name:     "RETURNING",
+			SQL:      "insert into t (a) values (1) on conflict do nothing returning id as ident, a;",
+			Expected: `INSERT INTO "t" ("a") VALUES (1) ON CONFLICT DO NOTHING RETURNING "id" AS "ident", "a";`,
+		},
+		{
+			Name:     "DELETE RETURNING",
+			SQL:      "delete from t where a = 1 returning *;",
+			Expected: `DELETE FROM "t" WHERE "a" = 1 RETURNING *;`,
+		},
+		{
+			Name:     "INSERT SELECT ON CONFLICT DO NOTHING",
+			SQL:      "insert into t (a) select x from s on conflict do nothing;",
+			Expected: `INSERT INTO "t" ("a") SELECT "x" FROM "s" ON CONFLICT DO NOTHING;`,
+		},
+		{
+			Name:     "CREATE TABLE IF NOT EXISTS AS SELECT",
+			SQL:      "create table if not exists t as select id, a as b from s where id > 1;",
+			Expected: `CREATE TABLE IF NOT EXISTS "t" AS SELECT "id", "a" AS "b" FROM "s" WHERE "id" > 1;`,
+		},
+		{
+			Name:     "CREATE INDEX",
+			SQL:      "create unique index ix on t (a, b);",
+			Expected: `CREATE UNIQUE INDEX "ix" ON "t" ("a", "b");`,
+		},
+		{
+			Name:     "CREATE INDEX on an expression",
+			SQL:      "create index ix on t (a, (doc->>'$.name'));",
+			Expected: `CREATE INDEX "ix" ON "t" ("a", ("doc" ->> '$.name'));`,
+		},
+		{
+			Name:     "JSON path operators",
+			SQL:      "select doc -> '$.a', doc->>'$.b' from t where (doc->'$.n') + 1 > 2;",
+			Expected: `SELECT "doc" -> '$.a', "doc" ->> '$.b' FROM "t" WHERE "doc" -> '$.n' + 1 > 2;`,
+		},
+		{
+			Name:     "DROP INDEX",
+			SQL:      "drop index ix;",
+			Expected: `DROP INDEX "ix";`,
+		},
+		{
+			Name:     "ALTER TABLE ADD COLUMN",
+			SQL:      "alter table t add n int not null check (n > 0);",
+			Expected: `ALTER TABLE "t" ADD COLUMN "n" INT NOT NULL CHECK ("n" > 0);`,
+		},
+		{
+			Name:     "ALTER TABLE RENAME COLUMN",
+			SQL:      "alter table t rename column a to b;",
+			Expected: `ALTER TABLE "t" RENAME COLUMN "a" TO "b";`,
+		},
+		{
+			Name:     "ALTER TABLE RENAME TO",
+			SQL:      "alter table t rename to u;",
+			Expected: `ALTER TABLE "t" RENAME TO "u";`,
+		},
+		{
+			Name:     "DROP TABLE",
+			SQL:      "drop table t;",
+			Expected: `DROP TABLE "t";`,
+		},
+	}
+
+	for _, tc := range ts {
+		t.Run(tc.Name, func(t *testing.T) {
+			q, err := Parse(tc.SQL)
+			require.NoError(t, err)
+			require.Equal(t, tc.Expected, Format(q))
+		})
+	}
+}
+
+// Parse(Format(q)) must give back the same tree and formatting it again must not change the text
+func FuzzFormatRoundTrip(f *testing.F) {
+	corpora := [][]testCase{selectTestCases, updateTestCases, deleteTestCases, insertTestCases, createTestCases, dropTestCases}
+	for _, cases := range corpora {
+		for _, tc := range cases {
+			f.Add(tc.SQL)
+		}
+	}
+	for _, stmt := range strings.SplitAfter(lexerTestInput, ";") {
+		f.Add(stmt)
+	}
+	f.Add("EXPLAIN ANALYZE SELECT a FROM t WHERE id > 1;")
+	f.Add("ANALYZE;")
+	f.Add("CREATE INDEX ix ON t (a);")
+	f.Add("SELECT -a, - -1, NOT NOT b, (a = b) = c, a = (b = c), a IN (1) IN (TRUE) FROM t WHERE (NOT a) = b;")
+
+	f.Fuzz(func(t *testing.T, sql string) {
+		q, err := Parse(sql)
+		if err != nil {
+			return
+		}
+		formatted := Format(q)
+		again, err := Parse(formatted)
+		require.NoError(t, err, "formatted sql %q doesn't parse", formatted)
+		require.Equal(t, q.Statement, again.Statement, "formatted sql %q", formatted)
+		require.Equal(t, formatted, Format(again))
+	})
+}
@@ -1,20 +1,39 @@
 package internal
 
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+/*
+Every table has a primary index on its primary key and any number of secondary indexes
+created with CREATE INDEX. All of them map keys to the location of a row (page*PAGESIZE+slot).
+Entries of a non unique index are the column values followed by the primary key so every entry is unique,
+entries of a unique index holding a null are made unique the same way as nulls never equal each other.
+*/
+
 //one per table
 type indexManager struct {
 	primaryTree *tree
 	columnName  string
+	secondary   map[string]*tree //trees of the secondary indexes by index name
 }
 
 func newIndexManager() *indexManager {
 	var newIndices *indexManager = new(indexManager)
-
+	newIndices.secondary = make(map[string]*tree)
 	return newIndices
 }
 
-func (i *indexManager) addIndex(dir, table, column string) error {
+func (i *indexManager) addIndex(dir, files, column string) error {
 	var err error
-	i.primaryTree, err = initializeTree(dir, table, column)
+	i.primaryTree, err = initializeTree(dir, files, column)
 	if err != nil {
 		return err
 	}
@@ -22,6 +41,461 @@ func (i *indexManager) addIndex(dir, table, column string) error {
 	return nil
 }
 
+// opens the tree of a secondary index, creating it when it doesn't exist yet
+func (i *indexManager) addSecondary(dir, files, name string) (*tree, error) {
+	t, err := openTree(indexFile(dir, files, name))
+	if err != nil {
+		return nil, err
+	}
+	i.secondary[name] = t
+	return t, nil
+}
+
 func (i *indexManager) close() {
 	i.primaryTree.closeIndex()
+	for _, t := range i.secondary {
+		t.closeIndex()
+	}
+}
+
+// file of the primary index, <files>.<columns>.pkey where columns are the key columns joined by underscores
+func primaryIndexFile(dir, files, columns string) string {
+	return filepath.Join(dir, files+"."+fileName(columns)+".pkey")
+}
+
+// file of a secondary index, <files>.<index>.idx next to the file of the primary index
+func indexFile(dir, files, name string) string {
+	return filepath.Join(dir, files+"."+fileName(name)+".idx")
+}
+
+// flag of an index enforcing the UNIQUE constraint of a column instead of being created by CREATE INDEX
+const IX_ISCONSTRAINT = 1 << 1
+
+// indexDef is the catalog entry of a secondary index
+type indexDef struct {
+	name       string //max size is maxuint8
+	unique     bool
+	constraint bool   //created for a UNIQUE column, lives as long as the constraint
+	columns    []int  //positions of the indexed columns in the table, -1 for a key part that is an expression
+	exprs      []Expr //expressions of the key parts that are not columns, nil without expressions
+}
+
+// column position of the key parts that are expressions, stored in the catalog before the expression text
+const exprKeyPart = 0xffff
+
+func (d *indexDef) toBytes() []byte {
+	buf := make([]byte, 1, 4+len(d.name)+2*len(d.columns))
+	buf[0] = uint8(len(d.name))
+	buf = append(buf, d.name...)
+	flags := byte(0)
+	if d.unique {
+		flags |= COL_ISUNIQUE
+	}
+	if d.constraint {
+		flags |= IX_ISCONSTRAINT
+	}
+	buf = append(buf, flags, uint8(len(d.columns)))
+	for i, pos := range d.columns {
+		if pos == -1 {
+			text := FormatExpr(d.exprs[i])
+			buf = binary.LittleEndian.AppendUint16(buf, exprKeyPart)
+			buf = binary.LittleEndian.AppendUint16(buf, uint16(len(text)))
+			buf = append(buf, text...)
+			continue
+		}
+		buf = append(buf, uint8(pos), uint8(pos>>8))
+	}
+	return buf
+}
+
+// returns number of bytes read
+func (d *indexDef) fromBytes(buf []byte) int {
+	nameSize := int(buf[0])
+	d.name = string(buf[1 : 1+nameSize])
+	offset := 1 + nameSize
+	d.unique = buf[offset]&COL_ISUNIQUE > 0
+	d.constraint = buf[offset]&IX_ISCONSTRAINT > 0
+	numCols := int(buf[offset+1])
+	offset += 2
+	d.columns = make([]int, numCols)
+	for i := range d.columns {
+		d.columns[i] = int(buf[offset]) | int(buf[offset+1])<<8
+		offset += 2
+		if d.columns[i] != exprKeyPart {
+			continue
+		}
+		size := int(binary.LittleEndian.Uint16(buf[offset:]))
+		offset += 2
+		expr, err := ParseExpr(string(buf[offset : offset+size]))
+		if err != nil {
+			panic(fmt.Sprintf("catalog holds invalid expression for index %s: %v", d.name, err))
+		}
+		if d.exprs == nil {
+			d.exprs = make([]Expr, numCols)
+		}
+		d.columns[i], d.exprs[i] = -1, expr
+		offset += size
+	}
+	return offset
+}
+
+// tableIndex is an index of the table the planner can scan
+type tableIndex struct {
+	name    string //empty for the primary index
+	tree    *tree
+	columns []int  //positions of the indexed columns in a row, in key order, -1 for an expression
+	exprs   []Expr //expressions of the key parts that are not columns
+	unique  bool
+}
+
+// primary index followed by the secondary indexes in the order they were created
+func (t *Table) scannableIndexes() []tableIndex {
+	indexes := make([]tableIndex, 0, len(t.indexes)+1)
+	indexes = append(indexes, tableIndex{tree: t.indices.primaryTree, columns: t.primaryKey, unique: true})
+	for _, def := range t.indexes {
+		indexes = append(indexes, tableIndex{name: def.name, tree: t.indices.secondary[def.name], columns: def.columns, exprs: def.exprs, unique: def.unique})
+	}
+	return indexes
+}
+
+// position of the integer primary key handing out row ids to inserts without a key,
+// -1 for composite keys and keys with a default or generated value
+func (t *Table) rowIdColumn() int {
+	if len(t.primaryKey) != 1 {
+		return -1
+	}
+	col := &t.Columns[t.primaryKey[0]]
+	if col.columnType != INT || col.columnDefault != nil || col.columnGenerated != nil {
+		return -1
+	}
+	return t.primaryKey[0]
+}
+
+// name the file of the primary index is stored under, the key columns joined by underscores
+func (t *Table) primaryIndexName() string {
+	names := make([]string, len(t.primaryKey))
+	for i, pos := range t.primaryKey {
+		names[i] = t.Columns[pos].columnName
+	}
+	return strings.Join(names, "_")
+}
+
+// appends the key of the columns at positions of row to buf
+func (t *Table) appendColumnsKey(buf []byte, row []Cell, positions []int) []byte {
+	for _, pos := range positions {
+		buf = appendKey(buf, cellValue(&t.Columns[pos], row[pos]))
+	}
+	return buf
+}
+
+// column the values of key part i are read as, the column at its position or the column of the values of its expression
+func (t *Table) keyColumn(columns []int, exprs []Expr, i int) *Column {
+	if columns[i] != -1 {
+		return &t.Columns[columns[i]]
+	}
+	col, _ := (&rowScope{table: t}).exprColumn(exprs[i])
+	return &col
+}
+
+// value of key part i for row, expressions are evaluated and converted to the column of their values
+func (t *Table) keyValue(columns []int, exprs []Expr, i int, row []Cell) (any, error) {
+	if columns[i] != -1 {
+		return cellValue(&t.Columns[columns[i]], row[columns[i]]), nil
+	}
+	v, err := (&rowScope{table: t}).eval(exprs[i], row)
+	if err != nil {
+		return nil, err
+	}
+	col := t.keyColumn(columns, exprs, i)
+	cell, err := valueCell(col, v)
+	if err != nil {
+		return nil, err
+	}
+	return cellValue(col, cell), nil
+}
+
+// appends the key of the key parts of an index to buf, expressions failing to evaluate are null
+// as rows are checked with checkKeySizes before they are written
+func (t *Table) appendPartsKey(buf []byte, row []Cell, columns []int, exprs []Expr) []byte {
+	if exprs == nil {
+		return t.appendColumnsKey(buf, row, columns)
+	}
+	for i := range columns {
+		v, _ := t.keyValue(columns, exprs, i, row)
+		buf = appendKey(buf, v)
+	}
+	return buf
+}
+
+// key of row in the primary index
+func (t *Table) rowKey(row []Cell) []byte {
+	return t.appendColumnsKey(make([]byte, 0, 8*len(t.primaryKey)), row, t.primaryKey)
+}
+
+// key of row in the secondary index
+func (t *Table) indexKey(def *indexDef, row []Cell) []byte {
+	key := t.appendPartsKey(make([]byte, 0, 16), row, def.columns, def.exprs)
+	if !def.unique || t.uniqueKey(def, row) == nil {
+		key = append(key, t.rowKey(row)...)
+	}
+	return key
+}
+
+// key row may not share with another row in a unique index, nil if it has a null in an indexed column
+func (t *Table) uniqueKey(def *indexDef, row []Cell) []byte {
+	for i := range def.columns {
+		if v, _ := t.keyValue(def.columns, def.exprs, i, row); v == nil {
+			return nil
+		}
+	}
+	return t.appendPartsKey(make([]byte, 0, 16), row, def.columns, def.exprs)
+}
+
+// location of row in the table file, found through its primary key
+func (t *Table) rowLocation(row []Cell) (int64, error) {
+	loc, ok := t.indices.primaryTree.findKeyValue(t.rowKey(row))
+	if !ok {
+		return 0, fmt.Errorf("row missing from primary index of table %q", t.Name)
+	}
+	return loc, nil
+}
+
+// adds row stored at loc to every index of the table
+func (t *Table) insertIndexEntries(row []Cell, loc int64) error {
+	if err := t.indices.primaryTree.insertNode(t.rowKey(row), loc); err != nil {
+		return err
+	}
+	for i := range t.indexes {
+		if err := t.indices.secondary[t.indexes[i].name].insertNode(t.indexKey(&t.indexes[i], row), loc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// every index key of the rows must be computable and fit in an index, checked before the rows are written
+func (t *Table) checkKeySizes(rows [][]Cell) error {
+	for _, row := range rows {
+		if row == nil {
+			continue
+		}
+		if key := t.rowKey(row); len(key) > MAXKEYSIZE {
+			return fmt.Errorf("key of %d bytes for the primary key of table %s is larger than the maximum of %d", len(key), t.Name, MAXKEYSIZE)
+		}
+		for i := range t.indexes {
+			if err := t.checkKeyExprs(&t.indexes[i], row); err != nil {
+				return err
+			}
+			if key := t.indexKey(&t.indexes[i], row); len(key) > MAXKEYSIZE {
+				return fmt.Errorf("key of %d bytes for index %q is larger than the maximum of %d", len(key), t.indexes[i].name, MAXKEYSIZE)
+			}
+		}
+	}
+	return nil
+}
+
+// evaluates the expressions of an index for row
+func (t *Table) checkKeyExprs(def *indexDef, row []Cell) error {
+	for i, pos := range def.columns {
+		if pos != -1 {
+			continue
+		}
+		if _, err := t.keyValue(def.columns, def.exprs, i, row); err != nil {
+			return fmt.Errorf("index %q: %w", def.name, err)
+		}
+	}
+	return nil
+}
+
+// names of the key parts of an index, expressions are written as sql
+func (t *Table) keyPartNames(def *indexDef) string {
+	names := make([]string, len(def.columns))
+	for i, pos := range def.columns {
+		if pos == -1 {
+			names[i] = FormatExpr(def.exprs[i])
+		} else {
+			names[i] = t.Columns[pos].columnName
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// removes row from every index of the table
+func (t *Table) deleteIndexEntries(row []Cell) {
+	t.indices.primaryTree.deleteKey(t.rowKey(row))
+	for i := range t.indexes {
+		t.indices.secondary[t.indexes[i].name].deleteKey(t.indexKey(&t.indexes[i], row))
+	}
+}
+
+/*
+checkUnique reports whether replacing the old rows with the new ones keeps the primary key and
+every unique index free of duplicates, keys of the old rows are free to be taken by the new rows.
+Either slice may be nil, a nil new row is a deleted row.
+*/
+func (t *Table) checkUnique(old, new [][]Cell) error {
+	check := func(tr *tree, key func(row []Cell) []byte, violation *ConstraintError) error {
+		removed := make(map[string]bool, len(old))
+		for _, row := range old {
+			if row == nil {
+				continue
+			}
+			if k := key(row); k != nil {
+				removed[string(k)] = true
+			}
+		}
+		added := make(map[string]bool, len(new))
+		for _, row := range new {
+			if row == nil {
+				continue
+			}
+			k := key(row)
+			if k == nil {
+				continue
+			}
+			if _, exists := tr.findKeyValue(k); added[string(k)] || (exists && !removed[string(k)]) {
+				return violation
+			}
+			added[string(k)] = true
+		}
+		return nil
+	}
+
+	violation := &ConstraintError{Kind: PrimaryKeyConstraint, Table: t.Name, Column: t.columnNames(t.primaryKey)}
+	if err := check(t.indices.primaryTree, t.rowKey, violation); err != nil {
+		return err
+	}
+	for i := range t.indexes {
+		def := &t.indexes[i]
+		if !def.unique {
+			continue
+		}
+		key := func(row []Cell) []byte { return t.uniqueKey(def, row) }
+		violation := &ConstraintError{Kind: UniqueConstraint, Table: t.Name, Column: t.keyPartNames(def), Name: def.name}
+		if err := check(t.indices.secondary[def.name], key, violation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// returns the table holding the index with the given name
+func (b *Backend) findIndex(name string) (*Table, int, bool) {
+	for i := range b.tables {
+		for j := range b.tables[i].indexes {
+			if b.tables[i].indexes[j].name == name {
+				return &b.tables[i], j, true
+			}
+		}
+	}
+	return nil, 0, false
+}
+
+// CreateIndex adds a secondary index to a table and fills it with the rows already stored
+func (b *Backend) CreateIndex(q Query) error {
+	stmt, ok := q.Statement.(*CreateIndexStatement)
+	if !ok {
+		return errors.New("not a CREATE INDEX statement")
+	}
+	table, ok := b.checkTableExist(q)
+	if !ok {
+		return errors.New("Table does not exist")
+	}
+	if _, _, exists := b.findIndex(stmt.Name); exists {
+		return errors.New("Index already exist")
+	}
+	if !(len(stmt.Name) > 0 && len(stmt.Name) < 255) {
+		return errors.New("index name too large in size")
+	}
+	names := slices.DeleteFunc(slices.Clone(stmt.Columns), func(name string) bool { return name == "" })
+	positions, err := table.columnPositions(names)
+	if err != nil {
+		return err
+	}
+	def := indexDef{name: stmt.Name, unique: stmt.Unique, columns: make([]int, len(stmt.Columns)), exprs: stmt.Exprs}
+	scope := &rowScope{table: table}
+	for i, column := range stmt.Columns {
+		if column != "" {
+			def.columns[i], positions = positions[0], positions[1:]
+			continue
+		}
+		def.columns[i] = -1
+		if err := scope.bind(stmt.Exprs[i]); err != nil {
+			return err
+		}
+		if _, ok := scope.exprColumn(stmt.Exprs[i]); !ok {
+			return fmt.Errorf("cannot index %s, only columns, JSON paths and JSON functions can be indexed", FormatExpr(stmt.Exprs[i]))
+		}
+	}
+
+	table.tableLock.Lock()
+	defer table.tableLock.Unlock()
+
+	os.Remove(indexFile(b.dir, table.files(), def.name)) //left behind if a previous create was interrupted
+	tr, err := table.indices.addSecondary(b.dir, table.files(), def.name)
+	if err != nil {
+		return err
+	}
+	if err := b.fillIndex(table, &def, tr); err != nil {
+		delete(table.indices.secondary, def.name)
+		tr.removeIndex()
+		return err
+	}
+	table.indexes = append(table.indexes, def)
+	b.writeTablesToDisk()
+	return nil
+}
+
+// adds every row of the table to a new index, the table must be locked
+func (b *Backend) fillIndex(table *Table, def *indexDef, tr *tree) error {
+	scan := &seqScan{table: table, pool: b.bufferPool}
+	for {
+		row, err := scan.next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := table.checkKeyExprs(def, row); err != nil {
+			return err
+		}
+		key := table.indexKey(def, row)
+		loc, err := table.rowLocation(row)
+		if err != nil {
+			return err
+		}
+		if err := tr.insertNode(key, loc); err != nil {
+			if def.unique && len(def.columns) == 1 {
+				return fmt.Errorf("could not create unique index %q: column %s has duplicate values", def.name, table.keyPartNames(def))
+			} else if def.unique {
+				return fmt.Errorf("could not create unique index %q: columns %s have duplicate values", def.name, table.keyPartNames(def))
+			}
+			return err
+		}
+	}
+}
+
+// DropIndex removes a secondary index and its file
+func (b *Backend) DropIndex(q Query) error {
+	stmt, ok := q.Statement.(*DropIndexStatement)
+	if !ok {
+		return errors.New("not a DROP INDEX statement")
+	}
+	table, pos, ok := b.findIndex(stmt.Name)
+	if !ok {
+		return errors.New("Index does not exist")
+	}
+
+	if def := table.indexes[pos]; def.constraint {
+		return fmt.Errorf("cannot drop index %q, it enforces the UNIQUE constraint of column %s", def.name, table.columnNames(def.columns))
+	}
+
+	table.tableLock.Lock()
+	defer table.tableLock.Unlock()
+
+	tr := table.indices.secondary[stmt.Name]
+	delete(table.indices.secondary, stmt.Name)
+	table.indexes = slices.Delete(table.indexes, pos, pos+1)
+	b.writeTablesToDisk()
+	return tr.removeIndex()
 }
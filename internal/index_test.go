@@ -0,0 +1,317 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecondaryIndex(t *testing.T) {
+	dir := t.TempDir()
+	b := CreateNewDatabase(dir)
+	values := make([]string, 0, 500)
+	for i := 1; i <= 500; i++ {
+		values = append(values, fmt.Sprintf("(%d, %d, 'n%d')", i, i%100, i))
+	}
+	for _, sql := range []string{
+		"CREATE TABLE t (id int primary key, n int, name char(8));",
+		fmt.Sprintf("INSERT INTO t (id, n, name) VALUES %s;", strings.Join(values, ", ")),
+		"CREATE INDEX t_n ON t (n);",
+		"CREATE UNIQUE INDEX t_name ON t (name);",
+		"ANALYZE t;",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	require.FileExists(t, indexFile(dir, "t", "t_n"))
+
+	require.Equal(t, [][]any{
+		{int64(1), int64(0), "Project", `"id"`},
+		{int64(2), int64(1), "Index Scan", `on "t" using index "t_n" ("n" = 7)`},
+	}, planShape(queryRows(t, b, "EXPLAIN SELECT id FROM t WHERE n = 7;")))
+	require.Equal(t, [][]any{{int64(7)}, {int64(107)}, {int64(207)}, {int64(307)}, {int64(407)}},
+		queryRows(t, b, "SELECT id FROM t WHERE n = 7;"))
+	require.Equal(t, [][]any{{int64(42)}}, queryRows(t, b, "SELECT id FROM t WHERE name = 'n42';"))
+
+	// the index follows every write
+	for _, sql := range []string{
+		"UPDATE t SET n = 1000 WHERE id = 107;",
+		"DELETE FROM t WHERE id = 207;",
+		"INSERT INTO t (id, n, name) VALUES (501, 7, 'n501');",
+		"UPDATE t SET name = 'moved' WHERE id = 42;",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	require.Equal(t, [][]any{{int64(7)}, {int64(501)}, {int64(307)}, {int64(407)}},
+		queryRows(t, b, "SELECT id FROM t WHERE n = 7;"), "501 takes the slot 207 was deleted from")
+	require.Equal(t, [][]any{{int64(107)}}, queryRows(t, b, "SELECT id FROM t WHERE n = 1000;"))
+	require.Empty(t, queryRows(t, b, "SELECT id FROM t WHERE name = 'n42';"))
+	require.Equal(t, [][]any{{int64(42)}}, queryRows(t, b, "SELECT id FROM t WHERE name = 'moved';"))
+
+	b.Close()
+	b, err := OpenExistingDatabase(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	require.Equal(t, []indexDef{{name: "t_n", columns: []int{1}}, {name: "t_name", unique: true, columns: []int{2}}}, b.tables[0].indexes)
+	require.Equal(t, [][]any{{int64(7)}, {int64(501)}, {int64(307)}, {int64(407)}},
+		queryRows(t, b, "SELECT id FROM t WHERE n = 7;"))
+
+	_, err = execSQL(b, "DROP INDEX t_n;")
+	require.NoError(t, err)
+	require.NoFileExists(t, indexFile(dir, "t", "t_n"))
+	require.Equal(t, "Seq Scan", planShape(queryRows(t, b, "EXPLAIN SELECT id FROM t WHERE n = 7;"))[2][2])
+	require.Len(t, queryRows(t, b, "SELECT id FROM t WHERE n = 7;"), 4)
+}
+
+func TestUniqueIndexViolation(t *testing.T) {
+	b := newTestBackend(t,
+		"CREATE TABLE t (id int primary key, name char(8));",
+		"INSERT INTO t (id, name) VALUES (1, 'a'), (2, 'b'), (3, 'b');",
+	)
+	_, err := execSQL(b, "CREATE UNIQUE INDEX t_name ON t (name);")
+	require.EqualError(t, err, `could not create unique index "t_name": column name has duplicate values`)
+	require.Empty(t, b.tables[0].indexes)
+
+	_, err = execSQL(b, "DELETE FROM t WHERE id = 3;")
+	require.NoError(t, err)
+	_, err = execSQL(b, "CREATE UNIQUE INDEX t_name ON t (name);")
+	require.NoError(t, err)
+
+	// nothing of a failing write is stored
+	_, err = execSQL(b, "INSERT INTO t (id, name) VALUES (4, 'c'), (5, 'a');")
+	require.EqualError(t, err, `insert: duplicate key value in column name of table t violates unique index "t_name"`)
+	_, err = execSQL(b, "UPDATE t SET name = 'b' WHERE id = 1;")
+	require.EqualError(t, err, `duplicate key value in column name of table t violates unique index "t_name"`)
+	require.Equal(t, [][]any{{int64(1), "a"}, {int64(2), "b"}}, trimRows(queryRows(t, b, "SELECT id, name FROM t WHERE id > 0;")))
+
+	// swapping values within one statement is allowed
+	_, err = execSQL(b, "UPDATE t SET id = id + 10 WHERE id > 0;")
+	require.NoError(t, err)
+	require.Equal(t, [][]any{{int64(11), "a"}, {int64(12), "b"}}, trimRows(queryRows(t, b, "SELECT id, name FROM t WHERE name = 'a' OR name = 'b';")))
+
+	_, err = execSQL(b, "CREATE INDEX t_name ON t (id);")
+	require.EqualError(t, err, "Index already exist")
+	_, err = execSQL(b, "CREATE INDEX ix ON t (missing);")
+	require.EqualError(t, err, "Columns not in table: missing")
+	_, err = execSQL(b, "DROP INDEX missing;")
+	require.EqualError(t, err, "Index does not exist")
+}
+
+func TestTreeRandomOperations(t *testing.T) {
+	filename := t.TempDir() + "/tree.db"
+	tr, err := openTree(filename)
+	require.NoError(t, err)
+
+	stored := make(map[int64]int64)
+	for i := int64(0); i < 5000; i++ {
+		key := (i * 7919) % 5000 // every key once in a scattered order, including 0
+		require.NoError(t, tr.insertNode(appendKey(nil, key), key*10))
+		stored[key] = key * 10
+	}
+	require.EqualError(t, tr.insertNode(appendKey(nil, int64(0)), 1), "key already exists")
+	for key := int64(0); key < 5000; key += 3 {
+		require.True(t, tr.deleteKey(appendKey(nil, key)))
+		delete(stored, key)
+	}
+	require.False(t, tr.deleteKey(appendKey(nil, int64(0))))
+	tr.closeIndex()
+
+	tr, err = openTree(filename)
+	require.NoError(t, err)
+	defer tr.removeIndex()
+	for key := int64(0); key < 5000; key++ {
+		value, ok := tr.findKeyValue(appendKey(nil, key))
+		expected, exists := stored[key]
+		require.Equal(t, exists, ok, key)
+		require.Equal(t, expected, value, key)
+	}
+
+	previous := int64(-1)
+	count := 0
+	tr.ascend(appendKey(nil, int64(1)), func(key []byte, value int64) bool {
+		require.Greater(t, value, previous*10)
+		previous = value / 10
+		count++
+		return true
+	})
+	require.Equal(t, len(stored), count)
+}
+
+func TestCompositeKeys(t *testing.T) {
+	dir := t.TempDir()
+	b := CreateNewDatabase(dir)
+	values := make([]string, 0, 300)
+	for tenant := 1; tenant <= 3; tenant++ {
+		for id := 1; id <= 100; id++ {
+			values = append(values, fmt.Sprintf("(%d, %d, %d)", tenant, id, id%10))
+		}
+	}
+	for _, sql := range []string{
+		"CREATE TABLE t (id int, tenant int, n int, PRIMARY KEY (tenant, id));",
+		fmt.Sprintf("INSERT INTO t (tenant, id, n) VALUES %s;", strings.Join(values, ", ")),
+		"INSERT INTO t (tenant, id) VALUES (4, 1);",
+		"CREATE INDEX t_n_id ON t (n, id);",
+		"ANALYZE t;",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	require.Equal(t, []int{1, 0}, b.tables[0].primaryKey)
+	require.FileExists(t, filepath.Join(dir, "t.tenant_id.pkey"))
+
+	ts := []struct {
+		SQL  string
+		Plan string
+		Rows [][]any
+	}{
+		{"SELECT tenant, id FROM t WHERE id = 7 AND tenant = 2;", `on "t" using primary key ("tenant" = 2 AND "id" = 7)`, [][]any{{int64(2), int64(7)}}},
+		{"SELECT tenant, id FROM t WHERE tenant = 3 AND id > 97;", `on "t" using primary key ("tenant" = 3 AND "id" > 97)`, [][]any{{int64(3), int64(98)}, {int64(3), int64(99)}, {int64(3), int64(100)}}},
+		{"SELECT tenant, id FROM t WHERE tenant = 4;", `on "t" using primary key ("tenant" = 4)`, [][]any{{int64(4), int64(1)}}},
+		{"SELECT tenant, id FROM t WHERE n = 3 AND id < 20;", `on "t" using index "t_n_id" ("n" = 3 AND "id" < 20)`, [][]any{{int64(1), int64(3)}, {int64(1), int64(13)}, {int64(2), int64(3)}, {int64(2), int64(13)}, {int64(3), int64(3)}, {int64(3), int64(13)}}},
+	}
+	for _, tc := range ts {
+		t.Run(tc.SQL, func(t *testing.T) {
+			plan := queryRows(t, b, "EXPLAIN "+tc.SQL)
+			require.Equal(t, tc.Plan, plan[len(plan)-1][3])
+			require.Equal(t, tc.Rows, queryRows(t, b, tc.SQL))
+		})
+	}
+
+	_, err := execSQL(b, "INSERT INTO t (tenant, id) VALUES (2, 7);")
+	require.EqualError(t, err, "insert: duplicate key value in columns tenant, id of table t violates primary key")
+	_, err = execSQL(b, "INSERT INTO t (id) VALUES (7);")
+	require.EqualError(t, err, "null value in column tenant of table t violates not null constraint")
+	_, err = execSQL(b, "INSERT INTO t (tenant, id) VALUES (2, 1000), (1, 1);")
+	require.Error(t, err)
+	require.Empty(t, queryRows(t, b, "SELECT id FROM t WHERE tenant = 2 AND id = 1000;"))
+
+	// rows with a null in a later column of an index are found through its leading columns
+	_, err = execSQL(b, "UPDATE t SET n = 1000 WHERE tenant = 4;")
+	require.NoError(t, err)
+	_, err = execSQL(b, "INSERT INTO t (tenant, id, n) VALUES (5, 1, 1000);")
+	require.NoError(t, err)
+	b.Close()
+
+	b, err = OpenExistingDatabase(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	_, err = execSQL(b, "CREATE INDEX t_n_tenant ON t (n, tenant, n);")
+	require.EqualError(t, err, "column n appears more than once")
+	require.Equal(t, [][]any{{int64(4), int64(1)}, {int64(5), int64(1)}}, queryRows(t, b, "SELECT tenant, id FROM t WHERE n = 1000;"))
+	require.Equal(t, [][]any{{int64(2), int64(7)}}, queryRows(t, b, "SELECT tenant, id FROM t WHERE tenant = 2 AND id = 7;"))
+}
+
+func TestCompositeIndexNulls(t *testing.T) {
+	b := newTestBackend(t,
+		"CREATE TABLE t (id int primary key, a int, c int);",
+		"INSERT INTO t (id, a, c) VALUES (1, 1, 1), (2, 1, 2), (3, 2, 1);",
+		"INSERT INTO t (id, a) VALUES (4, 1);",
+		"INSERT INTO t (id, c) VALUES (5, 1);",
+		"CREATE UNIQUE INDEX t_a_c ON t (a, c);",
+		"INSERT INTO t (id, a) VALUES (6, 1);", // nulls never equal each other
+	)
+	require.Equal(t, `on "t" using index "t_a_c" ("a" = 1)`, queryRows(t, b, "EXPLAIN SELECT id FROM t WHERE a = 1;")[1][3])
+	require.Equal(t, [][]any{{int64(1)}, {int64(2)}, {int64(4)}, {int64(6)}}, queryRows(t, b, "SELECT id FROM t WHERE a = 1;"))
+	require.Equal(t, [][]any{{int64(1)}}, queryRows(t, b, "SELECT id FROM t WHERE a = 1 AND c < 2;"))
+	require.Equal(t, [][]any{{int64(3)}}, queryRows(t, b, "SELECT id FROM t WHERE a = 2 AND c = 1;"))
+
+	_, err := execSQL(b, "INSERT INTO t (id, a, c) VALUES (7, 2, 1);")
+	require.EqualError(t, err, `insert: duplicate key value in columns a, c of table t violates unique index "t_a_c"`)
+	_, err = execSQL(b, "DELETE FROM t WHERE a = 1;")
+	require.NoError(t, err)
+	require.Equal(t, [][]any{{int64(3)}, {int64(5)}}, queryRows(t, b, "SELECT id FROM t WHERE id > 0;"))
+}
+
+func TestIndexFileNames(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		tableFile(dir, "a"), tableFile(dir, "ax"), tableFile(dir, "ixtfoo"), tableFile(dir, fileName("t.foo")), overflowFile(dir, "a"),
+		indexFile(dir, "a", "x1"), indexFile(dir, "ax", "1"), indexFile(dir, "t", "foo"), indexFile(dir, fileName("t.foo"), "x"), indexFile(dir, "t", "foo.x"),
+		primaryIndexFile(dir, "a", "x1"), primaryIndexFile(dir, "ax", "1"), primaryIndexFile(dir, fileName("a/b"), "id"),
+		tableFile(dir, tableFiles("t", 1)), tableFile(dir, tableFiles("t.1", 0)), indexFile(dir, tableFiles("t", 1), "x"), indexFile(dir, "t", "1.x"),
+		primaryIndexFile(dir, tableFiles("t", 1), "id"), primaryIndexFile(dir, "t", "1.id"),
+	}
+	seen := make(map[string]bool)
+	for _, f := range files {
+		require.False(t, seen[f], "%s is the file of two names", f)
+		seen[f] = true
+		require.Equal(t, dir, filepath.Dir(f))
+	}
+
+	b := CreateNewDatabase(dir)
+	for _, sql := range []string{
+		"CREATE TABLE a (id int primary key, n int);",
+		"CREATE TABLE ax (id int primary key, n int);",
+		"CREATE UNIQUE INDEX x1 ON a (n);",
+		`CREATE UNIQUE INDEX "1" ON ax (n);`,
+		"INSERT INTO a (n) VALUES (1), (2);",
+		"INSERT INTO ax (n) VALUES (3), (4);",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	b.Close()
+
+	b, err := OpenExistingDatabase(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	_, err = execSQL(b, "INSERT INTO a (n) VALUES (2);")
+	require.ErrorContains(t, err, "x1")
+	_, err = execSQL(b, "INSERT INTO ax (n) VALUES (4);")
+	require.ErrorContains(t, err, `"1"`)
+	_, err = execSQL(b, "INSERT INTO a (n) VALUES (3);")
+	require.NoError(t, err, "each index keeps only the keys of its table")
+}
+
+func TestNonIntegerKeys(t *testing.T) {
+	dir := t.TempDir()
+	b := CreateNewDatabase(dir)
+	for _, sql := range []string{
+		"CREATE TABLE currency (code char(3) primary key, name char(10));",
+		"INSERT INTO currency (code, name) VALUES ('USD', 'dollar'), ('EUR', 'euro'), ('JPY', 'yen'), ('CHF', 'franc');",
+		"CREATE TABLE rate (r float primary key, label char(5));",
+		"INSERT INTO rate (r, label) VALUES (0, 'zero'), (-1.5, 'neg'), (2.25, 'pos');",
+		"CREATE TABLE flag (enabled bool primary key, n int);",
+		"INSERT INTO flag (enabled, n) VALUES (false, 0), (true, 1);",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+
+	_, err := execSQL(b, "INSERT INTO currency (code, name) VALUES ('GBP', 'pound'), ('EUR', 'again');")
+	require.EqualError(t, err, "insert: duplicate key value in column code of table currency violates primary key")
+	_, err = execSQL(b, "INSERT INTO currency (name) VALUES ('none');")
+	require.EqualError(t, err, "null value in column code of table currency violates not null constraint")
+	b.Close()
+
+	b, err = OpenExistingDatabase(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	ts := []struct {
+		SQL  string
+		Plan string
+		Rows [][]any
+	}{
+		{"SELECT name FROM currency WHERE code = 'EUR';", `on "currency" using primary key ("code" = 'EUR')`, [][]any{{"euro"}}},
+		{"SELECT code FROM currency WHERE code > 'A' AND code < 'JPY';", `on "currency" using primary key ("code" > 'A' AND "code" < 'JPY')`, [][]any{{"EUR"}, {"CHF"}}},
+		{"SELECT label FROM rate WHERE r = 0;", `on "rate" using primary key ("r" = 0)`, [][]any{{"zero"}}},
+		{"SELECT label FROM rate WHERE r > -2 AND r < 1;", `on "rate" using primary key ("r" > -2 AND "r" < 1)`, [][]any{{"zero"}, {"neg"}}},
+		{"SELECT n FROM flag WHERE enabled = false;", `on "flag" using primary key ("enabled" = FALSE)`, [][]any{{int64(0)}}},
+	}
+	for _, tc := range ts {
+		t.Run(tc.SQL, func(t *testing.T) {
+			plan := queryRows(t, b, "EXPLAIN "+tc.SQL)
+			require.Equal(t, tc.Plan, plan[len(plan)-1][3])
+			require.Equal(t, tc.Rows, trimRows(queryRows(t, b, tc.SQL)))
+		})
+	}
+
+	_, err = execSQL(b, "UPDATE currency SET code = 'GBP' WHERE code = 'CHF';")
+	require.NoError(t, err)
+	require.Equal(t, [][]any{{"franc"}}, trimRows(queryRows(t, b, "SELECT name FROM currency WHERE code = 'GBP';")))
+	require.Empty(t, queryRows(t, b, "SELECT name FROM currency WHERE code = 'CHF';"))
+}
@@ -0,0 +1,325 @@
+package internal
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// rowInserter builds the rows of an INSERT and collects them into a batch written at once
+type rowInserter struct {
+	table        *Table
+	positions    []int    //position in the table of every inserted column
+	rowIdColumn  int      //column the primary key is handed out for, -1 if keys are given
+	lastRowId    int64    //last primary key handed out
+	batch        [][]Cell //rows built so far and updates of DO UPDATE, checked and written together at the end
+	stored       [][]Cell //stored row replaced by each row of the batch, nil for an inserted row
+	written      [][]Cell //rows inserted or updated by DO UPDATE
+	lastInsertId int64    //primary key handed out to the last inserted row, 0 if none was
+
+	onConflict   *OnConflict
+	arbiters     []arbiter //unique keys a conflict is detected on
+	setPositions []int     //position of every column set by DO UPDATE
+	scope        *rowScope //binds DO UPDATE to the stored row followed by the proposed one
+}
+
+// arbiter is the primary key or a unique index an ON CONFLICT clause detects conflicts on
+type arbiter struct {
+	tree    *tree
+	columns []int
+	pending map[string]bool //keys proposed by the statement
+}
+
+// key of row in the index, nil if it has a null in a key column
+func (a *arbiter) key(table *Table, row []Cell) []byte {
+	if slices.ContainsFunc(a.columns, func(pos int) bool { return row[pos] == nil }) {
+		return nil
+	}
+	return table.appendColumnsKey(nil, row, a.columns)
+}
+
+// builds the row of the table holding the inserted values, missing columns take their default or a new primary key
+func (ins *rowInserter) row(values []any) ([]Cell, error) {
+	table := ins.table
+	cellRow := make([]Cell, len(table.Columns))
+	for i, pos := range ins.positions {
+		var err error
+		cellRow[pos], err = valueCell(&table.Columns[pos], values[i])
+		if err != nil {
+			return nil, fmt.Errorf("insert: %w", err)
+		}
+	}
+	if err := table.applyDefaults(cellRow, ins.positions); err != nil {
+		return nil, fmt.Errorf("insert: %w", err)
+	}
+	if ins.rowIdColumn != -1 {
+		ins.lastRowId++
+		var err error
+		if cellRow[ins.rowIdColumn], err = integerCell(&table.Columns[ins.rowIdColumn], ins.lastRowId); err != nil {
+			return nil, fmt.Errorf("insert: %w", err)
+		}
+	}
+	if err := table.computeGenerated(cellRow); err != nil {
+		return nil, fmt.Errorf("insert: %w", err)
+	}
+	if err := table.checkNotNull(cellRow); err != nil {
+		return nil, err
+	}
+	if err := table.checkRow(cellRow); err != nil {
+		return nil, err
+	}
+	return cellRow, nil
+}
+
+// resolves the conflicting columns and the assignments of an ON CONFLICT clause
+func (ins *rowInserter) prepareConflict(c *OnConflict) error {
+	table := ins.table
+	ins.onConflict = c
+	candidates := []arbiter{{tree: table.indices.primaryTree, columns: table.primaryKey}}
+	for _, def := range table.indexes {
+		if def.unique && def.exprs == nil { //conflicts are named by columns, an index of expressions is never an arbiter
+			candidates = append(candidates, arbiter{tree: table.indices.secondary[def.name], columns: def.columns})
+		}
+	}
+	if len(c.Columns) == 0 {
+		ins.arbiters = candidates
+	} else {
+		target, err := table.columnPositions(c.Columns)
+		if err != nil {
+			return err
+		}
+		slices.Sort(target)
+		for _, a := range candidates {
+			columns := slices.Clone(a.columns)
+			slices.Sort(columns)
+			if slices.Equal(columns, target) {
+				ins.arbiters = append(ins.arbiters, a)
+			}
+		}
+		if len(ins.arbiters) == 0 {
+			return fmt.Errorf("no primary key or unique index of table %s matches ON CONFLICT (%s)", table.Name, strings.Join(c.Columns, ", "))
+		}
+	}
+	for i := range ins.arbiters {
+		ins.arbiters[i].pending = make(map[string]bool)
+	}
+	if !c.DoUpdate {
+		return nil
+	}
+
+	ins.scope = &rowScope{table: table, excluded: true}
+	ins.setPositions = make([]int, len(c.Set))
+	for i, set := range c.Set {
+		ins.setPositions[i] = slices.IndexFunc(table.Columns, func(col Column) bool { return col.columnName == set.Column })
+		if ins.setPositions[i] == -1 {
+			return fmt.Errorf("Columns not in table: %s", set.Column)
+		}
+		if table.Columns[ins.setPositions[i]].columnGenerated != nil {
+			return fmt.Errorf("cannot update generated column %s", set.Column)
+		}
+		if err := ins.scope.bind(set.Value); err != nil {
+			return err
+		}
+	}
+	return ins.scope.bind(c.Where)
+}
+
+// adds a built row to the batch, resolving its conflict first when the INSERT has an ON CONFLICT clause
+func (b *Backend) addRow(ins *rowInserter, row []Cell) error {
+	if ins.onConflict != nil {
+		resolved, err := b.resolveConflict(ins, row)
+		if resolved || err != nil {
+			return err
+		}
+		ins.propose(row)
+	}
+	ins.batch = append(ins.batch, row)
+	ins.stored = append(ins.stored, nil)
+	return nil
+}
+
+// marks the keys of a row proposed by the statement as taken
+func (ins *rowInserter) propose(row []Cell) {
+	for i := range ins.arbiters {
+		if key := ins.arbiters[i].key(ins.table, row); key != nil {
+			ins.arbiters[i].pending[string(key)] = true
+		}
+	}
+}
+
+// writes the batch of ins, the inserted rows and the updates of DO UPDATE are checked together before any is written
+func (b *Backend) flushRows(ins *rowInserter) error {
+	if len(ins.batch) == 0 {
+		return nil
+	}
+	if err := b.writeRows(ins.table, ins.stored, ins.batch); err != nil {
+		return fmt.Errorf("insert: %w", err)
+	}
+	ins.table.lastRowId = max(ins.table.lastRowId, ins.lastRowId)
+	for i, row := range ins.batch {
+		if ins.stored[i] == nil && ins.rowIdColumn != -1 {
+			ins.lastInsertId = row[ins.rowIdColumn].AsInt()
+		}
+	}
+	ins.written = ins.batch
+	ins.batch, ins.stored = nil, nil
+	return nil
+}
+
+// rows written by the INSERT projected on its RETURNING clause, with the count and the last key handed out
+func (ins *rowInserter) result(returning []ResultColumn) (driver.Rows, error) {
+	rows, err := returnRows(ins.table, returning, ins.written)
+	if err != nil {
+		return nil, err
+	}
+	rows.lastInsertId = ins.lastInsertId
+	return rows, nil
+}
+
+/*
+resolveConflict skips the row conflicting with row on one of the arbiters or queues its update, reports whether there was one.
+Like in Postgres DO UPDATE affects a row at most once, a key proposed twice by the statement is an error
+*/
+func (b *Backend) resolveConflict(ins *rowInserter, row []Cell) (bool, error) {
+	table := ins.table
+	for i := range ins.arbiters {
+		a := &ins.arbiters[i]
+		key := a.key(table, row)
+		if key == nil {
+			continue
+		}
+		if a.pending[string(key)] {
+			if !ins.onConflict.DoUpdate {
+				return true, nil
+			}
+			return true, errSecondUpdate(table)
+		}
+		loc, ok := a.tree.findKeyValue(key)
+		if !ok {
+			continue
+		}
+		if !ins.onConflict.DoUpdate {
+			return true, nil
+		}
+		stored, err := b.storedRow(table, loc, a.columns, key)
+		if err != nil {
+			return true, err
+		}
+		return true, b.updateConflict(ins, stored, row)
+	}
+	return false, nil
+}
+
+func errSecondUpdate(table *Table) error {
+	return fmt.Errorf("ON CONFLICT DO UPDATE cannot affect a row of table %s a second time", table.Name)
+}
+
+// row of table on the page of loc whose columns hold key
+func (b *Backend) storedRow(table *Table, loc int64, columns []int, key []byte) ([]Cell, error) {
+	for _, row := range b.bufferPool.fetchPage(table.Name, PageID(loc/PAGESIZE), nil) {
+		if bytes.Equal(table.appendColumnsKey(nil, row, columns), key) {
+			return row, nil
+		}
+	}
+	return nil, fmt.Errorf("row missing from page of table %q", table.Name)
+}
+
+// queues the update of DO UPDATE to the stored row conflicting with the proposed one
+func (b *Backend) updateConflict(ins *rowInserter, stored, proposed []Cell) error {
+	table := ins.table
+	both := append(slices.Clone(stored), proposed...)
+	if ok, err := ins.scope.matches(ins.onConflict.Where, both); err != nil || !ok {
+		return err
+	}
+	updated := slices.Clone(stored)
+	for i, set := range ins.onConflict.Set {
+		pos := ins.setPositions[i]
+		value, err := ins.scope.eval(set.Value, both)
+		if err != nil {
+			return err
+		}
+		if updated[pos], err = valueCell(&table.Columns[pos], value); err != nil {
+			return fmt.Errorf("insert: %w", err)
+		}
+	}
+	if err := table.computeGenerated(updated); err != nil {
+		return fmt.Errorf("insert: %w", err)
+	}
+	if err := table.checkNotNull(updated); err != nil {
+		return err
+	}
+	if err := table.checkRow(updated); err != nil {
+		return err
+	}
+	// the stored row has the keys of the proposed one on the arbiters, proposing them again is an error
+	ins.propose(proposed)
+	if pos := table.rowIdColumn(); pos != -1 { //keys handed out later must stay above an updated key
+		ins.lastRowId = max(ins.lastRowId, updated[pos].AsInt())
+	}
+	ins.batch = append(ins.batch, updated)
+	ins.stored = append(ins.stored, stored)
+	return nil
+}
+
+/*
+insertSelect inserts the rows of a SELECT. Like the rows of VALUES they are collected and checked together
+before any is written, so a row breaking a constraint leaves the table as it was.
+Rows selected from the table inserted into are read before any is written
+*/
+func (b *Backend) insertSelect(ins *rowInserter, sel *SelectStatement) error {
+	sq := lowerStatement(sel)
+	source, ok := b.checkTableExist(sq)
+	if !ok {
+		return errors.New("Table does not exist")
+	}
+	defer b.lockTables(ins.table, source)()
+	ins.lastRowId = ins.table.lastRowId
+
+	plan, err := b.planSelect(source, sq)
+	if err != nil {
+		return err
+	}
+	if len(plan.columns) != len(ins.positions) {
+		return fmt.Errorf("insert: %d columns are selected for %d inserted columns", len(plan.columns), len(ins.positions))
+	}
+	next := func() ([]Cell, error) { return pull(plan) }
+	if source == ins.table {
+		rows, err := drain(plan)
+		if err != nil {
+			return err
+		}
+		next = func() ([]Cell, error) {
+			if len(rows) == 0 {
+				return nil, io.EOF
+			}
+			row := rows[0]
+			rows = rows[1:]
+			return row, nil
+		}
+	}
+
+	for {
+		row, err := next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		values := make([]any, len(plan.columns))
+		for i, col := range plan.columns {
+			values[i] = cellValue(col.column(source), row[col.columnPos])
+		}
+		cellRow, err := ins.row(values)
+		if err != nil {
+			return err
+		}
+		if err := b.addRow(ins, cellRow); err != nil {
+			return err
+		}
+	}
+	return b.flushRows(ins)
+}
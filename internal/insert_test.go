@@ -0,0 +1,164 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertOnConflict(t *testing.T) {
+	b := newTestBackend(t,
+		"CREATE TABLE kv (id int primary key, name char(10) unique, n int not null, ver int default 0);",
+		"INSERT INTO kv (id, name, n) VALUES (1, 'a', 1), (2, 'b', 2);",
+		"INSERT INTO kv (id, name, n) VALUES (1, 'x', 5), (3, 'c', 3) ON CONFLICT DO NOTHING;",
+		"INSERT INTO kv (id, name, n) VALUES (2, 'b', 10), (4, 'd', 4) ON CONFLICT (id) DO UPDATE SET n = excluded.n + kv.n, ver = ver + 1;",
+		"INSERT INTO kv (name, n) VALUES ('a', 100) ON CONFLICT (name) DO UPDATE SET n = excluded.n;",
+		"INSERT INTO kv (id, name, n) VALUES (8, 'f', 1), (9, 'f', 2) ON CONFLICT DO NOTHING;",
+		"INSERT INTO kv (id, name, n) VALUES (3, 'c', 0) ON CONFLICT (id) DO UPDATE SET n = excluded.n WHERE kv.n > 1000;",
+	)
+	expected := [][]any{
+		{int64(1), "a", int64(100), int64(0)},
+		{int64(2), "b", int64(12), int64(1)},
+		{int64(3), "c", int64(3), int64(0)},
+		{int64(4), "d", int64(4), int64(0)},
+		{int64(8), "f", int64(1), int64(0)},
+	}
+	require.Equal(t, expected, trimRows(queryRows(t, b, "SELECT id, name, n, ver FROM kv WHERE id > 0;")))
+
+	errs := []struct {
+		SQL      string
+		Expected string
+	}{
+		{"INSERT INTO kv (id, name, n) VALUES (1, 'z', 1) ON CONFLICT (n) DO NOTHING;", "no primary key or unique index of table kv matches ON CONFLICT (n)"},
+		{"INSERT INTO kv (id, name, n) VALUES (5, 'a', 1) ON CONFLICT (id) DO NOTHING;", "insert: duplicate key value in column name of table kv violates unique index \"kv_name_key\""},
+		{"INSERT INTO kv (id, name, n) VALUES (7, 'e', 1), (7, 'e', 2) ON CONFLICT (id) DO UPDATE SET n = excluded.n;", "ON CONFLICT DO UPDATE cannot affect a row of table kv a second time"},
+		{"INSERT INTO kv (id, name, n) VALUES (2, 'b', 1), (5, 'a', 1) ON CONFLICT (id) DO UPDATE SET n = 0;", "insert: duplicate key value in column name of table kv violates unique index \"kv_name_key\""},
+		{"INSERT INTO kv (id, name, n) VALUES (1, 'z', 1) ON CONFLICT (id) DO UPDATE SET name = 'b';", "insert: duplicate key value in column name of table kv violates unique index \"kv_name_key\""},
+		{"INSERT INTO kv (id, name, n) VALUES (1, 'z', 1) ON CONFLICT (id) DO UPDATE SET n = NULL;", "null value in column n of table kv violates not null constraint"},
+		{"INSERT INTO kv (id, name, n) VALUES (1, 'z', 1) ON CONFLICT (id) DO UPDATE SET missing = 1;", "Columns not in table: missing"},
+		{"INSERT INTO kv (id, name, n) VALUES (1, 'z', 1) ON CONFLICT (id) DO UPDATE SET n = other.n;", `unknown table "other" in column reference "other"."n"`},
+	}
+	for _, tc := range errs {
+		t.Run(tc.SQL, func(t *testing.T) {
+			_, err := execSQL(b, tc.SQL)
+			require.EqualError(t, err, tc.Expected)
+		})
+	}
+	require.Equal(t, expected, trimRows(queryRows(t, b, "SELECT id, name, n, ver FROM kv WHERE id > 0;")), "a failed statement updates no row")
+}
+
+func TestInsertSelectOnConflict(t *testing.T) {
+	b := newTestBackend(t,
+		"CREATE TABLE src (id int primary key, n int);",
+		"INSERT INTO src (id, n) VALUES (1, 50), (2, 60), (10, 70);",
+		"CREATE TABLE dst (id int primary key, n int);",
+		"INSERT INTO dst (id, n) VALUES (1, 1), (2, 2);",
+		"INSERT INTO dst (id, n) SELECT id, n FROM src ON CONFLICT (id) DO UPDATE SET n = dst.n + excluded.n;",
+		"INSERT INTO dst (id, n) SELECT id, n FROM src WHERE id > 1 ON CONFLICT DO NOTHING;",
+	)
+	require.Equal(t, [][]any{{int64(1), int64(51)}, {int64(2), int64(62)}, {int64(10), int64(70)}}, queryRows(t, b, "SELECT id, n FROM dst WHERE id > 0;"))
+}
+
+func TestInsertKeysOutOfOrder(t *testing.T) {
+	even, odd := make([]string, 0, 300), make([]string, 0, 300)
+	for i := 1; i <= 600; i++ {
+		if i%2 == 0 {
+			even = append(even, fmt.Sprintf("(%d, 'n%d')", i, i))
+		} else {
+			odd = append(odd, fmt.Sprintf("(%d, 'n%d')", i, i))
+		}
+	}
+	dir := t.TempDir()
+	b := CreateNewDatabase(dir)
+	for _, sql := range []string{
+		"CREATE TABLE t (id int primary key, name char(8) unique);",
+		"INSERT INTO t (id, name) VALUES (1000, 'big');",
+		"INSERT INTO t (id, name) VALUES " + strings.Join(even, ", ") + ";",
+		"INSERT INTO t (id, name) VALUES " + strings.Join(odd, ", ") + ";",
+		"INSERT INTO t (name) VALUES ('next');",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	require.Equal(t, [][]any{{"n301"}}, trimRows(queryRows(t, b, "SELECT name FROM t WHERE id = 301;")))
+	require.Equal(t, [][]any{{int64(1001)}}, queryRows(t, b, "SELECT id FROM t WHERE name = 'next';"), "keys handed out follow the largest key")
+	_, err := execSQL(b, "INSERT INTO t (id, name) VALUES (999, 'a'), (999, 'b');")
+	require.EqualError(t, err, "insert: duplicate key value in column id of table t violates primary key")
+	_, err = execSQL(b, "INSERT INTO t (id, name) VALUES (300, 'a');")
+	require.EqualError(t, err, "insert: duplicate key value in column id of table t violates primary key")
+	var cerr *ConstraintError
+	require.ErrorAs(t, err, &cerr, "the constraint error is wrapped")
+	require.Equal(t, PrimaryKeyConstraint, cerr.Kind)
+
+	info, err := os.Stat(tableFile(dir, "t"))
+	require.NoError(t, err)
+	_, err = execSQL(b, "DELETE FROM t WHERE id <= 100;")
+	require.NoError(t, err)
+	_, err = execSQL(b, "INSERT INTO t (id, name) VALUES "+strings.Join(odd[:50], ", ")+";")
+	require.NoError(t, err)
+	_, err = execSQL(b, "DELETE FROM t WHERE id > 500 AND id <= 510;")
+	require.NoError(t, err)
+	b.Close()
+
+	b, err = OpenExistingDatabase(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	_, err = execSQL(b, "INSERT INTO t (id, name) VALUES "+strings.Join(even[:50], ", ")+";")
+	require.NoError(t, err)
+	_, err = execSQL(b, "INSERT INTO t (name) VALUES ('last');")
+	require.NoError(t, err)
+	after, err := os.Stat(tableFile(dir, "t"))
+	require.NoError(t, err)
+	require.Equal(t, info.Size(), after.Size(), "rows are written into the slots freed by deletes")
+	require.Equal(t, [][]any{{int64(1002)}}, queryRows(t, b, "SELECT id FROM t WHERE name = 'last';"))
+	require.Len(t, queryRows(t, b, "SELECT id FROM t WHERE id <= 100;"), 100)
+	require.Len(t, queryRows(t, b, "SELECT id FROM t WHERE id > 500 AND id <= 510;"), 0)
+}
+
+func TestInsertSelect(t *testing.T) {
+	values := make([]string, 0, 1000)
+	for i := 1; i <= 1000; i++ {
+		values = append(values, fmt.Sprintf("(%d, 'n%d', %d)", i, i, i%10))
+	}
+	b := newTestBackend(t,
+		"CREATE TABLE src (id int primary key, name char(10), grp int);",
+		"INSERT INTO src (id, name, grp) VALUES "+strings.Join(values, ", ")+";",
+		"CREATE TABLE dst (id int primary key, label char(12) unique, grp int not null, twice int generated always as (grp * 2) stored);",
+		"CREATE INDEX dst_grp ON dst (grp);",
+	)
+	_, err := execSQL(b, "INSERT INTO dst (label, grp) SELECT name, grp FROM src WHERE id > 10;")
+	require.NoError(t, err)
+	require.Len(t, queryRows(t, b, "SELECT id FROM dst WHERE grp = 3;"), 99, "every row is indexed")
+	require.Equal(t, [][]any{{int64(990), "n1000", int64(0)}}, trimRows(queryRows(t, b, "SELECT id, label, twice FROM dst WHERE id > 989;")))
+
+	_, err = execSQL(b, "INSERT INTO dst (id, label, grp) SELECT id, name, grp FROM src WHERE id <= 10;")
+	require.EqualError(t, err, "insert: duplicate key value in column id of table dst violates primary key")
+	_, err = execSQL(b, "INSERT INTO dst (label, grp) SELECT name, grp FROM src WHERE id = 11;")
+	require.EqualError(t, err, "insert: duplicate key value in column label of table dst violates unique index \"dst_label_key\"")
+	_, err = execSQL(b, "INSERT INTO dst (label, grp) SELECT name FROM src WHERE id = 1;")
+	require.EqualError(t, err, "insert: 1 columns are selected for 2 inserted columns")
+	_, err = execSQL(b, "INSERT INTO dst (label, grp) SELECT name, missing FROM src WHERE id = 1;")
+	require.EqualError(t, err, "Columns not in table: missing")
+
+	for _, sql := range []string{
+		"CREATE TABLE c (id int primary key, grp int check (id < 1000));",
+		"CREATE TABLE u (id int primary key, name char(10) unique);",
+		"INSERT INTO u (id, name) VALUES (0, 'n1000');",
+	} {
+		_, err = execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	_, err = execSQL(b, "INSERT INTO c (id, grp) SELECT id, grp FROM src WHERE id > 0;")
+	require.EqualError(t, err, `row of table c violates check constraint "c_grp_check"`)
+	require.Empty(t, queryRows(t, b, "SELECT id FROM c WHERE id > 0;"), "rows before the failing one are not written either")
+	_, err = execSQL(b, "INSERT INTO u (id, name) SELECT id, name FROM src WHERE id > 0;")
+	require.EqualError(t, err, "insert: duplicate key value in column name of table u violates unique index \"u_name_key\"")
+	require.Equal(t, [][]any{{int64(0)}}, queryRows(t, b, "SELECT id FROM u WHERE id >= 0;"))
+
+	_, err = execSQL(b, "INSERT INTO src (name, grp) SELECT name, grp FROM src WHERE id > 0;")
+	require.NoError(t, err, "rows of the table inserted into are read before writing")
+	require.Equal(t, [][]any{{int64(2000), "n1000"}}, trimRows(queryRows(t, b, "SELECT id, name FROM src WHERE id > 1999;")))
+}
@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+/*
+Integer columns are TINYINT, SMALLINT, INT32 and INT or BIGINT of 1, 2, 4 and 8 bytes, each of them
+can be UNSIGNED. Cells hold the little endian value in the width of the column. Signed columns and
+narrow unsigned columns evaluate as int64, BIGINT UNSIGNED evaluates as uint64 so values above
+math.MaxInt64 keep every bit.
+*/
+
+// bytes taken by each integer type
+var integerSizes = map[string]uint16{"TINYINT": 1, "SMALLINT": 2, "INT32": 4, "INT": 8, "BIGINT": 8}
+
+func (c *Cell) asUint() uint64 {
+	var buf [8]byte
+	copy(buf[:], *c)
+	return binary.LittleEndian.Uint64(buf[:])
+}
+
+// integerCell converts an int64 or uint64 to a cell of col, failing when the value is out of its range
+func integerCell(col *Column, v any) (Cell, error) {
+	var bits uint64
+	fits := true
+	width := uint(col.columnSize) * 8
+	switch n := v.(type) {
+	case int64:
+		bits = uint64(n)
+		if col.columnType == UINT {
+			fits = n >= 0 && (width == 64 || bits < 1<<width)
+		} else if width < 64 {
+			fits = n >= -1<<(width-1) && n < 1<<(width-1)
+		}
+	case uint64:
+		bits = n
+		if col.columnType == UINT {
+			fits = width == 64 || n < 1<<width
+		} else {
+			fits = n < 1<<(width-1)
+		}
+	}
+	if !fits {
+		return nil, fmt.Errorf("value %v is out of range for column %s", v, col.columnName)
+	}
+	return binary.LittleEndian.AppendUint64(nil, bits)[:col.columnSize], nil
+}
+
+// bigInteger returns an int64 or uint64 as a big integer
+func bigInteger(v any) (*big.Int, bool) {
+	switch n := v.(type) {
+	case int64:
+		return big.NewInt(n), true
+	case uint64:
+		return new(big.Int).SetUint64(n), true
+	}
+	return nil, false
+}
+
+// integerValue is n as an int64, or as an uint64 when it is above math.MaxInt64
+func integerValue(n *big.Int) (any, error) {
+	if n.IsInt64() {
+		return n.Int64(), nil
+	}
+	if n.IsUint64() {
+		return n.Uint64(), nil
+	}
+	return nil, fmt.Errorf("integer %s is out of range", n)
+}
+
+// integerOperands are the operands of arithmetic or comparison involving an uint64
+func integerOperands(left, right any) (*big.Int, *big.Int, bool) {
+	_, lu := left.(uint64)
+	_, ru := right.(uint64)
+	if !lu && !ru {
+		return nil, nil, false
+	}
+	l, lok := bigInteger(left)
+	r, rok := bigInteger(right)
+	return l, r, lok && rok
+}
+
+func integerArithmetic(op Operator, l, r *big.Int) (any, error) {
+	result := new(big.Int)
+	switch op {
+	case Plus:
+		result.Add(l, r)
+	case Minus:
+		result.Sub(l, r)
+	case Multiply:
+		result.Mul(l, r)
+	default:
+		if r.Sign() == 0 {
+			return nil, errors.New("division by zero")
+		}
+		result.Quo(l, r)
+	}
+	return integerValue(result)
+}
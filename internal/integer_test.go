@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntegerCell(t *testing.T) {
+	ts := []struct {
+		Type  uint8
+		Size  uint16
+		Value any
+		Fits  bool
+	}{
+		{INT, 1, int64(-128), true},
+		{INT, 1, int64(127), true},
+		{INT, 1, int64(128), false},
+		{INT, 2, int64(-32769), false},
+		{INT, 4, int64(math.MaxInt32), true},
+		{INT, 4, int64(math.MinInt32 - 1), false},
+		{INT, 8, int64(math.MinInt64), true},
+		{INT, 8, uint64(math.MaxInt64 + 1), false},
+		{UINT, 1, int64(255), true},
+		{UINT, 1, int64(256), false},
+		{UINT, 2, int64(-1), false},
+		{UINT, 4, int64(math.MaxUint32), true},
+		{UINT, 8, uint64(math.MaxUint64), true},
+		{UINT, 8, int64(-1), false},
+	}
+	for _, tc := range ts {
+		col := &Column{columnName: "n", columnType: tc.Type, columnSize: tc.Size}
+		cell, err := integerCell(col, tc.Value)
+		if !tc.Fits {
+			require.EqualError(t, err, fmt.Sprintf("value %v is out of range for column n", tc.Value))
+			continue
+		}
+		require.NoError(t, err, "%v in %d bytes", tc.Value, tc.Size)
+		require.Len(t, cell, int(tc.Size))
+		got := cellValue(col, cell)
+		if n, ok := tc.Value.(int64); ok && tc.Type == UINT && tc.Size == 8 {
+			require.Equal(t, uint64(n), got)
+		} else {
+			require.Equal(t, tc.Value, got)
+		}
+	}
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	got, err := arithmetic(Plus, uint64(math.MaxInt64), int64(1))
+	require.NoError(t, err)
+	require.Equal(t, uint64(math.MaxInt64+1), got)
+	got, err = arithmetic(Minus, uint64(math.MaxInt64+1), int64(1))
+	require.NoError(t, err)
+	require.Equal(t, int64(math.MaxInt64), got, "results that fit an int64 are int64")
+	_, err = arithmetic(Multiply, uint64(math.MaxUint64), int64(2))
+	require.EqualError(t, err, "integer 36893488147419103230 is out of range")
+
+	order, err := compareValues(int64(-1), uint64(math.MaxUint64))
+	require.NoError(t, err)
+	require.Equal(t, -1, order)
+}
+
+func TestIntegerTypes(t *testing.T) {
+	dir := t.TempDir()
+	b := CreateNewDatabase(dir)
+	for _, sql := range []string{
+		"CREATE TABLE counters (id smallint primary key, small tinyint, mid int32, big bigint, ubyte tinyint unsigned, ubig bigint unsigned);",
+		"CREATE INDEX counters_ubig ON counters (ubig);",
+		"INSERT INTO counters (small, mid, big, ubyte, ubig) VALUES (-128, 2147483647, -9223372036854775808, 255, 18446744073709551615), " +
+			"(127, -2147483648, 9223372036854775807, 0, 9223372036854775808), (0, 0, 0, '7', 1), (NULL, NULL, NULL, NULL, NULL);",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	sizes := []uint16{}
+	for _, col := range b.tables[0].Columns {
+		sizes = append(sizes, col.columnSize)
+	}
+	require.Equal(t, []uint16{2, 1, 4, 8, 1, 8}, sizes)
+
+	require.Equal(t, [][]any{
+		{int64(1), int64(-128), int64(2147483647), int64(math.MinInt64), int64(255), uint64(math.MaxUint64)},
+		{int64(2), int64(127), int64(-2147483648), int64(math.MaxInt64), int64(0), uint64(math.MaxInt64 + 1)},
+		{int64(3), int64(0), int64(0), int64(0), int64(7), uint64(1)},
+		{int64(4), nil, nil, nil, nil, nil},
+	}, queryRows(t, b, "SELECT * FROM counters;"))
+
+	ts := []struct {
+		SQL      string
+		Expected [][]any
+	}{
+		{"SELECT id FROM counters WHERE ubig > 9223372036854775807;", [][]any{{int64(1)}, {int64(2)}}},
+		{"SELECT id FROM counters WHERE ubig = 18446744073709551615;", [][]any{{int64(1)}}},
+		{"SELECT id FROM counters WHERE ubig < 5 AND ubig > -1;", [][]any{{int64(3)}}},
+		{"SELECT id FROM counters WHERE ubig - 1 = big;", [][]any{{int64(2)}, {int64(3)}}},
+		{"UPDATE counters SET ubig = ubig * 2 + 1, small = small - 1 WHERE id = 3 RETURNING ubig, small;", [][]any{{uint64(3), int64(-1)}}},
+	}
+	for _, tc := range ts {
+		require.Equal(t, tc.Expected, queryRows(t, b, tc.SQL), tc.SQL)
+	}
+
+	for sql, expected := range map[string]string{
+		"INSERT INTO counters (small) VALUES (128);":                 "insert: value 128 is out of range for column small",
+		"INSERT INTO counters (ubyte) VALUES (-1);":                  "insert: value -1 is out of range for column ubyte",
+		"INSERT INTO counters (big) VALUES (9223372036854775808);":   "insert: value 9223372036854775808 is out of range for column big",
+		"INSERT INTO counters (ubig) VALUES (18446744073709551616);": "insert: value 18446744073709551616 does not fit the type of column ubig",
+		"UPDATE counters SET ubig = ubig + 1 WHERE id = 1;":          "integer 18446744073709551616 is out of range",
+		"UPDATE counters SET mid = mid + 1 WHERE id = 1;":            "update: value 2147483648 is out of range for column mid",
+		"CREATE TABLE bad (id int primary key, f float unsigned);":   "FLOAT field cannot be unsigned",
+	} {
+		_, err := execSQL(b, sql)
+		require.EqualError(t, err, expected, sql)
+	}
+	b.Close()
+
+	b, err := OpenExistingDatabase(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	require.Equal(t, [][]any{{int64(1), uint64(math.MaxUint64)}}, queryRows(t, b, "SELECT id, ubig FROM counters WHERE ubig >= 18446744073709551615;"))
+	_, err = execSQL(b, "INSERT INTO counters (small) VALUES (1);")
+	require.NoError(t, err, "row ids continue after a reopen")
+	require.Equal(t, [][]any{{int64(5)}}, queryRows(t, b, "SELECT id FROM counters WHERE small = 1;"))
+}
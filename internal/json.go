@@ -0,0 +1,458 @@
+package internal
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+JSON values are validated when written and kept in a compact binary form: a tag byte followed by
+the value. Null, false and true are only the tag, integers are a zig-zag varint, other numbers the
+8 bytes of a float64 and strings their uvarint length and bytes. Arrays and objects start with the
+uvarint size of their contents so a path lookup skips members without decoding them, then the
+number of elements and the elements, object members as the key string followed by the value in key order.
+*/
+
+const (
+	jsonNull = iota
+	jsonFalse
+	jsonTrue
+	jsonInt
+	jsonFloat
+	jsonString
+	jsonArray
+	jsonObject
+)
+
+// jsonDoc is an encoded JSON value
+type jsonDoc string
+
+// parseJSON validates text and encodes it
+func parseJSON(text string) (jsonDoc, error) {
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return "", fmt.Errorf("invalid JSON: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return "", errors.New("invalid JSON: more than one value")
+	}
+	buf, err := appendJSON(nil, v)
+	return jsonDoc(buf), err
+}
+
+// appends the encoding of a decoded JSON value or a sql value to buf
+func appendJSON(buf []byte, v any) ([]byte, error) {
+	switch n := v.(type) {
+	case nil:
+		return append(buf, jsonNull), nil
+	case bool:
+		if n {
+			return append(buf, jsonTrue), nil
+		}
+		return append(buf, jsonFalse), nil
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			return appendJSON(buf, i)
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON: number %s is out of range", n)
+		}
+		return appendJSON(buf, f)
+	case int64:
+		return binary.AppendVarint(append(buf, jsonInt), n), nil
+	case uint64:
+		if n <= math.MaxInt64 {
+			return appendJSON(buf, int64(n))
+		}
+		return appendJSON(buf, float64(n))
+	case float64:
+		return binary.LittleEndian.AppendUint64(append(buf, jsonFloat), math.Float64bits(n)), nil
+	case decimal:
+		return appendJSON(buf, json.Number(n.String()))
+	case string:
+		buf = binary.AppendUvarint(append(buf, jsonString), uint64(len(n)))
+		return append(buf, n...), nil
+	case time.Time:
+		return appendJSON(buf, n.Format(time.RFC3339Nano))
+	case interval:
+		return appendJSON(buf, n.String())
+	case uuid:
+		return appendJSON(buf, n.String())
+	case jsonDoc:
+		return append(buf, n...), nil
+	case []any:
+		content := binary.AppendUvarint(nil, uint64(len(n)))
+		for _, elem := range n {
+			var err error
+			if content, err = appendJSON(content, elem); err != nil {
+				return nil, err
+			}
+		}
+		return appendJSONContainer(buf, jsonArray, content), nil
+	case map[string]any:
+		keys := make([]string, 0, len(n))
+		for key := range n {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		content := binary.AppendUvarint(nil, uint64(len(keys)))
+		for _, key := range keys {
+			var err error
+			content = append(binary.AppendUvarint(content, uint64(len(key))), key...)
+			if content, err = appendJSON(content, n[key]); err != nil {
+				return nil, err
+			}
+		}
+		return appendJSONContainer(buf, jsonObject, content), nil
+	}
+	return nil, fmt.Errorf("value %v cannot be stored as JSON", v)
+}
+
+func appendJSONContainer(buf []byte, tag byte, content []byte) []byte {
+	buf = binary.AppendUvarint(append(buf, tag), uint64(len(content)))
+	return append(buf, content...)
+}
+
+// end of the value starting at offset
+func (d jsonDoc) skip(offset int) int {
+	switch d[offset] {
+	case jsonInt:
+		_, n := binary.Varint([]byte(d[offset+1:]))
+		return offset + 1 + n
+	case jsonFloat:
+		return offset + 9
+	case jsonString, jsonArray, jsonObject:
+		size, n := binary.Uvarint([]byte(d[offset+1:]))
+		return offset + 1 + n + int(size)
+	}
+	return offset + 1
+}
+
+// start of the first element of the array or object at offset and the number of elements
+func (d jsonDoc) elements(offset int) (int, int) {
+	_, n := binary.Uvarint([]byte(d[offset+1:]))
+	offset += 1 + n
+	count, n := binary.Uvarint([]byte(d[offset:]))
+	return offset + n, int(count)
+}
+
+// string at offset and the offset following it
+func (d jsonDoc) str(offset int) (string, int) {
+	size, n := binary.Uvarint([]byte(d[offset:]))
+	start := offset + n
+	return string(d[start : start+int(size)]), start + int(size)
+}
+
+// value at the path, false if the path leads nowhere
+func (d jsonDoc) lookup(path []jsonStep) (jsonDoc, bool) {
+	offset := 0
+	for _, step := range path {
+		found := false
+		switch {
+		case step.isIndex && d[offset] == jsonArray:
+			elem, count := d.elements(offset)
+			if step.index < count {
+				for i := 0; i < step.index; i++ {
+					elem = d.skip(elem)
+				}
+				offset, found = elem, true
+			}
+		case !step.isIndex && d[offset] == jsonObject:
+			member, count := d.elements(offset)
+			for i := 0; i < count && !found; i++ {
+				var key string
+				key, member = d.str(member)
+				if key == step.key {
+					offset, found = member, true
+				}
+				member = d.skip(member)
+			}
+		}
+		if !found {
+			return "", false
+		}
+	}
+	return d[offset:d.skip(offset)], true
+}
+
+// decodes the value at offset into nil, bool, int64, float64, string, []any and map[string]any
+func (d jsonDoc) decode(offset int) any {
+	switch d[offset] {
+	case jsonFalse:
+		return false
+	case jsonTrue:
+		return true
+	case jsonInt:
+		n, _ := binary.Varint([]byte(d[offset+1:]))
+		return n
+	case jsonFloat:
+		return math.Float64frombits(binary.LittleEndian.Uint64([]byte(d[offset+1:])))
+	case jsonString:
+		s, _ := d.str(offset + 1)
+		return s
+	case jsonArray:
+		elem, count := d.elements(offset)
+		values := make([]any, count)
+		for i := range values {
+			values[i] = d.decode(elem)
+			elem = d.skip(elem)
+		}
+		return values
+	case jsonObject:
+		member, count := d.elements(offset)
+		values := make(map[string]any, count)
+		for i := 0; i < count; i++ {
+			var key string
+			key, member = d.str(member)
+			values[key] = d.decode(member)
+			member = d.skip(member)
+		}
+		return values
+	}
+	return nil
+}
+
+// JSON text of the value, object members in key order
+func (d jsonDoc) String() string {
+	var sb strings.Builder
+	enc := json.NewEncoder(&sb)
+	enc.SetEscapeHTML(false)
+	enc.Encode(d.decode(0))
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// scalar is the sql value of a JSON null, boolean, number or string, arrays and objects stay JSON
+func (d jsonDoc) scalar() any {
+	if d[0] == jsonArray || d[0] == jsonObject {
+		return d
+	}
+	return d.decode(0)
+}
+
+// text returned by ->>, strings without their quotes and NULL for a JSON null
+func (d jsonDoc) text() any {
+	switch d[0] {
+	case jsonNull:
+		return nil
+	case jsonString:
+		return d.decode(0)
+	}
+	return d.String()
+}
+
+// jsonStep is a member name or array index of a JSON path
+type jsonStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parses paths like $.a.b, $.list[0] and $."key with spaces"
+func parseJSONPath(path string) ([]jsonStep, error) {
+	invalid := fmt.Errorf("invalid JSON path '%s'", path)
+	rest, ok := strings.CutPrefix(strings.TrimSpace(path), "$")
+	if !ok {
+		return nil, invalid
+	}
+	steps := make([]jsonStep, 0)
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, `."`):
+			end := strings.IndexByte(rest[2:], '"')
+			if end == -1 {
+				return nil, invalid
+			}
+			steps = append(steps, jsonStep{key: rest[2 : 2+end]})
+			rest = rest[3+end:]
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end == -1 {
+				end = len(rest) - 1
+			}
+			if end == 0 {
+				return nil, invalid
+			}
+			steps = append(steps, jsonStep{key: rest[1 : 1+end]})
+			rest = rest[1+end:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, invalid
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, invalid
+			}
+			steps = append(steps, jsonStep{index: index, isIndex: true})
+			rest = rest[end+1:]
+		default:
+			return nil, invalid
+		}
+	}
+	return steps, nil
+}
+
+// the JSON value of a JSON operand, strings are parsed so JSON kept in text columns can be queried
+func jsonOperand(v any) (jsonDoc, error) {
+	switch n := v.(type) {
+	case jsonDoc:
+		return n, nil
+	case string:
+		return parseJSON(n)
+	}
+	return "", fmt.Errorf("expected JSON, got %v", v)
+}
+
+// value of doc at path, NULL when the path leads nowhere
+func jsonExtract(doc, path any) (any, error) {
+	if doc == nil || path == nil {
+		return nil, nil
+	}
+	d, err := jsonOperand(doc)
+	if err != nil {
+		return nil, err
+	}
+	p, ok := path.(string)
+	if !ok {
+		return nil, fmt.Errorf("JSON path must be a string, got %v", path)
+	}
+	steps, err := parseJSONPath(p)
+	if err != nil {
+		return nil, err
+	}
+	if value, found := d.lookup(steps); found {
+		return value, nil
+	}
+	return nil, nil
+}
+
+// jsonSet replaces or adds the value at path, the path is left alone when its parent does not exist
+func jsonSet(value any, path []jsonStep, newValue any) any {
+	if len(path) == 0 {
+		return newValue
+	}
+	step := path[0]
+	switch container := value.(type) {
+	case []any:
+		if !step.isIndex {
+			return value
+		}
+		if step.index < len(container) {
+			container[step.index] = jsonSet(container[step.index], path[1:], newValue)
+		} else if len(path) == 1 {
+			container = append(container, newValue)
+		}
+		return container
+	case map[string]any:
+		if step.isIndex {
+			return value
+		}
+		if current, ok := container[step.key]; ok {
+			container[step.key] = jsonSet(current, path[1:], newValue)
+		} else if len(path) == 1 {
+			container[step.key] = newValue
+		}
+		return container
+	}
+	return value
+}
+
+// evaluates the JSON functions
+func callJSONFunction(name string, args []any) (any, error) {
+	switch name {
+	case "JSON_EXTRACT":
+		if len(args) != 2 {
+			return nil, errors.New("JSON_EXTRACT takes a JSON value and a path")
+		}
+		return jsonExtract(args[0], args[1])
+	case "JSON_SET":
+		if len(args) < 3 || len(args)%2 == 0 {
+			return nil, errors.New("JSON_SET takes a JSON value followed by pairs of a path and a value")
+		}
+		if args[0] == nil {
+			return nil, nil
+		}
+		d, err := jsonOperand(args[0])
+		if err != nil {
+			return nil, err
+		}
+		value := d.decode(0)
+		for i := 1; i < len(args); i += 2 {
+			p, ok := args[i].(string)
+			if !ok {
+				return nil, fmt.Errorf("JSON path must be a string, got %v", args[i])
+			}
+			steps, err := parseJSONPath(p)
+			if err != nil {
+				return nil, err
+			}
+			newValue := args[i+1]
+			if doc, ok := newValue.(jsonDoc); ok {
+				newValue = doc.decode(0)
+			}
+			value = jsonSet(value, steps, newValue)
+		}
+		buf, err := appendJSON(nil, value)
+		return jsonDoc(buf), err
+	case "JSON_ARRAY_LENGTH":
+		if len(args) != 1 && len(args) != 2 {
+			return nil, errors.New("JSON_ARRAY_LENGTH takes a JSON value and an optional path")
+		}
+		doc := args[0]
+		if len(args) == 2 {
+			var err error
+			if doc, err = jsonExtract(args[0], args[1]); err != nil {
+				return nil, err
+			}
+		}
+		if doc == nil {
+			return nil, nil
+		}
+		d, err := jsonOperand(doc)
+		if err != nil {
+			return nil, err
+		}
+		if d[0] != jsonArray {
+			return nil, nil
+		}
+		_, count := d.elements(0)
+		return int64(count), nil
+	}
+	panic("not a JSON function: " + name)
+}
+
+// jsonScalars unwraps JSON numbers, strings, booleans and nulls used with sql values,
+// two JSON values are compared as they are
+func jsonScalars(op Operator, left, right any) (any, any) {
+	l, lok := left.(jsonDoc)
+	r, rok := right.(jsonDoc)
+	if lok && rok && op >= Eq && op <= Lte {
+		return left, right
+	}
+	if lok {
+		left = l.scalar()
+	}
+	if rok {
+		right = r.scalar()
+	}
+	return left, right
+}
+
+// compares two JSON values by their text
+func compareJSON(left, right jsonDoc) int {
+	if left == right {
+		return 0
+	}
+	return strings.Compare(left.String(), right.String())
+}
@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSON(t *testing.T) {
+	doc := func(s string) jsonDoc {
+		d, err := parseJSON(s)
+		require.NoError(t, err, s)
+		return d
+	}
+	for s, expected := range map[string]string{
+		`null`:                               `null`,
+		` {"b": [1, 2.5, "x<y"], "a": true}`: `{"a":true,"b":[1,2.5,"x<y"]}`,
+		`-9223372036854775808`:               `-9223372036854775808`,
+		`1e3`:                                `1000`,
+		`"é\u0000"`:                          `"é\u0000"`,
+		`[]`:                                 `[]`,
+	} {
+		require.Equal(t, expected, doc(s).String(), s)
+	}
+	for s, expected := range map[string]string{
+		`{"a": 1`:     "invalid JSON: unexpected EOF",
+		`{"a": 1} []`: "invalid JSON: more than one value",
+		`'a'`:         "invalid JSON: invalid character '\\'' looking for beginning of value",
+	} {
+		_, err := parseJSON(s)
+		require.EqualError(t, err, expected, s)
+	}
+
+	d := doc(`{"user": {"name": "ann", "tags": ["a", "b"]}, "n": 2, "odd key": null}`)
+	ts := []struct {
+		Path     string
+		Expected any
+	}{
+		{"$", d},
+		{"$.n", doc("2")},
+		{"$.user.name", doc(`"ann"`)},
+		{"$.user.tags[1]", doc(`"b"`)},
+		{`$."odd key"`, doc("null")},
+		{"$.user.tags[2]", nil},
+		{"$.missing.name", nil},
+		{"$.n[0]", nil},
+	}
+	for _, tc := range ts {
+		got, err := jsonExtract(d, tc.Path)
+		require.NoError(t, err, tc.Path)
+		require.Equal(t, tc.Expected, got, tc.Path)
+	}
+	for _, path := range []string{"user", "$.", "$[x]", "$[-1]", `$."open`} {
+		_, err := jsonExtract(d, path)
+		require.EqualError(t, err, "invalid JSON path '"+path+"'")
+	}
+
+	set := func(args ...any) string {
+		got, err := callJSONFunction("JSON_SET", append([]any{d}, args...))
+		require.NoError(t, err)
+		return got.(jsonDoc).String()
+	}
+	require.Equal(t, `{"n":3,"odd key":null,"user":{"name":"ann","tags":["a","b"]}}`, set("$.n", int64(3)))
+	require.Equal(t, `{"n":2,"odd key":null,"user":{"name":"ann","tags":["a","b","c"]},"x":[1]}`,
+		set("$.user.tags[5]", "c", "$.x", doc("[1]")), "new members and elements are added at the end")
+	require.Equal(t, d.String(), set("$.missing.name", "x"), "paths without a parent are left alone")
+
+	n, err := callJSONFunction("JSON_ARRAY_LENGTH", []any{d, "$.user.tags"})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+	n, err = callJSONFunction("JSON_ARRAY_LENGTH", []any{"[1, [2, 3]]"})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+	n, err = callJSONFunction("JSON_ARRAY_LENGTH", []any{d})
+	require.NoError(t, err)
+	require.Nil(t, n, "objects have no array length")
+}
+
+func TestJSONType(t *testing.T) {
+	dir := t.TempDir()
+	b := CreateNewDatabase(dir)
+	for _, sql := range []string{
+		"CREATE TABLE events (id int primary key, doc json, note text);",
+		`INSERT INTO events (doc, note) VALUES ('{"user": {"name": "ann"}, "n": 2, "tags": ["a", "b"]}', '{"n": 7}'), ` +
+			`('{"user": {"name": "bob"}, "n": 10.5, "tags": []}', NULL), ('{"n": null}', NULL), (NULL, NULL);`,
+		"CREATE INDEX events_name ON events ((doc->>'$.user.name'));",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+
+	ts := []struct {
+		SQL      string
+		Expected [][]any
+	}{
+		{"SELECT id, doc FROM events WHERE id = 1;", [][]any{{int64(1), `{"n":2,"tags":["a","b"],"user":{"name":"ann"}}`}}},
+		{"SELECT doc->'$.user' AS u, doc->>'$.user.name', doc->'$.n' FROM events WHERE id <= 2;",
+			[][]any{{`{"name":"ann"}`, "ann", "2"}, {`{"name":"bob"}`, "bob", "10.5"}}},
+		{"SELECT id FROM events WHERE doc->>'$.user.name' = 'bob';", [][]any{{int64(2)}}},
+		{"SELECT id FROM events WHERE doc->'$.n' > 5;", [][]any{{int64(2)}}},
+		{"SELECT id FROM events WHERE doc->'$.n' + 1 = 3;", [][]any{{int64(1)}}},
+		{"SELECT doc->'$.n' - id * 2 FROM events WHERE id <= 2;", [][]any{{float64(0)}, {6.5}}},
+		{"SELECT id FROM events WHERE doc->>'$.n' IS NULL;", [][]any{{int64(3)}, {int64(4)}}},
+		{"SELECT id FROM events WHERE doc->'$.n' IS NULL;", [][]any{{int64(4)}}},
+		{"SELECT id FROM events WHERE note->'$.n' = 7;", [][]any{{int64(1)}}},
+		{"SELECT JSON_ARRAY_LENGTH(doc, '$.tags'), JSON_EXTRACT(doc, '$.tags[0]') FROM events WHERE id <= 3;",
+			[][]any{{int64(2), `"a"`}, {int64(0), nil}, {nil, nil}}},
+		{`UPDATE events SET doc = JSON_SET(doc, '$.n', doc->'$.n' * 2, '$.user.name', 'cy') WHERE id = 1 RETURNING doc->'$.n', doc->>'$.user.name';`,
+			[][]any{{"4", "cy"}}},
+		{"SELECT id FROM events WHERE doc->>'$.user.name' = 'cy';", [][]any{{int64(1)}}},
+		{"SELECT id FROM events WHERE doc->>'$.user.name' = 'ann';", [][]any{}},
+	}
+	for _, tc := range ts {
+		require.Equal(t, tc.Expected, queryRows(t, b, tc.SQL), tc.SQL)
+	}
+	values := make([]string, 0, 600)
+	for i := 0; i < 600; i++ {
+		values = append(values, fmt.Sprintf(`('{"user": {"name": "user%d"}}')`, i))
+	}
+	_, err := execSQL(b, fmt.Sprintf("INSERT INTO events (doc) VALUES %s;", strings.Join(values, ", ")))
+	require.NoError(t, err)
+	require.Equal(t, [][]any{
+		{int64(1), int64(0), "Project", `"id"`},
+		{int64(2), int64(1), "Index Scan", `on "events" using index "events_name" ("doc" ->> '$.user.name' = 'bob')`},
+	}, planShape(queryRows(t, b, "EXPLAIN SELECT id FROM events WHERE doc->>'$.user.name' = 'bob';")))
+
+	for sql, expected := range map[string]string{
+		"INSERT INTO events (doc) VALUES ('{\"a\": }');":      "insert: column doc: invalid JSON: invalid character '}' looking for beginning of value",
+		"SELECT id FROM events WHERE doc->'user' = 1;":        "invalid JSON path 'user'",
+		"SELECT doc - INTERVAL '1 day' FROM events;":          `cannot select "doc" - INTERVAL '1 day', the types of its operands cannot be combined`,
+		"CREATE INDEX events_bad ON events ((id + 1));":       `cannot index "id" + 1, only columns, JSON paths and JSON functions can be indexed`,
+		"CREATE TABLE bad (id int primary key, doc json(5));": "json field takes no size",
+	} {
+		_, err := execSQL(b, sql)
+		require.EqualError(t, err, expected, sql)
+	}
+	b.Close()
+
+	b, err = OpenExistingDatabase(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	require.Equal(t, [][]any{{int64(2), "bob"}}, queryRows(t, b, "SELECT id, doc->>'$.user.name' AS name FROM events WHERE doc->>'$.user.name' = 'bob';"))
+	_, err = execSQL(b, "ALTER TABLE events RENAME COLUMN doc TO body;")
+	require.NoError(t, err)
+	require.Equal(t, [][]any{{int64(1)}}, queryRows(t, b, "SELECT id FROM events WHERE body->>'$.user.name' = 'cy';"))
+	_, err = execSQL(b, "ALTER TABLE events DROP COLUMN body;")
+	require.NoError(t, err, "the index of the expression is dropped with the column")
+	require.Empty(t, b.tables[0].indexes)
+}
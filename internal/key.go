@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+/*
+Index keys are values encoded so that comparing the bytes of two keys orders them
+the same way compareValues orders the values. Every value starts with a byte telling
+null (first) from not null and encodings are prefix free, no key of a type is the start
+of another key of that type, so keys of several values can be appended.
+*/
+
+const (
+	keyNull  = 0x00
+	keyValue = 0x01
+)
+
+// appendKey appends the order preserving encoding of a value to buf
+func appendKey(buf []byte, v any) []byte {
+	if v == nil {
+		return append(buf, keyNull)
+	}
+	buf = append(buf, keyValue)
+	switch n := v.(type) {
+	case int64:
+		return binary.BigEndian.AppendUint64(buf, uint64(n)^(1<<63)) //flipping the sign bit puts negatives first
+	case uint64: //only columns of BIGINT UNSIGNED hold uint64 values, their keys are never compared to int64 keys
+		return binary.BigEndian.AppendUint64(buf, n)
+	case float64:
+		if n == 0 {
+			n = 0 //-0 equals 0
+		}
+		bits := math.Float64bits(n)
+		if bits&(1<<63) != 0 {
+			bits = ^bits //negatives are ordered backwards by their bits
+		} else {
+			bits |= 1 << 63
+		}
+		return binary.BigEndian.AppendUint64(buf, bits)
+	case bool:
+		if n {
+			return append(buf, 1)
+		}
+		return append(buf, 0)
+	case string:
+		//zero bytes are escaped as 0x00 0xff and the string ends with 0x00 0x01
+		for i := 0; i < len(n); i++ {
+			if n[i] == 0 {
+				buf = append(buf, 0, 0xff)
+				continue
+			}
+			buf = append(buf, n[i])
+		}
+		return append(buf, 0, 1)
+	case time.Time: //ordered as the microseconds since 1970
+		return appendKey(buf[:len(buf)-1], n.UnixMicro())
+	case interval:
+		return appendKey(buf[:len(buf)-1], n.approxMicros())
+	case decimal:
+		return appendDecimalKey(buf, n)
+	case jsonDoc: //ordered as its text
+		return appendKey(buf[:len(buf)-1], n.String())
+	case uuid:
+		return append(buf, n[:]...)
+	}
+	panic("value cannot be used as an index key")
+}
+
+// compareKey orders key against bound, a key starting with bound compares equal to it
+// so the entries of a non unique index, value followed by primary key, are equal to the value
+func compareKey(key, bound []byte) int {
+	if bytes.HasPrefix(key, bound) {
+		return 0
+	}
+	return bytes.Compare(key, bound)
+}
@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyOrder(t *testing.T) {
+	ordered := [][]any{
+		{nil, int64(math.MinInt64), int64(-1), int64(0), int64(1), int64(math.MaxInt64)},
+		{nil, uint64(0), uint64(1), uint64(math.MaxInt64 + 1), uint64(math.MaxUint64)},
+		{nil, math.Inf(-1), -2.5, -0.5, 0.0, 0.5, 2.5, math.Inf(1)},
+		{nil, false, true},
+		{nil, "", "\x00", "\x00a", "a", "a\x00", "ab", "b"},
+		{nil, time.Date(1969, 12, 31, 23, 59, 59, 0, time.UTC), time.Unix(0, 0), time.Date(2024, 2, 29, 0, 0, 0, 1000, time.UTC)},
+		{nil, interval{micros: -1}, interval{}, interval{days: 29}, interval{months: 1, micros: 1}, interval{months: 12}},
+		{nil, decimal{unscaled: big.NewInt(-1000), scale: 2}, decimal{unscaled: big.NewInt(-1), scale: 2}, decimal{unscaled: big.NewInt(0), scale: 2}, decimal{unscaled: big.NewInt(99), scale: 2}, decimal{unscaled: pow10(37), scale: 2}},
+		{nil, uuid{}, uuid{15: 1}, uuid{0: 1}, uuid{0: 0xff, 15: 0xff}},
+	}
+	for _, values := range ordered {
+		for i := 1; i < len(values); i++ {
+			require.Equal(t, -1, bytes.Compare(appendKey(nil, values[i-1]), appendKey(nil, values[i])), "%v < %v", values[i-1], values[i])
+		}
+	}
+	require.Equal(t, appendKey(nil, 0.0), appendKey(nil, math.Copysign(0, -1)))
+
+	// keys of several columns order by the first column, then the next
+	require.Equal(t, -1, bytes.Compare(appendKey(appendKey(nil, "a"), int64(9)), appendKey(appendKey(nil, "ab"), int64(1))))
+	require.Equal(t, -1, bytes.Compare(appendKey(appendKey(nil, int64(1)), nil), appendKey(appendKey(nil, int64(1)), int64(-5))))
+	require.Equal(t, 0, compareKey(appendKey(appendKey(nil, int64(1)), int64(2)), appendKey(nil, int64(1))))
+}
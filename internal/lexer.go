@@ -11,15 +11,21 @@ type Lexer struct {
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
 	ch           byte // current char under examination
+	line         int  // current line number (1-based)
+	lineStart    int  // position in input where the current line begins
 }
 
 func NewLexer(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.lineStart = l.readPosition
+	}
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -37,10 +43,18 @@ func (l *Lexer) peekChar() byte {
 	}
 }
 
+// NextToken returns the next token in the input annotated with the line and column it starts at
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
-
 	l.skipWhitespace()
+	line, column := l.line, l.position-l.lineStart+1
+	tok := l.readToken()
+	tok.Line = line
+	tok.Column = column
+	return tok
+}
+
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
 
 	switch l.ch {
 	case '=':
@@ -48,7 +62,16 @@ func (l *Lexer) NextToken() token.Token {
 	case '+':
 		tok = newToken(token.PLUS, l.ch)
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		if l.peekChar() == '>' { //JSON path operators -> and ->>
+			l.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: "->"}
+			if l.peekChar() == '>' {
+				l.readChar()
+				tok = token.Token{Type: token.ARROW2, Literal: "->>"}
+			}
+		} else {
+			tok = newToken(token.MINUS, l.ch)
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -66,6 +89,10 @@ func (l *Lexer) NextToken() token.Token {
 			ch := l.ch
 			l.readChar()
 			tok = token.Token{Type: token.LTE, Literal: string(ch) + string(l.ch)}
+		} else if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.NOT_EQ, Literal: string(ch) + string(l.ch)}
 		} else {
 			tok = newToken(token.LT, l.ch)
 		}
@@ -85,6 +112,8 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.RPAREN, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
+	case '?':
+		tok = newToken(token.PARAM, l.ch)
 	case '\'':
 		tok.Type = token.STRINGLITERAL
 		tok.Literal = l.readString(l.ch)
@@ -103,7 +132,11 @@ func (l *Lexer) NextToken() token.Token {
 		tok.Literal = ""
 		tok.Type = token.EOF
 	default:
-		if isLetter(l.ch) {
+		if (l.ch == 'x' || l.ch == 'X') && l.peekChar() == '\'' { //hex literal of bytes
+			l.readChar()
+			tok.Type = token.HEXLITERAL
+			tok.Literal = l.readString(l.ch)
+		} else if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(strings.ToUpper(tok.Literal))
 			return tok
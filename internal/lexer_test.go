@@ -6,9 +6,7 @@ import (
 	"github.com/treeform-system/rootdb/internal/token"
 )
 
-// TODO: Update for different statements later on
-func TestNextToken(t *testing.T) {
-	input := `CREATE TABLE "_sometable@#$%" (column1 int Primary Key, column30 bool, somecolumn char(10), column400 float, column5 int);
+const lexerTestInput = `CREATE TABLE "_sometable@#$%" (column1 int Primary Key, column30 bool, somecolumn char(10), column400 float, column5 int);
 	.72 11.7 11.7.8 90 36.7.7.7.;
 	INSERT INTO "MyTable10" (column1,column5) VALUES ('1','somecharss', 'true','1.23'),
 	(2,'10letters', false,	.69,4.69);
@@ -16,6 +14,10 @@ func TestNextToken(t *testing.T) {
 	"MyTable10" WHERE column5 >= column1;
 	. ! != Set AS
 	`
+
+// TODO: Update for different statements later on
+func TestNextToken(t *testing.T) {
+	input := lexerTestInput
 	tests := []struct {
 		expectedType    token.TokenType
 		expectedLiteral string
@@ -126,3 +128,36 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenPosition(t *testing.T) {
+	input := "SELECT a,\n\tb FROM t\r\nWHERE a = 'x';"
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{"SELECT", 1, 1},
+		{"a", 1, 8},
+		{",", 1, 9},
+		{"b", 2, 2},
+		{"FROM", 2, 4},
+		{"t", 2, 9},
+		{"WHERE", 3, 1},
+		{"a", 3, 7},
+		{"=", 3, 9},
+		{"x", 3, 11},
+		{";", 3, 14},
+	}
+
+	l := NewLexer(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d", i, tt.expectedLine, tt.expectedColumn, tok.Line, tok.Column)
+		}
+	}
+}
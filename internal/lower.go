@@ -0,0 +1,212 @@
+package internal
+
+/*
+Flattens a syntax tree into the fields of Query used by the backend.
+Parts of the tree that can't be expressed by the flat fields (ie. OR in a WHERE clause
+or expressions as values) are left out, the full tree is always kept in Query.Statement.
+*/
+
+func lowerStatement(stmt Statement) Query {
+	q := Query{Type: statementType(stmt), Statement: stmt}
+	switch s := stmt.(type) {
+	case *SelectStatement:
+		if table, ok := s.From.(*TableName); ok {
+			q.TableName = table.Name
+		}
+		q.Fields, q.Aliases = lowerSelectItems(s.Items)
+		q.Conditions = lowerConditions(s.Where)
+	case *InsertStatement:
+		q.TableName = s.Table.Name
+		q.Fields = append(q.Fields, s.Columns...)
+		q.Inserts = make([][]string, 0, len(s.Rows))
+		for _, row := range s.Rows {
+			values := make([]string, 0, len(row))
+			for _, value := range row {
+				lit, ok := value.(*Literal)
+				if !ok || lit.Kind == NullLiteral {
+					break
+				}
+				values = append(values, lit.Value)
+			}
+			if len(values) != len(row) {
+				q.Inserts = nil
+				break
+			}
+			q.Inserts = append(q.Inserts, values)
+		}
+	case *UpdateStatement:
+		q.TableName = s.Table.Name
+		q.Updates = make(map[string]string, len(s.Set))
+		for _, set := range s.Set {
+			if lit, ok := set.Value.(*Literal); ok && lit.Kind != NullLiteral {
+				q.Updates[set.Column] = lit.Value
+			}
+		}
+		q.Conditions = lowerConditions(s.Where)
+	case *DeleteStatement:
+		q.TableName = s.Table.Name
+		q.Conditions = lowerConditions(s.Where)
+	case *CreateTableStatement:
+		q.TableName = s.Name
+		for _, col := range s.Columns {
+			lowerColumnDef(&q, col)
+		}
+		q.TableConstruction.primary = append(q.TableConstruction.primary, s.PrimaryKey...)
+		for _, check := range s.Checks {
+			q.TableConstruction.checks = addCheck(q.TableConstruction.checks, s.Name+"_check", check)
+		}
+		q.TableConstruction.foreignKeys = append(q.TableConstruction.foreignKeys, s.ForeignKeys...)
+	case *DropTableStatement:
+		q.TableName = s.Name
+	case *AnalyzeStatement:
+		q.TableName = s.Table
+	case *CreateIndexStatement:
+		q.TableName = s.Table
+	case *AlterTableStatement:
+		q.TableName = s.Table
+		if s.Action == AddColumn {
+			lowerColumnDef(&q, s.Column)
+		}
+	case *ExplainStatement:
+		q = lowerStatement(s.Statement)
+		q.Type = Explain
+		q.Statement = s
+	}
+	return q
+}
+
+// names of the selected columns, * for every column, and their aliases. Items that aren't columns are left out
+func lowerSelectItems(items []SelectItem) (fields []string, aliases map[string]string) {
+	for _, item := range items {
+		if item.Star {
+			fields = append(fields, "*")
+			continue
+		}
+		ident, ok := item.Expr.(*Identifier)
+		if !ok {
+			continue
+		}
+		fields = append(fields, ident.Name)
+		if item.Alias != "" {
+			if aliases == nil {
+				aliases = make(map[string]string)
+			}
+			aliases[ident.Name] = item.Alias
+		}
+	}
+	return fields, aliases
+}
+
+// adds a column and its constraints to the table construction of a CREATE TABLE or an ALTER TABLE ADD COLUMN
+func lowerColumnDef(q *Query, col ColumnDef) {
+	q.Fields = append(q.Fields, col.Name)
+	field := append([]string{col.Name, col.Type.Name}, col.Type.Args...)
+	q.TableConstruction.fieldsWTypes = append(q.TableConstruction.fieldsWTypes, field)
+	for _, constraint := range col.Constraints {
+		switch constraint.Kind {
+		case PrimaryKeyConstraint:
+			q.TableConstruction.primary = append(q.TableConstruction.primary, col.Name)
+		case UniqueConstraint:
+			q.TableConstruction.unique = append(q.TableConstruction.unique, col.Name)
+		case NotNullConstraint:
+			q.TableConstruction.notnullable = append(q.TableConstruction.notnullable, col.Name)
+		case NullConstraint:
+			q.TableConstruction.nullable = append(q.TableConstruction.nullable, col.Name)
+		case DefaultConstraint:
+			q.TableConstruction.defaults = setExpr(q.TableConstruction.defaults, col.Name, constraint.Expr)
+		case GeneratedConstraint:
+			q.TableConstruction.generated = setExpr(q.TableConstruction.generated, col.Name, constraint.Expr)
+		case CheckConstraint:
+			q.TableConstruction.checks = addCheck(q.TableConstruction.checks, q.TableName+"_"+col.Name+"_check", constraint.Expr)
+		case ForeignKeyConstraint:
+			fk := *constraint.ForeignKey
+			fk.Columns = []string{col.Name}
+			q.TableConstruction.foreignKeys = append(q.TableConstruction.foreignKeys, fk)
+		}
+	}
+}
+
+// sets m[name] to expr, allocating the map on first use
+func setExpr(m map[string]Expr, name string, expr Expr) map[string]Expr {
+	if m == nil {
+		m = make(map[string]Expr)
+	}
+	m[name] = expr
+	return m
+}
+
+// converts WHERE clause made only of "field op value" comparisons joined by AND into conditions.
+// returns nil for any other expression
+func lowerConditions(where Expr) []Condition {
+	if where == nil {
+		return nil
+	}
+	parts := splitConjunction(where)
+	conditions := make([]Condition, 0, len(parts))
+	for _, part := range parts {
+		bin, ok := part.(*BinaryExpr)
+		if !ok || bin.Operator < Eq || bin.Operator > Lte {
+			return nil
+		}
+		left, ok := bin.Left.(*Identifier)
+		if !ok {
+			return nil
+		}
+		cond := Condition{Operand1: left.Name, Operand1IsField: true, Operator: bin.Operator}
+		switch right := bin.Right.(type) {
+		case *Identifier:
+			cond.Operand2 = right.Name
+			cond.Operand2IsField = true
+		case *Literal:
+			if right.Kind == NullLiteral {
+				return nil
+			}
+			cond.Operand2 = right.Value
+		default:
+			return nil
+		}
+		conditions = append(conditions, cond)
+	}
+	return conditions
+}
+
+func statementType(stmt Statement) QueryType {
+	switch stmt.(type) {
+	case *SelectStatement:
+		return Select
+	case *InsertStatement:
+		return Insert
+	case *UpdateStatement:
+		return Update
+	case *DeleteStatement:
+		return Delete
+	case *CreateTableStatement:
+		return Create
+	case *DropTableStatement:
+		return Drop
+	case *ExplainStatement:
+		return Explain
+	case *AnalyzeStatement:
+		return Analyze
+	case *CreateIndexStatement:
+		return CreateIndex
+	case *DropIndexStatement:
+		return DropIndex
+	case *AlterTableStatement:
+		return AlterTable
+	}
+	return UnknownType
+}
+
+// returns WHERE clause of the statement or nil if it has none
+func (q Query) where() Expr {
+	switch s := q.Statement.(type) {
+	case *SelectStatement:
+		return s.Where
+	case *UpdateStatement:
+		return s.Where
+	case *DeleteStatement:
+		return s.Where
+	}
+	return nil
+}
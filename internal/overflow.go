@@ -0,0 +1,293 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+/*
+VARCHAR, TEXT and BLOB values too large for their row are kept on overflow pages in a separate file
+of the table. The row holds the length of the value and the first page of the chain in place of the value.
+Overflow pages start with the checksum of the rest of the page, then the next page of the chain
+(noOverflowPage at the end of the chain) and the number of value bytes on the page. A page with no
+value bytes is free and is reused by the next value written.
+*/
+
+const (
+	overflowHeaderSize = 26
+	overflowChunk      = PAGESIZE - overflowHeaderSize //value bytes held by one overflow page
+
+	overflowMarker      = 0xFFFF    //length written in a row for a value kept on overflow pages
+	overflowPointerSize = 2 + 8 + 8 //marker, length of the value and first page
+)
+
+const noOverflowPage = PageID(-1)
+
+// overflowRef points at the chain of overflow pages holding the value of a column of a row
+type overflowRef struct {
+	column int
+	length uint64
+	page   PageID
+}
+
+type overflowPage [PAGESIZE]byte
+
+func (p *overflowPage) next() PageID {
+	return PageID(binary.LittleEndian.Uint64(p[16:24]))
+}
+
+func (p *overflowPage) data() []byte {
+	used := int(binary.LittleEndian.Uint16(p[24:26]))
+	return p[overflowHeaderSize : overflowHeaderSize+used]
+}
+
+// fills the page with chunk and points it at next, an empty chunk marks the page free
+func (p *overflowPage) fill(chunk []byte, next PageID) {
+	clear(p[:])
+	binary.LittleEndian.PutUint64(p[16:24], uint64(next))
+	binary.LittleEndian.PutUint16(p[24:26], uint16(len(chunk)))
+	copy(p[overflowHeaderSize:], chunk)
+	checksum := md5.Sum(p[16:])
+	copy(p[0:16], checksum[:])
+}
+
+// file holding the overflow pages of a table with the base name files
+func overflowFile(dir, files string) string {
+	return filepath.Join(dir, files+".ovf")
+}
+
+// opens the overflow file of the table and notes its free pages
+func (b *bufferPool) openOverflow(path string) error {
+	var err error
+	if b.overflowFile, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644); err != nil {
+		return err
+	}
+	fi, err := b.overflowFile.Stat()
+	if err != nil {
+		return err
+	}
+	b.overflowPages = PageID(fi.Size() / PAGESIZE)
+	for pageid := range b.overflowPages {
+		page, err := b.readOverflowPage(pageid)
+		if err != nil {
+			return err
+		}
+		if len(page.data()) == 0 {
+			b.freeOverflow = append(b.freeOverflow, pageid)
+		}
+	}
+	return nil
+}
+
+func (b *bufferPool) readOverflowPage(pageid PageID) (*overflowPage, error) {
+	page := &overflowPage{}
+	if _, err := b.overflowFile.ReadAt(page[:], int64(pageid)*PAGESIZE); err != nil {
+		return nil, err
+	}
+	checksum := md5.Sum(page[16:])
+	if !bytes.Equal(page[0:16], checksum[:]) {
+		return nil, fmt.Errorf("overflow page %d has been corrupted", pageid)
+	}
+	return page, nil
+}
+
+// writes value to a chain of free or new overflow pages and returns its first page, mxwrite must be held
+func (b *bufferPool) writeOverflow(value []byte) (PageID, error) {
+	pageids := make([]PageID, (len(value)+overflowChunk-1)/overflowChunk)
+	for i := range pageids {
+		if n := len(b.freeOverflow); n > 0 {
+			pageids[i] = b.freeOverflow[n-1]
+			b.freeOverflow = b.freeOverflow[:n-1]
+			continue
+		}
+		pageids[i] = b.overflowPages
+		b.overflowPages++
+	}
+	var page overflowPage
+	for i, pageid := range pageids {
+		next := noOverflowPage
+		if i+1 < len(pageids) {
+			next = pageids[i+1]
+		}
+		page.fill(value[i*overflowChunk:min((i+1)*overflowChunk, len(value))], next)
+		if _, err := b.overflowFile.WriteAt(page[:], int64(pageid)*PAGESIZE); err != nil {
+			return 0, err
+		}
+	}
+	return pageids[0], nil
+}
+
+// marks the pages of the chain starting at first free or when its last reader closes, mxwrite must be held
+func (b *bufferPool) releaseOverflow(first PageID) error {
+	b.mxpin.Lock()
+	defer b.mxpin.Unlock()
+	if b.pinned[first] > 0 {
+		b.released[first] = true
+		return nil
+	}
+	return b.freeChain(first)
+}
+
+// keeps the chain starting at first from being freed until unpin
+func (b *bufferPool) pin(first PageID) {
+	b.mxpin.Lock()
+	defer b.mxpin.Unlock()
+	b.pinned[first]++
+}
+
+// frees the chain starting at first if it was released while pinned and this was its last reader
+func (b *bufferPool) unpin(first PageID) error {
+	b.mxwrite.Lock()
+	defer b.mxwrite.Unlock()
+	b.mxpin.Lock()
+	defer b.mxpin.Unlock()
+	if b.pinned[first]--; b.pinned[first] > 0 {
+		return nil
+	}
+	delete(b.pinned, first)
+	if !b.released[first] {
+		return nil
+	}
+	delete(b.released, first)
+	return b.freeChain(first)
+}
+
+func (b *bufferPool) freeChain(first PageID) error {
+	var free overflowPage
+	free.fill(nil, noOverflowPage)
+	for pageid := first; pageid != noOverflowPage; {
+		page, err := b.readOverflowPage(pageid)
+		if err != nil {
+			return err
+		}
+		if _, err := b.overflowFile.WriteAt(free[:], int64(pageid)*PAGESIZE); err != nil {
+			return err
+		}
+		b.freeOverflow = append(b.freeOverflow, pageid)
+		pageid = page.next()
+	}
+	return nil
+}
+
+// reads the whole value ref points at
+func (b *bufferPool) readOverflow(ref overflowRef) (Cell, error) {
+	value := make(Cell, ref.length)
+	if _, err := io.ReadFull(b.overflowReader(ref), value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+/*
+OpenValue streams the value of a VARCHAR, TEXT or BLOB column of the row with the primary key key,
+values kept on overflow pages are read a page at a time. Their pages are not reused until the reader
+is closed, so it reads the value as it was when opened even if the row is written in the meantime
+*/
+func (b *Backend) OpenValue(tablename, column string, key ...any) (io.ReadCloser, error) {
+	table, ok := b.checkTableExist(Query{TableName: tablename})
+	if !ok {
+		return nil, errors.New("Table does not exist")
+	}
+	pos := slices.IndexFunc(table.Columns, func(col Column) bool { return col.columnName == column })
+	if pos == -1 {
+		return nil, fmt.Errorf("Columns not in table: %s", column)
+	} else if !table.Columns[pos].isString() || table.Columns[pos].columnType == CHAR {
+		return nil, fmt.Errorf("column %s is not a VARCHAR, TEXT or BLOB column", column)
+	}
+	if len(key) != len(table.primaryKey) {
+		return nil, fmt.Errorf("primary key of table %s has %d columns, got %d values", table.Name, len(table.primaryKey), len(key))
+	}
+	table.tableLock.RLock()
+	defer table.tableLock.RUnlock()
+
+	row := make([]Cell, len(table.Columns))
+	for i, p := range table.primaryKey {
+		v := key[i]
+		switch n := v.(type) {
+		case int:
+			v = int64(n)
+		case []byte:
+			v = string(n)
+		}
+		var err error
+		if row[p], err = valueCell(&table.Columns[p], v); err != nil {
+			return nil, err
+		}
+	}
+	loc, ok := table.indices.primaryTree.findKeyValue(table.rowKey(row))
+	if !ok {
+		return nil, fmt.Errorf("no row of table %s has the key %v", table.Name, key)
+	}
+	pool := b.bufferPool.allpools[table.Name]
+	buf, err := pool.rawFetchPage(PageID(loc / PAGESIZE))
+	if err != nil {
+		return nil, err
+	}
+	cells, refs := pool.splitRow((*heapPage)(&buf).row(int(loc % PAGESIZE)))
+	for _, ref := range refs {
+		if ref.column == pos {
+			pool.pin(ref.page)
+			r := pool.overflowReader(ref)
+			r.pinned = ref.page
+			return r, nil
+		}
+	}
+	if cells[pos] == nil {
+		return nil, fmt.Errorf("value of column %s is null", column)
+	}
+	return io.NopCloser(bytes.NewReader(cells[pos])), nil
+}
+
+func (b *bufferPool) overflowReader(ref overflowRef) *overflowReader {
+	return &overflowReader{pool: b, next: ref.page, remaining: ref.length, pinned: noOverflowPage}
+}
+
+// overflowReader streams a value kept on overflow pages, reading one page at a time
+type overflowReader struct {
+	pool      *bufferPool
+	next      PageID
+	remaining uint64
+	chunk     []byte
+	pinned    PageID //first page of the chain kept for the reader until Close, noOverflowPage if none is
+}
+
+// lets the pages of the value be freed, reads after Close return io.EOF
+func (r *overflowReader) Close() error {
+	if r.pinned == noOverflowPage {
+		return nil
+	}
+	first := r.pinned
+	r.pinned, r.next, r.remaining, r.chunk = noOverflowPage, noOverflowPage, 0, nil
+	return r.pool.unpin(first)
+}
+
+func (r *overflowReader) Read(p []byte) (int, error) {
+	if len(r.chunk) == 0 {
+		if r.remaining == 0 {
+			return 0, io.EOF
+		}
+		if r.next == noOverflowPage {
+			return 0, errors.New("overflow chain ends before the end of its value")
+		}
+		page, err := r.pool.readOverflowPage(r.next)
+		if err != nil {
+			return 0, err
+		}
+		r.chunk = page.data()
+		if uint64(len(r.chunk)) > r.remaining {
+			r.chunk = r.chunk[:r.remaining]
+		}
+		r.next = page.next()
+	}
+	n := copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	r.remaining -= uint64(n)
+	return n, nil
+}
@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverflowValues(t *testing.T) {
+	dir := t.TempDir()
+	b := CreateNewDatabase(dir)
+	doc := strings.Repeat("{\"k\": 1}", 10000) //spans 20 overflow pages and is too long for a length prefix
+	image := strings.Repeat("PNG IHDR ", 1000)
+	for _, sql := range []string{
+		"CREATE TABLE files (id int primary key, doc text, image blob, note varchar(10));",
+		"INSERT INTO files (id, doc, image, note) VALUES (1, '" + doc + "', '" + image + "', 'a'), (2, 'small', '', 'b');",
+		"INSERT INTO files (id, doc) VALUES (3, '" + strings.Repeat("y", 3000) + "'), (4, '" + strings.Repeat("z", 3000) + "');",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql[:min(len(sql), 60)])
+	}
+	require.Equal(t, [][]any{{doc, []byte(image), "a"}}, queryRows(t, b, "SELECT doc, image, note FROM files WHERE id = 1;"))
+	require.Equal(t, [][]any{{"small", []byte{}}}, queryRows(t, b, "SELECT doc, image FROM files WHERE id = 2;"))
+	require.Equal(t, [][]any{{int64(4)}}, queryRows(t, b, "SELECT id FROM files WHERE doc = '"+strings.Repeat("z", 3000)+"';"))
+
+	pool := b.bufferPool.allpools["files"]
+	_, err := execSQL(b, "UPDATE files SET doc = 'short' WHERE id = 1;")
+	require.NoError(t, err)
+	require.Len(t, pool.freeOverflow, 20+3, "the image is written again, the pages of the old values are free")
+	pages := pool.overflowPages
+	_, err = execSQL(b, "UPDATE files SET doc = '"+doc+"' WHERE id = 2;")
+	require.NoError(t, err)
+	_, err = execSQL(b, "DELETE FROM files WHERE id = 3;")
+	require.NoError(t, err)
+	require.Equal(t, pages, pool.overflowPages, "freed pages are reused before the file grows")
+	b.Close()
+
+	b, err = OpenExistingDatabase(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	require.Len(t, b.bufferPool.allpools["files"].freeOverflow, 3, "free pages are found again on open")
+	require.Equal(t, [][]any{{int64(1), "short"}, {int64(2), doc}}, queryRows(t, b, "SELECT id, doc FROM files WHERE id <= 2;"))
+
+	r, err := b.OpenValue("files", "doc", 2)
+	require.NoError(t, err)
+	chunk := make([]byte, 100)
+	n, err := r.Read(chunk)
+	require.NoError(t, err)
+	require.Equal(t, doc[:n], string(chunk[:n]))
+	rest, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, doc, string(chunk[:n])+string(rest), "the value is streamed from its overflow pages")
+	require.NoError(t, r.Close())
+	r, err = b.OpenValue("files", "note", int64(1))
+	require.NoError(t, err)
+	note, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, "a", string(note))
+	require.NoError(t, r.Close())
+	_, err = b.OpenValue("files", "id", 1)
+	require.EqualError(t, err, "column id is not a VARCHAR, TEXT or BLOB column")
+	_, err = b.OpenValue("files", "image", 4)
+	require.EqualError(t, err, "value of column image is null")
+	_, err = b.OpenValue("files", "doc", 9)
+	require.EqualError(t, err, "no row of table files has the key [9]")
+
+	pool = b.bufferPool.allpools["files"]
+	free := len(pool.freeOverflow)
+	r, err = b.OpenValue("files", "doc", 2)
+	require.NoError(t, err)
+	n, err = r.Read(chunk)
+	require.NoError(t, err)
+	_, err = execSQL(b, "UPDATE files SET doc = 'gone' WHERE id = 2;")
+	require.NoError(t, err)
+	require.Len(t, pool.freeOverflow, free, "the pages of a value being read are not freed")
+	_, err = execSQL(b, "INSERT INTO files (id, doc) VALUES (5, '"+strings.Repeat("w", 5000)+"');")
+	require.NoError(t, err)
+	free = len(pool.freeOverflow)
+	rest, err = io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, doc, string(chunk[:n])+string(rest), "a reader keeps reading the value it was opened on")
+	require.NoError(t, r.Close())
+	require.Len(t, pool.freeOverflow, free+20, "the pages are freed when the reader closes")
+	for _, sql := range []string{
+		"DELETE FROM files WHERE id = 5;",
+		"UPDATE files SET doc = '" + doc + "' WHERE id = 2;",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql[:min(len(sql), 60)])
+	}
+
+	for _, sql := range []string{
+		"ALTER TABLE files ADD COLUMN size int;",
+		"ALTER TABLE files RENAME TO blobs;",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	require.Equal(t, [][]any{{"short", []byte(image), nil}, {doc, []byte{}, nil}}, queryRows(t, b, "SELECT doc, image, size FROM blobs WHERE id <= 2;"),
+		"values on overflow pages are kept by a rewrite and a rename")
+}
@@ -0,0 +1,175 @@
+package internal
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+)
+
+/*
+Table files are made of slotted pages. The header holds the page number, the number of slots,
+the checksum of the rest of the page and the offset the row data starts at. The slot directory
+follows the header, every slot is the offset and length of its row, and rows are written from the
+end of the page towards the directory. A slot with length 0 was emptied by a delete, the next row
+stored on the page takes it.
+Rows are located by page*PAGESIZE+slot so they keep their location when their page is compacted.
+*/
+
+const (
+	pageHeaderSize = 28
+	slotSize       = 4
+	maxRowSize     = PAGESIZE - pageHeaderSize - slotSize //largest row fitting on an empty page
+)
+
+type heapPage [PAGESIZE]byte
+
+// empty page numbered pageid
+func newHeapPage(pageid PageID) *heapPage {
+	p := &heapPage{}
+	binary.LittleEndian.PutUint64(p[0:8], uint64(pageid))
+	return p
+}
+
+func (p *heapPage) slotCount() int {
+	return int(binary.LittleEndian.Uint16(p[8:10]))
+}
+
+// offset of the first byte of row data, the end of the page when it holds no rows
+func (p *heapPage) dataStart() int {
+	if start := int(binary.LittleEndian.Uint16(p[26:28])); start != 0 {
+		return start
+	}
+	return PAGESIZE
+}
+
+func (p *heapPage) slot(i int) (int, int) {
+	entry := pageHeaderSize + i*slotSize
+	return int(binary.LittleEndian.Uint16(p[entry:])), int(binary.LittleEndian.Uint16(p[entry+2:]))
+}
+
+func (p *heapPage) setSlot(i, offset, length int) {
+	entry := pageHeaderSize + i*slotSize
+	binary.LittleEndian.PutUint16(p[entry:], uint16(offset))
+	binary.LittleEndian.PutUint16(p[entry+2:], uint16(length))
+}
+
+// bytes of the row in slot i, nil for an empty slot
+func (p *heapPage) row(i int) []byte {
+	offset, length := p.slot(i)
+	if length == 0 {
+		return nil
+	}
+	return p[offset : offset+length : offset+length]
+}
+
+// bytes left for rows and slots once the page is compacted
+func (p *heapPage) freeSpace() int {
+	used := pageHeaderSize + p.slotCount()*slotSize
+	for i := range p.slotCount() {
+		_, length := p.slot(i)
+		used += length
+	}
+	return PAGESIZE - used
+}
+
+// stores row in the first empty slot or a new one, reports false when it doesn't fit on the page
+func (p *heapPage) insert(row []byte) (int, bool) {
+	count := p.slotCount()
+	slot := count
+	for i := range count {
+		if _, length := p.slot(i); length == 0 {
+			slot = i
+			break
+		}
+	}
+	if slot == count { //the directory grows into the gap before the rows
+		if p.freeSpace() < slotSize+len(row) {
+			return 0, false
+		}
+		if p.dataStart()-(pageHeaderSize+count*slotSize) < slotSize+len(row) {
+			p.compact()
+		}
+		binary.LittleEndian.PutUint16(p[8:10], uint16(count+1))
+		p.setSlot(slot, 0, 0)
+	}
+	if !p.place(slot, row) {
+		return 0, false
+	}
+	return slot, true
+}
+
+// overwrites the row in slot, reports false when the longer row no longer fits and the slot was emptied
+func (p *heapPage) update(slot int, row []byte) bool {
+	offset, length := p.slot(slot)
+	if len(row) <= length {
+		copy(p[offset:], row)
+		p.setSlot(slot, offset, len(row))
+		return true
+	}
+	p.setSlot(slot, 0, 0)
+	if p.place(slot, row) {
+		return true
+	}
+	p.delete(slot)
+	return false
+}
+
+// empties slot, the slots left empty at the end of the directory are dropped
+func (p *heapPage) delete(slot int) {
+	p.setSlot(slot, 0, 0)
+	count := p.slotCount()
+	for count > 0 {
+		if _, length := p.slot(count - 1); length != 0 {
+			break
+		}
+		count--
+	}
+	binary.LittleEndian.PutUint16(p[8:10], uint16(count))
+	if count == 0 {
+		clear(p[pageHeaderSize:])
+		binary.LittleEndian.PutUint16(p[26:28], 0)
+	}
+}
+
+// writes row in front of the row data and points slot at it, compacting the page when the gap is too small
+func (p *heapPage) place(slot int, row []byte) bool {
+	directoryEnd := pageHeaderSize + p.slotCount()*slotSize
+	if p.dataStart()-directoryEnd < len(row) {
+		p.compact()
+		if p.dataStart()-directoryEnd < len(row) {
+			return false
+		}
+	}
+	start := p.dataStart() - len(row)
+	copy(p[start:], row)
+	p.setSlot(slot, start, len(row))
+	binary.LittleEndian.PutUint16(p[26:28], uint16(start))
+	return true
+}
+
+// moves every row to the end of the page so the free space is one gap after the slot directory
+func (p *heapPage) compact() {
+	rows := make([][]byte, p.slotCount())
+	for i := range rows {
+		if row := p.row(i); row != nil {
+			rows[i] = append([]byte(nil), row...)
+		}
+	}
+	directoryEnd := pageHeaderSize + len(rows)*slotSize
+	clear(p[directoryEnd:])
+	start := PAGESIZE
+	for i, row := range rows {
+		if row == nil {
+			continue
+		}
+		start -= len(row)
+		copy(p[start:], row)
+		p.setSlot(i, start, len(row))
+	}
+	binary.LittleEndian.PutUint16(p[26:28], uint16(start%PAGESIZE))
+}
+
+// sets the checksum of the page once it was changed
+func (p *heapPage) seal() {
+	checksum := md5.Sum(p[26:])
+	copy(p[10:26], checksum[:])
+}
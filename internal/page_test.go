@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeapPage(t *testing.T) {
+	page := newHeapPage(3)
+	rows := make([][]byte, 0)
+	for i := 0; ; i++ {
+		row := bytes.Repeat([]byte{byte(i + 1)}, 100+i)
+		slot, ok := page.insert(row)
+		if !ok {
+			break
+		}
+		require.Equal(t, i, slot)
+		rows = append(rows, row)
+	}
+	require.Len(t, rows, 33)
+	require.Less(t, page.freeSpace(), 100+len(rows)+slotSize)
+
+	page.delete(1)
+	page.delete(5)
+	_, ok := page.insert(bytes.Repeat([]byte{0xff}, 400))
+	require.False(t, ok, "the row is larger than the space left by both deletes")
+	slot, ok := page.insert(bytes.Repeat([]byte{0xee}, 150))
+	require.True(t, ok, "the page is compacted to make room")
+	require.Equal(t, 1, slot, "the first empty slot is taken")
+	rows[1] = bytes.Repeat([]byte{0xee}, 150)
+
+	require.True(t, page.update(0, []byte{9}), "a shorter row stays in place")
+	rows[0] = []byte{9}
+	require.True(t, page.update(2, bytes.Repeat([]byte{7}, 160)), "the space left by the shrunk row is reused")
+	rows[2] = bytes.Repeat([]byte{7}, 160)
+	require.False(t, page.update(3, bytes.Repeat([]byte{8}, 1000)))
+	require.Nil(t, page.row(3), "a row that no longer fits leaves its slot")
+	rows[3], rows[5] = nil, nil
+
+	page.seal()
+	for i, row := range rows {
+		require.Equal(t, row, page.row(i), "slot %d", i)
+	}
+
+	for i := len(rows) - 1; i >= 0; i-- {
+		page.delete(i)
+	}
+	require.Equal(t, 0, page.slotCount())
+	require.Equal(t, PAGESIZE-pageHeaderSize, page.freeSpace())
+	_, ok = page.insert(make([]byte, maxRowSize))
+	require.True(t, ok, "a row of the largest size fits an empty page")
+}
+
+func TestVarcharAndText(t *testing.T) {
+	dir := t.TempDir()
+	b := CreateNewDatabase(dir)
+	values := make([]string, 0, 40)
+	for i := 1; i <= 40; i++ {
+		values = append(values, fmt.Sprintf("(%d, 't%d', 'body %d')", i, i, i))
+	}
+	longTitle := strings.Repeat("x", 300)
+	for _, sql := range []string{
+		"CREATE TABLE docs (id int primary key, title varchar(300) unique, body text, tag char(4));",
+		"INSERT INTO docs (id, title, body) VALUES " + strings.Join(values, ", ") + ";",
+		"INSERT INTO docs (id, title, body, tag) VALUES (41, '" + longTitle + "', '', 'ab');",
+		"UPDATE docs SET body = '" + strings.Repeat("b", 3000) + "' WHERE id = 2;",
+		"UPDATE docs SET body = '" + strings.Repeat("c", 3000) + "' WHERE id = 3;",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	require.Equal(t, [][]any{{longTitle, "", "ab\x00\x00"}}, queryRows(t, b, "SELECT title, body, tag FROM docs WHERE id = 41;"), "only CHAR is padded")
+	require.Equal(t, [][]any{{int64(2), strings.Repeat("b", 3000)}}, queryRows(t, b, "SELECT id, body FROM docs WHERE title = 't2';"))
+	require.Equal(t, [][]any{{"body 40", nil}}, queryRows(t, b, "SELECT body, tag FROM docs WHERE id = 40;"))
+
+	errs := []struct {
+		SQL      string
+		Expected string
+	}{
+		{"INSERT INTO docs (id, title) VALUES (50, '" + longTitle + "y');", "insert: string to insert larger than allowed"},
+		{"CREATE TABLE k (name text primary key);", ""},
+		{"INSERT INTO k (name) VALUES ('" + strings.Repeat("k", 1100) + "');", "insert: key of 1103 bytes for the primary key of table k is larger than the maximum of 1024"},
+		{"CREATE TABLE v (id int primary key, name varchar);", "size needed for varchar field in table"},
+		{"CREATE TABLE v (id int primary key, name varchar(70000));", "size for varchar field must be between 1 and 65535"},
+		{"CREATE TABLE v (id int primary key, name text(10));", "text field takes no size"},
+		{"CREATE TABLE v (id int primary key, data blob(10));", "blob field takes no size"},
+	}
+	for _, tc := range errs {
+		t.Run(tc.SQL[:min(len(tc.SQL), 60)], func(t *testing.T) {
+			_, err := execSQL(b, tc.SQL)
+			if tc.Expected == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tc.Expected)
+		})
+	}
+	require.Empty(t, queryRows(t, b, "SELECT name FROM k WHERE name = 'k';"))
+	b.Close()
+
+	b, err := OpenExistingDatabase(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	require.Equal(t, [][]any{{int64(3), strings.Repeat("c", 3000)}}, queryRows(t, b, "SELECT id, body FROM docs WHERE title = 't3';"), "rows moved by an update are found through the index")
+	require.Len(t, queryRows(t, b, "SELECT id FROM docs WHERE id > 0;"), 41)
+	_, err = execSQL(b, "DELETE FROM docs WHERE id = 2;")
+	require.NoError(t, err)
+	_, err = execSQL(b, "INSERT INTO docs (id, title, body) VALUES (42, 'big', '"+strings.Repeat("d", 2900)+"');")
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), b.tables[0].lastPage, "both grown rows moved to a page of their own, the space of the deleted one is reused")
+}
@@ -1,9 +1,12 @@
 package internal
 
 import (
-	"errors"
+	"encoding/hex"
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/treeform-system/rootdb/internal/token"
 )
@@ -19,58 +22,27 @@ func Parse(sql string) (Query, error) {
 	return q, nil
 }
 
-func parse(sql string) (Query, error) {
-	return (&parser{sql: strings.TrimSpace(sql), step: stepType, query: Query{}, nextUpdateField: ""}).parse()
+// ParseArgs parses sql binding args in order to its ? placeholders, each becomes the literal of its value
+func ParseArgs(sql string, args []any) (Query, error) {
+	return (&parser{sql: strings.TrimSpace(sql), args: args}).parse()
 }
 
-type step int
-
-const (
-	stepType step = iota
-	stepSelectField
-	stepSelectFrom
-	stepSelectComma
-	stepSelectFromTable
-	stepInsertTable
-	stepInsertFieldsOpeningParens
-	stepInsertFields
-	stepInsertFieldsCommaOrClosingParens
-	stepInsertValuesOpeningParens
-	stepInsertValuesWord
-	stepInsertValues
-	stepInsertValuesCommaOrClosingParens
-	stepInsertValuesCommaBeforeOpeningParens
-	stepUpdateTable
-	stepUpdateSet
-	stepUpdateField
-	stepUpdateEquals
-	stepUpdateValue
-	stepUpdateComma
-	stepDeleteFromTable
-	stepWhere
-	stepWhereField
-	stepWhereOperator
-	stepWhereValue
-	stepWhereAnd
-	stepCreateTable
-	stepCreateFieldsOpeningParens
-	stepCreateFields
-	stepCreateColumnType
-	stepCreateColumnSize
-	stepCreateConstraints
-	stepDropTable
-)
+func parse(sql string) (Query, error) {
+	return (&parser{sql: strings.TrimSpace(sql)}).parse()
+}
 
+/*
+Recursive descent parser, every parseX function starts with curToken on the first token
+of X and returns with curToken on the first token after X.
+Expressions are parsed with precedence climbing (pratt parser) see parseExpr.
+*/
 type parser struct {
-	i               int
-	sql             string
-	lexer           *Lexer
-	curToken        token.Token
-	peekToken       token.Token
-	step            step
-	query           Query
-	err             error
-	nextUpdateField string
+	sql       string
+	lexer     *Lexer
+	curToken  token.Token
+	peekToken token.Token
+	args      []any //values of the ? placeholders in order
+	nextArg   int
 }
 
 const (
@@ -78,26 +50,66 @@ const (
 	FLOAT
 	BOOL
 	CHAR
+	VARCHAR
+	TEXT
+	BLOB
+	DATE
+	TIME
+	TIMESTAMP
+	INTERVAL
+	DECIMAL
+	UINT
+	JSON
+	UUID
 )
 
 func (p *parser) parse() (Query, error) {
 	if p.sql == "" {
-		return p.query, errors.New("query type cannot be empty")
+		return Query{}, &ParseError{Line: 1, Column: 1, Token: token.Token{Type: token.EOF}, Expected: "statement", Msg: "query type cannot be empty"}
 	} else if p.sql[len(p.sql)-1] != ';' {
-		return p.query, errors.New("sql string must end in semicolon")
+		end := NewLexer(p.sql)
+		var last token.Token
+		for tok := end.NextToken(); tok.Type != token.EOF; tok = end.NextToken() {
+			last = tok
+		}
+		return Query{}, &ParseError{Line: last.Line, Column: last.Column + len(last.Literal), Token: last, Expected: ";", Msg: "sql string must end in semicolon"}
 	}
 
 	p.lexer = NewLexer(p.sql)
 	p.nextToken()
 	p.nextToken()
 
-	q, err := p.doParse()
-	p.err = err
-	if p.err == nil {
-		p.err = p.validate()
+	stmt, err := p.parseStatement()
+	if err != nil {
+		return Query{Type: statementType(stmt)}, err
+	}
+	if p.curToken.Type != token.SEMICOLON {
+		return Query{Type: statementType(stmt)}, p.errorf(";", "unexpected %q after end of statement", p.curToken.Literal)
+	}
+	if p.nextArg != len(p.args) { // the placeholder of the first extra argument is missing at the end of the statement
+		return Query{Type: statementType(stmt)}, p.errorf("?", "%d arguments given for %d placeholders", len(p.args), p.nextArg)
+	}
+
+	q := lowerStatement(stmt)
+	if err := p.validate(q); err != nil {
+		return q, err
+	}
+	return q, nil
+}
+
+// ParseExpr parses a single expression without a trailing semicolon, ie. one written by FormatExpr
+func ParseExpr(sql string) (Expr, error) {
+	p := &parser{sql: sql, lexer: NewLexer(sql)}
+	p.nextToken()
+	p.nextToken()
+	expr, err := p.parseExpr(precedenceLowest)
+	if err != nil {
+		return nil, err
+	}
+	if p.curToken.Type != token.EOF {
+		return nil, p.errorf("", "unexpected %q after end of expression", p.curToken.Literal)
 	}
-	p.logError()
-	return q, p.err
+	return expr, nil
 }
 
 func (p *parser) nextToken() {
@@ -105,424 +117,1262 @@ func (p *parser) nextToken() {
 	p.peekToken = p.lexer.NextToken()
 }
 
-func (p *parser) doParse() (Query, error) {
-
-	for p.curToken.Type != token.EOF {
-		if p.curToken.Type == token.ILLEGAL {
-			return p.query, errors.New("unknown token in sql string")
-		}
-		switch p.step {
-		case stepType:
-			switch p.curToken.Type {
-			case token.SELECT:
-				p.query.Type = Select
-				p.step = stepSelectField
-			case token.INSERT:
-				p.query.Type = Insert
-				p.nextToken()
-				if p.curToken.Type != token.INTO {
-					return p.query, errors.New("insert statement invalid at INTO")
-				}
-				p.step = stepInsertTable
-			case token.UPDATE:
-				p.query.Type = Update
-				p.query.Updates = map[string]string{}
-				p.step = stepUpdateTable
-			case token.DELETE:
-				p.query.Type = Delete
-				p.nextToken()
-				if p.curToken.Type != token.FROM {
-					return p.query, errors.New("delete statement invalid at DELETE")
-				}
-				p.step = stepDeleteFromTable
-			case token.CREATE:
-				p.query.Type = Create
-				p.nextToken()
-				if p.curToken.Type != token.TABLE {
-					return p.query, errors.New("create statement invalid at CREATE")
-				}
-				p.step = stepCreateTable
-			case token.DROP:
-				p.query.Type = Drop
-				p.nextToken()
-				if p.curToken.Type != token.TABLE {
-					return p.query, errors.New("create statement invalid at CREATE")
-				}
-				p.step = stepDropTable
-			default:
-				return p.query, fmt.Errorf("invalid query type")
-			}
-		// Select steps
-		case stepSelectField:
-			if p.curToken.Type != token.IDENT && p.curToken.Type != token.ASTERISK {
-				return p.query, fmt.Errorf("at SELECT: expected field to SELECT")
-			}
-			p.query.Fields = append(p.query.Fields, p.curToken.Literal)
-			identifier := p.curToken.Literal
-			if p.peekToken.Type == token.AS {
-				p.nextToken()
-				if p.peekToken.Type != token.IDENT {
-					return p.query, fmt.Errorf("at SELECT: expected field alias for \"" + identifier + " as\" to SELECT")
-				}
-				if p.query.Aliases == nil {
-					p.query.Aliases = make(map[string]string)
-				}
-				p.query.Aliases[identifier] = p.peekToken.Literal
-				p.nextToken()
+func (p *parser) parseStatement() (Statement, error) {
+	switch p.curToken.Type {
+	case token.SELECT:
+		return p.parseSelect()
+	case token.INSERT:
+		return p.parseInsert()
+	case token.UPDATE:
+		return p.parseUpdate()
+	case token.DELETE:
+		return p.parseDelete()
+	case token.CREATE:
+		return p.parseCreate()
+	case token.DROP:
+		return p.parseDrop()
+	case token.ALTER:
+		return p.parseAlter()
+	case token.EXPLAIN:
+		return p.parseExplain()
+	case token.ANALYZE:
+		return p.parseAnalyze()
+	default:
+		return nil, p.errorf("statement keyword", "invalid query type")
+	}
+}
+
+// SELECT item [AS alias], ... FROM table [WHERE expr]
+func (p *parser) parseSelect() (*SelectStatement, error) {
+	stmt := &SelectStatement{}
+	p.nextToken()
+	var err error
+	if stmt.Items, err = p.parseSelectItems("SELECT", "SELECT"); err != nil {
+		return stmt, err
+	}
+
+	if p.curToken.Type != token.FROM {
+		return stmt, p.expected("SELECT", "comma or FROM")
+	}
+	p.nextToken()
+	from, err := p.parseTableName("SELECT")
+	if err != nil {
+		return stmt, err
+	}
+	stmt.From = from
+
+	stmt.Where, err = p.parseOptionalWhere()
+	return stmt, err
+}
+
+// item [AS alias], ... of a SELECT or RETURNING clause, what is the verb used in errors
+func (p *parser) parseSelectItems(clause, what string) ([]SelectItem, error) {
+	var items []SelectItem
+	for {
+		var item SelectItem
+		if p.curToken.Type == token.ASTERISK {
+			item.Star = true
+			p.nextToken()
+		} else if p.startsExpr() {
+			expr, err := p.parseExpr(precedenceLowest)
+			if err != nil {
+				return items, err
 			}
-			if p.peekToken.Type == token.FROM {
-				p.step = stepSelectFrom
-			} else {
-				p.step = stepSelectComma
+			item.Expr = expr
+		} else {
+			return items, p.expected(clause, "field to "+what)
+		}
+
+		if p.curToken.Type == token.AS {
+			if p.peekToken.Type != token.IDENT {
+				return items, p.expectedAt(p.peekToken, clause, fmt.Sprintf("field alias for \"%s as\" to %s", selectItemName(item), what))
 			}
-		case stepSelectComma:
-			if p.curToken.Type != token.COMMA {
-				return p.query, fmt.Errorf("at SELECT: expected comma or FROM")
+			p.nextToken()
+			item.Alias = p.curToken.Literal
+			p.nextToken()
+		}
+		items = append(items, item)
+
+		if p.curToken.Type != token.COMMA {
+			return items, nil
+		}
+		p.nextToken()
+	}
+}
+
+// [RETURNING item [AS alias], ...] ending an INSERT, UPDATE or DELETE
+func (p *parser) parseOptionalReturning() ([]SelectItem, error) {
+	if p.curToken.Type != token.RETURNING {
+		return nil, nil
+	}
+	p.nextToken()
+	return p.parseSelectItems("RETURNING", "return")
+}
+
+// INSERT INTO table (column, ...) VALUES (expr, ...), ... | SELECT ... [ON CONFLICT ...] [RETURNING item, ...]
+func (p *parser) parseInsert() (*InsertStatement, error) {
+	stmt := &InsertStatement{}
+	p.nextToken()
+	if p.curToken.Type != token.INTO {
+		return stmt, p.errorf("INTO", "insert statement invalid at INTO")
+	}
+	p.nextToken()
+	if p.curToken.Type != token.IDENT {
+		return stmt, p.expected("INSERT INTO", "table name")
+	}
+	stmt.Table = &TableName{Name: p.curToken.Literal}
+	p.nextToken()
+
+	if p.curToken.Type != token.LPAREN {
+		return stmt, p.expected("INSERT INTO", "opening parens")
+	}
+	p.nextToken()
+	for {
+		if p.curToken.Type != token.IDENT {
+			return stmt, p.expected("INSERT INTO", "at least one field to insert")
+		}
+		stmt.Columns = append(stmt.Columns, p.curToken.Literal)
+		p.nextToken()
+		if p.curToken.Type == token.RPAREN {
+			break
+		}
+		if p.curToken.Type != token.COMMA {
+			return stmt, p.expected("INSERT INTO", "comma or closing parens")
+		}
+		p.nextToken()
+	}
+	p.nextToken()
+
+	if p.curToken.Type == token.SELECT {
+		var err error
+		if stmt.Query, err = p.parseSelect(); err != nil {
+			return stmt, err
+		}
+		if err := p.parseOnConflict(stmt); err != nil {
+			return stmt, err
+		}
+		stmt.Returning, err = p.parseOptionalReturning()
+		return stmt, err
+	}
+	if p.curToken.Type != token.VALUES {
+		return stmt, p.expected("INSERT INTO", "'VALUES'")
+	}
+	p.nextToken()
+	for {
+		if p.curToken.Type != token.LPAREN {
+			return stmt, p.expected("INSERT INTO", "opening parens")
+		}
+		p.nextToken()
+		row := make([]Expr, 0, len(stmt.Columns))
+		for {
+			if !p.startsExpr() {
+				return stmt, p.expected("INSERT INTO", "value to insert string or number literal")
 			}
-			p.step = stepSelectField
-		case stepSelectFrom:
-			if p.curToken.Type != token.FROM {
-				return p.query, fmt.Errorf("at SELECT: expected FROM")
+			value, err := p.parseExpr(precedenceLowest)
+			if err != nil {
+				return stmt, err
 			}
-			p.step = stepSelectFromTable
-		case stepSelectFromTable:
-			if p.curToken.Type != token.IDENT {
-				return p.query, fmt.Errorf("at SELECT: expected table name")
+			row = append(row, value)
+			if p.curToken.Type == token.RPAREN {
+				break
 			}
-			p.query.TableName = p.curToken.Literal
-			p.step = stepWhere
-		// Delete steps
-		case stepDeleteFromTable:
-			if p.curToken.Type != token.IDENT {
-				return p.query, fmt.Errorf("at DELETE FROM: expected table name")
+			if p.curToken.Type != token.COMMA {
+				return stmt, p.expected("INSERT INTO", "comma or closing parens")
 			}
-			p.query.TableName = p.curToken.Literal
-			p.step = stepWhere
-		// Update steps
-		case stepUpdateTable:
-			if p.curToken.Type != token.IDENT {
-				return p.query, fmt.Errorf("at UPDATE: expected table name")
+			p.nextToken()
+		}
+		if len(row) != len(stmt.Columns) {
+			return stmt, p.errorf("", "at INSERT INTO: value count doesn't match field count")
+		}
+		stmt.Rows = append(stmt.Rows, row)
+		p.nextToken()
+
+		if p.curToken.Type != token.COMMA {
+			break
+		}
+		p.nextToken()
+	}
+	if err := p.parseOnConflict(stmt); err != nil {
+		return stmt, err
+	}
+	var err error
+	stmt.Returning, err = p.parseOptionalReturning()
+	return stmt, err
+}
+
+// [ON CONFLICT [(column, ...)] DO NOTHING | DO UPDATE SET column = value, ... [WHERE expr]]
+// CONFLICT, DO and NOTHING aren't reserved
+func (p *parser) parseOnConflict(stmt *InsertStatement) error {
+	if p.curToken.Type != token.ON {
+		return nil
+	}
+	p.nextToken()
+	if p.curToken.Type != token.IDENT || !strings.EqualFold(p.curToken.Literal, "CONFLICT") {
+		return p.expected("INSERT INTO", "CONFLICT after ON")
+	}
+	c := &OnConflict{}
+	stmt.OnConflict = c
+	p.nextToken()
+	if p.curToken.Type == token.LPAREN {
+		columns, err := p.parseColumnList("ON CONFLICT", "conflict column")
+		if err != nil {
+			return err
+		}
+		c.Columns = columns
+		p.nextToken()
+	}
+	if p.curToken.Type != token.IDENT || !strings.EqualFold(p.curToken.Literal, "DO") {
+		return p.expected("ON CONFLICT", "DO")
+	}
+	p.nextToken()
+	switch {
+	case p.curToken.Type == token.IDENT && strings.EqualFold(p.curToken.Literal, "NOTHING"):
+		p.nextToken()
+		return nil
+	case p.curToken.Type == token.UPDATE:
+	default:
+		return p.expected("ON CONFLICT", "NOTHING or UPDATE after DO")
+	}
+	if len(c.Columns) == 0 {
+		return p.errorf("", "at ON CONFLICT: DO UPDATE requires conflict columns")
+	}
+	c.DoUpdate = true
+	p.nextToken()
+	if p.curToken.Type != token.SET {
+		return p.expected("ON CONFLICT", "'SET'")
+	}
+	p.nextToken()
+	var err error
+	if c.Set, err = p.parseAssignments("ON CONFLICT"); err != nil {
+		return err
+	}
+	c.Where, err = p.parseOptionalWhere()
+	return err
+}
+
+// UPDATE table SET column = expr, ... [WHERE expr] [RETURNING item, ...]
+func (p *parser) parseUpdate() (*UpdateStatement, error) {
+	stmt := &UpdateStatement{}
+	p.nextToken()
+	if p.curToken.Type != token.IDENT {
+		return stmt, p.expected("UPDATE", "table name")
+	}
+	stmt.Table = &TableName{Name: p.curToken.Literal}
+	p.nextToken()
+
+	if p.curToken.Type != token.SET {
+		return stmt, p.expected("UPDATE", "'SET'")
+	}
+	p.nextToken()
+	var err error
+	if stmt.Set, err = p.parseAssignments("UPDATE"); err != nil {
+		return stmt, err
+	}
+	if stmt.Where, err = p.parseOptionalWhere(); err != nil {
+		return stmt, err
+	}
+	stmt.Returning, err = p.parseOptionalReturning()
+	return stmt, err
+}
+
+// column = expr, ... of an UPDATE or a DO UPDATE, followed by WHERE or the end of the statement
+func (p *parser) parseAssignments(clause string) ([]Assignment, error) {
+	var set []Assignment
+	for {
+		if p.curToken.Type != token.IDENT {
+			return set, p.expected(clause, "at least one field to update")
+		}
+		assignment := Assignment{Column: p.curToken.Literal}
+		p.nextToken()
+		if p.curToken.Type != token.EQ {
+			return set, p.expected(clause, "'='")
+		}
+		p.nextToken()
+		if !p.startsExpr() {
+			return set, p.expected(clause, "value for update")
+		}
+		value, err := p.parseExpr(precedenceLowest)
+		if err != nil {
+			return set, err
+		}
+		assignment.Value = value
+		set = append(set, assignment)
+
+		if p.curToken.Type != token.COMMA {
+			break
+		}
+		p.nextToken()
+	}
+
+	if p.curToken.Type != token.WHERE && p.curToken.Type != token.SEMICOLON && p.curToken.Type != token.RETURNING {
+		return set, p.expected(clause, "','")
+	}
+	return set, nil
+}
+
+// DELETE FROM table [WHERE expr] [RETURNING item, ...]
+func (p *parser) parseDelete() (*DeleteStatement, error) {
+	stmt := &DeleteStatement{}
+	p.nextToken()
+	if p.curToken.Type != token.FROM {
+		return stmt, p.errorf("FROM", "delete statement invalid at DELETE")
+	}
+	p.nextToken()
+	if p.curToken.Type != token.IDENT {
+		return stmt, p.expected("DELETE FROM", "table name")
+	}
+	stmt.Table = &TableName{Name: p.curToken.Literal}
+	p.nextToken()
+
+	var err error
+	if stmt.Where, err = p.parseOptionalWhere(); err != nil {
+		return stmt, err
+	}
+	stmt.Returning, err = p.parseOptionalReturning()
+	return stmt, err
+}
+
+// CREATE TABLE ... | CREATE [UNIQUE] INDEX ...
+func (p *parser) parseCreate() (Statement, error) {
+	p.nextToken()
+	switch p.curToken.Type {
+	case token.TABLE:
+		return p.parseCreateTable()
+	case token.UNIQUE, token.INDEX:
+		return p.parseCreateIndex()
+	}
+	return &CreateTableStatement{}, p.errorf("TABLE", "create statement invalid at CREATE")
+}
+
+// CREATE [UNIQUE] INDEX name ON table (column, ...)
+func (p *parser) parseCreateIndex() (*CreateIndexStatement, error) {
+	stmt := &CreateIndexStatement{}
+	if p.curToken.Type == token.UNIQUE {
+		stmt.Unique = true
+		p.nextToken()
+		if p.curToken.Type != token.INDEX {
+			return stmt, p.expected("CREATE UNIQUE", "INDEX keyword")
+		}
+	}
+	p.nextToken()
+	if p.curToken.Type != token.IDENT {
+		return stmt, p.expected("CREATE INDEX", "index name")
+	}
+	stmt.Name = p.curToken.Literal
+	p.nextToken()
+	if p.curToken.Type != token.ON {
+		return stmt, p.expected("CREATE INDEX", "ON keyword")
+	}
+	p.nextToken()
+	if p.curToken.Type != token.IDENT {
+		return stmt, p.expected("CREATE INDEX", "table name")
+	}
+	stmt.Table = p.curToken.Literal
+	p.nextToken()
+	if p.curToken.Type != token.LPAREN {
+		return stmt, p.expected("CREATE INDEX", "opening parens")
+	}
+	p.nextToken()
+	for {
+		if !p.startsExpr() {
+			return stmt, p.expected("CREATE INDEX", "column to index")
+		}
+		expr, err := p.parseExpr(precedenceLowest)
+		if err != nil {
+			return stmt, err
+		}
+		if ident, ok := expr.(*Identifier); ok && ident.Table == "" {
+			stmt.Columns = append(stmt.Columns, ident.Name)
+			if stmt.Exprs != nil {
+				stmt.Exprs = append(stmt.Exprs, nil)
 			}
-			p.query.TableName = p.curToken.Literal
-			p.step = stepUpdateSet
-		case stepUpdateSet:
-			if p.curToken.Type != token.SET {
-				return p.query, fmt.Errorf("at UPDATE: expected 'SET'")
+		} else { //expression index, ie. ((doc->>'$.name'))
+			if stmt.Exprs == nil {
+				stmt.Exprs = make([]Expr, len(stmt.Columns))
 			}
-			p.step = stepUpdateField
-		case stepUpdateField:
-			if p.curToken.Type != token.IDENT {
-				return p.query, fmt.Errorf("at UPDATE: expected at least one field to update")
+			stmt.Columns = append(stmt.Columns, "")
+			stmt.Exprs = append(stmt.Exprs, expr)
+		}
+		if p.curToken.Type == token.RPAREN {
+			break
+		}
+		if p.curToken.Type != token.COMMA {
+			return stmt, p.expected("CREATE INDEX", "comma or closing parens")
+		}
+		p.nextToken()
+	}
+	if p.peekToken.Type != token.SEMICOLON {
+		return stmt, p.expectedAt(p.peekToken, "CREATE INDEX", "semicolon to end sql")
+	}
+	p.nextToken()
+	return stmt, nil
+}
+
+func (p *parser) parseCreateTable() (*CreateTableStatement, error) {
+	stmt := &CreateTableStatement{}
+	p.nextToken()
+	if p.curToken.Type == token.IF {
+		p.nextToken()
+		if p.curToken.Type != token.NOT || p.peekToken.Type != token.EXISTS {
+			return stmt, p.expected("CREATE TABLE", "NOT EXISTS after IF")
+		}
+		stmt.IfNotExists = true
+		p.nextToken()
+		p.nextToken()
+	}
+	if p.curToken.Type != token.IDENT {
+		return stmt, p.expected("CREATE TABLE", "quoted table name")
+	}
+	stmt.Name = p.curToken.Literal
+	p.nextToken()
+
+	if p.curToken.Type == token.AS {
+		p.nextToken()
+		if p.curToken.Type != token.SELECT {
+			return stmt, p.expected("CREATE TABLE", "SELECT after AS")
+		}
+		var err error
+		stmt.Query, err = p.parseSelect()
+		return stmt, err
+	}
+
+	if p.curToken.Type != token.LPAREN {
+		return stmt, p.expected("CREATE TABLE", "opening parens")
+	}
+	p.nextToken()
+	for {
+		if p.curToken.Type == token.PRIMARY {
+			if err := p.parsePrimaryKey(stmt); err != nil {
+				return stmt, err
 			}
-			p.nextUpdateField = p.curToken.Literal
-			p.step = stepUpdateEquals
-		case stepUpdateEquals:
-			if p.curToken.Type != token.EQ {
-				return p.query, fmt.Errorf("at UPDATE: expected '='")
+		} else if p.curToken.Type == token.CHECK {
+			expr, err := p.parseCheck()
+			if err != nil {
+				return stmt, err
 			}
-			p.step = stepUpdateValue
-		case stepUpdateValue:
-			if p.curToken.Type != token.STRINGLITERAL && p.curToken.Type != token.NUMBERLITERAL {
-				return p.query, fmt.Errorf("at UPDATE: expected value for update")
+			stmt.Checks = append(stmt.Checks, expr)
+			p.nextToken()
+		} else if p.curToken.Type == token.FOREIGN {
+			fk, err := p.parseForeignKey()
+			if err != nil {
+				return stmt, err
 			}
-			p.query.Updates[p.nextUpdateField] = p.curToken.Literal
-			p.nextUpdateField = ""
-			if p.peekToken.Type == token.WHERE {
-				p.step = stepWhere
-			} else {
-				p.step = stepUpdateComma
+			stmt.ForeignKeys = append(stmt.ForeignKeys, fk)
+			p.nextToken()
+		} else {
+			column, err := p.parseColumnDef()
+			if err != nil {
+				return stmt, err
 			}
-		case stepUpdateComma:
-			if p.curToken.Type == token.SEMICOLON {
-				return p.query, p.err
+			stmt.Columns = append(stmt.Columns, column)
+		}
+		if p.curToken.Type == token.RPAREN {
+			break
+		}
+		if p.curToken.Type != token.COMMA {
+			return stmt, p.expected("CREATE TABLE", "constraint keyword")
+		}
+		p.nextToken()
+	}
+	if p.peekToken.Type != token.SEMICOLON {
+		return stmt, p.expectedAt(p.peekToken, "CREATE TABLE", "semicolon to end sql")
+	}
+	p.nextToken()
+	return stmt, nil
+}
+
+// PRIMARY KEY (column, ...) as a table constraint
+func (p *parser) parsePrimaryKey(stmt *CreateTableStatement) error {
+	if len(stmt.PrimaryKey) > 0 {
+		return p.errorf("", "at CREATE TABLE: multiple primary keys for table %q are not allowed", stmt.Name)
+	}
+	p.nextToken()
+	if p.curToken.Type != token.KEY {
+		return p.expected("CREATE TABLE", "key after primary keyword")
+	}
+	p.nextToken()
+	columns, err := p.parseColumnList("CREATE TABLE", "primary key column")
+	if err != nil {
+		return err
+	}
+	stmt.PrimaryKey = columns
+	p.nextToken()
+	return nil
+}
+
+func (p *parser) parseColumnDef() (ColumnDef, error) {
+	column := ColumnDef{}
+	if p.curToken.Type != token.IDENT {
+		return column, p.expected("CREATE TABLE", "field to CREATE")
+	}
+	column.Name = p.curToken.Literal
+	p.nextToken()
+
+	if !token.LookupDataType(p.curToken.Type) {
+		return column, p.expected("CREATE TABLE", "valid data type for column")
+	}
+	column.Type.Name = strings.ToUpper(p.curToken.Literal)
+	p.nextToken()
+	if p.curToken.Type == token.UNSIGNED { //BIGINT UNSIGNED
+		column.Type.Name += " UNSIGNED"
+		p.nextToken()
+	}
+	if p.curToken.Type == token.LPAREN {
+		for { //CHAR(10), DECIMAL(10, 2)
+			p.nextToken()
+			if p.curToken.Type != token.NUMBERLITERAL {
+				return column, p.expected("CREATE TABLE", "number for datatype size")
 			}
+			column.Type.Args = append(column.Type.Args, p.curToken.Literal)
+			p.nextToken()
 			if p.curToken.Type != token.COMMA {
-				return p.query, fmt.Errorf("at UPDATE: expected ','")
-			}
-			p.step = stepUpdateField
-		// Where steps
-		case stepWhere: //jumped from selectFromTable and deleteFromTable
-			if p.curToken.Type == token.SEMICOLON {
-				p.nextToken()
 				break
 			}
-			if p.curToken.Type != token.WHERE {
-				return p.query, fmt.Errorf("expected WHERE")
-			}
-			p.step = stepWhereField
-		case stepWhereField:
-			if p.curToken.Type != token.IDENT {
-				return p.query, fmt.Errorf("at WHERE: expected field")
-			}
-			p.query.Conditions = append(p.query.Conditions, Condition{Operand1: p.curToken.Literal, Operand1IsField: true})
-			p.step = stepWhereOperator
-		case stepWhereOperator:
-			currentCondition := p.query.Conditions[len(p.query.Conditions)-1]
-			switch p.curToken.Type {
-			case token.EQ:
-				currentCondition.Operator = Eq
-			case token.GT:
-				currentCondition.Operator = Gt
-			case token.GTE:
-				currentCondition.Operator = Gte
-			case token.LT:
-				currentCondition.Operator = Lt
-			case token.LTE:
-				currentCondition.Operator = Lte
-			case token.NOT_EQ:
-				currentCondition.Operator = Ne
-			default:
-				return p.query, fmt.Errorf("at WHERE: unknown operator")
-			}
-			p.query.Conditions[len(p.query.Conditions)-1] = currentCondition
-			p.step = stepWhereValue
-		case stepWhereValue:
-			currentCondition := p.query.Conditions[len(p.query.Conditions)-1]
-			if p.curToken.Type == token.BOOLLITERAL {
-				currentCondition.Operand2 = strings.ToUpper(p.curToken.Literal)
-				currentCondition.Operand2IsField = false
-			} else if p.curToken.Type == token.IDENT {
-				currentCondition.Operand2 = p.curToken.Literal
-				currentCondition.Operand2IsField = true
-			} else {
-				if p.curToken.Type != token.STRINGLITERAL && p.curToken.Type != token.NUMBERLITERAL {
-					return p.query, fmt.Errorf("at WHERE: expected value")
-				}
-				currentCondition.Operand2 = p.curToken.Literal
-				currentCondition.Operand2IsField = false
-			}
-			p.query.Conditions[len(p.query.Conditions)-1] = currentCondition
-			if p.peekToken.Type == token.SEMICOLON {
-				return p.query, p.err
-			}
-			p.step = stepWhereAnd
-		case stepWhereAnd:
-			if p.curToken.Type != token.AND {
-				return p.query, fmt.Errorf("at WHERE: expected AND")
-			}
-			p.step = stepWhereField
-		// Insert steps
-		case stepInsertTable:
-			if p.curToken.Type != token.IDENT {
-				return p.query, fmt.Errorf("at INSERT INTO: expected table name")
-			}
-			p.query.TableName = p.curToken.Literal
-			p.step = stepInsertFieldsOpeningParens
-		case stepInsertFieldsOpeningParens:
-			if p.curToken.Type != token.LPAREN {
-				return p.query, fmt.Errorf("at INSERT INTO: expected opening parens")
-			}
-			p.step = stepInsertFields
-		case stepInsertFields:
-			if p.curToken.Type != token.IDENT {
-				return p.query, fmt.Errorf("at INSERT INTO: expected at least one field to insert")
-			}
-			p.query.Fields = append(p.query.Fields, p.curToken.Literal)
-			p.step = stepInsertFieldsCommaOrClosingParens
-		case stepInsertFieldsCommaOrClosingParens:
-			if p.curToken.Type != token.COMMA && p.curToken.Type != token.RPAREN {
-				return p.query, fmt.Errorf("at INSERT INTO: expected comma or closing parens")
-			}
-			if p.curToken.Type == token.COMMA {
-				p.step = stepInsertFields
-			} else {
-				p.step = stepInsertValuesWord
-			}
-		case stepInsertValuesWord:
-			if p.curToken.Type != token.VALUES {
-				return p.query, fmt.Errorf("at INSERT INTO: expected 'VALUES'")
+		}
+		if p.curToken.Type != token.RPAREN {
+			return column, p.expected("CREATE TABLE", "closing parens for size value")
+		}
+		p.nextToken()
+	}
+
+	for p.curToken.Type != token.COMMA && p.curToken.Type != token.RPAREN && p.curToken.Type != token.SEMICOLON && p.curToken.Type != token.EOF {
+		switch p.curToken.Type {
+		case token.PRIMARY:
+			p.nextToken()
+			if p.curToken.Type != token.KEY {
+				return column, p.expected("CREATE TABLE", "key after primary keyword")
 			}
-			p.step = stepInsertValuesOpeningParens
-		case stepInsertValuesOpeningParens:
-			if p.curToken.Type != token.LPAREN {
-				return p.query, fmt.Errorf("at INSERT INTO: expected opening parens")
+			column.Constraints = append(column.Constraints, ColumnConstraint{Kind: PrimaryKeyConstraint})
+		case token.UNIQUE:
+			column.Constraints = append(column.Constraints, ColumnConstraint{Kind: UniqueConstraint})
+		case token.NOT:
+			p.nextToken()
+			if p.curToken.Type != token.NULL {
+				return column, p.expected("CREATE TABLE", "null keyword after not keyword")
 			}
-			p.query.Inserts = append(p.query.Inserts, []string{})
-			p.step = stepInsertValues
-		case stepInsertValues:
-			if p.curToken.Type != token.STRINGLITERAL && p.curToken.Type != token.NUMBERLITERAL && p.curToken.Type != token.BOOLLITERAL {
-				return p.query, fmt.Errorf("at INSERT INTO: expected value to insert string or number literal- (%s - %s)", p.curToken.Literal, p.curToken.Type)
+			column.Constraints = append(column.Constraints, ColumnConstraint{Kind: NotNullConstraint})
+		case token.NULL:
+			column.Constraints = append(column.Constraints, ColumnConstraint{Kind: NullConstraint})
+		case token.DEFAULT:
+			p.nextToken()
+			expr, err := p.parseExpr(precedenceLowest)
+			if err != nil {
+				return column, err
 			}
-			p.query.Inserts[len(p.query.Inserts)-1] = append(p.query.Inserts[len(p.query.Inserts)-1], p.curToken.Literal)
-			p.step = stepInsertValuesCommaOrClosingParens
-		case stepInsertValuesCommaOrClosingParens:
-			if p.curToken.Type != token.COMMA && p.curToken.Type != token.RPAREN {
-				return p.query, fmt.Errorf("at INSERT INTO: expected comma or closing parens")
+			column.Constraints = append(column.Constraints, ColumnConstraint{Kind: DefaultConstraint, Expr: expr})
+			continue
+		case token.GENERATED:
+			expr, err := p.parseGenerated()
+			if err != nil {
+				return column, err
 			}
-			if p.curToken.Type == token.COMMA {
-				p.step = stepInsertValues
-				break
+			column.Constraints = append(column.Constraints, ColumnConstraint{Kind: GeneratedConstraint, Expr: expr})
+		case token.CHECK:
+			expr, err := p.parseCheck()
+			if err != nil {
+				return column, err
 			}
-			currentInsertRow := p.query.Inserts[len(p.query.Inserts)-1]
-			if len(currentInsertRow) < len(p.query.Fields) {
-				return p.query, fmt.Errorf("at INSERT INTO: value count doesn't match field count")
+			column.Constraints = append(column.Constraints, ColumnConstraint{Kind: CheckConstraint, Expr: expr})
+		case token.REFERENCES:
+			fk := &ForeignKey{}
+			if err := p.parseReferences(fk); err != nil {
+				return column, err
 			}
-			p.step = stepInsertValuesCommaBeforeOpeningParens
-		case stepInsertValuesCommaBeforeOpeningParens:
-			if p.curToken.Type == token.SEMICOLON { //end of inserting values
-				return p.query, p.err
+			column.Constraints = append(column.Constraints, ColumnConstraint{Kind: ForeignKeyConstraint, ForeignKey: fk})
+		default:
+			return column, p.expected("CREATE TABLE", "constraint keyword")
+		}
+		p.nextToken()
+	}
+	return column, nil
+}
+
+// GENERATED ALWAYS AS (expr) STORED, stops at STORED
+func (p *parser) parseGenerated() (Expr, error) {
+	for _, keyword := range []token.TokenType{token.ALWAYS, token.AS, token.LPAREN} {
+		p.nextToken()
+		if p.curToken.Type != keyword {
+			return nil, p.expected("CREATE TABLE", fmt.Sprintf("%s in GENERATED ALWAYS AS (expression) STORED", keyword))
+		}
+	}
+	p.nextToken()
+	expr, err := p.parseExpr(precedenceLowest)
+	if err != nil {
+		return nil, err
+	}
+	if p.curToken.Type != token.RPAREN {
+		return nil, p.expected("CREATE TABLE", "closing parens for generation expression")
+	}
+	p.nextToken()
+	if p.curToken.Type != token.STORED {
+		return nil, p.expected("CREATE TABLE", "STORED after generation expression")
+	}
+	return expr, nil
+}
+
+// CHECK (expr), stops at the closing parens
+func (p *parser) parseCheck() (Expr, error) {
+	p.nextToken()
+	if p.curToken.Type != token.LPAREN {
+		return nil, p.expected("CREATE TABLE", "opening parens for check condition")
+	}
+	p.nextToken()
+	expr, err := p.parseExpr(precedenceLowest)
+	if err != nil {
+		return nil, err
+	}
+	if p.curToken.Type != token.RPAREN {
+		return nil, p.expected("CREATE TABLE", "closing parens for check condition")
+	}
+	return expr, nil
+}
+
+// FOREIGN KEY (column, ...) REFERENCES ... as a table constraint, stops at the last token of the references
+func (p *parser) parseForeignKey() (ForeignKey, error) {
+	fk := ForeignKey{}
+	p.nextToken()
+	if p.curToken.Type != token.KEY {
+		return fk, p.expected("CREATE TABLE", "key after foreign keyword")
+	}
+	p.nextToken()
+	columns, err := p.parseColumnList("CREATE TABLE", "foreign key column")
+	if err != nil {
+		return fk, err
+	}
+	fk.Columns = columns
+	p.nextToken()
+	if p.curToken.Type != token.REFERENCES {
+		return fk, p.expected("CREATE TABLE", "references after foreign key columns")
+	}
+	return fk, p.parseReferences(&fk)
+}
+
+// REFERENCES table [(column, ...)] [ON DELETE action] [ON UPDATE action], stops at the last token
+func (p *parser) parseReferences(fk *ForeignKey) error {
+	p.nextToken()
+	if p.curToken.Type != token.IDENT {
+		return p.expected("CREATE TABLE", "referenced table name")
+	}
+	fk.Table = p.curToken.Literal
+	if p.peekToken.Type == token.LPAREN {
+		p.nextToken()
+		columns, err := p.parseColumnList("CREATE TABLE", "referenced column")
+		if err != nil {
+			return err
+		}
+		fk.RefColumns = columns
+	}
+	for p.peekToken.Type == token.ON {
+		p.nextToken()
+		p.nextToken()
+		var action *ReferentialAction
+		switch p.curToken.Type {
+		case token.DELETE:
+			action = &fk.OnDelete
+		case token.UPDATE:
+			action = &fk.OnUpdate
+		default:
+			return p.expected("CREATE TABLE", "delete or update after on keyword")
+		}
+		p.nextToken()
+		switch {
+		case p.curToken.Type == token.CASCADE:
+			*action = Cascade
+		case p.curToken.Type == token.RESTRICT:
+			*action = Restrict
+		case p.curToken.Type == token.SET && p.peekToken.Type == token.NULL:
+			p.nextToken()
+			*action = SetNull
+		case p.curToken.Type == token.IDENT && strings.EqualFold(p.curToken.Literal, "NO") &&
+			p.peekToken.Type == token.IDENT && strings.EqualFold(p.peekToken.Literal, "ACTION"):
+			p.nextToken()
+			*action = NoAction
+		default:
+			return p.expected("CREATE TABLE", "CASCADE, RESTRICT, SET NULL or NO ACTION")
+		}
+	}
+	return nil
+}
+
+// (column, ...), stops at the closing parens
+func (p *parser) parseColumnList(clause, what string) ([]string, error) {
+	if p.curToken.Type != token.LPAREN {
+		return nil, p.expected(clause, "opening parens for "+what+"s")
+	}
+	p.nextToken()
+	var columns []string
+	for {
+		if p.curToken.Type != token.IDENT {
+			return nil, p.expected(clause, what)
+		}
+		columns = append(columns, p.curToken.Literal)
+		p.nextToken()
+		if p.curToken.Type == token.RPAREN {
+			return columns, nil
+		}
+		if p.curToken.Type != token.COMMA {
+			return nil, p.expected(clause, "comma or closing parens")
+		}
+		p.nextToken()
+	}
+}
+
+// DROP TABLE name | DROP INDEX name
+func (p *parser) parseDrop() (Statement, error) {
+	stmt := &DropTableStatement{}
+	p.nextToken()
+	if p.curToken.Type == token.INDEX {
+		return p.parseDropIndex()
+	}
+	if p.curToken.Type != token.TABLE {
+		return stmt, p.errorf("TABLE", "drop statement invalid at DROP")
+	}
+	p.nextToken()
+	if p.curToken.Type != token.IDENT {
+		return stmt, p.expected("DROP TABLE", "table name")
+	}
+	stmt.Name = p.curToken.Literal
+	if p.peekToken.Type != token.SEMICOLON {
+		return stmt, p.errorf(";", "at DROP TABLE: missing semicolon after table name")
+	}
+	p.nextToken()
+	return stmt, nil
+}
+
+// DROP INDEX name
+func (p *parser) parseDropIndex() (*DropIndexStatement, error) {
+	stmt := &DropIndexStatement{}
+	p.nextToken()
+	if p.curToken.Type != token.IDENT {
+		return stmt, p.expected("DROP INDEX", "index name")
+	}
+	stmt.Name = p.curToken.Literal
+	if p.peekToken.Type != token.SEMICOLON {
+		return stmt, p.errorf(";", "at DROP INDEX: missing semicolon after index name")
+	}
+	p.nextToken()
+	return stmt, nil
+}
+
+// ALTER TABLE table ADD [COLUMN] column | DROP [COLUMN] name | RENAME [COLUMN] name TO new | RENAME TO new
+func (p *parser) parseAlter() (*AlterTableStatement, error) {
+	stmt := &AlterTableStatement{}
+	p.nextToken()
+	if p.curToken.Type != token.TABLE {
+		return stmt, p.expected("ALTER", "table keyword")
+	}
+	p.nextToken()
+	if p.curToken.Type != token.IDENT {
+		return stmt, p.expected("ALTER TABLE", "table name")
+	}
+	stmt.Table = p.curToken.Literal
+	p.nextToken()
+	action := p.curToken.Type
+	p.nextToken()
+	//COLUMN isn't reserved, it is the keyword only when a name follows it
+	if p.curToken.Type == token.IDENT && strings.EqualFold(p.curToken.Literal, "COLUMN") && p.peekToken.Type == token.IDENT {
+		p.nextToken()
+	}
+	switch action {
+	case token.ADD:
+		stmt.Action = AddColumn
+		column, err := p.parseColumnDef()
+		if err != nil {
+			return stmt, err
+		}
+		stmt.Column = column
+		return stmt, nil
+	case token.DROP:
+		stmt.Action = DropColumn
+		if p.curToken.Type != token.IDENT {
+			return stmt, p.expected("ALTER TABLE", "column to drop")
+		}
+		stmt.Name = p.curToken.Literal
+		p.nextToken()
+		return stmt, nil
+	case token.RENAME:
+		stmt.Action = RenameTable
+		if p.curToken.Type == token.IDENT {
+			stmt.Action = RenameColumn
+			stmt.Name = p.curToken.Literal
+			p.nextToken()
+		}
+		if p.curToken.Type != token.TO {
+			return stmt, p.expected("ALTER TABLE", "to keyword")
+		}
+		p.nextToken()
+		if p.curToken.Type != token.IDENT {
+			return stmt, p.expected("ALTER TABLE", "new name")
+		}
+		stmt.NewName = p.curToken.Literal
+		p.nextToken()
+		return stmt, nil
+	}
+	return stmt, p.expected("ALTER TABLE", "ADD, DROP or RENAME")
+}
+
+// EXPLAIN [ANALYZE] statement
+func (p *parser) parseExplain() (*ExplainStatement, error) {
+	stmt := &ExplainStatement{}
+	p.nextToken()
+	if p.curToken.Type == token.ANALYZE {
+		stmt.Analyze = true
+		p.nextToken()
+	}
+	if p.curToken.Type == token.EXPLAIN {
+		return stmt, p.errorf("statement keyword", "at EXPLAIN: cannot explain EXPLAIN")
+	}
+	var err error
+	stmt.Statement, err = p.parseStatement()
+	return stmt, err
+}
+
+// ANALYZE [table]
+func (p *parser) parseAnalyze() (*AnalyzeStatement, error) {
+	stmt := &AnalyzeStatement{}
+	p.nextToken()
+	if p.curToken.Type == token.IDENT {
+		stmt.Table = p.curToken.Literal
+		p.nextToken()
+	} else if p.curToken.Type != token.SEMICOLON {
+		return stmt, p.expected("ANALYZE", "table name")
+	}
+	return stmt, nil
+}
+
+// table [[AS] alias]
+func (p *parser) parseTableName(clause string) (*TableName, error) {
+	if p.curToken.Type != token.IDENT {
+		return nil, p.expected(clause, "table name")
+	}
+	table := &TableName{Name: p.curToken.Literal}
+	p.nextToken()
+	if p.curToken.Type == token.AS {
+		p.nextToken()
+		if p.curToken.Type != token.IDENT {
+			return table, p.expected(clause, "table alias")
+		}
+	}
+	if p.curToken.Type == token.IDENT {
+		table.Alias = p.curToken.Literal
+		p.nextToken()
+	}
+	return table, nil
+}
+
+// parses WHERE clause if the current token starts one otherwise returns nil expression
+func (p *parser) parseOptionalWhere() (Expr, error) {
+	switch p.curToken.Type {
+	case token.SEMICOLON, token.RETURNING, token.ON: //ON starts the ON CONFLICT of INSERT ... SELECT
+		return nil, nil
+	}
+	if p.curToken.Type != token.WHERE {
+		return nil, p.errorf("WHERE", "expected WHERE")
+	}
+	p.nextToken()
+	if !p.startsExpr() {
+		return nil, p.expected("WHERE", "field")
+	}
+	where, err := p.parseExpr(precedenceLowest)
+	if err != nil {
+		return nil, err
+	}
+	// every condition of the WHERE clause must compare something, a bare column or literal is missing its operator
+	for _, cond := range splitConjunction(where) {
+		switch cond.(type) {
+		case *Identifier, *Literal:
+			return nil, p.errorf("comparison operator", "at WHERE: unknown operator")
+		}
+	}
+	return where, nil
+}
+
+//
+// # Expressions
+//
+
+const (
+	precedenceLowest = iota
+	precedenceOr
+	precedenceAnd
+	precedenceNot
+	precedenceCompare
+	precedenceSum
+	precedenceProduct
+	precedencePrefix
+	precedenceJSON
+)
+
+var infixPrecedence = map[token.TokenType]int{
+	token.OR:       precedenceOr,
+	token.AND:      precedenceAnd,
+	token.EQ:       precedenceCompare,
+	token.NOT_EQ:   precedenceCompare,
+	token.LT:       precedenceCompare,
+	token.GT:       precedenceCompare,
+	token.LTE:      precedenceCompare,
+	token.GTE:      precedenceCompare,
+	token.IS:       precedenceCompare,
+	token.IN:       precedenceCompare,
+	token.PLUS:     precedenceSum,
+	token.MINUS:    precedenceSum,
+	token.ASTERISK: precedenceProduct,
+	token.SLASH:    precedenceProduct,
+	token.ARROW:    precedenceJSON,
+	token.ARROW2:   precedenceJSON,
+}
+
+var infixOperators = map[token.TokenType]Operator{
+	token.OR:       Or,
+	token.AND:      And,
+	token.EQ:       Eq,
+	token.NOT_EQ:   Ne,
+	token.LT:       Lt,
+	token.GT:       Gt,
+	token.LTE:      Lte,
+	token.GTE:      Gte,
+	token.PLUS:     Plus,
+	token.MINUS:    Minus,
+	token.ASTERISK: Multiply,
+	token.SLASH:    Divide,
+	token.ARROW:    JSONGet,
+	token.ARROW2:   JSONGetText,
+}
+
+// reports whether the current token can be the first token of an expression
+func (p *parser) startsExpr() bool {
+	switch p.curToken.Type {
+	case token.IDENT, token.STRINGLITERAL, token.NUMBERLITERAL, token.HEXLITERAL, token.PARAM, token.TRUE, token.FALSE, token.NULL,
+		token.LPAREN, token.MINUS, token.NOT:
+		return true
+	case token.DATE, token.TIME, token.TIMESTAMP, token.INTERVAL:
+		return p.peekToken.Type == token.STRINGLITERAL
+	}
+	return false
+}
+
+// precedence of the current token when used as an infix operator, NOT only counts when followed by IN
+func (p *parser) curPrecedence() int {
+	if p.curToken.Type == token.NOT && p.peekToken.Type == token.IN {
+		return precedenceCompare
+	}
+	return infixPrecedence[p.curToken.Type]
+}
+
+func (p *parser) parseExpr(precedence int) (Expr, error) {
+	left, err := p.parsePrefix()
+	if err != nil {
+		return nil, err
+	}
+	for precedence < p.curPrecedence() {
+		left, err = p.parseInfix(left)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return left, nil
+}
+
+// literals written as a type keyword followed by a string
+var typedLiteralKinds = map[token.TokenType]LiteralKind{
+	token.DATE:      DateLiteral,
+	token.TIME:      TimeLiteral,
+	token.TIMESTAMP: TimestampLiteral,
+	token.INTERVAL:  IntervalLiteral,
+}
+
+// literal of a value bound to a placeholder, values are of the types database/sql hands to a driver
+func argLiteral(v any) (*Literal, error) {
+	switch v := v.(type) {
+	case nil:
+		return &Literal{Kind: NullLiteral, Value: "NULL"}, nil
+	case int64:
+		return &Literal{Kind: NumberLiteral, Value: strconv.FormatInt(v, 10)}, nil
+	case uint64:
+		return &Literal{Kind: NumberLiteral, Value: strconv.FormatUint(v, 10)}, nil
+	case float64:
+		text := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(text, ".eIN") { //a whole float keeps its point as it would be written in sql
+			text += ".0"
+		}
+		return &Literal{Kind: NumberLiteral, Value: text}, nil
+	case bool:
+		return &Literal{Kind: BoolLiteral, Value: strings.ToUpper(strconv.FormatBool(v))}, nil
+	case string:
+		return &Literal{Kind: StringLiteral, Value: v}, nil
+	case []byte:
+		return &Literal{Kind: HexLiteral, Value: hex.EncodeToString(v)}, nil
+	case time.Time:
+		return &Literal{Kind: TimestampLiteral, Value: v.Format(time.RFC3339Nano)}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %T", v)
+}
+
+func (p *parser) parsePrefix() (Expr, error) {
+	switch p.curToken.Type {
+	case token.IDENT:
+		return p.parseIdentifier()
+	case token.STRINGLITERAL:
+		lit := &Literal{Kind: StringLiteral, Value: p.curToken.Literal}
+		p.nextToken()
+		return lit, nil
+	case token.NUMBERLITERAL:
+		lit := &Literal{Kind: NumberLiteral, Value: p.curToken.Literal}
+		p.nextToken()
+		return lit, nil
+	case token.HEXLITERAL:
+		lit := &Literal{Kind: HexLiteral, Value: p.curToken.Literal}
+		p.nextToken()
+		return lit, nil
+	case token.PARAM:
+		if p.nextArg == len(p.args) {
+			return nil, p.errorf("", "no argument given for placeholder %d", p.nextArg+1)
+		}
+		lit, err := argLiteral(p.args[p.nextArg])
+		if err != nil {
+			return nil, p.errorf("", "argument %d: %v", p.nextArg+1, err)
+		}
+		p.nextArg++
+		p.nextToken()
+		return lit, nil
+	case token.TRUE, token.FALSE:
+		lit := &Literal{Kind: BoolLiteral, Value: strings.ToUpper(p.curToken.Literal)}
+		p.nextToken()
+		return lit, nil
+	case token.NULL:
+		p.nextToken()
+		return &Literal{Kind: NullLiteral, Value: "NULL"}, nil
+	case token.DATE, token.TIME, token.TIMESTAMP, token.INTERVAL:
+		keyword, kind := p.curToken.Type, typedLiteralKinds[p.curToken.Type]
+		p.nextToken()
+		if p.curToken.Type != token.STRINGLITERAL {
+			return nil, p.errorf("string", "expected quoted value after %s", keyword)
+		}
+		lit := &Literal{Kind: kind, Value: p.curToken.Literal}
+		p.nextToken()
+		return lit, nil
+	case token.LPAREN:
+		p.nextToken()
+		expr, err := p.parseExpr(precedenceLowest)
+		if err != nil {
+			return nil, err
+		}
+		if p.curToken.Type != token.RPAREN {
+			return nil, p.errorf(")", "expected closing parens in expression")
+		}
+		p.nextToken()
+		return expr, nil
+	case token.MINUS:
+		p.nextToken()
+		if p.curToken.Type == token.NUMBERLITERAL { // negative number literals are folded
+			lit := &Literal{Kind: NumberLiteral, Value: "-" + p.curToken.Literal}
+			p.nextToken()
+			return lit, nil
+		}
+		expr, err := p.parseExpr(precedencePrefix)
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Operator: Minus, Expr: expr}, nil
+	case token.NOT:
+		p.nextToken()
+		expr, err := p.parseExpr(precedenceNot)
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Operator: Not, Expr: expr}, nil
+	default:
+		return nil, p.errorf("expression", "expected expression")
+	}
+}
+
+// column, table.column or function(args)
+func (p *parser) parseIdentifier() (Expr, error) {
+	name := p.curToken.Literal
+	p.nextToken()
+	switch p.curToken.Type {
+	case token.PERIOD:
+		p.nextToken()
+		if p.curToken.Type != token.IDENT {
+			return nil, p.errorf("column name", "expected column name after %q", name+".")
+		}
+		ident := &Identifier{Table: name, Name: p.curToken.Literal}
+		p.nextToken()
+		return ident, nil
+	case token.LPAREN:
+		return p.parseFuncCall(strings.ToUpper(name))
+	}
+	return &Identifier{Name: name}, nil
+}
+
+func (p *parser) parseFuncCall(name string) (Expr, error) {
+	call := &FuncCall{Name: name}
+	p.nextToken()
+	if p.curToken.Type == token.ASTERISK {
+		call.Star = true
+		p.nextToken()
+	} else if p.curToken.Type != token.RPAREN {
+		for {
+			arg, err := p.parseExpr(precedenceLowest)
+			if err != nil {
+				return nil, err
 			}
+			call.Args = append(call.Args, arg)
 			if p.curToken.Type != token.COMMA {
-				return p.query, fmt.Errorf("at INSERT INTO: expected comma")
-			}
-			p.step = stepInsertValuesOpeningParens
-		// Create steps
-		case stepCreateTable:
-			if p.curToken.Type != token.IDENT {
-				return p.query, fmt.Errorf("at CREATE TABLE: expected quoted table name")
-			}
-			p.query.TableName = p.curToken.Literal
-			p.step = stepCreateFieldsOpeningParens
-		case stepCreateFieldsOpeningParens:
-			if p.curToken.Type != token.LPAREN {
-				return p.query, fmt.Errorf("at CREATE TABLE: expected opening parens")
-			}
-			p.step = stepCreateFields
-		case stepCreateFields:
-			if p.curToken.Type != token.IDENT {
-				return p.query, fmt.Errorf("at CREATE TABLE: expected field to CREATE")
-			}
-			p.query.Fields = append(p.query.Fields, p.curToken.Literal)
-			p.query.TableConstruction.fieldsWTypes = append(p.query.TableConstruction.fieldsWTypes, []string{p.curToken.Literal})
-			p.step = stepCreateColumnType
-		case stepCreateColumnType:
-			if !token.LookupDataType(p.curToken.Type) {
-				return p.query, fmt.Errorf("at CREATE TABLE: expected valid data type for column")
-			}
-			lastpos := len(p.query.TableConstruction.fieldsWTypes) - 1
-			p.query.TableConstruction.fieldsWTypes[lastpos] = append(p.query.TableConstruction.fieldsWTypes[lastpos], strings.ToUpper(p.curToken.Literal))
-			if p.peekToken.Type == token.LPAREN {
-				p.step = stepCreateColumnSize
-				p.nextToken() //consumes the left parentheses
-			} else if p.peekToken.Type == token.COMMA {
-				p.step = stepCreateFields
-				p.nextToken() //consumes the comma
-			} else if p.peekToken.Type == token.RPAREN {
-				p.nextToken()
-				if p.peekToken.Type != token.SEMICOLON {
-					return p.query, errors.New("at CREATE TABLE: expected semicolon to end sql")
-				}
-				return p.query, p.err
-			} else {
-				p.step = stepCreateConstraints
-			}
-		case stepCreateColumnSize:
-			if p.curToken.Type != token.NUMBERLITERAL {
-				return p.query, errors.New("at CREATE TABLE: expected number for datatype size")
-			}
-			lastpos := len(p.query.TableConstruction.fieldsWTypes) - 1
-			p.query.TableConstruction.fieldsWTypes[lastpos] = append(p.query.TableConstruction.fieldsWTypes[lastpos], p.curToken.Literal)
-			if p.peekToken.Type != token.RPAREN {
-				return p.query, fmt.Errorf("at CREATE TABLE: expected closing parens for size value")
+				break
 			}
 			p.nextToken()
-			if p.peekToken.Type == token.COMMA {
-				p.step = stepCreateFields
-				p.nextToken()
-			} else if p.peekToken.Type == token.RPAREN {
-				p.nextToken()
-				if p.peekToken.Type != token.SEMICOLON {
-					return p.query, errors.New("at CREATE TABLE: expected semicolon to end sql")
-				}
-				return p.query, p.err
-			} else {
-				p.step = stepCreateConstraints
-			}
-		case stepCreateConstraints:
-			if p.curToken.Type == token.PRIMARY {
-				p.nextToken()
-				if p.curToken.Type != token.KEY {
-					return p.query, fmt.Errorf("at CREATE TABLE: expected key after primary keyword")
-				}
-				p.query.TableConstruction.primary = append(p.query.TableConstruction.primary, p.query.Fields[len(p.query.Fields)-1])
-			} else if p.curToken.Type == token.UNIQUE {
-				p.query.TableConstruction.unique = append(p.query.TableConstruction.unique, p.query.Fields[len(p.query.Fields)-1])
-			} else if p.curToken.Type == token.NOT {
-				p.nextToken()
-				if p.curToken.Type != token.NULL {
-					return p.query, fmt.Errorf("at CREATE TABLE: expected null keyword after not keyword")
-				}
-				p.query.TableConstruction.notnullable = append(p.query.TableConstruction.notnullable, p.query.Fields[len(p.query.Fields)-1])
-			} else if p.curToken.Type == token.NULL {
-				p.query.TableConstruction.nullable = append(p.query.TableConstruction.nullable, p.query.Fields[len(p.query.Fields)-1])
-			} else {
-				return p.query, fmt.Errorf("at CREATE TABLE: expected constraint keyword")
-			}
+		}
+	}
+	if p.curToken.Type != token.RPAREN {
+		return nil, p.errorf(")", "expected closing parens for arguments of %s", name)
+	}
+	p.nextToken()
+	return call, nil
+}
 
-			if p.peekToken.Type == token.COMMA {
-				p.step = stepCreateFields
-				p.nextToken() //consumes comma
-			} else if p.peekToken.Type == token.RPAREN {
-				p.nextToken()
-				if p.peekToken.Type != token.SEMICOLON {
-					return p.query, errors.New("at CREATE TABLE: expected semicolon to end sql string")
-				}
-				return p.query, p.err
-			} else {
-				p.step = stepCreateConstraints
-			}
-		case stepDropTable:
-			if p.curToken.Type != token.IDENT {
-				return p.query, fmt.Errorf("at DROP TABLE: expected table name")
+func (p *parser) parseInfix(left Expr) (Expr, error) {
+	switch p.curToken.Type {
+	case token.IS:
+		p.nextToken()
+		isNull := &IsNullExpr{Expr: left}
+		if p.curToken.Type == token.NOT {
+			isNull.Not = true
+			p.nextToken()
+		}
+		if p.curToken.Type != token.NULL {
+			return nil, p.errorf("NULL", "expected NULL after IS")
+		}
+		p.nextToken()
+		return isNull, nil
+	case token.NOT, token.IN:
+		in := &InExpr{Expr: left}
+		if p.curToken.Type == token.NOT {
+			in.Not = true
+			p.nextToken()
+		}
+		p.nextToken()
+		if p.curToken.Type != token.LPAREN {
+			return nil, p.errorf("(", "expected opening parens after IN")
+		}
+		p.nextToken()
+		for {
+			item, err := p.parseExpr(precedenceLowest)
+			if err != nil {
+				return nil, err
 			}
-			p.query.TableName = p.curToken.Literal
-			if p.peekToken.Type != token.SEMICOLON {
-				return p.query, errors.New("at DROP TABLE: missing semicolon after table name")
+			in.List = append(in.List, item)
+			if p.curToken.Type != token.COMMA {
+				break
 			}
-			return p.query, p.err
+			p.nextToken()
+		}
+		if p.curToken.Type != token.RPAREN {
+			return nil, p.errorf(")", "expected closing parens for IN list")
 		}
 		p.nextToken()
+		return in, nil
+	}
+
+	precedence := p.curPrecedence()
+	op := infixOperators[p.curToken.Type]
+	p.nextToken()
+	if !p.startsExpr() {
+		return nil, p.errorf("expression", "expected expression after operator")
+	}
+	right, err := p.parseExpr(precedence)
+	if err != nil {
+		return nil, err
 	}
-	return p.query, p.err
+	return &BinaryExpr{Operator: op, Left: left, Right: right}, nil
 }
 
-func (p *parser) validate() error {
-	if len(p.query.Conditions) == 0 && p.step == stepWhereField {
-		return fmt.Errorf("at WHERE: empty WHERE clause")
-	} else if p.query.Type == UnknownType {
-		return fmt.Errorf("query type cannot be empty")
-	} else if p.query.TableName == "" {
-		return fmt.Errorf("table name cannot be empty")
-	} else if len(p.query.Conditions) == 0 && (p.query.Type == Update || p.query.Type == Delete) {
-		return fmt.Errorf("at WHERE: WHERE clause is mandatory for UPDATE & DELETE")
+// splits an expression on its top level AND operators
+func splitConjunction(expr Expr) []Expr {
+	if bin, ok := expr.(*BinaryExpr); ok && bin.Operator == And {
+		return append(splitConjunction(bin.Left), splitConjunction(bin.Right)...)
 	}
-	for _, c := range p.query.Conditions {
-		if c.Operator == UnknownOperator {
-			return fmt.Errorf("at WHERE: condition without operator")
-		}
-		if c.Operand1 == "" && c.Operand1IsField {
-			return fmt.Errorf("at WHERE: condition with empty left side operand")
-		}
-		if c.Operand2 == "" && c.Operand2IsField {
-			return fmt.Errorf("at WHERE: condition with empty right side operand")
-		}
+	return []Expr{expr}
+}
+
+func selectItemName(item SelectItem) string {
+	if ident, ok := item.Expr.(*Identifier); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+func (p *parser) validate(q Query) error {
+	if explain, ok := q.Statement.(*ExplainStatement); ok {
+		return p.validate(lowerStatement(explain.Statement))
 	}
-	if p.query.Type == Insert && len(p.query.Inserts) == 0 {
-		return fmt.Errorf("at INSERT INTO: need at least one row to insert")
+	if q.Type == UnknownType {
+		return p.errorf("", "query type cannot be empty")
+	} else if q.TableName == "" && q.Type != Analyze && q.Type != DropIndex {
+		return p.errorf("", "table name cannot be empty")
+	} else if q.where() == nil && (q.Type == Update || q.Type == Delete) {
+		return p.errorf("", "at WHERE: WHERE clause is mandatory for UPDATE & DELETE")
 	}
-	if p.query.Type == Insert {
-		for _, i := range p.query.Inserts {
-			if len(i) != len(p.query.Fields) {
-				return fmt.Errorf("at INSERT INTO: value count doesn't match field count")
+	if stmt, ok := q.Statement.(*AlterTableStatement); ok && stmt.Action == AddColumn {
+		for _, constraint := range stmt.Column.Constraints {
+			if constraint.Kind == PrimaryKeyConstraint {
+				return p.errorf("", "at ALTER TABLE: cannot add primary key column %q", stmt.Column.Name)
 			}
 		}
 	}
-	if p.query.Type == Create && len(p.query.Fields) == 0 {
-		return fmt.Errorf("at CREATE TABLE: can't have empty table")
-	} else if p.query.Type == Create {
-		intersection := intersectGeneric(p.query.TableConstruction.nullable, p.query.TableConstruction.notnullable)
+	if stmt, ok := q.Statement.(*CreateTableStatement); ok {
+		primaryKeys := 0
+		if len(stmt.PrimaryKey) > 0 {
+			primaryKeys++
+		}
+		for _, col := range stmt.Columns {
+			if slices.Contains(col.Constraints, ColumnConstraint{Kind: PrimaryKeyConstraint}) {
+				primaryKeys++
+			}
+		}
+		if primaryKeys > 1 {
+			return p.errorf("", "at CREATE TABLE: multiple primary keys for table %q are not allowed", stmt.Name)
+		}
+	}
+	if q.Type == Create {
+		intersection := intersectGeneric(q.TableConstruction.nullable, q.TableConstruction.notnullable)
 		if len(intersection) > 0 {
-			return fmt.Errorf("at CREATE TABLE: cannot have column be both nullable and non-nullable for columns: %v", intersection)
+			return p.errorf("", "at CREATE TABLE: cannot have column be both nullable and non-nullable for columns: %v", intersection)
 		}
 	}
 	return nil
 }
 
-func (p *parser) logError() {
-	if p.err == nil {
-		return
+// ParseError describes why a sql string could not be parsed and where in the string it happened.
+// Line and Column are 1-based and point at the start of the offending token.
+type ParseError struct {
+	Line     int
+	Column   int
+	Token    token.Token // token the parser stopped at
+	Expected string      // what the parser was looking for instead of Token, empty if unknown
+	Msg      string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s (line %d, column %d)", e.Msg, e.Line, e.Column)
+}
+
+// expected reports that the current token is not what the clause requires
+func (p *parser) expected(clause, what string) error {
+	return p.expectedAt(p.curToken, clause, what)
+}
+
+func (p *parser) expectedAt(tok token.Token, clause, what string) error {
+	if tok.Type == token.ILLEGAL {
+		return &ParseError{Line: tok.Line, Column: tok.Column, Token: tok, Expected: what, Msg: "unknown token in sql string"}
+	}
+	return &ParseError{Line: tok.Line, Column: tok.Column, Token: tok, Expected: what, Msg: fmt.Sprintf("at %s: expected %s", clause, what)}
+}
+
+// errorf reports an error at the current token
+func (p *parser) errorf(expected string, format string, args ...any) error {
+	if p.curToken.Type == token.ILLEGAL {
+		format, args = "unknown token in sql string", nil
 	}
-	fmt.Println(p.sql)
-	fmt.Println(strings.Repeat(" ", p.i) + "^")
-	fmt.Println(p.err)
+	return &ParseError{Line: p.curToken.Line, Column: p.curToken.Column, Token: p.curToken, Expected: expected, Msg: fmt.Sprintf(format, args...)}
 }
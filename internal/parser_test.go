@@ -2,7 +2,9 @@ package internal
 
 import (
 	"fmt"
+	"math"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -14,219 +16,219 @@ type testCase struct {
 	Err      error
 }
 
-func TestSelectSQL(t *testing.T) {
-	ts := []testCase{
-		{
-			Name:     "empty query fails",
-			SQL:      "",
-			Expected: Query{},
-			Err:      fmt.Errorf("query type cannot be empty"),
-		},
-		{
-			Name:     "SELECT without FROM fails",
-			SQL:      "SELECT;",
-			Expected: Query{Type: Select},
-			Err:      fmt.Errorf("at SELECT: expected field to SELECT"),
-		},
-		{
-			Name:     "SELECT without fields fails",
-			SQL:      "SELECT FROM a;",
-			Expected: Query{Type: Select},
-			Err:      fmt.Errorf("at SELECT: expected field to SELECT"),
-		},
-		{
-			Name:     "SELECT with comma and empty field fails",
-			SQL:      "SELECT b, FROM a;",
-			Expected: Query{Type: Select},
-			Err:      fmt.Errorf("at SELECT: expected field to SELECT"),
-		},
-		{
-			Name:     "SELECT works",
-			SQL:      "SELECT a FROM b;",
-			Expected: Query{Type: Select, TableName: "b", Fields: []string{"a"}},
-			Err:      nil,
-		},
-		{
-			Name:     "SELECT works with lowercase",
-			SQL:      "select a fRoM \"b\";",
-			Expected: Query{Type: Select, TableName: "b", Fields: []string{"a"}},
-			Err:      nil,
-		},
-		{
-			Name:     "SELECT many fields works",
-			SQL:      "SELECT a, c, d FROM b;",
-			Expected: Query{Type: Select, TableName: "b", Fields: []string{"a", "c", "d"}},
-			Err:      nil,
-		},
-		{
-			Name: "SELECT with alias works",
-			SQL:  "SELECT a as z, b as y, c FROM \"b\";",
-			Expected: Query{
-				Type:      Select,
-				TableName: "b",
-				Fields:    []string{"a", "b", "c"},
-				Aliases: map[string]string{
-					"a": "z",
-					"b": "y",
-				},
+var selectTestCases = []testCase{
+	{
+		Name:     "empty query fails",
+		SQL:      "",
+		Expected: Query{},
+		Err:      fmt.Errorf("query type cannot be empty"),
+	},
+	{
+		Name:     "SELECT without FROM fails",
+		SQL:      "SELECT;",
+		Expected: Query{Type: Select},
+		Err:      fmt.Errorf("at SELECT: expected field to SELECT"),
+	},
+	{
+		Name:     "SELECT without fields fails",
+		SQL:      "SELECT FROM a;",
+		Expected: Query{Type: Select},
+		Err:      fmt.Errorf("at SELECT: expected field to SELECT"),
+	},
+	{
+		Name:     "SELECT with comma and empty field fails",
+		SQL:      "SELECT b, FROM a;",
+		Expected: Query{Type: Select},
+		Err:      fmt.Errorf("at SELECT: expected field to SELECT"),
+	},
+	{
+		Name:     "SELECT works",
+		SQL:      "SELECT a FROM b;",
+		Expected: Query{Type: Select, TableName: "b", Fields: []string{"a"}},
+		Err:      nil,
+	},
+	{
+		Name:     "SELECT works with lowercase",
+		SQL:      "select a fRoM \"b\";",
+		Expected: Query{Type: Select, TableName: "b", Fields: []string{"a"}},
+		Err:      nil,
+	},
+	{
+		Name:     "SELECT many fields works",
+		SQL:      "SELECT a, c, d FROM b;",
+		Expected: Query{Type: Select, TableName: "b", Fields: []string{"a", "c", "d"}},
+		Err:      nil,
+	},
+	{
+		Name: "SELECT with alias works",
+		SQL:  "SELECT a as z, b as y, c FROM \"b\";",
+		Expected: Query{
+			Type:      Select,
+			TableName: "b",
+			Fields:    []string{"a", "b", "c"},
+			Aliases: map[string]string{
+				"a": "z",
+				"b": "y",
 			},
-			Err: nil,
-		},
-		{
-			Name:     "SELECT with empty WHERE fails",
-			SQL:      "SELECT a, c, d FROM b WHERE;",
-			Expected: Query{Type: Select, TableName: "b", Fields: []string{"a", "c", "d"}},
-			Err:      fmt.Errorf("at WHERE: expected field"),
 		},
-		{
-			Name:     "SELECT with WHERE with only operand fails",
-			SQL:      "SELECT a, c, d FROM b WHERE a;",
-			Expected: Query{Type: Select, TableName: "b", Fields: []string{"a", "c", "d"}},
-			Err:      fmt.Errorf("at WHERE: unknown operator"),
-		},
-		{
-			Name: "SELECT with WHERE with = works",
-			SQL:  "SELECT a, c, d FROM b WHERE a = '';",
-			Expected: Query{
-				Type:      Select,
-				TableName: "b",
-				Fields:    []string{"a", "c", "d"},
-				Conditions: []Condition{
-					{Operand1: "a", Operand1IsField: true, Operator: Eq, Operand2: "", Operand2IsField: false},
-				},
+		Err: nil,
+	},
+	{
+		Name:     "SELECT with empty WHERE fails",
+		SQL:      "SELECT a, c, d FROM b WHERE;",
+		Expected: Query{Type: Select, TableName: "b", Fields: []string{"a", "c", "d"}},
+		Err:      fmt.Errorf("at WHERE: expected field"),
+	},
+	{
+		Name:     "SELECT with WHERE with only operand fails",
+		SQL:      "SELECT a, c, d FROM b WHERE a;",
+		Expected: Query{Type: Select, TableName: "b", Fields: []string{"a", "c", "d"}},
+		Err:      fmt.Errorf("at WHERE: unknown operator"),
+	},
+	{
+		Name: "SELECT with WHERE with = works",
+		SQL:  "SELECT a, c, d FROM b WHERE a = '';",
+		Expected: Query{
+			Type:      Select,
+			TableName: "b",
+			Fields:    []string{"a", "c", "d"},
+			Conditions: []Condition{
+				{Operand1: "a", Operand1IsField: true, Operator: Eq, Operand2: "", Operand2IsField: false},
 			},
-			Err: nil,
 		},
-		{
-			Name: "SELECT with WHERE with < works",
-			SQL:  "SELECT a, c, d FROM \"b\" WHERE a < 1;",
-			Expected: Query{
-				Type:      Select,
-				TableName: "b",
-				Fields:    []string{"a", "c", "d"},
-				Conditions: []Condition{
-					{Operand1: "a", Operand1IsField: true, Operator: Lt, Operand2: "1", Operand2IsField: false},
-				},
+		Err: nil,
+	},
+	{
+		Name: "SELECT with WHERE with < works",
+		SQL:  "SELECT a, c, d FROM \"b\" WHERE a < 1;",
+		Expected: Query{
+			Type:      Select,
+			TableName: "b",
+			Fields:    []string{"a", "c", "d"},
+			Conditions: []Condition{
+				{Operand1: "a", Operand1IsField: true, Operator: Lt, Operand2: "1", Operand2IsField: false},
 			},
-			Err: nil,
 		},
-		{
-			Name: "SELECT with WHERE with <= works",
-			SQL:  "SELECT a, c, d FROM b WHERE a <= 1;",
-			Expected: Query{
-				Type:      Select,
-				TableName: "b",
-				Fields:    []string{"a", "c", "d"},
-				Conditions: []Condition{
-					{Operand1: "a", Operand1IsField: true, Operator: Lte, Operand2: "1", Operand2IsField: false},
-				},
+		Err: nil,
+	},
+	{
+		Name: "SELECT with WHERE with <= works",
+		SQL:  "SELECT a, c, d FROM b WHERE a <= 1;",
+		Expected: Query{
+			Type:      Select,
+			TableName: "b",
+			Fields:    []string{"a", "c", "d"},
+			Conditions: []Condition{
+				{Operand1: "a", Operand1IsField: true, Operator: Lte, Operand2: "1", Operand2IsField: false},
 			},
-			Err: nil,
 		},
-		{
-			Name: "SELECT with WHERE with boolean value works",
-			SQL:  "SELECT a, c, d FROM b WHERE a = true;",
-			Expected: Query{
-				Type:      Select,
-				TableName: "b",
-				Fields:    []string{"a", "c", "d"},
-				Conditions: []Condition{
-					{Operand1: "a", Operand1IsField: true, Operator: Eq, Operand2: "TRUE", Operand2IsField: false},
-				},
+		Err: nil,
+	},
+	{
+		Name: "SELECT with WHERE with boolean value works",
+		SQL:  "SELECT a, c, d FROM b WHERE a = true;",
+		Expected: Query{
+			Type:      Select,
+			TableName: "b",
+			Fields:    []string{"a", "c", "d"},
+			Conditions: []Condition{
+				{Operand1: "a", Operand1IsField: true, Operator: Eq, Operand2: "TRUE", Operand2IsField: false},
 			},
-			Err: nil,
 		},
-		{
-			Name: "SELECT with WHERE with > works",
-			SQL:  "SELECT a, c, d FROM b WHERE a > 1;",
-			Expected: Query{
-				Type:      Select,
-				TableName: "b",
-				Fields:    []string{"a", "c", "d"},
-				Conditions: []Condition{
-					{Operand1: "a", Operand1IsField: true, Operator: Gt, Operand2: "1", Operand2IsField: false},
-				},
+		Err: nil,
+	},
+	{
+		Name: "SELECT with WHERE with > works",
+		SQL:  "SELECT a, c, d FROM b WHERE a > 1;",
+		Expected: Query{
+			Type:      Select,
+			TableName: "b",
+			Fields:    []string{"a", "c", "d"},
+			Conditions: []Condition{
+				{Operand1: "a", Operand1IsField: true, Operator: Gt, Operand2: "1", Operand2IsField: false},
 			},
-			Err: nil,
 		},
-		{
-			Name: "SELECT with WHERE with >= works",
-			SQL:  "SELECT a, c, d FROM b WHERE a >= 1;",
-			Expected: Query{
-				Type:      Select,
-				TableName: "b",
-				Fields:    []string{"a", "c", "d"},
-				Conditions: []Condition{
-					{Operand1: "a", Operand1IsField: true, Operator: Gte, Operand2: "1", Operand2IsField: false},
-				},
+		Err: nil,
+	},
+	{
+		Name: "SELECT with WHERE with >= works",
+		SQL:  "SELECT a, c, d FROM b WHERE a >= 1;",
+		Expected: Query{
+			Type:      Select,
+			TableName: "b",
+			Fields:    []string{"a", "c", "d"},
+			Conditions: []Condition{
+				{Operand1: "a", Operand1IsField: true, Operator: Gte, Operand2: "1", Operand2IsField: false},
 			},
-			Err: nil,
 		},
-		{
-			Name: "SELECT with WHERE with != works",
-			SQL:  "SELECT a, c,	 d FROM b WHERE a != '1';",
-			Expected: Query{
-				Type:      Select,
-				TableName: "b",
-				Fields:    []string{"a", "c", "d"},
-				Conditions: []Condition{
-					{Operand1: "a", Operand1IsField: true, Operator: Ne, Operand2: "1", Operand2IsField: false},
-				},
+		Err: nil,
+	},
+	{
+		Name: "SELECT with WHERE with != works",
+		SQL:  "SELECT a, c,	 d FROM b WHERE a != '1';",
+		Expected: Query{
+			Type:      Select,
+			TableName: "b",
+			Fields:    []string{"a", "c", "d"},
+			Conditions: []Condition{
+				{Operand1: "a", Operand1IsField: true, Operator: Ne, Operand2: "1", Operand2IsField: false},
 			},
-			Err: nil,
 		},
-		{
-			Name: "SELECT with WHERE with != works (comparing field against another field)",
-			SQL:  "SELECT a, c, d FROM b WHERE a != \"b\";",
-			Expected: Query{
-				Type:      Select,
-				TableName: "b",
-				Fields:    []string{"a", "c", "d"},
-				Conditions: []Condition{
-					{Operand1: "a", Operand1IsField: true, Operator: Ne, Operand2: "b", Operand2IsField: true},
-				},
+		Err: nil,
+	},
+	{
+		Name: "SELECT with WHERE with != works (comparing field against another field)",
+		SQL:  "SELECT a, c, d FROM b WHERE a != \"b\";",
+		Expected: Query{
+			Type:      Select,
+			TableName: "b",
+			Fields:    []string{"a", "c", "d"},
+			Conditions: []Condition{
+				{Operand1: "a", Operand1IsField: true, Operator: Ne, Operand2: "b", Operand2IsField: true},
 			},
-			Err: nil,
 		},
-		{
-			Name: "SELECT * works",
-			SQL:  "SELECT * FROM b;",
-			Expected: Query{
-				Type:       Select,
-				TableName:  "b",
-				Fields:     []string{"*"},
-				Conditions: nil,
-			},
-			Err: nil,
+		Err: nil,
+	},
+	{
+		Name: "SELECT * works",
+		SQL:  "SELECT * FROM b;",
+		Expected: Query{
+			Type:       Select,
+			TableName:  "b",
+			Fields:     []string{"*"},
+			Conditions: nil,
 		},
-		{
-			Name: "SELECT a, * works",
-			SQL:  "SELECT a, * FROM b;",
-			Expected: Query{
-				Type:       Select,
-				TableName:  "b",
-				Fields:     []string{"a", "*"},
-				Conditions: nil,
-			},
-			Err: nil,
+		Err: nil,
+	},
+	{
+		Name: "SELECT a, * works",
+		SQL:  "SELECT a, * FROM b;",
+		Expected: Query{
+			Type:       Select,
+			TableName:  "b",
+			Fields:     []string{"a", "*"},
+			Conditions: nil,
 		},
-		{
-			Name: "SELECT with WHERE with two conditions using AND works",
-			SQL:  "SELECT a, c, d FROM b WHERE a != 1 AND b = '2';",
-			Expected: Query{
-				Type:      Select,
-				TableName: "b",
-				Fields:    []string{"a", "c", "d"},
-				Conditions: []Condition{
-					{Operand1: "a", Operand1IsField: true, Operator: Ne, Operand2: "1", Operand2IsField: false},
-					{Operand1: "b", Operand1IsField: true, Operator: Eq, Operand2: "2", Operand2IsField: false},
-				},
+		Err: nil,
+	},
+	{
+		Name: "SELECT with WHERE with two conditions using AND works",
+		SQL:  "SELECT a, c, d FROM b WHERE a != 1 AND b = '2';",
+		Expected: Query{
+			Type:      Select,
+			TableName: "b",
+			Fields:    []string{"a", "c", "d"},
+			Conditions: []Condition{
+				{Operand1: "a", Operand1IsField: true, Operator: Ne, Operand2: "1", Operand2IsField: false},
+				{Operand1: "b", Operand1IsField: true, Operator: Eq, Operand2: "2", Operand2IsField: false},
 			},
-			Err: nil,
 		},
-	}
+		Err: nil,
+	},
+}
 
-	for _, tc := range ts {
+func TestSelectSQL(t *testing.T) {
+	for _, tc := range selectTestCases {
 		t.Run(tc.Name, func(t *testing.T) {
 			_, err := Parse(tc.SQL)
 			if tc.Err != nil && err == nil {
@@ -236,112 +238,114 @@ func TestSelectSQL(t *testing.T) {
 				t.Errorf("Error should have been nil but was %v", err)
 			}
 			if tc.Err != nil && err != nil {
-				require.Equal(t, tc.Err, err, "Unexpected error")
+				var perr *ParseError
+				require.ErrorAs(t, err, &perr)
+				require.Equal(t, tc.Err.Error(), perr.Msg, "Unexpected error")
 			}
 		})
 	}
 }
 
-func TestUpdateSQL(t *testing.T) {
-	ts := []testCase{
-		{
-			Name:     "Empty UPDATE fails",
-			SQL:      "UPDATE;",
-			Expected: Query{},
-			Err:      fmt.Errorf("at UPDATE: expected table name"),
-		},
-		{
-			Name:     "Incomplete UPDATE with table name fails",
-			SQL:      "UPDATE a;",
-			Expected: Query{},
-			Err:      fmt.Errorf("at UPDATE: expected 'SET'"),
-		},
-		{
-			Name:     "Incomplete UPDATE with table name and SET fails",
-			SQL:      "UPDATE a SET;",
-			Expected: Query{},
-			Err:      fmt.Errorf("at UPDATE: expected at least one field to update"),
-		},
-		{
-			Name:     "Incomplete UPDATE with table name, SET with a field but no value and WHERE fails",
-			SQL:      "UPDATE a SET b WHERE;",
-			Expected: Query{},
-			Err:      fmt.Errorf("at UPDATE: expected '='"),
-		},
-		{
-			Name:     "Incomplete UPDATE with table name, SET with a field and = but no value and WHERE fails",
-			SQL:      "UPDATE a SET b = WHERE;",
-			Expected: Query{},
-			Err:      fmt.Errorf("at UPDATE: expected value for update"),
-		},
-		{
-			Name:     "Incomplete UPDATE due to no WHERE clause fails",
-			SQL:      "UPDATE a SET b = 'hello' WHERE;",
-			Expected: Query{},
-			Err:      fmt.Errorf("at WHERE: expected field"),
-		},
-		{
-			Name:     "Incomplete UPDATE due incomplete WHERE clause fails",
-			SQL:      "UPDATE a SET b = 'hello' WHERE a;",
-			Expected: Query{},
-			Err:      fmt.Errorf("at WHERE: unknown operator"),
-		},
-		{
-			Name: "UPDATE works",
-			SQL:  "UPDATE a SET \"b\" = 'hello' WHERE a = 1;",
-			Expected: Query{
-				Type:      Update,
-				TableName: "a",
-				Updates:   map[string]string{"b": "hello"},
-				Conditions: []Condition{
-					{Operand1: "a", Operand1IsField: true, Operator: Eq, Operand2: "1", Operand2IsField: false},
-				},
+var updateTestCases = []testCase{
+	{
+		Name:     "Empty UPDATE fails",
+		SQL:      "UPDATE;",
+		Expected: Query{},
+		Err:      fmt.Errorf("at UPDATE: expected table name"),
+	},
+	{
+		Name:     "Incomplete UPDATE with table name fails",
+		SQL:      "UPDATE a;",
+		Expected: Query{},
+		Err:      fmt.Errorf("at UPDATE: expected 'SET'"),
+	},
+	{
+		Name:     "Incomplete UPDATE with table name and SET fails",
+		SQL:      "UPDATE a SET;",
+		Expected: Query{},
+		Err:      fmt.Errorf("at UPDATE: expected at least one field to update"),
+	},
+	{
+		Name:     "Incomplete UPDATE with table name, SET with a field but no value and WHERE fails",
+		SQL:      "UPDATE a SET b WHERE;",
+		Expected: Query{},
+		Err:      fmt.Errorf("at UPDATE: expected '='"),
+	},
+	{
+		Name:     "Incomplete UPDATE with table name, SET with a field and = but no value and WHERE fails",
+		SQL:      "UPDATE a SET b = WHERE;",
+		Expected: Query{},
+		Err:      fmt.Errorf("at UPDATE: expected value for update"),
+	},
+	{
+		Name:     "Incomplete UPDATE due to no WHERE clause fails",
+		SQL:      "UPDATE a SET b = 'hello' WHERE;",
+		Expected: Query{},
+		Err:      fmt.Errorf("at WHERE: expected field"),
+	},
+	{
+		Name:     "Incomplete UPDATE due incomplete WHERE clause fails",
+		SQL:      "UPDATE a SET b = 'hello' WHERE a;",
+		Expected: Query{},
+		Err:      fmt.Errorf("at WHERE: unknown operator"),
+	},
+	{
+		Name: "UPDATE works",
+		SQL:  "UPDATE a SET \"b\" = 'hello' WHERE a = 1;",
+		Expected: Query{
+			Type:      Update,
+			TableName: "a",
+			Updates:   map[string]string{"b": "hello"},
+			Conditions: []Condition{
+				{Operand1: "a", Operand1IsField: true, Operator: Eq, Operand2: "1", Operand2IsField: false},
 			},
-			Err: nil,
 		},
-		{
-			Name: "UPDATE works with simple quote inside",
-			SQL:  "UPDATE a SET b = 'hello\\world' WHERE a = 1;",
-			Expected: Query{
-				Type:      Update,
-				TableName: "a",
-				Updates:   map[string]string{"b": "hello\\world"},
-				Conditions: []Condition{
-					{Operand1: "a", Operand1IsField: true, Operator: Eq, Operand2: "1", Operand2IsField: false},
-				},
+		Err: nil,
+	},
+	{
+		Name: "UPDATE works with simple quote inside",
+		SQL:  "UPDATE a SET b = 'hello\\world' WHERE a = 1;",
+		Expected: Query{
+			Type:      Update,
+			TableName: "a",
+			Updates:   map[string]string{"b": "hello\\world"},
+			Conditions: []Condition{
+				{Operand1: "a", Operand1IsField: true, Operator: Eq, Operand2: "1", Operand2IsField: false},
 			},
-			Err: nil,
 		},
-		{
-			Name: "UPDATE with multiple SETs works",
-			SQL:  "UPDATE a SET b = 'hello', c = 'bye' WHERE a = 1;",
-			Expected: Query{
-				Type:      Update,
-				TableName: "a",
-				Updates:   map[string]string{"b": "hello", "c": "bye"},
-				Conditions: []Condition{
-					{Operand1: "a", Operand1IsField: true, Operator: Eq, Operand2: "1", Operand2IsField: false},
-				},
+		Err: nil,
+	},
+	{
+		Name: "UPDATE with multiple SETs works",
+		SQL:  "UPDATE a SET b = 'hello', c = 'bye' WHERE a = 1;",
+		Expected: Query{
+			Type:      Update,
+			TableName: "a",
+			Updates:   map[string]string{"b": "hello", "c": "bye"},
+			Conditions: []Condition{
+				{Operand1: "a", Operand1IsField: true, Operator: Eq, Operand2: "1", Operand2IsField: false},
 			},
-			Err: nil,
 		},
-		{
-			Name: "UPDATE with multiple SETs and multiple conditions works",
-			SQL:  "UPDATE a SET b = 'hello', c = 'bye' WHERE a = '1' AND b = 789;",
-			Expected: Query{
-				Type:      Update,
-				TableName: "a",
-				Updates:   map[string]string{"b": "hello", "c": "bye"},
-				Conditions: []Condition{
-					{Operand1: "a", Operand1IsField: true, Operator: Eq, Operand2: "1", Operand2IsField: false},
-					{Operand1: "b", Operand1IsField: true, Operator: Eq, Operand2: "789", Operand2IsField: false},
-				},
+		Err: nil,
+	},
+	{
+		Name: "UPDATE with multiple SETs and multiple conditions works",
+		SQL:  "UPDATE a SET b = 'hello', c = 'bye' WHERE a = '1' AND b = 789;",
+		Expected: Query{
+			Type:      Update,
+			TableName: "a",
+			Updates:   map[string]string{"b": "hello", "c": "bye"},
+			Conditions: []Condition{
+				{Operand1: "a", Operand1IsField: true, Operator: Eq, Operand2: "1", Operand2IsField: false},
+				{Operand1: "b", Operand1IsField: true, Operator: Eq, Operand2: "789", Operand2IsField: false},
 			},
-			Err: nil,
 		},
-	}
+		Err: nil,
+	},
+}
 
-	for _, tc := range ts {
+func TestUpdateSQL(t *testing.T) {
+	for _, tc := range updateTestCases {
 		t.Run(tc.Name, func(t *testing.T) {
 			_, err := Parse(tc.SQL)
 			if tc.Err != nil && err == nil {
@@ -351,53 +355,55 @@ func TestUpdateSQL(t *testing.T) {
 				t.Errorf("Error should have been nil but was %v", err)
 			}
 			if tc.Err != nil && err != nil {
-				require.Equal(t, tc.Err, err, "Unexpected error")
+				var perr *ParseError
+				require.ErrorAs(t, err, &perr)
+				require.Equal(t, tc.Err.Error(), perr.Msg, "Unexpected error")
 			}
 		})
 	}
 }
 
-func TestDeleteSQL(t *testing.T) {
-	ts := []testCase{
-		{
-			Name:     "Empty DELETE fails",
-			SQL:      "DELETE FROM;",
-			Expected: Query{},
-			Err:      fmt.Errorf("at DELETE FROM: expected table name"),
-		},
-		{
-			Name:     "DELETE without WHERE fails",
-			SQL:      "DELETE FROM \"a\";",
-			Expected: Query{},
-			Err:      fmt.Errorf("at WHERE: WHERE clause is mandatory for UPDATE & DELETE"),
-		},
-		{
-			Name:     "DELETE with empty WHERE fails",
-			SQL:      "DELETE FROM a WHERE;",
-			Expected: Query{},
-			Err:      fmt.Errorf("at WHERE: expected field"),
-		},
-		{
-			Name:     "DELETE with WHERE with field but no operator fails",
-			SQL:      "DELETE FROM a WHERE b;",
-			Expected: Query{},
-			Err:      fmt.Errorf("at WHERE: unknown operator"),
-		},
-		{
-			Name: "DELETE with WHERE works",
-			SQL:  "DELETE FROM a WHERE b = 'some';",
-			Expected: Query{
-				Type:      Delete,
-				TableName: "a",
-				Conditions: []Condition{
-					{Operand1: "b", Operand1IsField: true, Operator: Eq, Operand2: "some", Operand2IsField: false},
-				},
+var deleteTestCases = []testCase{
+	{
+		Name:     "Empty DELETE fails",
+		SQL:      "DELETE FROM;",
+		Expected: Query{},
+		Err:      fmt.Errorf("at DELETE FROM: expected table name"),
+	},
+	{
+		Name:     "DELETE without WHERE fails",
+		SQL:      "DELETE FROM \"a\";",
+		Expected: Query{},
+		Err:      fmt.Errorf("at WHERE: WHERE clause is mandatory for UPDATE & DELETE"),
+	},
+	{
+		Name:     "DELETE with empty WHERE fails",
+		SQL:      "DELETE FROM a WHERE;",
+		Expected: Query{},
+		Err:      fmt.Errorf("at WHERE: expected field"),
+	},
+	{
+		Name:     "DELETE with WHERE with field but no operator fails",
+		SQL:      "DELETE FROM a WHERE b;",
+		Expected: Query{},
+		Err:      fmt.Errorf("at WHERE: unknown operator"),
+	},
+	{
+		Name: "DELETE with WHERE works",
+		SQL:  "DELETE FROM a WHERE b = 'some';",
+		Expected: Query{
+			Type:      Delete,
+			TableName: "a",
+			Conditions: []Condition{
+				{Operand1: "b", Operand1IsField: true, Operator: Eq, Operand2: "some", Operand2IsField: false},
 			},
-			Err: nil,
 		},
-	}
+		Err: nil,
+	},
+}
 
-	for _, tc := range ts {
+func TestDeleteSQL(t *testing.T) {
+	for _, tc := range deleteTestCases {
 		t.Run(tc.Name, func(t *testing.T) {
 			_, err := Parse(tc.SQL)
 			if tc.Err != nil && err == nil {
@@ -407,98 +413,350 @@ func TestDeleteSQL(t *testing.T) {
 				t.Errorf("Error should have been nil but was %v", err)
 			}
 			if tc.Err != nil && err != nil {
-				require.Equal(t, tc.Err, err, "Unexpected error")
+				var perr *ParseError
+				require.ErrorAs(t, err, &perr)
+				require.Equal(t, tc.Err.Error(), perr.Msg, "Unexpected error")
 			}
 		})
 	}
 }
 
-func TestInsertSQL(t *testing.T) {
-	ts := []testCase{
-		{
-			Name:     "Empty INSERT fails",
-			SQL:      "INSERT INTO;",
-			Expected: Query{},
-			Err:      fmt.Errorf("at INSERT INTO: expected table name"),
-		},
-		{
-			Name:     "INSERT with no rows to insert fails",
-			SQL:      "INSERT INTO a;",
-			Expected: Query{},
-			Err:      fmt.Errorf("at INSERT INTO: expected opening parens"),
+var insertTestCases = []testCase{
+	{
+		Name:     "Empty INSERT fails",
+		SQL:      "INSERT INTO;",
+		Expected: Query{},
+		Err:      fmt.Errorf("at INSERT INTO: expected table name"),
+	},
+	{
+		Name:     "INSERT with no rows to insert fails",
+		SQL:      "INSERT INTO a;",
+		Expected: Query{},
+		Err:      fmt.Errorf("at INSERT INTO: expected opening parens"),
+	},
+	{
+		Name:     "INSERT with incomplete value section fails",
+		SQL:      "INSERT INTO a (;",
+		Expected: Query{},
+		Err:      fmt.Errorf("at INSERT INTO: expected at least one field to insert"),
+	},
+	{
+		Name:     "INSERT with incomplete value section fails #2",
+		SQL:      "INSERT INTO a (b;",
+		Expected: Query{},
+		Err:      fmt.Errorf("at INSERT INTO: expected comma or closing parens"),
+	},
+	{
+		Name:     "INSERT with incomplete value section fails #3",
+		SQL:      "INSERT INTO a (b);",
+		Expected: Query{},
+		Err:      fmt.Errorf("at INSERT INTO: expected 'VALUES'"),
+	},
+	{
+		Name:     "INSERT with incomplete value section fails #4",
+		SQL:      "INSERT INTO a (b) VALUES;",
+		Expected: Query{},
+		Err:      fmt.Errorf("at INSERT INTO: expected opening parens"),
+	},
+	{
+		Name:     "INSERT with incomplete row fails",
+		SQL:      "INSERT INTO a (b) VALUES (;",
+		Expected: Query{},
+		Err:      fmt.Errorf("at INSERT INTO: expected value to insert string or number literal"),
+	},
+	{
+		Name: "INSERT works",
+		SQL:  "INSERT INTO a (b) VALUES ('1');",
+		Expected: Query{
+			Type:      Insert,
+			TableName: "a",
+			Fields:    []string{"b"},
+			Inserts:   [][]string{{"1"}},
 		},
-		{
-			Name:     "INSERT with incomplete value section fails",
-			SQL:      "INSERT INTO a (;",
-			Expected: Query{},
-			Err:      fmt.Errorf("at INSERT INTO: expected at least one field to insert"),
+		Err: nil,
+	},
+	{
+		Name:     "INSERT * fails",
+		SQL:      "INSERT INTO a (*) VALUES ('1');",
+		Expected: Query{},
+		Err:      fmt.Errorf("at INSERT INTO: expected at least one field to insert"),
+	},
+	{
+		Name: "INSERT with multiple fields works",
+		SQL:  "INSERT INTO a (b,c,    d) VALUES ('1','2' ,  '3' );",
+		Expected: Query{
+			Type:      Insert,
+			TableName: "a",
+			Fields:    []string{"b", "c", "d"},
+			Inserts:   [][]string{{"1", "2", "3"}},
 		},
-		{
-			Name:     "INSERT with incomplete value section fails #2",
-			SQL:      "INSERT INTO a (b;",
-			Expected: Query{},
-			Err:      fmt.Errorf("at INSERT INTO: expected comma or closing parens"),
+		Err: nil,
+	},
+	{
+		Name: "INSERT with multiple fields and multiple values works",
+		SQL:  "INSERT INTO a (b,c,    d) VALUES ('1','2' ,  '3' ),('4','5' ,'6' );",
+		Expected: Query{
+			Type:      Insert,
+			TableName: "a",
+			Fields:    []string{"b", "c", "d"},
+			Inserts:   [][]string{{"1", "2", "3"}, {"4", "5", "6"}},
 		},
-		{
-			Name:     "INSERT with incomplete value section fails #3",
-			SQL:      "INSERT INTO a (b);",
-			Expected: Query{},
-			Err:      fmt.Errorf("at INSERT INTO: expected 'VALUES'"),
+		Err: nil,
+	},
+}
+
+func TestInsertSQL(t *testing.T) {
+	for _, tc := range insertTestCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			_, err := Parse(tc.SQL)
+			if tc.Err != nil && err == nil {
+				t.Errorf("Error should have been %v", tc.Err)
+			}
+			if tc.Err == nil && err != nil {
+				t.Errorf("Error should have been nil but was %v", err)
+			}
+			if tc.Err != nil && err != nil {
+				var perr *ParseError
+				require.ErrorAs(t, err, &perr)
+				require.Equal(t, tc.Err.Error(), perr.Msg, "Unexpected error")
+			}
+		})
+	}
+}
+
+var createTestCases = []testCase{
+	{
+		Name:     "Empty CREATE fails",
+		SQL:      "CREATE TABLE;",
+		Expected: Query{},
+		Err:      fmt.Errorf("at CREATE TABLE: expected quoted table name"),
+	},
+	{
+		Name:     "Empty CREATE table",
+		SQL:      "CREATE TABLE a;",
+		Expected: Query{},
+		Err:      fmt.Errorf("at CREATE TABLE: expected opening parens"),
+	},
+	{
+		Name:     "CREATE table with no fields",
+		SQL:      "CREATE TABLE b ();",
+		Expected: Query{},
+		Err:      fmt.Errorf("at CREATE TABLE: expected field to CREATE"),
+	},
+	{
+		Name:     "CREATE with field no datatype",
+		SQL:      "CREATE TABLE b (ID);",
+		Expected: Query{},
+		Err:      fmt.Errorf("at CREATE TABLE: expected valid data type for column"),
+	},
+	{
+		Name: "CREATE with valid field and datatype",
+		SQL:  "CREATE TABLE b (ID int);",
+		Expected: Query{
+			Type:      Create,
+			TableName: "b",
+			TableConstruction: createQuery{
+				fieldsWTypes: [][]string{{"ID", "INT"}},
+			},
 		},
-		{
-			Name:     "INSERT with incomplete value section fails #4",
-			SQL:      "INSERT INTO a (b) VALUES;",
-			Expected: Query{},
-			Err:      fmt.Errorf("at INSERT INTO: expected opening parens"),
+		Err: nil,
+	},
+	{
+		Name: "CREATE with multiple fields",
+		SQL:  "CREATE TABLE c10 (column1 int, column2 char(20),column3 bool, column4 float);",
+		Expected: Query{
+			Type:      Create,
+			TableName: "c10",
+			TableConstruction: createQuery{
+				fieldsWTypes: [][]string{{"column1", "INT"}, {"column2", "CHAR", "20"}, {"column3", "BOOL"}, {"column4", "FLOAT"}},
+			},
 		},
-		{
-			Name:     "INSERT with incomplete row fails",
-			SQL:      "INSERT INTO a (b) VALUES (;",
-			Expected: Query{},
-			Err:      fmt.Errorf("at INSERT INTO: expected value to insert string or number literal"),
+		Err: nil,
+	},
+	{
+		Name: "CREATE with valid field and datatype with constraints",
+		SQL:  "CREATE TABLE b(ID int Primary Key);",
+		Expected: Query{
+			Type:      Create,
+			TableName: "b",
+			TableConstruction: createQuery{
+				fieldsWTypes: [][]string{{"ID", "INT"}},
+				primary:      []string{"ID"},
+			},
 		},
-		{
-			Name: "INSERT works",
-			SQL:  "INSERT INTO a (b) VALUES ('1');",
-			Expected: Query{
-				Type:      Insert,
-				TableName: "a",
-				Fields:    []string{"b"},
-				Inserts:   [][]string{{"1"}},
+		Err: nil,
+	},
+	{
+		Name: "CREATE multiple valid fields and datatype with constraints",
+		SQL:  "CREATE TABLE b (ID int Primary Key,Column flOat UNique NOT NULL,third Char(50) NOt Null);",
+		Expected: Query{
+			Type:      Create,
+			TableName: "b",
+			TableConstruction: createQuery{
+				fieldsWTypes: [][]string{{"ID", "INT"}, {"Column", "FLOAT"}, {"third", "CHAR", "50"}},
+				primary:      []string{"ID"},
+				unique:       []string{"Column"},
+				notnullable:  []string{"Column", "third"},
 			},
-			Err: nil,
 		},
-		{
-			Name:     "INSERT * fails",
-			SQL:      "INSERT INTO a (*) VALUES ('1');",
-			Expected: Query{},
-			Err:      fmt.Errorf("at INSERT INTO: expected at least one field to insert"),
+		Err: nil,
+	},
+	{
+		Name: "CREATE multiple valid fields and datatype",
+		SQL:  "CREATE TABLE MyTable10 (column1 int Primary Key, column2 char(20),column30 bool, column400 float);",
+		Expected: Query{
+			Type:      Create,
+			TableName: "MyTable10",
+			TableConstruction: createQuery{
+				fieldsWTypes: [][]string{{"column1", "INT"}, {"column2", "CHAR", "20"}, {"column30", "BOOL"}, {"column400", "FLOAT"}},
+				primary:      []string{"column1"},
+			},
 		},
-		{
-			Name: "INSERT with multiple fields works",
-			SQL:  "INSERT INTO a (b,c,    d) VALUES ('1','2' ,  '3' );",
-			Expected: Query{
-				Type:      Insert,
-				TableName: "a",
-				Fields:    []string{"b", "c", "d"},
-				Inserts:   [][]string{{"1", "2", "3"}},
+		Err: nil,
+	},
+	{
+		Name: "CREATE with table level primary key",
+		SQL:  "CREATE TABLE t (tenant int, id int, PRIMARY KEY (tenant, id));",
+		Expected: Query{
+			Type:      Create,
+			TableName: "t",
+			TableConstruction: createQuery{
+				fieldsWTypes: [][]string{{"tenant", "INT"}, {"id", "INT"}},
+				primary:      []string{"tenant", "id"},
 			},
-			Err: nil,
 		},
-		{
-			Name: "INSERT with multiple fields and multiple values works",
-			SQL:  "INSERT INTO a (b,c,    d) VALUES ('1','2' ,  '3' ),('4','5' ,'6' );",
-			Expected: Query{
-				Type:      Insert,
-				TableName: "a",
-				Fields:    []string{"b", "c", "d"},
-				Inserts:   [][]string{{"1", "2", "3"}, {"4", "5", "6"}},
+		Err: nil,
+	},
+	{
+		Name: "CREATE with column and table level checks",
+		SQL:  "CREATE TABLE t (id int primary key check (id > 0), n int check (n != 1) check (n != 2), CHECK (n < id), check (n > 0));",
+		Expected: Query{
+			Type:      Create,
+			TableName: "t",
+			TableConstruction: createQuery{
+				fieldsWTypes: [][]string{{"id", "INT"}, {"n", "INT"}},
+				primary:      []string{"id"},
+				checks: []checkDef{
+					{name: "t_id_check", expr: &BinaryExpr{Operator: Gt, Left: &Identifier{Name: "id"}, Right: &Literal{Kind: NumberLiteral, Value: "0"}}},
+					{name: "t_n_check", expr: &BinaryExpr{Operator: Ne, Left: &Identifier{Name: "n"}, Right: &Literal{Kind: NumberLiteral, Value: "1"}}},
+					{name: "t_n_check1", expr: &BinaryExpr{Operator: Ne, Left: &Identifier{Name: "n"}, Right: &Literal{Kind: NumberLiteral, Value: "2"}}},
+					{name: "t_check", expr: &BinaryExpr{Operator: Lt, Left: &Identifier{Name: "n"}, Right: &Identifier{Name: "id"}}},
+					{name: "t_check1", expr: &BinaryExpr{Operator: Gt, Left: &Identifier{Name: "n"}, Right: &Literal{Kind: NumberLiteral, Value: "0"}}},
+				},
 			},
-			Err: nil,
 		},
+		Err: nil,
+	},
+	{
+		Name:     "CREATE with unknown referential action fails",
+		SQL:      "CREATE TABLE t (id int primary key references p on delete nothing);",
+		Expected: Query{},
+		Err:      fmt.Errorf("at CREATE TABLE: expected CASCADE, RESTRICT, SET NULL or NO ACTION"),
+	},
+	{
+		Name:     "CREATE with check without parens fails",
+		SQL:      "CREATE TABLE t (id int primary key check id > 0);",
+		Expected: Query{},
+		Err:      fmt.Errorf("at CREATE TABLE: expected opening parens for check condition"),
+	},
+	{
+		Name:     "CREATE with column and table level primary key fails",
+		SQL:      "CREATE TABLE t (tenant int primary key, id int, PRIMARY KEY (tenant, id));",
+		Expected: Query{},
+		Err:      fmt.Errorf("at CREATE TABLE: multiple primary keys for table %q are not allowed", "t"),
+	},
+	{
+		Name:     "RETURNING without columns fails",
+		SQL:      "INSERT INTO t (id) VALUES (1) RETURNING;",
+		Expected: Query{},
+		Err:      fmt.Errorf("at RETURNING: expected field to return"),
+	},
+	{
+		Name:     "ON CONFLICT DO UPDATE without conflict columns fails",
+		SQL:      "INSERT INTO t (id) VALUES (1) ON CONFLICT DO UPDATE SET id = 2;",
+		Expected: Query{},
+		Err:      fmt.Errorf("at ON CONFLICT: DO UPDATE requires conflict columns"),
+	},
+	{
+		Name:     "ON CONFLICT without action fails",
+		SQL:      "INSERT INTO t (id) VALUES (1) ON CONFLICT (id) DO;",
+		Expected: Query{},
+		Err:      fmt.Errorf("at ON CONFLICT: expected NOTHING or UPDATE after DO"),
+	},
+	{
+		Name:     "CREATE with IF but no NOT EXISTS fails",
+		SQL:      "CREATE TABLE IF t (id int primary key);",
+		Expected: Query{},
+		Err:      fmt.Errorf("at CREATE TABLE: expected NOT EXISTS after IF"),
+	},
+	{
+		Name:     "CREATE AS without SELECT fails",
+		SQL:      "CREATE TABLE t AS (id int primary key);",
+		Expected: Query{},
+		Err:      fmt.Errorf("at CREATE TABLE: expected SELECT after AS"),
+	},
+	{
+		Name:     "ALTER adding a primary key column fails",
+		SQL:      "ALTER TABLE t ADD COLUMN id int primary key;",
+		Expected: Query{},
+		Err:      fmt.Errorf("at ALTER TABLE: cannot add primary key column %q", "id"),
+	},
+	{
+		Name:     "CREATE with primary key without columns fails",
+		SQL:      "CREATE TABLE t (id int, PRIMARY KEY ());",
+		Expected: Query{},
+		Err:      fmt.Errorf("at CREATE TABLE: expected primary key column"),
+	},
+}
+
+func TestCreateSQL(t *testing.T) {
+	for _, tc := range createTestCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			_, err := Parse(tc.SQL)
+			if tc.Err != nil && err == nil {
+				t.Errorf("Error should have been %v", tc.Err)
+			}
+			if tc.Err == nil && err != nil {
+				t.Errorf("Error should have been nil but was %v", err)
+			}
+			if tc.Err != nil && err != nil {
+				var perr *ParseError
+				require.ErrorAs(t, err, &perr)
+				require.Equal(t, tc.Err.Error(), perr.Msg, "Unexpected error")
+			}
+		})
 	}
+}
 
-	for _, tc := range ts {
+var dropTestCases = []testCase{
+	{
+		Name:     "Empty DROP fails",
+		SQL:      "DROP TABLE;",
+		Expected: Query{},
+		Err:      fmt.Errorf("at DROP TABLE: expected table name"),
+	},
+	{
+		Name: "DROP TABLE with quotes",
+		SQL:  "DROP TABLE \"mytable\";",
+		Expected: Query{
+			Type:      Drop,
+			TableName: "mytable",
+		},
+		Err: nil,
+	},
+	{
+		Name: "DROP TABLE with no quotes",
+		SQL:  "DROP TABLE sometable;",
+		Expected: Query{
+			Type:      Drop,
+			TableName: "sometable",
+		},
+		Err: nil,
+	},
+}
+
+func TestDropSQL(t *testing.T) {
+	for _, tc := range dropTestCases {
 		t.Run(tc.Name, func(t *testing.T) {
 			_, err := Parse(tc.SQL)
 			if tc.Err != nil && err == nil {
@@ -508,161 +766,378 @@ func TestInsertSQL(t *testing.T) {
 				t.Errorf("Error should have been nil but was %v", err)
 			}
 			if tc.Err != nil && err != nil {
-				require.Equal(t, tc.Err, err, "Unexpected error")
+				var perr *ParseError
+				require.ErrorAs(t, err, &perr)
+				require.Equal(t, tc.Err.Error(), perr.Msg, "Unexpected error")
 			}
 		})
 	}
 }
 
-func TestCreateSQL(t *testing.T) {
-	ts := []testCase{
+func TestParseErrorPosition(t *testing.T) {
+	ts := []struct {
+		Name     string
+		SQL      string
+		Line     int
+		Column   int
+		Literal  string
+		Expected string
+	}{
 		{
-			Name:     "Empty CREATE fails",
-			SQL:      "CREATE TABLE;",
-			Expected: Query{},
-			Err:      fmt.Errorf("at CREATE TABLE: expected quoted table name"),
+			Name:     "missing field on first line",
+			SQL:      "SELECT FROM a;",
+			Line:     1,
+			Column:   8,
+			Literal:  "FROM",
+			Expected: "field to SELECT",
 		},
 		{
-			Name:     "Empty CREATE table",
-			SQL:      "CREATE TABLE a;",
-			Expected: Query{},
-			Err:      fmt.Errorf("at CREATE TABLE: expected opening parens"),
+			Name:     "missing operator on later line",
+			SQL:      "SELECT a\nFROM b\n  WHERE a 1;",
+			Line:     3,
+			Column:   11,
+			Literal:  "1",
+			Expected: "comparison operator",
 		},
 		{
-			Name:     "CREATE table with no fields",
-			SQL:      "CREATE TABLE b ();",
-			Expected: Query{},
-			Err:      fmt.Errorf("at CREATE TABLE: expected field to CREATE"),
+			Name:     "missing semicolon points past last token",
+			SQL:      "SELECT a FROM b",
+			Line:     1,
+			Column:   16,
+			Literal:  "b",
+			Expected: ";",
 		},
+	}
+
+	for _, tc := range ts {
+		t.Run(tc.Name, func(t *testing.T) {
+			_, err := Parse(tc.SQL)
+			var perr *ParseError
+			require.ErrorAs(t, err, &perr)
+			require.Equal(t, tc.Line, perr.Line)
+			require.Equal(t, tc.Column, perr.Column)
+			require.Equal(t, tc.Literal, perr.Token.Literal)
+			require.Equal(t, tc.Expected, perr.Expected)
+		})
+	}
+}
+
+func TestParseAST(t *testing.T) {
+	ts := []struct {
+		Name     string
+		SQL      string
+		Expected Statement
+	}{
 		{
-			Name:     "CREATE with field no datatype",
-			SQL:      "CREATE TABLE b (ID);",
-			Expected: Query{},
-			Err:      fmt.Errorf("at CREATE TABLE: expected valid data type for column"),
+			Name: "operator precedence",
+			SQL:  "SELECT a + b * 2 AS total FROM t WHERE a = 1 OR b > 2 AND NOT c = -3;",
+			Expected: &SelectStatement{
+				Items: []SelectItem{{
+					Expr: &BinaryExpr{Operator: Plus,
+						Left:  &Identifier{Name: "a"},
+						Right: &BinaryExpr{Operator: Multiply, Left: &Identifier{Name: "b"}, Right: &Literal{Kind: NumberLiteral, Value: "2"}},
+					},
+					Alias: "total",
+				}},
+				From: &TableName{Name: "t"},
+				Where: &BinaryExpr{Operator: Or,
+					Left: &BinaryExpr{Operator: Eq, Left: &Identifier{Name: "a"}, Right: &Literal{Kind: NumberLiteral, Value: "1"}},
+					Right: &BinaryExpr{Operator: And,
+						Left: &BinaryExpr{Operator: Gt, Left: &Identifier{Name: "b"}, Right: &Literal{Kind: NumberLiteral, Value: "2"}},
+						Right: &UnaryExpr{Operator: Not,
+							Expr: &BinaryExpr{Operator: Eq, Left: &Identifier{Name: "c"}, Right: &Literal{Kind: NumberLiteral, Value: "-3"}},
+						},
+					},
+				},
+			},
 		},
 		{
-			Name: "CREATE with valid field and datatype",
-			SQL:  "CREATE TABLE b (ID int);",
-			Expected: Query{
-				Type:      Create,
-				TableName: "b",
-				TableConstruction: createQuery{
-					fieldsWTypes: [][]string{{"ID", "INT"}},
+			Name: "parens, IN, IS NULL, functions and qualified columns",
+			SQL:  "SELECT COUNT(*), x.a FROM t AS x WHERE (x.a - 1) * 2 IN (2, 4) AND b NOT IN ('q') AND lower(c) IS NOT NULL;",
+			Expected: &SelectStatement{
+				Items: []SelectItem{
+					{Expr: &FuncCall{Name: "COUNT", Star: true}},
+					{Expr: &Identifier{Table: "x", Name: "a"}},
+				},
+				From: &TableName{Name: "t", Alias: "x"},
+				Where: &BinaryExpr{Operator: And,
+					Left: &BinaryExpr{Operator: And,
+						Left: &InExpr{
+							Expr: &BinaryExpr{Operator: Multiply,
+								Left:  &BinaryExpr{Operator: Minus, Left: &Identifier{Table: "x", Name: "a"}, Right: &Literal{Kind: NumberLiteral, Value: "1"}},
+								Right: &Literal{Kind: NumberLiteral, Value: "2"},
+							},
+							List: []Expr{&Literal{Kind: NumberLiteral, Value: "2"}, &Literal{Kind: NumberLiteral, Value: "4"}},
+						},
+						Right: &InExpr{Expr: &Identifier{Name: "b"}, List: []Expr{&Literal{Kind: StringLiteral, Value: "q"}}, Not: true},
+					},
+					Right: &IsNullExpr{Expr: &FuncCall{Name: "LOWER", Args: []Expr{&Identifier{Name: "c"}}}, Not: true},
 				},
 			},
-			Err: nil,
 		},
 		{
-			Name: "CREATE with multiple fields",
-			SQL:  "CREATE TABLE c10 (column1 int, column2 char(20),column3 bool, column4 float);",
-			Expected: Query{
-				Type:      Create,
-				TableName: "c10",
-				TableConstruction: createQuery{
-					fieldsWTypes: [][]string{{"column1", "INT"}, {"column2", "CHAR", "20"}, {"column3", "BOOL"}, {"column4", "FLOAT"}},
+			Name: "INSERT with expressions",
+			SQL:  "INSERT INTO t (a, b) VALUES (1 + 1, NULL), (true, 'x');",
+			Expected: &InsertStatement{
+				Table:   &TableName{Name: "t"},
+				Columns: []string{"a", "b"},
+				Rows: [][]Expr{
+					{&BinaryExpr{Operator: Plus, Left: &Literal{Kind: NumberLiteral, Value: "1"}, Right: &Literal{Kind: NumberLiteral, Value: "1"}}, &Literal{Kind: NullLiteral, Value: "NULL"}},
+					{&Literal{Kind: BoolLiteral, Value: "TRUE"}, &Literal{Kind: StringLiteral, Value: "x"}},
 				},
 			},
-			Err: nil,
 		},
 		{
-			Name: "CREATE with valid field and datatype with constraints",
-			SQL:  "CREATE TABLE b(ID int Primary Key);",
-			Expected: Query{
-				Type:      Create,
-				TableName: "b",
-				TableConstruction: createQuery{
-					fieldsWTypes: [][]string{{"ID", "INT"}},
-					primary:      []string{"ID"},
+			Name: "CREATE TABLE",
+			SQL:  "CREATE TABLE t (id int PRIMARY KEY, name char(10) NOT NULL UNIQUE);",
+			Expected: &CreateTableStatement{
+				Name: "t",
+				Columns: []ColumnDef{
+					{Name: "id", Type: DataType{Name: "INT"}, Constraints: []ColumnConstraint{{Kind: PrimaryKeyConstraint}}},
+					{Name: "name", Type: DataType{Name: "CHAR", Args: []string{"10"}}, Constraints: []ColumnConstraint{{Kind: NotNullConstraint}, {Kind: UniqueConstraint}}},
 				},
 			},
-			Err: nil,
 		},
 		{
-			Name: "CREATE multiple valid fields and datatype with constraints",
-			SQL:  "CREATE TABLE b (ID int Primary Key,Column flOat UNique NOT NULL,third Char(50) NOt Null);",
-			Expected: Query{
-				Type:      Create,
-				TableName: "b",
-				TableConstruction: createQuery{
-					fieldsWTypes: [][]string{{"ID", "INT"}, {"Column", "FLOAT"}, {"third", "CHAR", "50"}},
-					primary:      []string{"ID"},
-					unique:       []string{"Column"},
-					notnullable:  []string{"Column", "third"},
+			Name: "EXPLAIN ANALYZE",
+			SQL:  "explain analyze SELECT a FROM t WHERE id = 1;",
+			Expected: &ExplainStatement{
+				Analyze: true,
+				Statement: &SelectStatement{
+					Items: []SelectItem{{Expr: &Identifier{Name: "a"}}},
+					From:  &TableName{Name: "t"},
+					Where: &BinaryExpr{Operator: Eq, Left: &Identifier{Name: "id"}, Right: &Literal{Kind: NumberLiteral, Value: "1"}},
 				},
 			},
-			Err: nil,
 		},
 		{
-			Name: "CREATE multiple valid fields and datatype",
-			SQL:  "CREATE TABLE MyTable10 (column1 int Primary Key, column2 char(20),column30 bool, column400 float);",
-			Expected: Query{
-				Type:      Create,
-				TableName: "MyTable10",
-				TableConstruction: createQuery{
-					fieldsWTypes: [][]string{{"column1", "INT"}, {"column2", "CHAR", "20"}, {"column30", "BOOL"}, {"column400", "FLOAT"}},
-					primary:      []string{"column1"},
+			Name:     "ANALYZE",
+			SQL:      "analyze t;",
+			Expected: &AnalyzeStatement{Table: "t"},
+		},
+		{
+			Name:     "ANALYZE every table",
+			SQL:      "ANALYZE;",
+			Expected: &AnalyzeStatement{},
+		},
+		{
+			Name: "CREATE TABLE with a table level primary key",
+			SQL:  "CREATE TABLE t (id int, tenant int not null, PRIMARY KEY (tenant, id));",
+			Expected: &CreateTableStatement{
+				Name: "t",
+				Columns: []ColumnDef{
+					{Name: "id", Type: DataType{Name: "INT"}},
+					{Name: "tenant", Type: DataType{Name: "INT"}, Constraints: []ColumnConstraint{{Kind: NotNullConstraint}}},
 				},
+				PrimaryKey: []string{"tenant", "id"},
 			},
-			Err: nil,
 		},
-	}
-
-	for _, tc := range ts {
-		t.Run(tc.Name, func(t *testing.T) {
-			_, err := Parse(tc.SQL)
-			if tc.Err != nil && err == nil {
-				t.Errorf("Error should have been %v", tc.Err)
-			}
-			if tc.Err == nil && err != nil {
-				t.Errorf("Error should have been nil but was %v", err)
-			}
-			if tc.Err != nil && err != nil {
-				require.Equal(t, tc.Err, err, "Unexpected error")
-			}
-		})
-	}
-}
-
-func TestDropSQL(t *testing.T) {
-	ts := []testCase{
 		{
-			Name:     "Empty DROP fails",
-			SQL:      "DROP TABLE;",
-			Expected: Query{},
-			Err:      fmt.Errorf("at DROP TABLE: expected table name"),
+			Name: "CREATE TABLE with a default and a generated column",
+			SQL:  "CREATE TABLE t (id int primary key, n int default 1 + 2, m int generated always as (n * 2) stored);",
+			Expected: &CreateTableStatement{
+				Name: "t",
+				Columns: []ColumnDef{
+					{Name: "id", Type: DataType{Name: "INT"}, Constraints: []ColumnConstraint{{Kind: PrimaryKeyConstraint}}},
+					{Name: "n", Type: DataType{Name: "INT"}, Constraints: []ColumnConstraint{{Kind: DefaultConstraint, Expr: &BinaryExpr{
+						Operator: Plus, Left: &Literal{Kind: NumberLiteral, Value: "1"}, Right: &Literal{Kind: NumberLiteral, Value: "2"}}}}},
+					{Name: "m", Type: DataType{Name: "INT"}, Constraints: []ColumnConstraint{{Kind: GeneratedConstraint, Expr: &BinaryExpr{
+						Operator: Multiply, Left: &Identifier{Name: "n"}, Right: &Literal{Kind: NumberLiteral, Value: "2"}}}}},
+				},
+			},
+		},
+		{
+			Name: "CREATE TABLE with column and table level checks",
+			SQL:  "CREATE TABLE t (id int check (id > 0), check (id < 10));",
+			Expected: &CreateTableStatement{
+				Name: "t",
+				Columns: []ColumnDef{
+					{Name: "id", Type: DataType{Name: "INT"}, Constraints: []ColumnConstraint{{Kind: CheckConstraint, Expr: &BinaryExpr{
+						Operator: Gt, Left: &Identifier{Name: "id"}, Right: &Literal{Kind: NumberLiteral, Value: "0"}}}}},
+				},
+				Checks: []Expr{&BinaryExpr{Operator: Lt, Left: &Identifier{Name: "id"}, Right: &Literal{Kind: NumberLiteral, Value: "10"}}},
+			},
+		},
+		{
+			Name: "CREATE TABLE with foreign keys",
+			SQL:  "CREATE TABLE t (id int references p on delete cascade on update no action, a int, b int, FOREIGN KEY (a, b) REFERENCES q (x, y) ON UPDATE SET NULL ON DELETE RESTRICT);",
+			Expected: &CreateTableStatement{
+				Name: "t",
+				Columns: []ColumnDef{
+					{Name: "id", Type: DataType{Name: "INT"}, Constraints: []ColumnConstraint{{Kind: ForeignKeyConstraint, ForeignKey: &ForeignKey{Table: "p", OnDelete: Cascade}}}},
+					{Name: "a", Type: DataType{Name: "INT"}},
+					{Name: "b", Type: DataType{Name: "INT"}},
+				},
+				ForeignKeys: []ForeignKey{{Columns: []string{"a", "b"}, Table: "q", RefColumns: []string{"x", "y"}, OnDelete: Restrict, OnUpdate: SetNull}},
+			},
+		},
+		{
+			Name: "INSERT SELECT",
+			SQL:  "INSERT INTO t (a, b) SELECT x, y FROM s;",
+			Expected: &InsertStatement{Table: &TableName{Name: "t"}, Columns: []string{"a", "b"}, Query: &SelectStatement{
+				Items: []SelectItem{{Expr: &Identifier{Name: "x"}}, {Expr: &Identifier{Name: "y"}}},
+				From:  &TableName{Name: "s"},
+			}},
+		},
+		{
+			Name: "INSERT ON CONFLICT DO UPDATE",
+			SQL:  "INSERT INTO t (id, n) VALUES (1, 2) ON CONFLICT (id) DO UPDATE SET n = excluded.n WHERE t.n < 5;",
+			Expected: &InsertStatement{Table: &TableName{Name: "t"}, Columns: []string{"id", "n"},
+				Rows: [][]Expr{{&Literal{Kind: NumberLiteral, Value: "1"}, &Literal{Kind: NumberLiteral, Value: "2"}}},
+				OnConflict: &OnConflict{Columns: []string{"id"}, DoUpdate: true,
+					Set:   []Assignment{{Column: "n", Value: &Identifier{Table: "excluded", Name: "n"}}},
+					Where: &BinaryExpr{Operator: Lt, Left: &Identifier{Table: "t", Name: "n"}, Right: &Literal{Kind: NumberLiteral, Value: "5"}},
+				},
+			},
+		},
+		{
+			Name: "INSERT SELECT ON CONFLICT DO NOTHING",
+			SQL:  "INSERT INTO t (a) SELECT x FROM s on conflict do nothing;",
+			Expected: &InsertStatement{Table: &TableName{Name: "t"}, Columns: []string{"a"},
+				Query:      &SelectStatement{Items: []SelectItem{{Expr: &Identifier{Name: "x"}}}, From: &TableName{Name: "s"}},
+				OnConflict: &OnConflict{},
+			},
 		},
 		{
-			Name: "DROP TABLE with quotes",
-			SQL:  "DROP TABLE \"mytable\";",
-			Expected: Query{
-				Type:      Drop,
-				TableName: "mytable",
+			Name: "UPDATE RETURNING",
+			SQL:  "UPDATE t SET n = 1 WHERE id = 2 RETURNING id AS ident, *;",
+			Expected: &UpdateStatement{Table: &TableName{Name: "t"},
+				Set:       []Assignment{{Column: "n", Value: &Literal{Kind: NumberLiteral, Value: "1"}}},
+				Where:     &BinaryExpr{Operator: Eq, Left: &Identifier{Name: "id"}, Right: &Literal{Kind: NumberLiteral, Value: "2"}},
+				Returning: []SelectItem{{Expr: &Identifier{Name: "id"}, Alias: "ident"}, {Star: true}},
 			},
-			Err: nil,
 		},
 		{
-			Name: "DROP TABLE with no quotes",
-			SQL:  "DROP TABLE sometable;",
-			Expected: Query{
-				Type:      Drop,
-				TableName: "sometable",
+			Name: "DELETE RETURNING",
+			SQL:  "DELETE FROM t WHERE id = 2 RETURNING name;",
+			Expected: &DeleteStatement{Table: &TableName{Name: "t"},
+				Where:     &BinaryExpr{Operator: Eq, Left: &Identifier{Name: "id"}, Right: &Literal{Kind: NumberLiteral, Value: "2"}},
+				Returning: []SelectItem{{Expr: &Identifier{Name: "name"}}},
 			},
-			Err: nil,
+		},
+		{
+			Name:     "CREATE TABLE IF NOT EXISTS",
+			SQL:      "CREATE TABLE IF NOT EXISTS t (id int);",
+			Expected: &CreateTableStatement{Name: "t", IfNotExists: true, Columns: []ColumnDef{{Name: "id", Type: DataType{Name: "INT"}}}},
+		},
+		{
+			Name: "CREATE TABLE AS SELECT",
+			SQL:  "CREATE TABLE t AS SELECT id, a AS b FROM s WHERE id > 1;",
+			Expected: &CreateTableStatement{Name: "t", Query: &SelectStatement{
+				Items: []SelectItem{{Expr: &Identifier{Name: "id"}}, {Expr: &Identifier{Name: "a"}, Alias: "b"}},
+				From:  &TableName{Name: "s"},
+				Where: &BinaryExpr{Operator: Gt, Left: &Identifier{Name: "id"}, Right: &Literal{Kind: NumberLiteral, Value: "1"}},
+			}},
+		},
+		{
+			Name:     "CREATE UNIQUE INDEX",
+			SQL:      "create unique index ix on t (a, b);",
+			Expected: &CreateIndexStatement{Name: "ix", Table: "t", Columns: []string{"a", "b"}, Unique: true},
+		},
+		{
+			Name:     "CREATE INDEX",
+			SQL:      "CREATE INDEX ix ON t (a);",
+			Expected: &CreateIndexStatement{Name: "ix", Table: "t", Columns: []string{"a"}},
+		},
+		{
+			Name: "CREATE INDEX on an expression",
+			SQL:  "CREATE INDEX ix ON t (a, (doc->>'$.name'));",
+			Expected: &CreateIndexStatement{Name: "ix", Table: "t", Columns: []string{"a", ""}, Exprs: []Expr{nil,
+				&BinaryExpr{Operator: JSONGetText, Left: &Identifier{Name: "doc"}, Right: &Literal{Kind: StringLiteral, Value: "$.name"}}}},
+		},
+		{
+			Name:     "DROP INDEX",
+			SQL:      "DROP INDEX ix;",
+			Expected: &DropIndexStatement{Name: "ix"},
+		},
+		{
+			Name: "ALTER TABLE ADD COLUMN",
+			SQL:  "ALTER TABLE t ADD column int default 0;",
+			Expected: &AlterTableStatement{Table: "t", Action: AddColumn, Column: ColumnDef{Name: "column", Type: DataType{Name: "INT"},
+				Constraints: []ColumnConstraint{{Kind: DefaultConstraint, Expr: &Literal{Kind: NumberLiteral, Value: "0"}}}}},
+		},
+		{
+			Name:     "ALTER TABLE DROP COLUMN",
+			SQL:      "ALTER TABLE t DROP COLUMN a;",
+			Expected: &AlterTableStatement{Table: "t", Action: DropColumn, Name: "a"},
+		},
+		{
+			Name:     "ALTER TABLE RENAME COLUMN",
+			SQL:      "ALTER TABLE t RENAME a TO b;",
+			Expected: &AlterTableStatement{Table: "t", Action: RenameColumn, Name: "a", NewName: "b"},
+		},
+		{
+			Name:     "ALTER TABLE RENAME TO",
+			SQL:      "ALTER TABLE t RENAME TO u;",
+			Expected: &AlterTableStatement{Table: "t", Action: RenameTable, NewName: "u"},
 		},
 	}
 
 	for _, tc := range ts {
 		t.Run(tc.Name, func(t *testing.T) {
-			_, err := Parse(tc.SQL)
-			if tc.Err != nil && err == nil {
-				t.Errorf("Error should have been %v", tc.Err)
-			}
-			if tc.Err == nil && err != nil {
-				t.Errorf("Error should have been nil but was %v", err)
-			}
-			if tc.Err != nil && err != nil {
-				require.Equal(t, tc.Err, err, "Unexpected error")
-			}
+			q, err := Parse(tc.SQL)
+			require.NoError(t, err)
+			require.Equal(t, tc.Expected, q.Statement)
 		})
 	}
 }
+
+func TestParseArgs(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 30, 15, 250000000, time.FixedZone("", 3600))
+	q, err := ParseArgs("UPDATE t SET a = ?, b = ?, c = ?, d = ? WHERE e = ? AND f IN (?, ?, ?);",
+		[]any{"it's", int64(-3), uint64(math.MaxUint64), 2.0, at, []byte{0x0a, 0xff}, true, nil})
+	require.NoError(t, err)
+	require.Equal(t, &UpdateStatement{
+		Table: &TableName{Name: "t"},
+		Set: []Assignment{
+			{Column: "a", Value: &Literal{Kind: StringLiteral, Value: "it's"}},
+			{Column: "b", Value: &Literal{Kind: NumberLiteral, Value: "-3"}},
+			{Column: "c", Value: &Literal{Kind: NumberLiteral, Value: "18446744073709551615"}},
+			{Column: "d", Value: &Literal{Kind: NumberLiteral, Value: "2.0"}},
+		},
+		Where: &BinaryExpr{Operator: And,
+			Left: &BinaryExpr{Operator: Eq, Left: &Identifier{Name: "e"}, Right: &Literal{Kind: TimestampLiteral, Value: "2024-03-01T12:30:15.25+01:00"}},
+			Right: &InExpr{Expr: &Identifier{Name: "f"}, List: []Expr{
+				&Literal{Kind: HexLiteral, Value: "0aff"}, &Literal{Kind: BoolLiteral, Value: "TRUE"}, &Literal{Kind: NullLiteral, Value: "NULL"},
+			}},
+		},
+	}, q.Statement)
+
+	_, err = ParseArgs("SELECT a FROM t WHERE a = ?;", nil)
+	require.EqualError(t, err, "no argument given for placeholder 1 (line 1, column 27)")
+	_, err = ParseArgs("SELECT a FROM t WHERE a = ?;", []any{int64(1), int64(2)})
+	require.EqualError(t, err, "2 arguments given for 1 placeholders (line 1, column 28)")
+	var parseErr *ParseError
+	require.ErrorAs(t, err, &parseErr)
+	require.Equal(t, "?", parseErr.Expected)
+	_, err = ParseArgs("SELECT a FROM t WHERE a = ?;", []any{int32(1)})
+	require.EqualError(t, err, "argument 1: unsupported type int32 (line 1, column 27)")
+}
+
+func TestLowerStatement(t *testing.T) {
+	q, err := Parse("SELECT a AS z, b FROM t WHERE a >= 1 AND b = c;")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, q.Fields)
+	require.Equal(t, map[string]string{"a": "z"}, q.Aliases)
+	require.Equal(t, []Condition{
+		{Operand1: "a", Operand1IsField: true, Operator: Gte, Operand2: "1"},
+		{Operand1: "b", Operand1IsField: true, Operator: Eq, Operand2: "c", Operand2IsField: true},
+	}, q.Conditions)
+
+	q, err = Parse("SELECT a FROM t WHERE a = 1 OR b = 2;")
+	require.NoError(t, err)
+	require.Nil(t, q.Conditions, "OR can't be expressed as conditions")
+
+	q, err = Parse("EXPLAIN SELECT a FROM t;")
+	require.NoError(t, err)
+	require.Equal(t, Explain, q.Type)
+	require.Equal(t, "t", q.TableName)
+
+	q, err = Parse("ANALYZE t;")
+	require.NoError(t, err)
+	require.Equal(t, Analyze, q.Type)
+	require.Equal(t, "t", q.TableName)
+
+	_, err = Parse("EXPLAIN DELETE FROM t;")
+	require.EqualError(t, err, "at WHERE: WHERE clause is mandatory for UPDATE & DELETE (line 1, column 22)")
+}
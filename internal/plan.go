@@ -0,0 +1,392 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+/*
+Query plans are trees of operators, rows are pulled from the root one at a time (volcano model).
+Scans at the leaves read pages through the buffer pool, every operator keeps counters
+of the rows it produced, pages it fetched and time spent so EXPLAIN ANALYZE can report them.
+*/
+
+// planNode is a single operator of a query plan. next returns io.EOF once no rows are left
+type planNode interface {
+	next() ([]Cell, error)
+	explain() (operator string, detail string)
+	children() []planNode
+	stats() *operatorStats
+}
+
+// operatorStats is embedded by every operator, elapsed includes time spent in its children.
+// estRows and cost are the planner's estimates, the other fields count what actually happened
+type operatorStats struct {
+	estRows float64
+	cost    float64
+	rows    int64
+	buffer  bufferStats
+	elapsed time.Duration
+}
+
+func (s *operatorStats) stats() *operatorStats {
+	return s
+}
+
+// pull fetches the next row of n and records it in the stats of n
+func pull(n planNode) ([]Cell, error) {
+	st := n.stats()
+	start := time.Now()
+	row, err := n.next()
+	st.elapsed += time.Since(start)
+	if err == nil {
+		st.rows++
+	}
+	return row, err
+}
+
+// drain runs the plan to completion and returns every row produced by the root
+func drain(root planNode) ([][]Cell, error) {
+	rows := make([][]Cell, 0)
+	for {
+		row, err := pull(root)
+		if err == io.EOF {
+			return rows, nil
+		} else if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+// seqScan reads every page of the table in PageID order
+type seqScan struct {
+	operatorStats
+	table *Table
+	pool  *bufferPoolManager
+	page  PageID
+	rows  [][]Cell // rows of the last fetched page not returned yet
+}
+
+func (s *seqScan) next() ([]Cell, error) {
+	for len(s.rows) == 0 {
+		if uint64(s.page) > s.table.lastPage {
+			return nil, io.EOF
+		}
+		s.rows = s.pool.fetchPage(s.table.Name, s.page, &s.buffer)
+		s.page++
+	}
+	row := s.rows[0]
+	s.rows = s.rows[1:]
+	return row, nil
+}
+
+func (s *seqScan) explain() (string, string) {
+	return "Seq Scan", fmt.Sprintf("on %q", s.table.Name)
+}
+
+func (s *seqScan) children() []planNode {
+	return nil
+}
+
+// keyBound is one end of a key range
+type keyBound struct {
+	value     any    //converted to the type of the key column
+	key       []byte //value encoded with appendKey
+	inclusive bool
+}
+
+/*
+keyRange bounds the keys visited by an index scan.
+The leading key columns may be fixed to one value each, lo and hi then bound the column after them
+and their keys start with the prefix. Nil bounds are open
+*/
+type keyRange struct {
+	prefix    []any  //values of the leading key columns
+	prefixKey []byte //prefix encoded with appendKey
+	lo, hi    *keyBound
+}
+
+// fixes the next key column to value, must be called before any bound is set
+func (r *keyRange) fix(value any) {
+	r.prefix = append(r.prefix, value)
+	r.prefixKey = appendKey(r.prefixKey, value)
+}
+
+// sets the bounds of the column after the prefix to those of column, a range over that column alone
+func (r *keyRange) narrow(column keyRange) {
+	for _, bound := range []**keyBound{&column.lo, &column.hi} {
+		if *bound != nil {
+			withPrefix := **bound
+			withPrefix.key = append(slices.Clip(r.prefixKey), withPrefix.key...)
+			*bound = &withPrefix
+		}
+	}
+	r.lo, r.hi = column.lo, column.hi
+}
+
+// first key of the range to search the index from, nil to start at the smallest key
+func (r keyRange) start() []byte {
+	if r.lo != nil {
+		return r.lo.key
+	}
+	return r.prefixKey
+}
+
+func (r keyRange) contains(key []byte) bool {
+	if !bytes.HasPrefix(key, r.prefixKey) {
+		return false
+	}
+	if (r.lo != nil || r.hi != nil) && key[len(r.prefixKey)] == keyNull { //null is never in a range
+		return false
+	}
+	if r.lo != nil {
+		if order := compareKey(key, r.lo.key); order < 0 || (order == 0 && !r.lo.inclusive) {
+			return false
+		}
+	}
+	return !r.beyond(key)
+}
+
+// reports whether key is past the upper bound so no larger key can be in the range
+func (r keyRange) beyond(key []byte) bool {
+	if r.hi == nil {
+		return compareKey(key, r.prefixKey) > 0
+	}
+	order := compareKey(key, r.hi.key)
+	return order > 0 || (order == 0 && !r.hi.inclusive)
+}
+
+// reports whether the bounds hold a single value of the column after the prefix
+func (r keyRange) isPoint() bool {
+	return r.lo != nil && r.hi != nil && r.lo.inclusive && r.hi.inclusive && bytes.Equal(r.lo.key, r.hi.key)
+}
+
+// reports whether the range only fixes the leading key columns
+func (r keyRange) isPrefix() bool {
+	return len(r.prefix) > 0 && r.lo == nil && r.hi == nil
+}
+
+// reports whether no key can be in the range
+func (r keyRange) isEmpty() bool {
+	if r.lo == nil || r.hi == nil {
+		return false
+	}
+	order := bytes.Compare(r.lo.key, r.hi.key)
+	return order > 0 || (order == 0 && !(r.lo.inclusive && r.hi.inclusive))
+}
+
+// describes the range over the key columns, names are written as sql
+func (r keyRange) String(columns []string) string {
+	bounds := make([]string, 0, len(r.prefix)+2)
+	for i, value := range r.prefix {
+		bounds = append(bounds, fmt.Sprintf("%s = %s", columns[i], FormatExpr(valueLiteral(value))))
+	}
+	if len(r.prefix) == len(columns) {
+		return strings.Join(bounds, " AND ")
+	}
+	column := columns[len(r.prefix)]
+	if r.isPoint() {
+		bounds = append(bounds, fmt.Sprintf("%s = %s", column, FormatExpr(valueLiteral(r.lo.value))))
+		return strings.Join(bounds, " AND ")
+	}
+	if r.lo != nil {
+		op := Gt
+		if r.lo.inclusive {
+			op = Gte
+		}
+		bounds = append(bounds, fmt.Sprintf("%s %s %s", column, op, FormatExpr(valueLiteral(r.lo.value))))
+	}
+	if r.hi != nil {
+		op := Lt
+		if r.hi.inclusive {
+			op = Lte
+		}
+		bounds = append(bounds, fmt.Sprintf("%s %s %s", column, op, FormatExpr(valueLiteral(r.hi.value))))
+	}
+	return strings.Join(bounds, " AND ")
+}
+
+// indexScan looks up the keys of the range in an index and reads only the pages holding them
+type indexScan struct {
+	operatorStats
+	table  *Table
+	pool   *bufferPoolManager
+	index  tableIndex
+	bounds keyRange
+	pages  []PageID // pages left to read, nil until the index was searched
+	rows   [][]Cell
+}
+
+func (s *indexScan) next() ([]Cell, error) {
+	if s.pages == nil {
+		s.pages = s.searchPages()
+	}
+	bounded := min(len(s.bounds.prefix)+1, len(s.index.columns))
+	for {
+		for len(s.rows) > 0 {
+			row := s.rows[0]
+			s.rows = s.rows[1:]
+			if key := s.table.appendPartsKey(nil, row, s.index.columns[:bounded], s.index.exprs[:min(bounded, len(s.index.exprs))]); key != nil && s.bounds.contains(key) {
+				return row, nil
+			}
+		}
+		if len(s.pages) == 0 {
+			return nil, io.EOF
+		}
+		s.rows = s.pool.fetchPage(s.table.Name, s.pages[0], &s.buffer)
+		s.pages = s.pages[1:]
+	}
+}
+
+// walks the index within the range and returns the sorted pages holding those keys
+func (s *indexScan) searchPages() []PageID {
+	pages := make([]PageID, 0)
+	s.index.tree.ascend(s.bounds.start(), func(key []byte, loc int64) bool {
+		if s.bounds.beyond(key) {
+			return false
+		}
+		if s.bounds.contains(key) {
+			pages = append(pages, PageID(loc/PAGESIZE))
+		}
+		return true
+	})
+	slices.Sort(pages)
+	return slices.Compact(pages)
+}
+
+func (s *indexScan) explain() (string, string) {
+	columns := make([]string, len(s.index.columns))
+	for i, pos := range s.index.columns {
+		if pos == -1 {
+			columns[i] = FormatExpr(s.index.exprs[i])
+			continue
+		}
+		columns[i] = FormatExpr(&Identifier{Name: s.table.Columns[pos].columnName})
+	}
+	bounds := s.bounds.String(columns)
+	if s.index.name == "" {
+		return "Index Scan", fmt.Sprintf("on %q using primary key (%s)", s.table.Name, bounds)
+	}
+	return "Index Scan", fmt.Sprintf("on %q using index %q (%s)", s.table.Name, s.index.name, bounds)
+}
+
+func (s *indexScan) children() []planNode {
+	return nil
+}
+
+// filter passes on the rows of its child for which the predicate is TRUE
+type filter struct {
+	operatorStats
+	child     planNode
+	scope     *rowScope
+	predicate Expr
+}
+
+func (f *filter) next() ([]Cell, error) {
+	for {
+		row, err := pull(f.child)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := f.scope.matches(f.predicate, row)
+		if err != nil {
+			return nil, err
+		} else if ok {
+			return row, nil
+		}
+	}
+}
+
+func (f *filter) explain() (string, string) {
+	return "Filter", FormatExpr(f.predicate)
+}
+
+func (f *filter) children() []planNode {
+	return []planNode{f.child}
+}
+
+// project is the root of a select, the columns are picked out of each row by Rows
+type project struct {
+	operatorStats
+	child   planNode
+	table   *Table
+	columns []ResultColumn
+}
+
+func (p *project) next() ([]Cell, error) {
+	row, err := pull(p.child)
+	if err != nil {
+		return nil, err
+	}
+	return computeColumns(p.table, p.columns, row)
+}
+
+func (p *project) explain() (string, string) {
+	names := make([]string, len(p.columns))
+	for i := range p.columns {
+		names[i] = fmt.Sprintf("%q", p.columns[i].Name)
+	}
+	return "Project", strings.Join(names, ", ")
+}
+
+func (p *project) children() []planNode {
+	return []planNode{p.child}
+}
+
+// modify is the root of an UPDATE or DELETE, it writes the changes to every row of its child at once and returns the rows written
+type modify struct {
+	operatorStats
+	child   planNode
+	table   *Table
+	backend *Backend
+	delete  bool
+	change  func(row []Cell) ([]Cell, error) //new version of a matched row, unused by DELETE
+	written [][]Cell
+	done    bool
+}
+
+func (m *modify) next() ([]Cell, error) {
+	if !m.done {
+		m.done = true
+		matched, err := drain(m.child)
+		if err != nil {
+			return nil, err
+		}
+		m.written = make([][]Cell, len(matched))
+		if !m.delete {
+			for i := range matched {
+				if m.written[i], err = m.change(matched[i]); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if err := m.backend.writeRows(m.table, matched, m.written); err != nil {
+			return nil, err
+		}
+		if m.delete {
+			m.written = matched
+		}
+	}
+	if len(m.written) == 0 {
+		return nil, io.EOF
+	}
+	row := m.written[0]
+	m.written = m.written[1:]
+	return row, nil
+}
+
+func (m *modify) explain() (string, string) {
+	if m.delete {
+		return "Delete", fmt.Sprintf("on %q", m.table.Name)
+	}
+	return "Update", fmt.Sprintf("on %q", m.table.Name)
+}
+
+func (m *modify) children() []planNode {
+	return []planNode{m.child}
+}
@@ -0,0 +1,486 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+/*
+The planner turns a statement into a tree of operators (see plan.go).
+Every candidate scan gets a cost in units of sequential page reads, estimated from the statistics
+gathered by ANALYZE or from the size of the table file when the table was never analyzed.
+The cheapest scan wins and the conditions it doesn't answer are checked by a filter above it.
+*/
+
+const (
+	seqPageCost    = 1.0
+	randomPageCost = 1.5  // index scans read pages out of order
+	cpuRowCost     = 0.01 // checking or passing on a single row
+
+	defaultEqSelectivity    = 0.005
+	defaultRangeSelectivity = 1.0 / 3
+	defaultSelectivity      = 0.5
+)
+
+// planSelect builds the plan for a select, the table must be read locked until the plan is drained
+func (b *Backend) planSelect(table *Table, q Query) (*project, error) {
+	stmt, ok := q.Statement.(*SelectStatement)
+	if !ok {
+		return nil, errors.New("only SELECT statements can be planned")
+	}
+	scan, err := b.planScan(table, newRowScope(table, stmt.From.(*TableName)), stmt.Where)
+	if err != nil {
+		return nil, err
+	}
+	if slices.ContainsFunc(stmt.Items, func(item SelectItem) bool { return isAggregate(item.Expr) }) {
+		return planAggregate(table, scan, stmt.Items)
+	}
+	columns, err := resultColumns(table, stmt.Items)
+	if err != nil {
+		return nil, err
+	}
+	root := &project{child: scan, table: table, columns: columns}
+	root.estRows, root.cost = scan.stats().estRows, scan.stats().cost
+	return root, nil
+}
+
+// columns of table selected by the items of a SELECT or RETURNING clause.
+// Expressions are computed into cells following the columns of the table
+func resultColumns(table *Table, items []SelectItem) ([]ResultColumn, error) {
+	columns := make([]ResultColumn, 0, len(table.Columns))
+	missing := make([]string, 0)
+	scope := &rowScope{table: table}
+	computed := 0
+	for _, item := range items {
+		if item.Star {
+			for i := range table.Columns {
+				columns = append(columns, ResultColumn{Name: table.Columns[i].columnName, ColumnType: table.Columns[i].columnType, columnPos: i, scale: table.Columns[i].columnScale})
+			}
+			continue
+		}
+		if ident, ok := item.Expr.(*Identifier); ok {
+			pos := slices.IndexFunc(table.Columns, func(col Column) bool { return col.columnName == ident.Name })
+			if pos == -1 {
+				missing = append(missing, ident.Name)
+				continue
+			}
+			name := ident.Name
+			if item.Alias != "" {
+				name = item.Alias
+			}
+			columns = append(columns, ResultColumn{Name: name, ColumnType: table.Columns[pos].columnType, columnPos: pos, scale: table.Columns[pos].columnScale})
+			continue
+		}
+		if err := scope.bind(item.Expr); err != nil {
+			return nil, err
+		}
+		col, ok := scope.valueColumn(item.Expr)
+		if !ok {
+			return nil, fmt.Errorf("cannot select %s, the types of its operands cannot be combined", FormatExpr(item.Expr))
+		}
+		if item.Alias != "" {
+			col.columnName = item.Alias
+		}
+		columns = append(columns, ResultColumn{Name: col.columnName, ColumnType: col.columnType, columnPos: len(table.Columns) + computed, scale: col.columnScale, expr: item.Expr, computed: &col})
+		computed++
+	}
+	if len(missing) != 0 {
+		return nil, fmt.Errorf("Columns not in table: %s", strings.Join(missing, "|"))
+	}
+	return columns, nil
+}
+
+// appends the cells of the computed columns to a copy of row, row is returned as is without computed columns
+func computeColumns(table *Table, columns []ResultColumn, row []Cell) ([]Cell, error) {
+	scope := &rowScope{table: table}
+	extended := row
+	for i := range columns {
+		if columns[i].expr == nil {
+			continue
+		}
+		if len(extended) == len(row) {
+			extended = slices.Clip(row)
+		}
+		v, err := scope.eval(columns[i].expr, row)
+		if err != nil {
+			return nil, err
+		}
+		cell, err := valueCell(columns[i].computed, v)
+		if err != nil {
+			return nil, err
+		}
+		extended = append(extended, cell)
+	}
+	return extended, nil
+}
+
+// planScan returns the cheapest operators reading the rows of table that match where
+func (b *Backend) planScan(table *Table, scope *rowScope, where Expr) (planNode, error) {
+	if err := scope.bind(where); err != nil {
+		return nil, err
+	}
+	est := estimateTable(table)
+	var scan planNode = &seqScan{table: table, pool: b.bufferPool}
+	scan.stats().estRows, scan.stats().cost = est.rows, est.seqCost()
+	if where == nil {
+		return scan, nil
+	}
+
+	conditions := splitConjunction(where)
+	rest := conditions
+	for _, index := range table.scannableIndexes() {
+		bounds, remaining, ok := indexBounds(scope, index, conditions)
+		if !ok {
+			continue
+		}
+		candidate := &indexScan{table: table, pool: b.bufferPool, index: index, bounds: bounds}
+		candidate.estRows = est.rangeRows(index, bounds)
+		candidate.cost = est.indexCost(candidate.estRows, index.name == "")
+		if candidate.cost < scan.stats().cost {
+			scan, rest = candidate, remaining
+		}
+	}
+	conditions = rest
+	if len(conditions) == 0 {
+		return scan, nil
+	}
+
+	f := &filter{child: scan, scope: scope, predicate: joinConjunction(conditions)}
+	f.estRows = scan.stats().estRows * est.selectivity(scope, f.predicate)
+	f.cost = scan.stats().cost + scan.stats().estRows*cpuRowCost
+	return f, nil
+}
+
+/*
+indexBounds turns the conditions on the key columns of index into the range of keys to visit.
+Equalities on the leading columns fix them, the first column without one may be bounded by a range.
+Returns the range and the conditions that are not part of it, ok is false if no condition bounds the first column.
+*/
+func indexBounds(scope *rowScope, index tableIndex, conditions []Expr) (bounds keyRange, rest []Expr, ok bool) {
+	rest = conditions
+	for part := range index.columns {
+		column, remaining, found := keyBounds(scope, index, part, rest)
+		if !found {
+			break
+		}
+		ok, rest = true, remaining
+		if !column.isPoint() {
+			bounds.narrow(column)
+			break
+		}
+		bounds.fix(column.lo.value)
+	}
+	return bounds, rest, ok
+}
+
+/*
+keyBounds combines every condition comparing key part of index to a literal into one range.
+Returns the range and the conditions that are not part of it, ok is false if no condition bounds the key part.
+*/
+func keyBounds(scope *rowScope, index tableIndex, part int, conditions []Expr) (bounds keyRange, rest []Expr, ok bool) {
+	rest = make([]Expr, 0, len(conditions))
+	for _, cond := range conditions {
+		op, value, isKey := keyComparison(scope, index, part, cond)
+		if !isKey {
+			rest = append(rest, cond)
+			continue
+		}
+		ok = true
+		if op == Eq || op == Gt || op == Gte {
+			bounds.tightenLo(value, op != Gt)
+		}
+		if op == Eq || op == Lt || op == Lte {
+			bounds.tightenHi(value, op != Lt)
+		}
+	}
+	return bounds, rest, ok
+}
+
+// matches "column op literal" and "literal op column", the operator is returned as if the column was on the left
+// and the literal is converted to the type of the column. A key part that is an expression matches the same expression
+func keyComparison(scope *rowScope, index tableIndex, part int, cond Expr) (Operator, any, bool) {
+	bin, ok := cond.(*BinaryExpr)
+	if !ok || bin.Operator == Ne || bin.Operator < Eq || bin.Operator > Lte {
+		return 0, nil, false
+	}
+	op, ident, lit := bin.Operator, bin.Left, bin.Right
+	if _, isLit := ident.(*Literal); isLit {
+		op, ident, lit = flipComparison(op), bin.Right, bin.Left
+	}
+	if !isKeyPart(scope, index, part, ident) {
+		return 0, nil, false
+	}
+	literal, ok := lit.(*Literal)
+	if !ok {
+		return 0, nil, false
+	}
+	value, err := literalValue(literal)
+	if err != nil || value == nil {
+		return 0, nil, false
+	}
+	col := scope.table.keyColumn(index.columns, index.exprs, part)
+	cell, err := valueCell(col, value)
+	if err != nil {
+		return 0, nil, false
+	}
+	if order, err := compareValues(value, cellValue(col, cell)); err != nil || order != 0 {
+		return 0, nil, false //the column cannot hold the value exactly, 1.005 rounded to 1.01 is no bound
+	}
+	return op, cellValue(col, cell), true
+}
+
+// whether expr is key part of index, the column at its position or an expression written the same way
+func isKeyPart(scope *rowScope, index tableIndex, part int, expr Expr) bool {
+	if index.columns[part] == -1 {
+		return FormatExpr(expr) == FormatExpr(index.exprs[part])
+	}
+	column, ok := expr.(*Identifier)
+	if !ok {
+		return false
+	}
+	pos, err := scope.lookup(column)
+	return err == nil && pos == index.columns[part]
+}
+
+// operator with its operands swapped, 1 < a is a > 1
+func flipComparison(op Operator) Operator {
+	switch op {
+	case Gt:
+		return Lt
+	case Lt:
+		return Gt
+	case Gte:
+		return Lte
+	case Lte:
+		return Gte
+	}
+	return op
+}
+
+func (r *keyRange) tightenLo(value any, inclusive bool) {
+	if r.lo != nil {
+		order, _ := compareValues(value, r.lo.value)
+		if order < 0 || (order == 0 && (inclusive || !r.lo.inclusive)) {
+			return
+		}
+	}
+	r.lo = &keyBound{value: value, key: appendKey(nil, value), inclusive: inclusive}
+}
+
+func (r *keyRange) tightenHi(value any, inclusive bool) {
+	if r.hi != nil {
+		order, _ := compareValues(value, r.hi.value)
+		if order > 0 || (order == 0 && (inclusive || !r.hi.inclusive)) {
+			return
+		}
+	}
+	r.hi = &keyBound{value: value, key: appendKey(nil, value), inclusive: inclusive}
+}
+
+// joins expressions with AND, the inverse of splitConjunction
+func joinConjunction(exprs []Expr) Expr {
+	joined := exprs[0]
+	for _, expr := range exprs[1:] {
+		joined = &BinaryExpr{Operator: And, Left: joined, Right: expr}
+	}
+	return joined
+}
+
+// tableEstimate is what the planner believes about the size of a table
+type tableEstimate struct {
+	table *Table
+	stats *tableStats // nil if the table was never analyzed
+	rows  float64
+	pages float64
+}
+
+func estimateTable(table *Table) tableEstimate {
+	est := tableEstimate{table: table, stats: table.stats, pages: float64(table.lastPage + 1)}
+	if est.stats != nil && est.stats.pages > 0 {
+		// rows per page of the snapshot applied to the current size of the table
+		est.rows = float64(est.stats.rows) / float64(est.stats.pages) * est.pages
+	} else {
+		est.rows = est.pages * float64(table.rowsPerPage())
+	}
+	return est
+}
+
+func (e tableEstimate) seqCost() float64 {
+	return e.pages*seqPageCost + e.rows*cpuRowCost
+}
+
+/*
+the index itself is kept in memory so only the pages holding matching rows are read.
+Rows are stored in primary key order as keys are handed out in increasing order,
+rows found through a secondary index are assumed to each be on a different page
+*/
+func (e tableEstimate) indexCost(rows float64, clustered bool) float64 {
+	pages := math.Min(e.pages, math.Ceil(rows))
+	if clustered && e.rows > 0 {
+		pages = math.Min(e.pages, math.Ceil(rows/(e.rows/e.pages)))
+	}
+	return pages*randomPageCost + 2*rows*cpuRowCost // every key is visited in the index and then in its page
+}
+
+// estimated number of rows with a key in bounds
+func (e tableEstimate) rangeRows(index tableIndex, bounds keyRange) float64 {
+	if bounds.isEmpty() {
+		return 0
+	}
+	if index.unique && bounds.isPrefix() && len(bounds.prefix) == len(index.columns) {
+		return math.Min(1, e.rows)
+	}
+	rows := e.rows
+	for _, pos := range index.columns[:len(bounds.prefix)] {
+		rows *= e.eqSelectivity(e.statsAt(pos))
+	}
+	if bounds.isPrefix() {
+		return rows
+	}
+
+	pos := index.columns[len(bounds.prefix)]
+	col := e.statsAt(pos)
+	if bounds.isPoint() {
+		return rows * e.eqSelectivity(col)
+	}
+	return rows * e.rangeSelectivity(pos, e.table.keyColumn(index.columns, index.exprs, len(bounds.prefix)), bounds)
+}
+
+// stats of the column at pos, nil if the table was never analyzed or pos is -1 for an expression
+func (e tableEstimate) statsAt(pos int) *columnStats {
+	if e.stats == nil || pos == -1 {
+		return nil
+	}
+	return &e.stats.columns[pos]
+}
+
+// estimated fraction of rows with a value of column, at pos in the table, within the bounds
+func (e tableEstimate) rangeSelectivity(pos int, column *Column, bounds keyRange) float64 {
+	col := e.statsAt(pos)
+	min, minOk := toFloat(cellValue(column, col.minOrNil()))
+	max, maxOk := toFloat(cellValue(column, col.maxOrNil()))
+	if !minOk || !maxOk { // without stats a range open at either end may well hold every row
+		if bounds.lo != nil && bounds.hi != nil {
+			return defaultRangeSelectivity
+		}
+		return 1
+	}
+	lo, hi := min, max
+	if v, ok := boundFloat(bounds.lo); ok {
+		lo = math.Max(lo, v)
+	}
+	if v, ok := boundFloat(bounds.hi); ok {
+		hi = math.Min(hi, v)
+	}
+	if hi < lo {
+		return 0
+	}
+	if column.columnType == INT || column.columnType == UINT { // integers are discrete, both ends count
+		return (hi - lo + 1) / (max - min + 1)
+	}
+	if max == min {
+		return 1
+	}
+	return (hi - lo) / (max - min)
+}
+
+func boundFloat(bound *keyBound) (float64, bool) {
+	if bound == nil {
+		return 0, false
+	}
+	return toFloat(bound.value)
+}
+
+// estimated fraction of rows for which expr is TRUE
+func (e tableEstimate) selectivity(scope *rowScope, expr Expr) float64 {
+	switch ex := expr.(type) {
+	case *BinaryExpr:
+		switch ex.Operator {
+		case And:
+			return e.selectivity(scope, ex.Left) * e.selectivity(scope, ex.Right)
+		case Or:
+			left, right := e.selectivity(scope, ex.Left), e.selectivity(scope, ex.Right)
+			return left + right - left*right
+		case Eq, Ne, Gt, Lt, Gte, Lte:
+			return e.comparisonSelectivity(scope, ex)
+		}
+	case *UnaryExpr:
+		if ex.Operator == Not {
+			return 1 - e.selectivity(scope, ex.Expr)
+		}
+	case *IsNullExpr:
+		selectivity := defaultEqSelectivity
+		if col := e.columnStats(scope, ex.Expr); col != nil && e.stats.rows > 0 {
+			selectivity = float64(col.nulls) / float64(e.stats.rows)
+		}
+		if ex.Not {
+			return 1 - selectivity
+		}
+		return selectivity
+	case *InExpr:
+		selectivity := math.Min(1, float64(len(ex.List))*e.eqSelectivity(e.columnStats(scope, ex.Expr)))
+		if ex.Not {
+			return 1 - selectivity
+		}
+		return selectivity
+	}
+	return defaultSelectivity
+}
+
+func (e tableEstimate) comparisonSelectivity(scope *rowScope, bin *BinaryExpr) float64 {
+	op, column, other := bin.Operator, bin.Left, bin.Right
+	if _, ok := column.(*Literal); ok {
+		op, column, other = flipComparison(op), bin.Right, bin.Left
+	}
+	col := e.columnStats(scope, column)
+	switch op {
+	case Eq:
+		return e.eqSelectivity(col)
+	case Ne:
+		return 1 - e.eqSelectivity(col)
+	}
+
+	lit, ok := other.(*Literal)
+	if col == nil || col.min == nil || !ok {
+		return defaultRangeSelectivity
+	}
+	pos, _ := scope.lookup(column.(*Identifier))
+	min, minOk := toFloat(cellValue(&scope.table.Columns[pos], col.min))
+	max, maxOk := toFloat(cellValue(&scope.table.Columns[pos], col.max))
+	value, err := literalValue(lit)
+	if s, ok := value.(string); ok && err == nil {
+		value, err = convertString(s, cellValue(&scope.table.Columns[pos], col.min))
+	}
+	v, ok := toFloat(value)
+	if !minOk || !maxOk || !ok || err != nil || max <= min {
+		return defaultRangeSelectivity
+	}
+	below := math.Max(0, math.Min(1, (v-min)/(max-min))) // fraction of values less than v
+	if op == Lt || op == Lte {
+		return below
+	}
+	return 1 - below
+}
+
+func (e tableEstimate) eqSelectivity(col *columnStats) float64 {
+	if col == nil || col.distinct == 0 {
+		return defaultEqSelectivity
+	}
+	return (1 - float64(col.nulls)/float64(max(e.stats.rows, 1))) / float64(col.distinct)
+}
+
+// stats of the column expr refers to, nil if expr is not a column or the table was never analyzed
+func (e tableEstimate) columnStats(scope *rowScope, expr Expr) *columnStats {
+	ident, ok := expr.(*Identifier)
+	if !ok {
+		return nil
+	}
+	pos, err := scope.lookup(ident)
+	if err != nil {
+		return nil
+	}
+	return e.statsAt(pos)
+}
@@ -9,6 +9,7 @@ type Query struct {
 	Fields            []string // Used for SELECT (i.e. SELECTed field names) and INSERT (INSERTEDed field names) and CREATE
 	Aliases           map[string]string
 	TableConstruction createQuery
+	Statement         Statement // syntax tree the fields above are derived from
 }
 
 type createQuery struct {
@@ -17,6 +18,10 @@ type createQuery struct {
 	notnullable  []string
 	primary      []string
 	unique       []string
+	defaults     map[string]Expr //DEFAULT expression by column name
+	generated    map[string]Expr //GENERATED ALWAYS AS expression by column name
+	checks       []checkDef      //column and table level CHECK constraints in order
+	foreignKeys  []ForeignKey    //column and table level FOREIGN KEY constraints, Columns is always set
 }
 
 type QueryType int
@@ -36,6 +41,16 @@ const (
 	Create
 	//Drop represents a DROP query
 	Drop
+	// Explain represents an EXPLAIN [ANALYZE] query, the fields above are lowered from the explained statement
+	Explain
+	// Analyze represents an ANALYZE query
+	Analyze
+	// CreateIndex represents a CREATE INDEX query
+	CreateIndex
+	// DropIndex represents a DROP INDEX query
+	DropIndex
+	// AlterTable represents an ALTER TABLE query
+	AlterTable
 )
 
 // Operator is between operands in a condition
@@ -50,6 +65,15 @@ const (
 	Lt                       // Lt -> "<"
 	Gte                      // Gte -> ">="
 	Lte                      // Lte -> "<="
+	And                      // And -> "AND"
+	Or                       // Or -> "OR"
+	Not                      // Not -> "NOT"
+	Plus                     // Plus -> "+"
+	Minus                    // Minus -> "-"
+	Multiply                 // Multiply -> "*"
+	Divide                   // Divide -> "/"
+	JSONGet                  // JSONGet -> "->"
+	JSONGetText              // JSONGetText -> "->>"
 )
 
 // Condition is a single boolean condition in a WHERE clause
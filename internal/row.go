@@ -2,6 +2,7 @@ package internal
 
 import (
 	"database/sql/driver"
+	"errors"
 	"io"
 )
 
@@ -14,6 +15,36 @@ type Rows struct {
 	columns []ResultColumn //should be result column holding name and type
 	index   uint64
 	rows    [][]Cell //holds all the values returned from the queries which is slice of array of bytes
+
+	affected     int64 //rows written by an INSERT, UPDATE or DELETE
+	lastInsertId int64 //primary key handed out to the last row inserted, 0 if none was
+}
+
+// rows written by an INSERT, UPDATE or DELETE projected on the columns of its RETURNING clause, no rows without one
+func returnRows(table *Table, columns []ResultColumn, rows [][]Cell) (*Rows, error) {
+	if len(columns) == 0 {
+		return &Rows{affected: int64(len(rows))}, nil
+	}
+	for i := range rows {
+		var err error
+		if rows[i], err = computeColumns(table, columns, rows[i]); err != nil {
+			return nil, err
+		}
+	}
+	return &Rows{columns: columns, rows: rows, affected: int64(len(rows))}, nil
+}
+
+// RowsAffected is the number of rows an INSERT, UPDATE or DELETE wrote, Rows is its driver.Result
+func (r *Rows) RowsAffected() (int64, error) {
+	return r.affected, nil
+}
+
+// LastInsertId is the primary key handed out to the last row an INSERT inserted
+func (r *Rows) LastInsertId() (int64, error) {
+	if r.lastInsertId == 0 {
+		return 0, errors.New("no primary key was handed out by the statement")
+	}
+	return r.lastInsertId, nil
 }
 
 func (r *Rows) Columns() []string {
@@ -42,21 +73,71 @@ func (r *Rows) Next(dest []driver.Value) error {
 		case INT:
 			if cell == nil {
 				dest[i] = nil
+				continue
 			}
 			dest[i] = cell.AsInt()
-		case CHAR:
+		case UINT:
+			if cell == nil {
+				dest[i] = nil
+				continue
+			}
+			if len(cell) == 8 { //values above math.MaxInt64 are kept by handing out an uint64
+				dest[i] = cell.asUint()
+				continue
+			}
+			dest[i] = int64(cell.asUint())
+		case CHAR, VARCHAR, TEXT:
 			if cell == nil {
 				dest[i] = nil
+				continue
 			}
 			dest[i] = cell.AsString()
+		case BLOB:
+			if cell == nil {
+				dest[i] = nil
+				continue
+			}
+			dest[i] = []byte(cell)
+		case DATE, TIME, TIMESTAMP:
+			if cell == nil {
+				dest[i] = nil
+				continue
+			}
+			dest[i] = cell.AsTime(r.columns[i].ColumnType)
+		case INTERVAL:
+			if cell == nil {
+				dest[i] = nil
+				continue
+			}
+			dest[i] = cell.asInterval().String()
+		case JSON:
+			if cell == nil {
+				dest[i] = nil
+				continue
+			}
+			dest[i] = jsonDoc(cell).String()
+		case DECIMAL:
+			if cell == nil {
+				dest[i] = nil
+				continue
+			}
+			dest[i] = cell.asDecimal(r.columns[i].scale).String()
+		case UUID:
+			if cell == nil {
+				dest[i] = nil
+				continue
+			}
+			dest[i] = uuid(cell).String()
 		case FLOAT:
 			if cell == nil {
 				dest[i] = nil
+				continue
 			}
 			dest[i] = cell.AsFloat()
 		case BOOL:
 			if cell == nil {
 				dest[i] = nil
+				continue
 			}
 			dest[i] = cell.AsBool()
 		}
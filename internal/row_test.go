@@ -0,0 +1,48 @@
+package internal
+
+import (
+	"database/sql/driver"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReturning(t *testing.T) {
+	b := newTestBackend(t,
+		"CREATE TABLE t (id int primary key, name char(5) unique, n int default 7, twice int generated always as (n * 2) stored);",
+		"INSERT INTO t (id, name, n) VALUES (1, 'a', 1);",
+	)
+	require.Equal(t, [][]any{{int64(2), int64(7), int64(14)}, {int64(3), int64(7), int64(14)}},
+		queryRows(t, b, "INSERT INTO t (name) VALUES ('b'), ('c') RETURNING id, n, twice;"))
+	require.Equal(t, [][]any{{int64(1), int64(5)}, {int64(4), int64(8)}},
+		queryRows(t, b, "INSERT INTO t (id, name, n) VALUES (1, 'a', 5), (4, 'd', 8) ON CONFLICT (id) DO UPDATE SET n = excluded.n RETURNING id, n;"))
+	require.Empty(t, queryRows(t, b, "INSERT INTO t (id, name) VALUES (1, 'a') ON CONFLICT DO NOTHING RETURNING id;"))
+	require.Equal(t, [][]any{{int64(2), int64(0)}},
+		queryRows(t, b, "INSERT INTO t (name, n) SELECT name, n FROM t WHERE id = 2 ON CONFLICT (name) DO UPDATE SET n = 0 RETURNING id, n;"))
+
+	rows, err := execSQL(b, "UPDATE t SET n = n + 1 WHERE id >= 3 RETURNING *;")
+	require.NoError(t, err)
+	require.Equal(t, []string{"id", "name", "n", "twice"}, rows.Columns())
+	rows, err = execSQL(b, "INSERT INTO t (name) VALUES ('z') RETURNING id AS ident;")
+	require.NoError(t, err)
+	require.Equal(t, []string{"ident"}, rows.Columns())
+	require.Equal(t, [][]any{{int64(3), int64(32)}, {int64(4), int64(36)}},
+		queryRows(t, b, "UPDATE t SET n = n * 2 WHERE id >= 3 AND id <= 4 RETURNING id, twice;"))
+	require.Equal(t, [][]any{{"c"}, {"d"}}, trimRows(queryRows(t, b, "DELETE FROM t WHERE id >= 3 AND id <= 4 RETURNING name;")))
+	require.Empty(t, queryRows(t, b, "DELETE FROM t WHERE id = 3 RETURNING name;"))
+
+	rows, err = execSQL(b, "DELETE FROM t WHERE id = 1;")
+	require.NoError(t, err)
+	require.Empty(t, rows.Columns(), "no rows without RETURNING")
+	require.Equal(t, io.EOF, rows.Next(nil))
+	affected, err := rows.(driver.Result).RowsAffected()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), affected)
+	_, err = execSQL(b, "DELETE FROM t WHERE id = 2 RETURNING missing;")
+	require.EqualError(t, err, "Columns not in table: missing")
+	require.Equal(t, [][]any{{int64(2)}}, queryRows(t, b, "UPDATE t SET n = 1 WHERE id = 2 RETURNING n + 1;"))
+	_, err = execSQL(b, "UPDATE t SET n = 2 WHERE id = 2 RETURNING name + 1;")
+	require.EqualError(t, err, `cannot select "name" + 1, the types of its operands cannot be combined`)
+	require.Len(t, queryRows(t, b, "SELECT id FROM t WHERE id = 2;"), 1, "nothing is written when RETURNING is invalid")
+}
@@ -0,0 +1,246 @@
+package internal
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+/*
+Statistics gathered by ANALYZE and used by the planner to estimate the cost of a plan.
+They are kept in the system catalog file main.stats next to main.db and loaded when the database is opened,
+the dot in its name keeps it apart from the files of the tables.
+Stats are a snapshot, the planner scales the row count by the current number of pages.
+*/
+
+const STATSFILE = "main.stats"
+
+type tableStats struct {
+	rows    int64
+	pages   int64
+	columns []columnStats // same order as Table.Columns
+}
+
+type columnStats struct {
+	nulls    int64
+	distinct int64 // estimate of the number of distinct non null values
+	min, max Cell  // nil when every value is null
+}
+
+// Analyze gathers statistics of the table named in the query or of every table when no name is given
+func (b *Backend) Analyze(q Query) error {
+	tables := make([]*Table, 0, len(b.tables))
+	if q.TableName != "" {
+		table, ok := b.checkTableExist(q)
+		if !ok {
+			return errors.New("Table does not exist")
+		}
+		tables = append(tables, table)
+	} else {
+		for i := range b.tables {
+			tables = append(tables, &b.tables[i])
+		}
+	}
+
+	for _, table := range tables {
+		table.tableLock.Lock()
+		stats, err := b.analyzeTable(table)
+		if err == nil {
+			table.stats = stats
+		}
+		table.tableLock.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return b.writeStatsToDisk()
+}
+
+// reads every row of the table, the table must be locked
+func (b *Backend) analyzeTable(table *Table) (*tableStats, error) {
+	stats := &tableStats{pages: int64(table.lastPage) + 1, columns: make([]columnStats, len(table.Columns))}
+	sketches := make([]distinctSketch, len(table.Columns))
+	scan := &seqScan{table: table, pool: b.bufferPool}
+	for {
+		row, err := scan.next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		stats.rows++
+		for i := range table.Columns {
+			col := &stats.columns[i]
+			if row[i] == nil {
+				col.nulls++
+				continue
+			}
+			value := cellValue(&table.Columns[i], row[i])
+			sketches[i].add(newCell(value))
+			if len(row[i]) >= nullStatsCell { //too long for the stats file, min and max only steer estimates
+				continue
+			}
+			if col.min == nil || compareCells(&table.Columns[i], row[i], col.min) < 0 {
+				col.min = row[i]
+			}
+			if col.max == nil || compareCells(&table.Columns[i], row[i], col.max) > 0 {
+				col.max = row[i]
+			}
+		}
+	}
+	for i := range stats.columns {
+		stats.columns[i].distinct = sketches[i].estimate()
+	}
+	return stats, nil
+}
+
+// smallest value of the column, nil if unknown
+func (c *columnStats) minOrNil() Cell {
+	if c == nil {
+		return nil
+	}
+	return c.min
+}
+
+// largest value of the column, nil if unknown
+func (c *columnStats) maxOrNil() Cell {
+	if c == nil {
+		return nil
+	}
+	return c.max
+}
+
+func compareCells(col *Column, a, b Cell) int {
+	order, _ := compareValues(cellValue(col, a), cellValue(col, b))
+	return order
+}
+
+/*
+distinctSketch estimates the number of distinct values with the k minimum values of their hashes.
+Below k values the count is exact, above it the density of the smallest hashes gives the estimate.
+*/
+type distinctSketch struct {
+	hashes []uint64 // sorted, at most sketchSize
+}
+
+const sketchSize = 1024
+
+func (d *distinctSketch) add(value []byte) {
+	h := fnv.New64a()
+	h.Write(value)
+	sum := h.Sum64()
+	pos, found := slices.BinarySearch(d.hashes, sum)
+	if found || (len(d.hashes) == sketchSize && pos == sketchSize) {
+		return
+	}
+	d.hashes = slices.Insert(d.hashes, pos, sum)
+	if len(d.hashes) > sketchSize {
+		d.hashes = d.hashes[:sketchSize]
+	}
+}
+
+func (d *distinctSketch) estimate() int64 {
+	if len(d.hashes) < sketchSize {
+		return int64(len(d.hashes))
+	}
+	kth := float64(d.hashes[sketchSize-1]) / math.MaxUint64
+	return int64(float64(sketchSize-1) / kth)
+}
+
+func (s *tableStats) toBytes(name string) []byte {
+	buf := make([]byte, 1, 100)
+	buf[0] = uint8(len(name))
+	buf = append(buf, name...)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(s.rows))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(s.pages))
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(s.columns)))
+	for _, col := range s.columns {
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(col.nulls))
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(col.distinct))
+		buf = appendStatsCell(buf, col.min)
+		buf = appendStatsCell(buf, col.max)
+	}
+	return buf
+}
+
+// returns name of the table the stats belong to and number of bytes read
+func (s *tableStats) fromBytes(buf []byte) (string, int) {
+	nameSize := int(buf[0])
+	name := string(buf[1 : 1+nameSize])
+	offset := 1 + nameSize
+	s.rows = int64(binary.LittleEndian.Uint64(buf[offset:]))
+	s.pages = int64(binary.LittleEndian.Uint64(buf[offset+8:]))
+	numCols := binary.LittleEndian.Uint16(buf[offset+16:])
+	offset += 18
+	s.columns = make([]columnStats, numCols)
+	for i := range s.columns {
+		s.columns[i].nulls = int64(binary.LittleEndian.Uint64(buf[offset:]))
+		s.columns[i].distinct = int64(binary.LittleEndian.Uint64(buf[offset+8:]))
+		offset += 16
+		var n int
+		s.columns[i].min, n = readStatsCell(buf[offset:])
+		offset += n
+		s.columns[i].max, n = readStatsCell(buf[offset:])
+		offset += n
+	}
+	return name, offset
+}
+
+const nullStatsCell = math.MaxUint16
+
+func appendStatsCell(buf []byte, cell Cell) []byte {
+	if cell == nil {
+		return binary.LittleEndian.AppendUint16(buf, nullStatsCell)
+	}
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(cell)))
+	return append(buf, cell...)
+}
+
+func readStatsCell(buf []byte) (Cell, int) {
+	size := binary.LittleEndian.Uint16(buf)
+	if size == nullStatsCell {
+		return nil, 2
+	}
+	return Cell(slices.Clone(buf[2 : 2+int(size)])), 2 + int(size)
+}
+
+// writes stats of every analyzed table to the catalog, replacing the old file atomically
+func (b *Backend) writeStatsToDisk() error {
+	buf := make([]byte, 0, PAGESIZE)
+	for i := range b.tables {
+		if b.tables[i].stats != nil {
+			buf = append(buf, b.tables[i].stats.toBytes(b.tables[i].Name)...)
+		}
+	}
+	newfile := filepath.Join(b.dir, STATSFILE+".tmp")
+	if err := os.WriteFile(newfile, buf, 0644); err != nil {
+		return err
+	}
+	return os.Rename(newfile, filepath.Join(b.dir, STATSFILE))
+}
+
+// loads the catalog into the tables it belongs to, a missing catalog means nothing was analyzed yet
+func (b *Backend) readStatsFromDisk() error {
+	buf, err := os.ReadFile(filepath.Join(b.dir, STATSFILE))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for offset := 0; offset < len(buf); {
+		stats := &tableStats{}
+		name, n := stats.fromBytes(buf[offset:])
+		offset += n
+		for i := range b.tables {
+			if b.tables[i].Name == name && len(b.tables[i].Columns) == len(stats.columns) {
+				b.tables[i].stats = stats
+			}
+		}
+	}
+	return nil
+}
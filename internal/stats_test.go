@@ -0,0 +1,164 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyze(t *testing.T) {
+	dir := t.TempDir()
+	b := CreateNewDatabase(dir)
+	statements := []string{"CREATE TABLE t (id int primary key, n int);"}
+	for i := 1; i <= 1000; i += 10 {
+		values := make([]string, 0, 9)
+		for j := i; j < i+9; j++ {
+			values = append(values, fmt.Sprintf("(%d, %d)", j, j%50))
+		}
+		statements = append(statements,
+			fmt.Sprintf("INSERT INTO t (id, n) VALUES %s;", strings.Join(values, ", ")),
+			fmt.Sprintf("INSERT INTO t (id) VALUES (%d);", i+9), // every tenth n is null
+		)
+	}
+	for _, sql := range append(statements, "ANALYZE t;") {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+
+	stats := b.tables[0].stats
+	require.NotNil(t, stats)
+	require.Equal(t, int64(1000), stats.rows)
+	require.Equal(t, int64(b.tables[0].lastPage+1), stats.pages)
+	require.Equal(t, int64(1000), stats.columns[0].distinct)
+	require.Equal(t, int64(1), stats.columns[0].min.AsInt())
+	require.Equal(t, int64(1000), stats.columns[0].max.AsInt())
+	require.Equal(t, int64(100), stats.columns[1].nulls)
+	require.Equal(t, int64(45), stats.columns[1].distinct) // multiples of 10 are null
+	require.Equal(t, int64(1), stats.columns[1].min.AsInt())
+	require.Equal(t, int64(49), stats.columns[1].max.AsInt())
+
+	b.Close()
+	reopened, err := OpenExistingDatabase(dir)
+	require.NoError(t, err)
+	defer reopened.Close()
+	require.Equal(t, stats, reopened.tables[0].stats, "stats are loaded from the catalog")
+	require.Len(t, queryRows(t, reopened, "SELECT id FROM t WHERE id > 990;"), 10)
+
+	_, err = execSQL(reopened, "ANALYZE missing;")
+	require.EqualError(t, err, "Table does not exist")
+}
+
+func TestCatalogFileNames(t *testing.T) {
+	dir := t.TempDir()
+	b := CreateNewDatabase(dir)
+	for _, sql := range []string{
+		"CREATE TABLE stats (id int primary key, n int);",
+		`CREATE TABLE "main.stats" (id int primary key);`,
+		`CREATE TABLE "main.db" (id int primary key);`,
+		"INSERT INTO stats (n) VALUES (1), (2);",
+		"ANALYZE stats;",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	for sql, expected := range map[string]string{
+		"CREATE TABLE main (id int primary key);":                      "table name main is reserved",
+		"CREATE TABLE main AS SELECT id FROM stats;":                   "table name main is reserved",
+		"ALTER TABLE stats RENAME TO main;":                            "table name main is reserved",
+		"CREATE TABLE IF NOT EXISTS main (id int primary key, n int);": "table name main is reserved",
+	} {
+		_, err := execSQL(b, sql)
+		require.EqualError(t, err, expected, sql)
+	}
+	b.Close()
+
+	b, err := OpenExistingDatabase(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	require.Equal(t, [][]any{{int64(1), int64(1)}, {int64(2), int64(2)}}, queryRows(t, b, "SELECT * FROM stats;"), "ANALYZE leaves the table stats alone")
+	require.Equal(t, int64(2), b.tables[0].stats.rows)
+}
+
+func TestCostBasedPlan(t *testing.T) {
+	values := make([]string, 0, 2000)
+	for i := 1; i <= 2000; i++ {
+		values = append(values, fmt.Sprintf("(%d, %d)", i, i%4))
+	}
+	b := newTestBackend(t,
+		"CREATE TABLE t (id int primary key, n int);",
+		fmt.Sprintf("INSERT INTO t (id, n) VALUES %s;", strings.Join(values, ", ")),
+	)
+
+	// without stats a range open at one end may hold the whole table, one bounded at both ends a third of it
+	require.Equal(t, [][]any{
+		{int64(1), int64(0), "Project", `"id"`},
+		{int64(2), int64(1), "Filter", `"id" > 0`},
+		{int64(3), int64(2), "Seq Scan", `on "t"`},
+	}, planShape(queryRows(t, b, "EXPLAIN SELECT id FROM t WHERE id > 0;")))
+	require.Equal(t, "Index Scan", planShape(queryRows(t, b, "EXPLAIN SELECT id FROM t WHERE id > 0 AND id < 100;"))[1][2])
+
+	_, err := execSQL(b, "ANALYZE;")
+	require.NoError(t, err)
+
+	ts := []struct {
+		Name     string
+		SQL      string
+		Expected [][]any
+	}{
+		{
+			Name: "range covering the whole table is a sequential scan",
+			SQL:  "EXPLAIN SELECT id FROM t WHERE id > 0;",
+			Expected: [][]any{
+				{int64(1), int64(0), "Project", `"id"`},
+				{int64(2), int64(1), "Filter", `"id" > 0`},
+				{int64(3), int64(2), "Seq Scan", `on "t"`},
+			},
+		},
+		{
+			Name: "bounds on the key are combined into one range",
+			SQL:  "EXPLAIN SELECT id FROM t WHERE id > 10 AND n = 1 AND 20 >= id AND id < 30;",
+			Expected: [][]any{
+				{int64(1), int64(0), "Project", `"id"`},
+				{int64(2), int64(1), "Filter", `"n" = 1`},
+				{int64(3), int64(2), "Index Scan", `on "t" using primary key ("id" > 10 AND "id" <= 20)`},
+			},
+		},
+		{
+			Name: "equality",
+			SQL:  "EXPLAIN SELECT id FROM t WHERE id = 1500;",
+			Expected: [][]any{
+				{int64(1), int64(0), "Project", `"id"`},
+				{int64(2), int64(1), "Index Scan", `on "t" using primary key ("id" = 1500)`},
+			},
+		},
+	}
+	for _, tc := range ts {
+		t.Run(tc.Name, func(t *testing.T) {
+			require.Equal(t, tc.Expected, planShape(queryRows(t, b, tc.SQL)))
+		})
+	}
+
+	plan := queryRows(t, b, "EXPLAIN SELECT id FROM t WHERE id >= 101 AND id <= 200 AND n = 1;")
+	require.Equal(t, int64(100), plan[2][4], "estimated rows of the index scan")
+	require.Equal(t, int64(25), plan[1][4], "a quarter of the rows have n = 1")
+
+	rows := queryRows(t, b, "SELECT id FROM t WHERE id > 10 AND 20 >= id AND n = 1;")
+	require.Equal(t, [][]any{{int64(13)}, {int64(17)}}, rows)
+	require.Empty(t, queryRows(t, b, "SELECT id FROM t WHERE id > 20 AND id < 10;"))
+}
+
+func TestDistinctSketch(t *testing.T) {
+	var d distinctSketch
+	for i := int64(0); i < 200_000; i++ {
+		d.add(newCell(i % 50_000))
+	}
+	require.InEpsilon(t, 50_000, d.estimate(), 0.1)
+
+	d = distinctSketch{}
+	for i := int64(0); i < 500; i++ {
+		d.add(newCell(i % 100))
+	}
+	require.Equal(t, int64(100), d.estimate(), "exact below the sketch size")
+}
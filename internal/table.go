@@ -2,7 +2,9 @@ package internal
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
 )
@@ -16,10 +18,16 @@ type Table struct {
 	Columns       []Column
 	Name          string //max size is maxuint8
 	lastRowId     int64
+	primaryKey    []int      //positions of the primary key columns in key order
+	indexes       []indexDef //secondary indexes, trees are kept in indices
+	checks        []checkDef //CHECK constraints evaluated against every written row
+	foreignKeys   []foreignKey
+	version       uint32        //bumped when the table is rewritten into new files
 	rowEmptyBytes uint64        //dynamic at runtime
 	lastPage      uint64        //dynamic at runtime
 	indices       *indexManager //dynamic at runtime created or loaded
 	tableLock     *sync.RWMutex //dynamic at runtime created
+	stats         *tableStats   //dynamic at runtime loaded from catalog, nil until analyzed
 }
 
 func (t *Table) toBytes() []byte {
@@ -33,10 +41,26 @@ func (t *Table) toBytes() []byte {
 	for i := 0; i < len(t.Columns); i++ {
 		buf = append(buf, t.Columns[i].toBytes()...)
 	}
-	return buf
+	buf = append(buf, uint8(len(t.primaryKey)))
+	for _, pos := range t.primaryKey {
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(pos))
+	}
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(t.indexes)))
+	for i := range t.indexes {
+		buf = append(buf, t.indexes[i].toBytes()...)
+	}
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(t.checks)))
+	for i := range t.checks {
+		buf = append(buf, t.checks[i].toBytes()...)
+	}
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(t.foreignKeys)))
+	for i := range t.foreignKeys {
+		buf = append(buf, t.foreignKeys[i].toBytes()...)
+	}
+	return binary.LittleEndian.AppendUint32(buf, t.version)
 }
 
-func (t *Table) fromBytes(buf []byte) int {
+func (t *Table) fromBytes(buf []byte) (int, error) {
 	tableNameSize := int(buf[0])
 	t.Name = string(buf[1 : 1+tableNameSize])
 	byteIndex := int(1 + tableNameSize)
@@ -52,13 +76,54 @@ func (t *Table) fromBytes(buf []byte) int {
 		byteIndex += offset
 		t.Columns[i] = newCol
 	}
-	return byteIndex
+	numKeys := int(buf[byteIndex])
+	byteIndex++
+	for i := 0; i < numKeys; i++ {
+		t.primaryKey = append(t.primaryKey, int(binary.LittleEndian.Uint16(buf[byteIndex:])))
+		byteIndex += 2
+	}
+	numIndexes := binary.LittleEndian.Uint16(buf[byteIndex:])
+	byteIndex += 2
+	for i := 0; i < int(numIndexes); i++ {
+		def := indexDef{}
+		byteIndex += def.fromBytes(buf[byteIndex:])
+		t.indexes = append(t.indexes, def)
+	}
+	numChecks := binary.LittleEndian.Uint16(buf[byteIndex:])
+	byteIndex += 2
+	for i := 0; i < int(numChecks); i++ {
+		check := checkDef{}
+		n, err := check.fromBytes(buf[byteIndex:])
+		if err != nil {
+			return 0, err
+		}
+		byteIndex += n
+		t.checks = append(t.checks, check)
+	}
+	numForeignKeys := binary.LittleEndian.Uint16(buf[byteIndex:])
+	byteIndex += 2
+	for i := 0; i < int(numForeignKeys); i++ {
+		fk := foreignKey{}
+		n, err := fk.fromBytes(buf[byteIndex:])
+		if err != nil {
+			return 0, err
+		}
+		byteIndex += n
+		t.foreignKeys = append(t.foreignKeys, fk)
+	}
+	t.version = binary.LittleEndian.Uint32(buf[byteIndex:])
+	return byteIndex + 4, nil
 }
 
+// bytes taken by the columns of a row with no null, VARCHAR, TEXT and BLOB values count the pointer to their overflow pages
 func (t *Table) GenerateRowBytes() uint64 {
 	if t.rowEmptyBytes == 0 {
 		bytelength := 0
 		for _, val := range t.Columns {
+			if val.isVariable() {
+				bytelength += overflowPointerSize
+				continue
+			}
 			bytelength += int(val.columnSize)
 		}
 		t.rowEmptyBytes = uint64(bytelength)
@@ -66,15 +131,135 @@ func (t *Table) GenerateRowBytes() uint64 {
 	return t.rowEmptyBytes
 }
 
+// a row with no null must fit on a page once its VARCHAR, TEXT and BLOB values are moved to overflow pages
+func (t *Table) checkRowSize() error {
+	if int(t.GenerateRowBytes())+rowHeaderSize(len(t.Columns)) > maxRowSize {
+		return errors.New("row size for this table exceeds max row size")
+	}
+	return nil
+}
+
+// estimated number of rows fitting on a page after the page header
+func (t *Table) rowsPerPage() int {
+	rowSize := rowHeaderSize(len(t.Columns)) + slotSize
+	for i := range t.Columns {
+		rowSize += t.Columns[i].width()
+	}
+	return (PAGESIZE - pageHeaderSize) / rowSize
+}
+
 func (t *Table) GenerateFields() {
-	offset := 0
 	for i := range t.Columns {
 		t.Columns[i].columnIndex = i
-		t.Columns[i].columnOffset = offset
-		offset += int(t.Columns[i].columnSize)
 	}
 }
 
+// checks that every default fits its column, generation expressions only use columns that aren't generated
+// and check constraints only use columns of the table
+func (t *Table) checkColumnValues() error {
+	scope := newRowScope(t, nil)
+	for i := range t.Columns {
+		col := &t.Columns[i]
+		if col.columnDefault != nil {
+			value, err := evalConstant(col.columnDefault)
+			if err == nil {
+				_, err = valueCell(col, value)
+			}
+			if err != nil {
+				return fmt.Errorf("invalid default for column %s: %w", col.columnName, err)
+			}
+		}
+		if col.columnGenerated == nil {
+			continue
+		}
+		if err := scope.bind(col.columnGenerated); err != nil {
+			return err
+		}
+		var generated *Identifier
+		walkExpr(col.columnGenerated, func(e Expr) bool {
+			if ident, ok := e.(*Identifier); ok && generated == nil {
+				if pos, _ := scope.lookup(ident); t.Columns[pos].columnGenerated != nil {
+					generated = ident
+				}
+			}
+			return generated == nil
+		})
+		if generated != nil {
+			return fmt.Errorf("generation expression of column %s cannot use generated column %s", col.columnName, generated.Name)
+		}
+	}
+	for _, check := range t.checks {
+		if err := scope.bind(check.expr); err != nil {
+			return fmt.Errorf("invalid check constraint %s: %w", check.name, err)
+		}
+	}
+	return nil
+}
+
+// sets the columns of row that aren't in given to their defaults
+func (t *Table) applyDefaults(row []Cell, given []int) error {
+	for i := range t.Columns {
+		col := &t.Columns[i]
+		if col.columnDefault == nil || slices.Contains(given, i) {
+			continue
+		}
+		value, err := evalConstant(col.columnDefault)
+		if err != nil {
+			return err
+		}
+		if row[i], err = valueCell(col, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recomputes every generated column of row from the other columns
+func (t *Table) computeGenerated(row []Cell) error {
+	scope := newRowScope(t, nil)
+	for i := range t.Columns {
+		col := &t.Columns[i]
+		if col.columnGenerated == nil {
+			continue
+		}
+		value, err := scope.eval(col.columnGenerated, row)
+		if err != nil {
+			return err
+		}
+		if row[i], err = valueCell(col, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// positions of the named columns, every name must be a distinct column of the table
+func (t *Table) columnPositions(names []string) ([]int, error) {
+	positions := make([]int, len(names))
+	missing := make([]string, 0)
+	for i, name := range names {
+		positions[i] = slices.IndexFunc(t.Columns, func(col Column) bool { return col.columnName == name })
+		if positions[i] == -1 {
+			missing = append(missing, name)
+		} else if slices.Contains(positions[:i], positions[i]) {
+			return nil, fmt.Errorf("column %s appears more than once", name)
+		}
+	}
+	if len(missing) != 0 {
+		return nil, fmt.Errorf("Columns not in table: %s", strings.Join(missing, "|"))
+	}
+	return positions, nil
+}
+
+// names of the columns at positions separated by commas
+func (t *Table) columnNames(positions []int) string {
+	names := make([]string, len(positions))
+	for i, pos := range positions {
+		names[i] = t.Columns[pos].columnName
+	}
+	return strings.Join(names, ", ")
+}
+
 func (t *Table) String() string {
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("Name: %s\n", t.Name))
@@ -14,8 +14,9 @@ func TestTable(t *testing.T) {
 		// the table itself
 		{"Table equality test 1",
 			Table{
-				Name:      "MyTable2024",
-				lastRowId: 50,
+				Name:       "MyTable2024",
+				lastRowId:  50,
+				primaryKey: []int{1},
 				Columns: []Column{
 					{columnName: "SomeName", columnType: BOOL, columnSize: 8, columnIsUnique: false, columnIsNullable: true, columnIsPrimary: false},
 					{columnName: "_column1", columnType: FLOAT, columnSize: 24, columnIsUnique: false, columnIsNullable: false, columnIsPrimary: true},
@@ -24,24 +25,38 @@ func TestTable(t *testing.T) {
 			}},
 		{"Table equality test 2",
 			Table{
-				Name:      "someTable@54",
-				lastRowId: 655367,
+				Name:       "someTable@54",
+				lastRowId:  655367,
+				primaryKey: []int{2},
 				Columns: []Column{
 					{columnName: "Testing", columnType: CHAR, columnSize: 8, columnIsUnique: true, columnIsNullable: true, columnIsPrimary: false},
 					{columnName: "_mycol", columnType: FLOAT, columnSize: 56, columnIsUnique: false, columnIsNullable: true, columnIsPrimary: false},
 					{columnName: "randColumn123", columnType: CHAR, columnSize: 255, columnIsUnique: true, columnIsNullable: false, columnIsPrimary: true},
 				},
+				indexes: []indexDef{
+					{name: "someTable_testing", unique: true, constraint: true, columns: []int{0}},
+					{name: "ix2", unique: false, columns: []int{1}},
+				},
+				foreignKeys: []foreignKey{
+					{name: "someTable@54_Testing_fkey", columns: []int{0}, parent: "other", onDelete: Cascade, onUpdate: SetNull},
+					{name: "someTable@54_randColumn123_mycol_fkey", columns: []int{2, 1}, parent: "someTable@54"},
+				},
 			}},
 		{"Table equality test 3",
 			Table{
-				Name:      "Table3",
-				lastRowId: 1,
+				Name:       "Table3",
+				lastRowId:  1,
+				primaryKey: []int{0, 2},
 				Columns: []Column{
 					{columnName: "someCol@", columnType: INT, columnSize: 1, columnIsUnique: true, columnIsNullable: true, columnIsPrimary: true},
 					{columnName: "FUNCOLUMN", columnType: FLOAT, columnSize: 3, columnIsUnique: true, columnIsNullable: false, columnIsPrimary: false},
 					{columnName: "New_col", columnType: INT, columnSize: 26, columnIsUnique: true, columnIsNullable: false, columnIsPrimary: false},
 					{columnName: "lastcol", columnType: BOOL, columnSize: 18, columnIsUnique: false, columnIsNullable: false, columnIsPrimary: false},
 				},
+				checks: []checkDef{
+					{name: "Table3_FUNCOLUMN_check", expr: &BinaryExpr{Operator: Gte, Left: &Identifier{Name: "FUNCOLUMN"}, Right: &Literal{Kind: NumberLiteral, Value: "0"}}},
+					{name: "Table3_check", expr: &InExpr{Expr: &Identifier{Name: "lastcol"}, List: []Expr{&Literal{Kind: BoolLiteral, Value: "TRUE"}}, Not: true}},
+				},
 			}},
 	}
 	// The execution loop
@@ -51,7 +66,8 @@ func TestTable(t *testing.T) {
 			tt.input.GenerateFields()
 
 			newtable := Table{}
-			readLen := newtable.fromBytes(buf)
+			readLen, err := newtable.fromBytes(buf)
+			require.NoError(t, err)
 			if readLen != len(buf) {
 				t.Error("len of bytes from column not equal to read length from 'frombytes'")
 			}
@@ -60,3 +76,33 @@ func TestTable(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateTableIfNotExistsAndAs(t *testing.T) {
+	b := newTestBackend(t,
+		"CREATE TABLE t (id int primary key, name char(5) not null unique, f float);",
+		"INSERT INTO t (id, name, f) VALUES (1, 'a', 1.5), (2, 'b', 2.5), (3, 'c', 3.5);",
+		"CREATE TABLE IF NOT EXISTS t (x int primary key);",
+		"CREATE TABLE c AS SELECT name AS label, id FROM t WHERE id >= 2;",
+		"CREATE TABLE IF NOT EXISTS c AS SELECT id FROM t;",
+		"CREATE TABLE empty AS SELECT * FROM t WHERE id > 5;",
+	)
+	_, err := execSQL(b, "CREATE TABLE t (x int primary key);")
+	require.EqualError(t, err, "Table already exist")
+	require.Equal(t, [][]any{{"b", int64(2)}, {"c", int64(3)}}, trimRows(queryRows(t, b, "SELECT label, id FROM c WHERE id > 0;")))
+
+	_, err = execSQL(b, "INSERT INTO c (label) VALUES ('d');")
+	require.NoError(t, err, "keys continue after the loaded rows")
+	_, err = execSQL(b, "INSERT INTO c (label) VALUES ('d');")
+	require.NoError(t, err, "constraints other than the primary key are not copied")
+	require.Equal(t, [][]any{{int64(4)}, {int64(5)}}, queryRows(t, b, "SELECT id FROM c WHERE label = 'd';"))
+	_, err = execSQL(b, "INSERT INTO c (id, label) VALUES (3, 'e');")
+	require.EqualError(t, err, "insert: duplicate key value in column id of table c violates primary key")
+	require.Empty(t, queryRows(t, b, "SELECT * FROM empty WHERE id > 0;"))
+
+	_, err = execSQL(b, "CREATE TABLE n AS SELECT name FROM t;")
+	require.EqualError(t, err, "primary key column id of table t must be selected")
+	_, err = execSQL(b, "CREATE TABLE n AS SELECT id, name AS id FROM t;")
+	require.EqualError(t, err, "column id appears more than once")
+	_, err = execSQL(b, "CREATE TABLE n AS SELECT id FROM missing;")
+	require.EqualError(t, err, "Table does not exist")
+}
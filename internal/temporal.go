@@ -0,0 +1,394 @@
+package internal
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+DATE, TIME and TIMESTAMP values are evaluated as time.Time in UTC, a TIME is a time of the day of January 1st of year 0.
+DATE is stored as the days since 1970-01-01 in 4 bytes, TIME as the microseconds since midnight and
+TIMESTAMP as the microseconds since 1970-01-01 in 8 bytes.
+INTERVAL keeps months, days and microseconds apart since months and days have no fixed length,
+it is stored in 16 bytes and ordered as if months had 30 days.
+*/
+
+const microsPerDay = 24 * int64(time.Hour/time.Microsecond)
+
+type interval struct {
+	months int32
+	days   int32
+	micros int64
+}
+
+// length of the interval in microseconds counting a month as 30 days
+func (iv interval) approxMicros() int64 {
+	return (int64(iv.months)*30+int64(iv.days))*microsPerDay + iv.micros
+}
+
+func (iv interval) negate() interval {
+	return interval{months: -iv.months, days: -iv.days, micros: -iv.micros}
+}
+
+func (iv interval) add(other interval) interval {
+	return interval{months: iv.months + other.months, days: iv.days + other.days, micros: iv.micros + other.micros}
+}
+
+// adds the interval to t, months first then days then time. A day past the end of the month ends up on its last day
+func (iv interval) addTo(t time.Time) time.Time {
+	year, month, day := t.Date()
+	firstOfMonth := time.Date(year, month+time.Month(iv.months), 1, 0, 0, 0, 0, t.Location())
+	if last := firstOfMonth.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	t = time.Date(firstOfMonth.Year(), firstOfMonth.Month(), day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	return t.AddDate(0, 0, int(iv.days)).Add(time.Duration(iv.micros) * time.Microsecond)
+}
+
+// formats the interval as 1 year 2 mons 3 days 04:05:06, the way it is parsed back
+func (iv interval) String() string {
+	parts := make([]string, 0, 4)
+	unit := func(n int64, name string) {
+		if n == 0 {
+			return
+		}
+		if n != 1 && n != -1 {
+			name += "s"
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", n, name))
+	}
+	unit(int64(iv.months/12), "year")
+	unit(int64(iv.months%12), "mon")
+	unit(int64(iv.days), "day")
+	if iv.micros != 0 || len(parts) == 0 {
+		micros, sign := iv.micros, ""
+		if micros < 0 {
+			micros, sign = -micros, "-"
+		}
+		d := time.Duration(micros) * time.Microsecond
+		clock := fmt.Sprintf("%s%02d:%02d:%02d", sign, int64(d/time.Hour), int64(d/time.Minute)%60, int64(d/time.Second)%60)
+		if frac := micros % 1e6; frac != 0 {
+			clock += strings.TrimRight(fmt.Sprintf(".%06d", frac), "0")
+		}
+		parts = append(parts, clock)
+	}
+	return strings.Join(parts, " ")
+}
+
+var temporalLayouts = []string{
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+var clockLayouts = []string{"15:04:05Z07:00", "15:04:05", "15:04"}
+
+// parses an ISO-8601 date, date and time or time of the day, times without a zone are UTC
+func parseTemporal(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range temporalLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	for _, layout := range clockLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			t = t.UTC()
+			return time.Date(0, 1, 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot read '%s' as a date or time", s)
+}
+
+var intervalUnits = map[string]interval{
+	"year":        {months: 12},
+	"month":       {months: 1},
+	"mon":         {months: 1},
+	"week":        {days: 7},
+	"day":         {days: 1},
+	"hour":        {micros: int64(time.Hour / time.Microsecond)},
+	"minute":      {micros: int64(time.Minute / time.Microsecond)},
+	"min":         {micros: int64(time.Minute / time.Microsecond)},
+	"second":      {micros: int64(time.Second / time.Microsecond)},
+	"sec":         {micros: int64(time.Second / time.Microsecond)},
+	"millisecond": {micros: 1000},
+	"microsecond": {micros: 1},
+}
+
+// parses an interval written as quantities and units (1 year 2 days), a clock (-04:05:06) or ISO-8601 (P1Y2DT3H)
+func parseInterval(s string) (interval, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "P") {
+		return parseISOInterval(s)
+	}
+	var iv interval
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) == 0 {
+		return iv, fmt.Errorf("cannot read '%s' as an interval", s)
+	}
+	for i := 0; i < len(fields); i++ {
+		if strings.Contains(fields[i], ":") {
+			micros, err := parseClock(fields[i])
+			if err != nil {
+				return iv, fmt.Errorf("cannot read '%s' as an interval", s)
+			}
+			iv.micros += micros
+			continue
+		}
+		n, err := strconv.ParseInt(fields[i], 10, 32)
+		if err != nil || i+1 == len(fields) {
+			return iv, fmt.Errorf("cannot read '%s' as an interval", s)
+		}
+		i++
+		unit, ok := intervalUnits[strings.TrimSuffix(fields[i], "s")]
+		if !ok {
+			return iv, fmt.Errorf("unknown interval unit %s", fields[i])
+		}
+		iv = iv.add(interval{months: unit.months * int32(n), days: unit.days * int32(n), micros: unit.micros * n})
+	}
+	return iv, nil
+}
+
+// microseconds of a [-]hh:mm[:ss[.ffffff]] clock
+func parseClock(s string) (int64, error) {
+	sign := int64(1)
+	if strings.HasPrefix(s, "-") {
+		sign, s = -1, s[1:]
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, errors.New("invalid clock")
+	}
+	hours, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	minutes, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	seconds := 0.0
+	if len(parts) == 3 {
+		if seconds, err = strconv.ParseFloat(parts[2], 64); err != nil {
+			return 0, err
+		}
+	}
+	return sign * ((hours*60+minutes)*60*1e6 + int64(seconds*1e6+0.5)), nil
+}
+
+// parses P[nY][nM][nW][nD][T[nH][nM][nS]]
+func parseISOInterval(s string) (interval, error) {
+	var iv interval
+	inTime := false
+	number := ""
+	for _, r := range s[1:] {
+		switch {
+		case r == 'T':
+			inTime = true
+			continue
+		case r >= '0' && r <= '9' || r == '.' || r == '-':
+			number += string(r)
+			continue
+		}
+		n, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			return iv, fmt.Errorf("cannot read '%s' as an interval", s)
+		}
+		number = ""
+		switch {
+		case r == 'Y' && !inTime:
+			iv.months += int32(n * 12)
+		case r == 'M' && !inTime:
+			iv.months += int32(n)
+		case r == 'W' && !inTime:
+			iv.days += int32(n * 7)
+		case r == 'D' && !inTime:
+			iv.days += int32(n)
+		case r == 'H' && inTime:
+			iv.micros += int64(n * float64(time.Hour/time.Microsecond))
+		case r == 'M' && inTime:
+			iv.micros += int64(n * float64(time.Minute/time.Microsecond))
+		case r == 'S' && inTime:
+			iv.micros += int64(n * 1e6)
+		default:
+			return iv, fmt.Errorf("cannot read '%s' as an interval", s)
+		}
+	}
+	if number != "" || len(s) == 1 {
+		return iv, fmt.Errorf("cannot read '%s' as an interval", s)
+	}
+	return iv, nil
+}
+
+// encodes t the way a column of columnType stores it
+func temporalCell(columnType uint8, t time.Time) Cell {
+	t = t.UTC()
+	switch columnType {
+	case DATE:
+		days := t.Unix() / 86400
+		if t.Unix() < 0 && t.Unix()%86400 != 0 {
+			days-- //days before 1970 are counted down from the day they start
+		}
+		return binary.LittleEndian.AppendUint32(nil, uint32(int32(days)))
+	case TIME:
+		clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+			time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+		return binary.LittleEndian.AppendUint64(nil, uint64(clock/time.Microsecond))
+	}
+	return binary.LittleEndian.AppendUint64(nil, uint64(t.UnixMicro()))
+}
+
+// decodes a cell of a DATE, TIME or TIMESTAMP column
+func (c *Cell) AsTime(columnType uint8) time.Time {
+	switch columnType {
+	case DATE:
+		days := int32(binary.LittleEndian.Uint32(*c))
+		return time.Unix(int64(days)*86400, 0).UTC()
+	case TIME:
+		micros := int64(binary.LittleEndian.Uint64(*c))
+		return time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(micros) * time.Microsecond)
+	}
+	return time.UnixMicro(int64(binary.LittleEndian.Uint64(*c))).UTC()
+}
+
+func intervalCell(iv interval) Cell {
+	buf := binary.LittleEndian.AppendUint32(nil, uint32(iv.months))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(iv.days))
+	return binary.LittleEndian.AppendUint64(buf, uint64(iv.micros))
+}
+
+func (c *Cell) asInterval() interval {
+	return interval{
+		months: int32(binary.LittleEndian.Uint32((*c)[0:4])),
+		days:   int32(binary.LittleEndian.Uint32((*c)[4:8])),
+		micros: int64(binary.LittleEndian.Uint64((*c)[8:16])),
+	}
+}
+
+// +, - between dates or times and intervals or days, reports false when neither operand is a date, time or interval
+func temporalArithmetic(op Operator, left, right any) (any, bool, error) {
+	lt, ltime := left.(time.Time)
+	rt, rtime := right.(time.Time)
+	li, linterval := left.(interval)
+	ri, rinterval := right.(interval)
+	if !ltime && !rtime && !linterval && !rinterval {
+		return nil, false, nil
+	}
+	if n, ok := right.(int64); ok && ltime { //a number of days
+		ri, rinterval = interval{days: int32(n)}, true
+	} else if n, ok := left.(int64); ok && rtime && op == Plus {
+		li, linterval = interval{days: int32(n)}, true
+	}
+	switch {
+	case ltime && rinterval && op == Plus:
+		return ri.addTo(lt), true, nil
+	case ltime && rinterval && op == Minus:
+		return ri.negate().addTo(lt), true, nil
+	case linterval && rtime && op == Plus:
+		return li.addTo(rt), true, nil
+	case ltime && rtime && op == Minus:
+		diff := lt.UnixMicro() - rt.UnixMicro()
+		return interval{days: int32(diff / microsPerDay), micros: diff % microsPerDay}, true, nil
+	case linterval && rinterval && op == Plus:
+		return li.add(ri), true, nil
+	case linterval && rinterval && op == Minus:
+		return li.add(ri.negate()), true, nil
+	case linterval && op == Multiply:
+		if n, ok := right.(int64); ok {
+			return interval{months: li.months * int32(n), days: li.days * int32(n), micros: li.micros * n}, true, nil
+		}
+	}
+	return nil, true, fmt.Errorf("cannot apply %s to %v and %v", op, left, right)
+}
+
+func compareTemporal(left, right any) (int, bool) {
+	switch l := left.(type) {
+	case time.Time:
+		if r, ok := right.(time.Time); ok {
+			return l.Compare(r), true
+		}
+	case interval:
+		if r, ok := right.(interval); ok {
+			return cmp.Compare(l.approxMicros(), r.approxMicros()), true
+		}
+	}
+	return 0, false
+}
+
+// DATE_TRUNC(unit, t) sets every part of t smaller than unit to its start
+func dateTrunc(unit string, t time.Time) (time.Time, error) {
+	switch strings.ToLower(unit) {
+	case "microsecond", "microseconds":
+		return t.Truncate(time.Microsecond), nil
+	case "millisecond", "milliseconds":
+		return t.Truncate(time.Millisecond), nil
+	case "second":
+		return t.Truncate(time.Second), nil
+	case "minute":
+		return t.Truncate(time.Minute), nil
+	case "hour":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location()), nil
+	case "day":
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()), nil
+	case "week": //weeks start on monday
+		return time.Date(t.Year(), t.Month(), t.Day()-(int(t.Weekday())+6)%7, 0, 0, 0, 0, t.Location()), nil
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()), nil
+	case "quarter":
+		return time.Date(t.Year(), t.Month()-(t.Month()-1)%3, 1, 0, 0, 0, 0, t.Location()), nil
+	case "year":
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location()), nil
+	}
+	return time.Time{}, fmt.Errorf("unknown unit %s for DATE_TRUNC", unit)
+}
+
+// evaluates a call of a built in function
+func callFunction(name string, args []any) (any, error) {
+	switch name {
+	case "NOW":
+		if len(args) != 0 {
+			return nil, errors.New("NOW takes no arguments")
+		}
+		return time.Now().UTC().Truncate(time.Microsecond), nil
+	case "DATE_TRUNC":
+		if len(args) != 2 {
+			return nil, errors.New("DATE_TRUNC takes a unit and a date or time")
+		}
+		if args[0] == nil || args[1] == nil {
+			return nil, nil
+		}
+		unit, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("unit of DATE_TRUNC must be a string, got %v", args[0])
+		}
+		t, ok := args[1].(time.Time)
+		if s, isString := args[1].(string); isString {
+			var err error
+			if t, err = parseTemporal(s); err != nil {
+				return nil, err
+			}
+			ok = true
+		}
+		if !ok {
+			return nil, fmt.Errorf("DATE_TRUNC expects a date or time, got %v", args[1])
+		}
+		return dateTrunc(unit, t)
+	case "JSON_EXTRACT", "JSON_SET", "JSON_ARRAY_LENGTH":
+		return callJSONFunction(name, args)
+	case "GEN_RANDOM_UUID":
+		if len(args) != 0 {
+			return nil, errors.New("GEN_RANDOM_UUID takes no arguments")
+		}
+		return randomUUID()
+	}
+	return nil, fmt.Errorf("unknown function %s", name)
+}
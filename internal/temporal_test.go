@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTemporal(t *testing.T) {
+	ts := []struct {
+		Value    string
+		Expected time.Time
+	}{
+		{"2024-02-29", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"2024-02-29 13:45", time.Date(2024, 2, 29, 13, 45, 0, 0, time.UTC)},
+		{"2024-02-29T13:45:30.25", time.Date(2024, 2, 29, 13, 45, 30, 250000000, time.UTC)},
+		{"2024-02-29T13:45:30+02:00", time.Date(2024, 2, 29, 11, 45, 30, 0, time.UTC)},
+		{"13:45:30", time.Date(0, 1, 1, 13, 45, 30, 0, time.UTC)},
+	}
+	for _, tc := range ts {
+		got, err := parseTemporal(tc.Value)
+		require.NoError(t, err, tc.Value)
+		require.Equal(t, tc.Expected, got, tc.Value)
+	}
+	_, err := parseTemporal("yesterday")
+	require.EqualError(t, err, "cannot read 'yesterday' as a date or time")
+
+	for _, tc := range []time.Time{
+		time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+	} {
+		cell := temporalCell(DATE, tc.Add(5*time.Hour))
+		require.Equal(t, tc, cell.AsTime(DATE), "a date keeps the day it falls on")
+	}
+	cell := temporalCell(TIME, time.Date(2024, 2, 29, 13, 45, 30, 1000, time.UTC))
+	require.Equal(t, time.Date(0, 1, 1, 13, 45, 30, 1000, time.UTC), cell.AsTime(TIME))
+}
+
+func TestInterval(t *testing.T) {
+	ts := []struct {
+		Value    string
+		Expected interval
+		Format   string
+	}{
+		{"1 day", interval{days: 1}, "1 day"},
+		{"1 year 2 months 3 days 04:05:06.5", interval{months: 14, days: 3, micros: 14706500000}, "1 year 2 mons 3 days 04:05:06.5"},
+		{"2 weeks -3 hours", interval{days: 14, micros: -10800000000}, "14 days -03:00:00"},
+		{"90 minutes", interval{micros: 5400000000}, "01:30:00"},
+		{"P1Y2M3DT4H5M6S", interval{months: 14, days: 3, micros: 14706000000}, "1 year 2 mons 3 days 04:05:06"},
+		{"0 days", interval{}, "00:00:00"},
+	}
+	for _, tc := range ts {
+		got, err := parseInterval(tc.Value)
+		require.NoError(t, err, tc.Value)
+		require.Equal(t, tc.Expected, got, tc.Value)
+		require.Equal(t, tc.Format, got.String())
+		back, err := parseInterval(got.String())
+		require.NoError(t, err)
+		require.Equal(t, got, back, "formatted intervals are read back")
+	}
+	for _, value := range []string{"", "1", "1 fortnight", "P", "P1X"} {
+		_, err := parseInterval(value)
+		require.Error(t, err, value)
+	}
+
+	jan31 := time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC)
+	require.Equal(t, time.Date(2024, 2, 29, 10, 0, 0, 0, time.UTC), interval{months: 1}.addTo(jan31), "the day is kept within the month")
+	require.Equal(t, time.Date(2023, 2, 28, 10, 0, 0, 0, time.UTC), interval{months: -11}.addTo(jan31))
+	require.Equal(t, time.Date(2024, 3, 30, 10, 0, 0, 0, time.UTC), interval{months: 1, days: 30}.addTo(jan31), "months are added before days")
+	truncated, err := dateTrunc("week", time.Date(2024, 2, 29, 13, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, 2, 26, 0, 0, 0, 0, time.UTC), truncated)
+	truncated, err = dateTrunc("quarter", time.Date(2024, 8, 15, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC), truncated)
+	_, err = dateTrunc("fortnight", jan31)
+	require.EqualError(t, err, "unknown unit fortnight for DATE_TRUNC")
+}
+
+func TestTemporalTypes(t *testing.T) {
+	b := CreateNewDatabase(t.TempDir())
+	defer b.Close()
+	before := time.Now().UTC().Truncate(time.Microsecond)
+	for _, sql := range []string{
+		"CREATE TABLE events (id int primary key, day date, at timestamp, starts time, span interval, created timestamp default now());",
+		"CREATE INDEX events_at ON events (at);",
+		"INSERT INTO events (id, day, at, starts, span) VALUES (1, '2024-01-31', '2024-01-31T22:30:00+02:00', '09:15', '1 month 2 hours'), " +
+			"(2, DATE '2024-02-29', TIMESTAMP '2024-02-29 12:00:00.5', TIME '18:00:00', INTERVAL 'P1DT30M'), (3, NULL, NULL, NULL, NULL);",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	after := time.Now().UTC()
+
+	date := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+	require.Equal(t, [][]any{
+		{date(2024, 1, 31), time.Date(2024, 1, 31, 20, 30, 0, 0, time.UTC), time.Date(0, 1, 1, 9, 15, 0, 0, time.UTC), "1 mon 02:00:00"},
+		{date(2024, 2, 29), time.Date(2024, 2, 29, 12, 0, 0, 500000000, time.UTC), time.Date(0, 1, 1, 18, 0, 0, 0, time.UTC), "1 day 00:30:00"},
+		{nil, nil, nil, nil},
+	}, queryRows(t, b, "SELECT day, at, starts, span FROM events;"))
+	created := queryRows(t, b, "SELECT created FROM events WHERE id = 1;")[0][0].(time.Time)
+	require.True(t, !created.Before(before) && !created.After(after), "NOW() is the time of the insert")
+
+	ts := []struct {
+		SQL      string
+		Expected [][]any
+	}{
+		{"SELECT id FROM events WHERE day > '2024-02-01';", [][]any{{int64(2)}}},
+		{"SELECT id FROM events WHERE at >= TIMESTAMP '2024-01-31 20:30:00' AND at < '2024-02-01';", [][]any{{int64(1)}}},
+		{"SELECT id FROM events WHERE day + 29 = DATE '2024-02-29';", [][]any{{int64(1)}}},
+		{"SELECT id FROM events WHERE day + INTERVAL '1 month' = '2024-02-29';", [][]any{{int64(1)}}},
+		{"SELECT id FROM events WHERE at - day > INTERVAL '13 hours';", [][]any{{int64(1)}}},
+		{"SELECT id FROM events WHERE span > '1 day' AND starts < TIME '12:00';", [][]any{{int64(1)}}},
+		{"SELECT id FROM events WHERE DATE_TRUNC('month', at) = '2024-02-01' OR created - NOW() > INTERVAL '1 hour';", [][]any{{int64(2)}}},
+		{"SELECT day + INTERVAL '1 month', day + 1, at - day, starts + INTERVAL '1 hour' FROM events WHERE id <= 2;", [][]any{
+			{date(2024, 2, 29), date(2024, 2, 1), "20:30:00", time.Date(0, 1, 1, 10, 15, 0, 0, time.UTC)},
+			{date(2024, 3, 29), date(2024, 3, 1), "12:00:00.5", time.Date(0, 1, 1, 19, 0, 0, 0, time.UTC)},
+		}},
+		{"UPDATE events SET at = at + span * 2, day = DATE_TRUNC('year', day) WHERE id = 1 RETURNING day, at;",
+			[][]any{{date(2024, 1, 1), time.Date(2024, 4, 1, 0, 30, 0, 0, time.UTC)}}},
+		{"SELECT id FROM events WHERE at > '2024-03-01';", [][]any{{int64(1)}}},
+	}
+	for _, tc := range ts {
+		require.Equal(t, tc.Expected, queryRows(t, b, tc.SQL), tc.SQL)
+	}
+
+	for sql, expected := range map[string]string{
+		"INSERT INTO events (id, day) VALUES (4, 'soon');":                 "insert: cannot convert 'soon' for column day",
+		"INSERT INTO events (id, span) VALUES (4, '3 fortnights');":        "insert: cannot convert '3 fortnights' for column span",
+		"SELECT id FROM events WHERE DATE_TRUNC('fortnight', at) = at;":    "unknown unit fortnight for DATE_TRUNC",
+		"SELECT id FROM events WHERE day * 2 = day;":                       "cannot apply * to 2024-01-01 00:00:00 +0000 UTC and 2",
+		"CREATE TABLE bad (id int primary key, at timestamp default now);": "invalid default for column at: column \"now\" cannot be used in a constant expression",
+	} {
+		_, err := execSQL(b, sql)
+		require.EqualError(t, err, expected, sql)
+	}
+}
@@ -5,6 +5,8 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int // 1-based line the token starts on
+	Column  int // 1-based column (in bytes) the token starts on
 }
 
 // TODO:convert to enum
@@ -16,12 +18,31 @@ const (
 	IDENT         = "IDENT" // add, foobar, x, y, ...
 	STRINGLITERAL = "STRINGLITERAL"
 	NUMBERLITERAL = "NUMBERLITERAL"
+	HEXLITERAL    = "HEXLITERAL" // X'0aff'
+	PARAM         = "?"          // placeholder bound to an argument of the query
 	BOOLLITERAL   = "BOOLLITERAL"
 	// Data types
-	INT   = "INT" // 1343456
-	CHAR  = "CHAR"
-	BOOL  = "BOOL"
-	FLOAT = "FLOAT"
+	INT       = "INT" // 1343456
+	CHAR      = "CHAR"
+	VARCHAR   = "VARCHAR"
+	TEXT      = "TEXT"
+	BLOB      = "BLOB"
+	DATE      = "DATE"
+	TIME      = "TIME"
+	TIMESTAMP = "TIMESTAMP"
+	INTERVAL  = "INTERVAL"
+	DECIMAL   = "DECIMAL"
+	JSON      = "JSON"
+	UUID      = "UUID"
+	BYTEA     = "BYTEA"
+	TINYINT   = "TINYINT"
+	SMALLINT  = "SMALLINT"
+	INT32     = "INT32"
+	BIGINT    = "BIGINT"
+	UNSIGNED  = "UNSIGNED"
+	NUMERIC   = "NUMERIC"
+	BOOL      = "BOOL"
+	FLOAT     = "FLOAT"
 	// Operators
 	PLUS     = "+"
 	MINUS    = "-"
@@ -34,6 +55,8 @@ const (
 	GTE      = ">="
 	EQ       = "="
 	NOT_EQ   = "!="
+	ARROW    = "->"
+	ARROW2   = "->>"
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
@@ -41,64 +64,144 @@ const (
 	RPAREN    = ")"
 	PERIOD    = "."
 	// Keywords
-	SELECT = "SELECT"
-	INSERT = "INSERT"
-	INTO   = "INTO"
-	VALUES = "VALUES"
-	UPDATE = "UPDATE"
-	DELETE = "DELETE"
-	CREATE = "CREATE"
-	DROP   = "DROP"
-	TABLE  = "TABLE"
-	FROM   = "FROM"
-	WHERE  = "WHERE"
-	SET    = "SET"
-	AS     = "AS"
-	IS     = "IS"
-	TRUE   = "TRUE"
-	FALSE  = "FALSE"
-	AND    = "AND"
+	SELECT    = "SELECT"
+	INSERT    = "INSERT"
+	INTO      = "INTO"
+	VALUES    = "VALUES"
+	UPDATE    = "UPDATE"
+	DELETE    = "DELETE"
+	CREATE    = "CREATE"
+	DROP      = "DROP"
+	TABLE     = "TABLE"
+	FROM      = "FROM"
+	WHERE     = "WHERE"
+	SET       = "SET"
+	AS        = "AS"
+	IS        = "IS"
+	TRUE      = "TRUE"
+	FALSE     = "FALSE"
+	AND       = "AND"
+	OR        = "OR"
+	IN        = "IN"
+	EXPLAIN   = "EXPLAIN"
+	ANALYZE   = "ANALYZE"
+	INDEX     = "INDEX"
+	ON        = "ON"
+	ALTER     = "ALTER"
+	ADD       = "ADD"
+	RENAME    = "RENAME"
+	TO        = "TO"
+	IF        = "IF"
+	EXISTS    = "EXISTS"
+	RETURNING = "RETURNING"
 	// Constraints
 	PRIMARY = "PRIMARY"
 	KEY     = "KEY"
 	NOT     = "NOT"
 	NULL    = "NULL"
 	UNIQUE  = "UNIQUE"
+	CHECK   = "CHECK"
+	// Foreign keys
+	FOREIGN    = "FOREIGN"
+	REFERENCES = "REFERENCES"
+	CASCADE    = "CASCADE"
+	RESTRICT   = "RESTRICT"
+	// Column values
+	DEFAULT   = "DEFAULT"
+	GENERATED = "GENERATED"
+	ALWAYS    = "ALWAYS"
+	STORED    = "STORED"
 )
 
 var keywords = map[string]TokenType{
-	"SELECT":  SELECT,
-	"INSERT":  INSERT,
-	"INTO":    INTO,
-	"VALUES":  VALUES,
-	"UPDATE":  UPDATE,
-	"DELETE":  DELETE,
-	"FROM":    FROM,
-	"WHERE":   WHERE,
-	"SET":     SET,
-	"AS":      AS,
-	"CREATE":  CREATE,
-	"TABLE":   TABLE,
-	"DROP":    DROP,
-	"AND":     AND,
-	"PRIMARY": PRIMARY,
-	"KEY":     KEY,
-	"NOT":     NOT,
-	"NULL":    NULL,
-	"UNIQUE":  UNIQUE,
-	"INT":     INT,
-	"FLOAT":   FLOAT,
-	"TRUE":    BOOLLITERAL,
-	"FALSE":   BOOLLITERAL,
-	"CHAR":    CHAR,
-	"BOOL":    BOOL,
+	"SELECT":     SELECT,
+	"INSERT":     INSERT,
+	"INTO":       INTO,
+	"VALUES":     VALUES,
+	"UPDATE":     UPDATE,
+	"DELETE":     DELETE,
+	"FROM":       FROM,
+	"WHERE":      WHERE,
+	"SET":        SET,
+	"AS":         AS,
+	"CREATE":     CREATE,
+	"TABLE":      TABLE,
+	"DROP":       DROP,
+	"AND":        AND,
+	"OR":         OR,
+	"IN":         IN,
+	"IS":         IS,
+	"EXPLAIN":    EXPLAIN,
+	"ANALYZE":    ANALYZE,
+	"INDEX":      INDEX,
+	"ON":         ON,
+	"ALTER":      ALTER,
+	"ADD":        ADD,
+	"RENAME":     RENAME,
+	"TO":         TO,
+	"IF":         IF,
+	"EXISTS":     EXISTS,
+	"RETURNING":  RETURNING,
+	"PRIMARY":    PRIMARY,
+	"KEY":        KEY,
+	"NOT":        NOT,
+	"NULL":       NULL,
+	"UNIQUE":     UNIQUE,
+	"CHECK":      CHECK,
+	"FOREIGN":    FOREIGN,
+	"REFERENCES": REFERENCES,
+	"CASCADE":    CASCADE,
+	"RESTRICT":   RESTRICT,
+	"DEFAULT":    DEFAULT,
+	"GENERATED":  GENERATED,
+	"ALWAYS":     ALWAYS,
+	"STORED":     STORED,
+	"INT":        INT,
+	"FLOAT":      FLOAT,
+	"TRUE":       TRUE,
+	"FALSE":      FALSE,
+	"CHAR":       CHAR,
+	"VARCHAR":    VARCHAR,
+	"TEXT":       TEXT,
+	"BLOB":       BLOB,
+	"DATE":       DATE,
+	"TIME":       TIME,
+	"TIMESTAMP":  TIMESTAMP,
+	"INTERVAL":   INTERVAL,
+	"DECIMAL":    DECIMAL,
+	"JSON":       JSON,
+	"UUID":       UUID,
+	"BYTEA":      BYTEA,
+	"TINYINT":    TINYINT,
+	"SMALLINT":   SMALLINT,
+	"INT32":      INT32,
+	"BIGINT":     BIGINT,
+	"UNSIGNED":   UNSIGNED,
+	"NUMERIC":    NUMERIC,
+	"BOOL":       BOOL,
 }
 
 var dataTypes = map[TokenType]struct{}{
-	INT:   {},
-	FLOAT: {},
-	BOOL:  {},
-	CHAR:  {},
+	INT:       {},
+	FLOAT:     {},
+	BOOL:      {},
+	CHAR:      {},
+	VARCHAR:   {},
+	TEXT:      {},
+	BLOB:      {},
+	DATE:      {},
+	TIME:      {},
+	TIMESTAMP: {},
+	INTERVAL:  {},
+	DECIMAL:   {},
+	JSON:      {},
+	UUID:      {},
+	BYTEA:     {},
+	TINYINT:   {},
+	SMALLINT:  {},
+	INT32:     {},
+	BIGINT:    {},
+	NUMERIC:   {},
 }
 
 var constraintTypes = map[TokenType]struct{}{
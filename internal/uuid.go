@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+/*
+UUID values are 16 bytes stored as they are, so keys and comparisons order them the same
+way as their canonical text 8-4-4-4-12 of lower case hex digits.
+*/
+
+type uuid [16]byte
+
+// parses the canonical form, digits of either case and the form without hyphens
+func parseUUID(s string) (uuid, error) {
+	var u uuid
+	digits := s
+	if len(s) == 36 && s[8] == '-' && s[13] == '-' && s[18] == '-' && s[23] == '-' {
+		digits = s[:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	}
+	if len(digits) != 32 {
+		return u, fmt.Errorf("invalid UUID '%s'", s)
+	}
+	if _, err := hex.Decode(u[:], []byte(digits)); err != nil {
+		return u, fmt.Errorf("invalid UUID '%s'", s)
+	}
+	return u, nil
+}
+
+func (u uuid) String() string {
+	digits := hex.EncodeToString(u[:])
+	return strings.Join([]string{digits[:8], digits[8:12], digits[12:16], digits[16:20], digits[20:]}, "-")
+}
+
+// random version 4 UUID
+func randomUUID() (uuid, error) {
+	var u uuid
+	if _, err := rand.Read(u[:]); err != nil {
+		return u, err
+	}
+	u[6] = u[6]&0x0f | 0x40 //version 4
+	u[8] = u[8]&0x3f | 0x80 //variant 10
+	return u, nil
+}
+
+// converts v to a UUID, strings are read as text and strings of 16 bytes, such as blobs, as the bytes of the UUID
+func toUUID(v any) (uuid, bool) {
+	switch n := v.(type) {
+	case uuid:
+		return n, true
+	case string:
+		if u, err := parseUUID(n); err == nil {
+			return u, true
+		} else if len(n) == 16 {
+			return uuid([]byte(n)), true
+		}
+	}
+	return uuid{}, false
+}
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUUID(t *testing.T) {
+	for _, s := range []string{"a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", "A0EEBC99-9C0B-4EF8-BB6D-6BB9BD380A11", "a0eebc999c0b4ef8bb6d6bb9bd380a11"} {
+		u, err := parseUUID(s)
+		require.NoError(t, err, s)
+		require.Equal(t, "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", u.String())
+	}
+	for _, s := range []string{"", "a0eebc99-9c0b-4ef8-bb6d", "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a1g", "a0eebc99x9c0bx4ef8xbb6dx6bb9bd380a11"} {
+		_, err := parseUUID(s)
+		require.EqualError(t, err, "invalid UUID '"+s+"'")
+	}
+
+	u, ok := toUUID("\x00\x01\x02\x03\x04\x05\x06\x07\x08\x09\x0a\x0b\x0c\x0d\x0e\x0f")
+	require.True(t, ok, "16 bytes are the bytes of the UUID")
+	require.Equal(t, "00010203-0405-0607-0809-0a0b0c0d0e0f", u.String())
+	_, ok = toUUID("short")
+	require.False(t, ok)
+
+	first, err := randomUUID()
+	require.NoError(t, err)
+	second, err := randomUUID()
+	require.NoError(t, err)
+	require.NotEqual(t, first, second)
+	require.Equal(t, byte(0x40), first[6]&0xf0, "version 4")
+	require.Equal(t, byte(0x80), first[8]&0xc0, "variant 10")
+}
+
+func TestUUIDAndBytea(t *testing.T) {
+	dir := t.TempDir()
+	b := CreateNewDatabase(dir)
+	for _, sql := range []string{
+		"CREATE TABLE files (id uuid primary key default gen_random_uuid(), data bytea, parent uuid);",
+		"CREATE INDEX files_parent ON files (parent);",
+		"INSERT INTO files (id, data, parent) VALUES ('A0EEBC99-9C0B-4EF8-BB6D-6BB9BD380A11', X'00ff10', NULL), " +
+			"('b0eebc999c0b4ef8bb6d6bb9bd380a11', 'text', 'a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11');",
+		"INSERT INTO files (data, parent) VALUES (X'', X'a0eebc999c0b4ef8bb6d6bb9bd380a11');",
+	} {
+		_, err := execSQL(b, sql)
+		require.NoError(t, err, sql)
+	}
+	sizes := []uint16{}
+	for _, col := range b.tables[0].Columns {
+		sizes = append(sizes, col.columnSize)
+	}
+	require.Equal(t, []uint16{16, 0, 16}, sizes)
+
+	ts := []struct {
+		SQL      string
+		Expected [][]any
+	}{
+		{"SELECT id, data FROM files WHERE id = 'a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11';",
+			[][]any{{"a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", []byte{0, 0xff, 0x10}}}},
+		{"SELECT data FROM files WHERE id > 'a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11' AND id < 'c0000000-0000-0000-0000-000000000000' AND data != X'';",
+			[][]any{{[]byte("text")}}},
+		{"SELECT data FROM files WHERE data = X'00FF10' OR data = 'text';", [][]any{{[]byte{0, 0xff, 0x10}}, {[]byte("text")}}},
+		{"SELECT data FROM files WHERE parent = 'a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11';", [][]any{{[]byte("text")}, {[]byte{}}}},
+	}
+	for _, tc := range ts {
+		require.Equal(t, tc.Expected, queryRows(t, b, tc.SQL), tc.SQL)
+	}
+
+	generated := queryRows(t, b, "SELECT id FROM files WHERE data = X'';")
+	require.Len(t, generated, 1)
+	id := generated[0][0].(string)
+	require.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, id, "gen_random_uuid gives a version 4 UUID")
+	require.NotEqual(t, queryRows(t, b, "SELECT gen_random_uuid() FROM files WHERE id = '"+id+"';"), [][]any{{id}})
+
+	for sql, expected := range map[string]string{
+		"INSERT INTO files (id) VALUES ('a0eebc99-9c0b-4ef8-bb6d');": "insert: cannot convert 'a0eebc99-9c0b-4ef8-bb6d' for column id",
+		"INSERT INTO files (data) VALUES (X'0g');":                   "insert: invalid hex literal X'0g'",
+		"SELECT id FROM files WHERE parent = 'nope';":                "invalid UUID 'nope'",
+		"CREATE TABLE bad (id int primary key, b bytea(4));":         "blob field takes no size",
+	} {
+		_, err := execSQL(b, sql)
+		require.EqualError(t, err, expected, sql)
+	}
+	b.Close()
+
+	b, err := OpenExistingDatabase(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	require.Equal(t, [][]any{{[]byte{}}}, queryRows(t, b, "SELECT data FROM files WHERE id = '"+id+"';"))
+	require.Equal(t, [][]any{{"a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"}}, queryRows(t, b, "SELECT parent FROM files WHERE id = 'b0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11';"))
+}